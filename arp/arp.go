@@ -0,0 +1,224 @@
+// Package arp provides ARP ping functionality for the circle-pinger tool.
+// It sends who-has requests on the local link and measures the reply latency,
+// which works even when ICMP and every port of the target are firewalled.
+package arp
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+const (
+	etherTypeARP = 0x0806
+	etherHeader  = 14
+	arpLength    = 28
+	opRequest    = 1
+	opReply      = 2
+)
+
+// ErrNotLocal is returned when the target is not on a directly connected subnet.
+var ErrNotLocal = errors.New("target is not on a directly connected IPv4 subnet")
+
+// frameConn sends and receives raw ethernet frames on one interface.
+type frameConn interface {
+	ReadFrame(b []byte) (int, error)
+	WriteFrame(b []byte) error
+	SetDeadline(t time.Time) error
+	Close() error
+}
+
+// New creates a new ARP Ping instance. iface may be empty, in which case the
+// interface whose subnet contains the target is used.
+func New(host string, op *pinger.Option, iface string) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{
+		host:   host,
+		iface:  iface,
+		option: op,
+	}
+}
+
+// Ping is the ARP ping implementation.
+type Ping struct {
+	option *pinger.Option
+	host   string
+	iface  string
+}
+
+// Ping broadcasts a single ARP request and waits for the target's reply.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+	start := time.Now()
+
+	target, err := p.resolve(ctx, stats)
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	stats.Address = target.String()
+
+	ifi, source, err := route(target, p.iface)
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	stats.Meta["iface"] = pinger.StringerFunc(func() string { return ifi.Name })
+
+	conn, err := listen(ifi)
+	if err != nil {
+		stats.Error = fmt.Errorf("open packet socket failed: %w", err)
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	sendStart := time.Now()
+	if err := conn.WriteFrame(marshalRequest(ifi.HardwareAddr, source, target)); err != nil {
+		stats.Error = fmt.Errorf("write failed: %w", err)
+		stats.Duration = time.Since(start)
+		return stats
+	}
+
+	buf := make([]byte, 1514)
+	for {
+		n, err := conn.ReadFrame(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			stats.Error = fmt.Errorf("read failed: %w", err)
+			break
+		}
+		if mac, ok := parseReply(buf[:n], target); ok {
+			stats.Connected = true
+			stats.Meta["mac"] = mac
+			break
+		}
+	}
+	stats.Duration = time.Since(sendStart)
+	return stats
+}
+
+// resolve returns the IPv4 address of the target.
+func (p *Ping) resolve(ctx context.Context, stats *pinger.Stats) (net.IP, error) {
+	if ip := net.ParseIP(p.host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4, nil
+		}
+		return nil, fmt.Errorf("arp requires an IPv4 target, got %s", ip)
+	}
+	resolver := net.DefaultResolver
+	if p.option.Resolver != nil {
+		resolver = p.option.Resolver
+	}
+	dnsStart := time.Now()
+	ips, err := resolver.LookupIP(ctx, "ip4", p.host)
+	stats.DNSDuration = time.Since(dnsStart)
+	if err != nil {
+		return nil, fmt.Errorf("dns lookup failed: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("dns lookup returned no IPv4 addresses for %s", p.host)
+	}
+	return ips[0].To4(), nil
+}
+
+// route finds the interface and source address used to reach target on the
+// local link. When name is set only that interface is considered.
+func route(target net.IP, name string) (*net.Interface, net.IP, error) {
+	var ifaces []net.Interface
+	if name != "" {
+		ifi, err := net.InterfaceByName(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		ifaces = []net.Interface{*ifi}
+	} else {
+		all, err := net.Interfaces()
+		if err != nil {
+			return nil, nil, err
+		}
+		ifaces = all
+	}
+	for i := range ifaces {
+		ifi := &ifaces[i]
+		if ifi.Flags&net.FlagUp == 0 || ifi.Flags&net.FlagLoopback != 0 || len(ifi.HardwareAddr) != 6 {
+			continue
+		}
+		addrs, err := ifi.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			ipnet, ok := addr.(*net.IPNet)
+			if !ok || ipnet.IP.To4() == nil {
+				continue
+			}
+			if ipnet.Contains(target) {
+				return ifi, ipnet.IP.To4(), nil
+			}
+		}
+	}
+	return nil, nil, ErrNotLocal
+}
+
+// marshalRequest builds a broadcast ethernet frame carrying an ARP who-has.
+func marshalRequest(src net.HardwareAddr, spa, tpa net.IP) []byte {
+	b := make([]byte, etherHeader+arpLength)
+	copy(b[0:6], []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	copy(b[6:12], src)
+	binary.BigEndian.PutUint16(b[12:], etherTypeARP)
+
+	a := b[etherHeader:]
+	binary.BigEndian.PutUint16(a[0:], 1)      // hardware type: ethernet
+	binary.BigEndian.PutUint16(a[2:], 0x0800) // protocol type: IPv4
+	a[4], a[5] = 6, 4
+	binary.BigEndian.PutUint16(a[6:], opRequest)
+	copy(a[8:14], src)
+	copy(a[14:18], spa.To4())
+	copy(a[24:28], tpa.To4())
+	return b
+}
+
+// parseReply reports whether frame is an ARP reply from target and returns
+// the sender hardware address.
+func parseReply(frame []byte, target net.IP) (net.HardwareAddr, bool) {
+	if len(frame) < etherHeader+arpLength || binary.BigEndian.Uint16(frame[12:]) != etherTypeARP {
+		return nil, false
+	}
+	a := frame[etherHeader:]
+	if binary.BigEndian.Uint16(a[6:]) != opReply || !bytes.Equal(a[14:18], target.To4()) {
+		return nil, false
+	}
+	return net.HardwareAddr(append([]byte(nil), a[8:14]...)), true
+}
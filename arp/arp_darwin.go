@@ -0,0 +1,108 @@
+package arp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// bpfConn is a BPF device attached to a single interface.
+type bpfConn struct {
+	f       *os.File
+	buf     []byte
+	pending []byte
+}
+
+// listen opens the first free /dev/bpf device and attaches it to ifi.
+func listen(ifi *net.Interface) (frameConn, error) {
+	fd := -1
+	var err error
+	for i := 0; i < 256; i++ {
+		fd, err = syscall.Open(fmt.Sprintf("/dev/bpf%d", i), syscall.O_RDWR, 0)
+		if err == nil || !errors.Is(err, syscall.EBUSY) {
+			break
+		}
+	}
+	if err != nil {
+		return nil, os.NewSyscallError("open", err)
+	}
+
+	var ifreq [32]byte
+	copy(ifreq[:], ifi.Name)
+	if err := ioctl(uintptr(fd), syscall.BIOCSETIF, unsafe.Pointer(&ifreq[0])); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("BIOCSETIF", err)
+	}
+	one := uint32(1)
+	if err := ioctl(uintptr(fd), syscall.BIOCIMMEDIATE, unsafe.Pointer(&one)); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("BIOCIMMEDIATE", err)
+	}
+	// We write complete frames including our own source address.
+	if err := ioctl(uintptr(fd), syscall.BIOCSHDRCMPLT, unsafe.Pointer(&one)); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("BIOCSHDRCMPLT", err)
+	}
+	var blen uint32
+	if err := ioctl(uintptr(fd), syscall.BIOCGBLEN, unsafe.Pointer(&blen)); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("BIOCGBLEN", err)
+	}
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	return &bpfConn{f: os.NewFile(uintptr(fd), "bpf"), buf: make([]byte, blen)}, nil
+}
+
+// ReadFrame returns the next captured frame, reading a new BPF buffer from
+// the device once all buffered frames have been consumed.
+func (c *bpfConn) ReadFrame(b []byte) (int, error) {
+	for len(c.pending) == 0 {
+		n, err := c.f.Read(c.buf)
+		if err != nil {
+			return 0, err
+		}
+		c.pending = c.buf[:n]
+	}
+	var hdr syscall.BpfHdr
+	if len(c.pending) < int(unsafe.Sizeof(hdr)) {
+		c.pending = nil
+		return 0, nil
+	}
+	hdr = *(*syscall.BpfHdr)(unsafe.Pointer(&c.pending[0]))
+	start := int(hdr.Hdrlen)
+	end := start + int(hdr.Caplen)
+	if end > len(c.pending) {
+		c.pending = nil
+		return 0, nil
+	}
+	n := copy(b, c.pending[start:end])
+	next := (end + 3) &^ 3
+	if next >= len(c.pending) {
+		c.pending = nil
+	} else {
+		c.pending = c.pending[next:]
+	}
+	return n, nil
+}
+
+func (c *bpfConn) WriteFrame(b []byte) error {
+	_, err := c.f.Write(b)
+	return err
+}
+
+func (c *bpfConn) SetDeadline(t time.Time) error { return c.f.SetDeadline(t) }
+
+func (c *bpfConn) Close() error { return c.f.Close() }
+
+func ioctl(fd uintptr, req uint, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, uintptr(req), uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package arp
+
+import (
+	"net"
+	"os"
+	"syscall"
+	"time"
+)
+
+// packetConn is an AF_PACKET socket bound to a single interface.
+type packetConn struct {
+	f *os.File
+}
+
+// listen opens an AF_PACKET socket receiving ARP frames on ifi.
+func listen(ifi *net.Interface) (frameConn, error) {
+	proto := htons(syscall.ETH_P_ARP)
+	fd, err := syscall.Socket(syscall.AF_PACKET, syscall.SOCK_RAW, int(proto))
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+	if err := syscall.Bind(fd, &syscall.SockaddrLinklayer{Protocol: proto, Ifindex: ifi.Index}); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("bind", err)
+	}
+	// A non-blocking descriptor lets the runtime poller enforce deadlines.
+	if err := syscall.SetNonblock(fd, true); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("setnonblock", err)
+	}
+	return &packetConn{f: os.NewFile(uintptr(fd), "arp")}, nil
+}
+
+func (c *packetConn) ReadFrame(b []byte) (int, error) { return c.f.Read(b) }
+
+func (c *packetConn) WriteFrame(b []byte) error {
+	_, err := c.f.Write(b)
+	return err
+}
+
+func (c *packetConn) SetDeadline(t time.Time) error { return c.f.SetDeadline(t) }
+
+func (c *packetConn) Close() error { return c.f.Close() }
+
+// htons converts a short from host to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package arp
+
+import (
+	"errors"
+	"net"
+)
+
+// listen is unsupported on this platform.
+func listen(ifi *net.Interface) (frameConn, error) {
+	return nil, errors.New("arp ping is only supported on linux and darwin")
+}
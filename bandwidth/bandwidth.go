@@ -0,0 +1,136 @@
+// Package bandwidth bounds the traffic of all probes together, so that
+// deployments on constrained links can limit their own overhead.
+package bandwidth
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Limiter implements the pinger.Budget interface
+var _ pinger.Budget = (*Limiter)(nil)
+
+// rateUnits are the suffixes ParseRate accepts, longest first so that "kbps"
+// is not read as "bps". Units are powers of 1000, as usual for links.
+var rateUnits = []struct {
+	suffix string
+	bits   int64
+}{
+	{"kbps", 1e3}, {"mbps", 1e6}, {"gbps", 1e9},
+	{"bps", 1},
+}
+
+// ParseRate parses a rate in bits per second such as "50kbps", "2Mbps" or
+// "9600bps". Units are case-insensitive; a bare number is in bits per second.
+func ParseRate(s string) (int64, error) {
+	text := strings.ToLower(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for _, unit := range rateUnits {
+		if strings.HasSuffix(text, unit.suffix) {
+			text, multiplier = strings.TrimSpace(strings.TrimSuffix(text, unit.suffix)), unit.bits
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(text, 64)
+	if err != nil || n <= 0 || n*float64(multiplier) > 1e15 {
+		return 0, fmt.Errorf("invalid rate %q", s)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+// FormatRate formats bits per second with the largest unit that keeps it at
+// least one, such as "50kbps".
+func FormatRate(bits int64) string {
+	for _, unit := range rateUnits {
+		if bits >= unit.bits && unit.bits > 1 {
+			return strconv.FormatFloat(float64(bits)/float64(unit.bits), 'f', -1, 64) + unit.suffix
+		}
+	}
+	return strconv.FormatInt(bits, 10) + "bps"
+}
+
+// minBurst is the smallest burst a Limiter allows, so that a probe can
+// always start on an idle limiter.
+const minBurst = 1500
+
+// Limiter is a token bucket of bytes, the pinger.Budget shared by the
+// Pingers of all targets. Probes are charged their traffic once done, as
+// their size is only known then, so the bucket may go into debt; a probe
+// waits until the debt is paid back. Over time the traffic stays within the
+// rate.
+type Limiter struct {
+	rate  float64 // bytes per second
+	burst float64 // the most bytes saved up while idle
+
+	mu      sync.Mutex
+	balance float64   // bytes that may be sent, negative when in debt
+	last    time.Time // when balance was last refilled
+	now     func() time.Time
+}
+
+// New creates a Limiter for bits per second. It allows a burst of one
+// second of traffic.
+func New(bits int64) *Limiter {
+	rate := float64(bits) / 8
+	return &Limiter{
+		rate:    rate,
+		burst:   max(rate, minBurst),
+		balance: max(rate, minBurst),
+		last:    time.Now(),
+		now:     time.Now,
+	}
+}
+
+// refill adds the bytes earned since the last refill. l.mu must be held.
+func (l *Limiter) refill() {
+	now := l.now()
+	l.balance = min(l.burst, l.balance+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+}
+
+// delay returns how long until the Limiter is out of debt.
+func (l *Limiter) delay() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	if l.balance >= 0 {
+		return 0
+	}
+	return time.Duration(-l.balance / l.rate * float64(time.Second))
+}
+
+// Wait blocks until the Limiter is out of debt or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	for {
+		d := l.delay()
+		if d <= 0 {
+			return nil
+		}
+		timer := time.NewTimer(d)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Spend charges the traffic of a probe.
+func (l *Limiter) Spend(stats *pinger.Stats) {
+	l.charge(stats.Traffic.Bytes())
+}
+
+// charge charges n bytes.
+func (l *Limiter) charge(n int64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill()
+	l.balance -= float64(n)
+}
@@ -0,0 +1,56 @@
+package bandwidth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseRate(t *testing.T) {
+	for s, want := range map[string]int64{"50kbps": 50000, "2Mbps": 2000000, "9600bps": 9600, "9600": 9600, "1.5 Gbps": 1500000000} {
+		if got, err := ParseRate(s); err != nil || got != want {
+			t.Errorf("ParseRate(%q) = %d, %v, want %d", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "0kbps", "-1kbps", "fast", "50KB"} {
+		if _, err := ParseRate(s); err == nil {
+			t.Errorf("ParseRate(%q) succeeded", s)
+		}
+	}
+	if got := FormatRate(50000); got != "50kbps" {
+		t.Errorf("FormatRate(50000) = %q", got)
+	}
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(80000) // 10000 bytes per second
+	l.now = func() time.Time { return now }
+	l.last = now
+
+	// The burst is spent without waiting, then the debt is paid back
+	l.charge(10000)
+	if d := l.delay(); d != 0 {
+		t.Fatalf("expected no delay after spending the burst, got %s", d)
+	}
+	l.charge(5000)
+	if d := l.delay(); d != 500*time.Millisecond {
+		t.Fatalf("expected a 500ms delay, got %s", d)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if d := l.delay(); d != 0 {
+		t.Fatalf("expected no delay once paid back, got %s", d)
+	}
+
+	// Idle time saves up at most the burst
+	now = now.Add(time.Hour)
+	l.charge(25000)
+	if d := l.delay(); d != 1500*time.Millisecond {
+		t.Fatalf("expected a 1.5s delay, got %s", d)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("expected Wait to stop with the context")
+	}
+}
@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// allIPs enables rotating the probes of hosts with several addresses between
+// all of them.
+var allIPs bool
+
+// allIPsProtocols are the protocols whose probes can be pinned to an
+// address.
+var allIPsProtocols = []pinger.Protocol{pinger.TCP, pinger.UDP, pinger.HTTP, pinger.HTTPS, pinger.TLS, pinger.H2C}
+
+// withAllIPs replaces the Ping of a target probing u with one rotating
+// between all the addresses of its host when --all-ips is set and the host
+// has several. Otherwise ping is returned with a note explaining why.
+func withAllIPs(u *url.URL, protocol pinger.Protocol, option *pinger.Option, factory pinger.Factory, ping pinger.Ping) (pinger.Ping, string) {
+	if !allIPs {
+		return ping, ""
+	}
+	if !slices.Contains(allIPsProtocols, protocol) {
+		return ping, fmt.Sprintf("--all-ips does not support %s", protocol)
+	}
+	if net.ParseIP(u.Hostname()) != nil {
+		return ping, fmt.Sprintf("--all-ips needs a host name, %s is an address", u.Hostname())
+	}
+	if option.Proxy != nil {
+		return ping, "--all-ips leaves the addresses to the proxy, probing the host as usual"
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), max(option.Timeout, pinger.DefaultTimeout))
+	defer cancel()
+	ips, err := option.LookupIP(ctx, option.Resolver, u.Hostname())
+	if err != nil {
+		return ping, fmt.Sprintf("--all-ips could not resolve %s, probing it as usual: %v", u.Hostname(), err)
+	}
+	if len(ips) < 2 {
+		return ping, fmt.Sprintf("%s has a single address, probing it as usual", u.Hostname())
+	}
+	pings := make([]pinger.Ping, len(ips))
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		ipOption := *option
+		ipOption.Pin = pinger.NewPinTo(ip)
+		p, err := factory(u, &ipOption)
+		if err != nil {
+			return ping, err.Error()
+		}
+		pings[i], addrs[i] = p, ip.String()
+	}
+	return pinger.AllIPs(pings, addrs), ""
+}
+
+// printAllIPs compares the results of the addresses of every target probed
+// with --all-ips, ending with a verdict per target.
+func printAllIPs(w io.Writer, samples map[string]map[string]*stats.Sample) {
+	fmt.Fprintln(w, "\nPer-address comparison")
+	if len(samples) == 0 {
+		fmt.Fprintln(w, "    No targets with several addresses.")
+		return
+	}
+	for _, target := range slices.Sorted(maps.Keys(samples)) {
+		addresses := samples[target]
+		fmt.Fprintf(w, "    %s\n", target)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "        ADDRESS\tLOSS\tAVG\tP95\tPROBES")
+		for _, addr := range slices.Sorted(maps.Keys(addresses)) {
+			s := addresses[addr]
+			avg, p95 := "-", "-"
+			if len(s.Durations) > 0 {
+				avg, p95 = s.Mean().String(), s.Percentile(95).String()
+			}
+			fmt.Fprintf(tw, "        %s\t%.1f%%\t%s\t%s\t%d\n", addr, s.Loss()*100, avg, p95, s.Total)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "        Verdict: %s\n", allIPsVerdict(addresses))
+	}
+}
+
+// allIPsVerdict names the addresses every probe of which failed, then those
+// losing more than the best address by dualStackLoss.
+func allIPsVerdict(addresses map[string]*stats.Sample) string {
+	var broken, degraded []string
+	best := 1.0
+	for _, s := range addresses {
+		best = min(best, s.Loss())
+	}
+	for _, addr := range slices.Sorted(maps.Keys(addresses)) {
+		s := addresses[addr]
+		switch {
+		case s.Failed == s.Total:
+			broken = append(broken, addr)
+		case s.Loss()-best >= dualStackLoss:
+			degraded = append(degraded, fmt.Sprintf("%s (%.1f%% loss)", addr, s.Loss()*100))
+		}
+	}
+	switch {
+	case len(broken) == len(addresses):
+		return "every address fails"
+	case len(broken) > 0:
+		return fmt.Sprintf("%d of %d addresses broken, every probe of %s failed", len(broken), len(addresses), strings.Join(broken, ", "))
+	case len(degraded) > 0:
+		return fmt.Sprintf("%d of %d addresses degraded: %s", len(degraded), len(addresses), strings.Join(degraded, ", "))
+	}
+	return fmt.Sprintf("all %d addresses on par", len(addresses))
+}
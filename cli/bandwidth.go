@@ -0,0 +1,23 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/circle-protocol/circle-pinger/bandwidth"
+)
+
+// maxBandwidth bounds the traffic of all targets together, shared by the
+// root, daemon and serve commands. Empty means unbounded.
+var maxBandwidth string
+
+// newLimiter returns the Limiter for --max-bandwidth, or nil when unset.
+func newLimiter() (*bandwidth.Limiter, error) {
+	if maxBandwidth == "" {
+		return nil, nil
+	}
+	bits, err := bandwidth.ParseRate(maxBandwidth)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --max-bandwidth: %w", err)
+	}
+	return bandwidth.New(bits), nil
+}
@@ -0,0 +1,88 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/dnscache"
+	"github.com/circle-protocol/circle-pinger/power"
+)
+
+const (
+	// batteryFactor widens the intervals while on battery.
+	batteryFactor = 4
+	// batteryPoll is how often the power source is read.
+	batteryPoll = 30 * time.Second
+	// batteryDNSWindow is how long DNS answers are reused while on battery.
+	batteryDNSWindow = 5 * time.Minute
+)
+
+var (
+	// batteryAware widens intervals and batches DNS while on battery
+	batteryAware bool
+
+	// onBattery is whether the machine ran on battery when last read
+	onBattery atomic.Bool
+
+	// dnsCaches are the DNS caches of battery-aware runs, per set of DNS
+	// servers, so that all targets share their answers
+	dnsCaches   = make(map[string]*dnscache.Cache)
+	dnsCachesMu sync.Mutex
+)
+
+// watchBattery reads the power source and keeps reading it every
+// batteryPoll, noting changes on stderr. Where the power source cannot be
+// read, probing continues as usual after a note.
+func watchBattery() {
+	on, err := power.OnBattery()
+	if err != nil {
+		fmt.Fprintf(stderr, "note: --battery-aware has no effect: %v\n", err)
+		return
+	}
+	if on {
+		setBattery(on)
+	}
+	go func() {
+		for range time.Tick(batteryPoll) {
+			if on, err := power.OnBattery(); err == nil && on != onBattery.Load() {
+				setBattery(on)
+			}
+		}
+	}()
+}
+
+// setBattery records the power source and notes it on stderr.
+func setBattery(on bool) {
+	onBattery.Store(on)
+	if on {
+		fmt.Fprintf(stderr, "power: on battery, probing %dx less often and reusing DNS answers for %s\n", batteryFactor, batteryDNSWindow)
+	} else {
+		fmt.Fprintln(stderr, "power: on mains, probing at the usual interval")
+	}
+}
+
+// batteryPace widens interval while on battery.
+func batteryPace(interval time.Duration) time.Duration {
+	if onBattery.Load() {
+		return batteryFactor * interval
+	}
+	return interval
+}
+
+// dnsCache returns the shared DNS cache forwarding to servers, or to the
+// system's servers when empty, through dial.
+func dnsCache(servers []string, dial dnscache.DialFunc) *net.Resolver {
+	dnsCachesMu.Lock()
+	defer dnsCachesMu.Unlock()
+	key := strings.Join(servers, ",")
+	c, ok := dnsCaches[key]
+	if !ok {
+		c = dnscache.New(dial, batteryDNSWindow, onBattery.Load)
+		dnsCaches[key] = c
+	}
+	return c.Resolver()
+}
@@ -0,0 +1,348 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Check flags
+	checkWithin    string
+	checkRequire   string
+	checkInterval  string
+	checkTimeout   string
+	checkQuiet     bool
+	checkReadyFile string
+	checkOneshot   bool
+	checkOnce      bool
+)
+
+// checkCmd waits until a target is available.
+var checkCmd = &cobra.Command{
+	Use:   "check target... --within 30s --require 3-of-5",
+	Short: "Probe targets until they are available or a time box expires",
+	Long: `Probe targets until each meets a success criterion or the time box expires,
+for example in a container entrypoint waiting for its dependencies to come up.
+
+The criterion "N-of-M" is met once N of the last M probes succeeded, which
+tolerates a flapping service better than waiting for a single success. The
+targets are probed concurrently; the command exits 0 as soon as all of them
+are available, and 1 if the time box expires first. With --ready-file the
+file is written only once all targets are available.
+
+--oneshot is meant for init containers started over and over: the targets are
+probed in turn from a single goroutine, without signal handling or per-probe
+output, and only the outcome is printed.
+
+--once is meant for exec liveness and readiness probes and Docker
+HEALTHCHECK: every target is probed exactly once with --timeout, a single
+line is printed per target, and the command exits 0 if all succeeded and 1
+otherwise, without waiting or retrying; the orchestrator does that.`,
+	Example: `
+  1. wait up to a minute for a database, requiring 3 of the last 5 probes
+    > circle-pinger check db:5432 --within 1m --require 3-of-5 && ./start-app
+  2. gate an entrypoint on a database, a cache and a broker
+    > circle-pinger check db:5432 redis:6379 rabbitmq:5672 --within 2m --ready-file /tmp/ready
+  3. gate an init container with the lean profile
+    > circle-pinger check --oneshot db:5432 --within 1m
+  4. a Kubernetes exec readiness probe or Docker HEALTHCHECK
+    > circle-pinger check --once localhost:8080 -T 500ms`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runCheck,
+}
+
+// criterion is a success criterion: need successes among the last window probes.
+type criterion struct {
+	need, window int
+}
+
+// String returns the criterion in its "N-of-M" form.
+func (c criterion) String() string {
+	return fmt.Sprintf("%d-of-%d", c.need, c.window)
+}
+
+// parseCriterion parses an "N-of-M" criterion.
+func parseCriterion(s string) (criterion, error) {
+	need, window, ok := strings.Cut(s, "-of-")
+	n, err1 := strconv.Atoi(need)
+	m, err2 := strconv.Atoi(window)
+	if !ok || err1 != nil || err2 != nil || n < 1 || n > m {
+		return criterion{}, fmt.Errorf(`invalid --require %q, want "N-of-M" with 1 <= N <= M`, s)
+	}
+	return criterion{need: n, window: m}, nil
+}
+
+// checkWindow keeps the outcomes of the last probes.
+type checkWindow struct {
+	criterion
+	results []bool
+	met     bool
+}
+
+// add records a probe outcome and reports whether the criterion is met.
+func (w *checkWindow) add(ok bool) bool {
+	w.results = append(w.results, ok)
+	if len(w.results) > w.window {
+		w.results = w.results[1:]
+	}
+	w.met = w.successes() >= w.need
+	return w.met
+}
+
+// successes returns the number of successes in the window.
+func (w *checkWindow) successes() int {
+	n := 0
+	for _, ok := range w.results {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// runCheck probes the targets until every one meets the criterion or time
+// runs out.
+func runCheck(cmd *cobra.Command, args []string) error {
+	within, err := utils.ParseDuration(checkWithin)
+	if err != nil {
+		return fmt.Errorf("invalid --within: %w", err)
+	}
+	interval, err := utils.ParseDuration(checkInterval)
+	if err != nil {
+		return fmt.Errorf("invalid --interval: %w", err)
+	}
+	timeout, err := utils.ParseDuration(checkTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid --timeout: %w", err)
+	}
+	crit, err := parseCriterion(checkRequire)
+	if err != nil {
+		return err
+	}
+	if checkOnce {
+		for _, name := range []string{"within", "require", "interval", "ready-file", "oneshot"} {
+			if cmd.Flags().Changed(name) {
+				return fmt.Errorf("--once cannot be combined with --%s", name)
+			}
+		}
+	}
+	var targets []*target
+	for _, addr := range args {
+		t, err := newTarget(cmd, addr, "", interval, timeout)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, t)
+	}
+
+	if checkOnce {
+		if code := checkTargetsOnce(targets, timeout); code != exitPass {
+			os.Exit(code)
+		}
+		return nil
+	}
+
+	if checkReadyFile != "" {
+		if err := removeReadyFile(checkReadyFile); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), within)
+	defer cancel()
+	start := time.Now()
+	windows := make([]*checkWindow, len(targets))
+	for i := range targets {
+		windows[i] = &checkWindow{criterion: crit}
+	}
+
+	if checkOneshot {
+		checkOneshotTargets(ctx, targets, windows, interval, timeout)
+		return checkOutcome(targets, windows, crit, within, start)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkTarget(ctx, t, windows[i], interval, timeout)
+		}()
+	}
+	wg.Wait()
+	return checkOutcome(targets, windows, crit, within, start)
+}
+
+// checkOutcome reports whether all targets met the criterion and writes the
+// readiness file if they did.
+func checkOutcome(targets []*target, windows []*checkWindow, crit criterion, within time.Duration, start time.Time) error {
+	var unavailable []string
+	for i, t := range targets {
+		if !windows[i].met {
+			w := windows[i]
+			unavailable = append(unavailable, fmt.Sprintf("%s (%d of the last %d probes succeeded)", t.url, w.successes(), len(w.results)))
+		}
+	}
+	if len(unavailable) > 0 {
+		return fmt.Errorf("not available within %s, %s required: %s", within, crit, strings.Join(unavailable, ", "))
+	}
+	if len(targets) > 1 {
+		fmt.Fprintf(stdout, "all %d targets are available after %s\n", len(targets), time.Since(start).Round(time.Millisecond))
+	}
+	if checkReadyFile != "" {
+		return writeReadyFile(checkReadyFile, targets)
+	}
+	return nil
+}
+
+// checkTarget probes t until its window meets the criterion or ctx is done.
+func checkTarget(ctx context.Context, t *target, window *checkWindow, interval, timeout time.Duration) {
+	start := time.Now()
+	for seq := 1; ; seq++ {
+		probeStart := time.Now()
+		pingCtx, pingCancel := context.WithTimeout(ctx, timeout)
+		stats := t.ping.Ping(pingCtx)
+		pingCancel()
+		if ctx.Err() != nil {
+			return
+		}
+		if !checkQuiet {
+			record := &pinger.Record{Target: t.url.String(), Seq: seq, Timestamp: probeStart, Stats: stats}
+			fmt.Fprint(stdout, record.String())
+		}
+		if window.add(stats.Connected) {
+			fmt.Fprintf(stdout, "%s is available: %d of the last %d probes succeeded after %s\n",
+				t.url, window.successes(), len(window.results), time.Since(start).Round(time.Millisecond))
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(probeStart.Add(interval))):
+		}
+	}
+}
+
+// checkTargetsOnce probes every target exactly once, concurrently, and
+// prints their results in the order of the targets, unless quiet. It returns
+// the exit code of the run: exitPass when all probes succeeded, exitFail
+// otherwise.
+func checkTargetsOnce(targets []*target, timeout time.Duration) int {
+	records := make([]*pinger.Record, len(targets))
+	var wg sync.WaitGroup
+	for i, t := range targets {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			records[i] = &pinger.Record{Target: t.url.String(), Seq: 1, Timestamp: start, Stats: t.ping.Ping(ctx)}
+		}()
+	}
+	wg.Wait()
+
+	code := exitPass
+	for _, record := range records {
+		if !checkQuiet {
+			fmt.Fprint(stdout, record.String())
+		}
+		if !record.Stats.Connected {
+			code = exitFail
+		}
+	}
+	return code
+}
+
+// checkOneshotTargets probes the targets in rounds from the calling goroutine
+// until all of them meet the criterion or ctx is done. It leaves signals at
+// their default disposition and prints only the outcome, keeping a run in an
+// init container as cheap as possible.
+func checkOneshotTargets(ctx context.Context, targets []*target, windows []*checkWindow, interval, timeout time.Duration) {
+	start := time.Now()
+	for {
+		roundStart := time.Now()
+		pending := 0
+		for i, t := range targets {
+			if windows[i].met {
+				continue
+			}
+			pingCtx, pingCancel := context.WithTimeout(ctx, timeout)
+			stats := t.ping.Ping(pingCtx)
+			pingCancel()
+			if ctx.Err() != nil {
+				return
+			}
+			if windows[i].add(stats.Connected) {
+				fmt.Fprintf(stdout, "%s is available after %s\n", t.url, time.Since(start).Round(time.Millisecond))
+			} else {
+				pending++
+			}
+		}
+		if pending == 0 {
+			return
+		}
+
+		deadline, _ := ctx.Deadline()
+		wait := time.Until(roundStart.Add(interval))
+		if left := time.Until(deadline); left < wait {
+			time.Sleep(left)
+			return
+		}
+		time.Sleep(wait)
+	}
+}
+
+// removeReadyFile removes the readiness file left by a previous run, which
+// must not signal readiness.
+func removeReadyFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// writeReadyFile atomically writes the readiness file listing the targets.
+func writeReadyFile(path string, targets []*target) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "ready %s\n", time.Now().UTC().Format(time.RFC3339))
+	for _, t := range targets {
+		fmt.Fprintln(&b, t.url)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// initCheckCommand registers the check subcommand.
+func initCheckCommand() {
+	checkCmd.Flags().StringVar(&checkWithin, "within", "30s", "give up after this long")
+	checkCmd.Flags().StringVar(&checkRequire, "require", "1-of-1", `succeed once N of the last M probes succeeded, as "N-of-M"`)
+	checkCmd.Flags().StringVarP(&checkInterval, "interval", "I", "1s", "time between the starts of two probes")
+	checkCmd.Flags().StringVarP(&checkTimeout, "timeout", "T", "1s", "timeout of a single probe")
+	checkCmd.Flags().BoolVarP(&checkQuiet, "quiet", "q", false, "only print the outcome")
+	checkCmd.Flags().StringVar(&checkReadyFile, "ready-file", "", "write this file once all targets are available, removing any stale one first")
+	checkCmd.Flags().BoolVar(&checkOneshot, "oneshot", false, "probe sequentially without background goroutines or per-probe output, for init containers")
+	checkCmd.Flags().BoolVar(&checkOnce, "once", false, "probe every target exactly once, print a line each and exit 0 if all succeeded or 1, for liveness and readiness probes")
+	RootCmd.AddCommand(checkCmd)
+}
@@ -0,0 +1,272 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/cobra"
+)
+
+// scriptedPing answers with its outcomes in turn, repeating the last one.
+type scriptedPing struct {
+	outcomes []bool
+	n        int
+}
+
+func (s *scriptedPing) Ping(ctx context.Context) *pinger.Stats {
+	ok := s.outcomes[min(s.n, len(s.outcomes)-1)]
+	s.n++
+	if !ok {
+		return &pinger.Stats{Error: errors.New("connection refused"), Duration: time.Millisecond}
+	}
+	return &pinger.Stats{Connected: true, Duration: time.Millisecond}
+}
+
+// newCheckTarget returns a target probing with a scriptedPing of outcomes.
+func newCheckTarget(host string, outcomes ...bool) *target {
+	u, _ := url.Parse("tcp://" + host + ":5432")
+	return &target{url: u, ping: &scriptedPing{outcomes: outcomes}}
+}
+
+func TestParseCriterion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want criterion
+		err  bool
+	}{
+		{"1-of-1", criterion{1, 1}, false},
+		{"3-of-5", criterion{3, 5}, false},
+		{"5-of-5", criterion{5, 5}, false},
+		{"3of5", criterion{}, true},
+		{"3-in-5", criterion{}, true},
+		{"3/5", criterion{}, true},
+		{"0-of-5", criterion{}, true},
+		{"6-of-5", criterion{}, true},
+		{"-1-of-5", criterion{}, true},
+		{"a-of-5", criterion{}, true},
+		{"3-of-", criterion{}, true},
+		{"", criterion{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCriterion(tt.in)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("parseCriterion(%q) = %v, %v; want %v, error %v", tt.in, got, err, tt.want, tt.err)
+		}
+	}
+}
+
+func TestRunCheck_InvalidFlags(t *testing.T) {
+	defer func(within, interval, timeout, require string) {
+		checkWithin, checkInterval, checkTimeout, checkRequire = within, interval, timeout, require
+	}(checkWithin, checkInterval, checkTimeout, checkRequire)
+
+	tests := []struct {
+		name                               string
+		within, interval, timeout, require string
+		want                               string
+	}{
+		{"within unit", "30x", "1s", "1s", "1-of-1", "invalid --within"},
+		{"interval unit", "30s", "1 sec", "1s", "1-of-1", "invalid --interval"},
+		{"timeout unit", "30s", "1s", "5y", "1-of-1", "invalid --timeout"},
+		{"criterion", "30s", "1s", "1s", "3-in-5", "invalid --require"},
+	}
+	for _, tt := range tests {
+		checkWithin, checkInterval, checkTimeout, checkRequire = tt.within, tt.interval, tt.timeout, tt.require
+		err := runCheck(checkCmd, []string{"db:5432"})
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: runCheck() error = %v, want %q", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestCheckWindow(t *testing.T) {
+	tests := []struct {
+		name      string
+		crit      criterion
+		outcomes  []bool
+		met       bool
+		successes int
+	}{
+		{"single success", criterion{1, 1}, []bool{true}, true, 1},
+		{"single failure", criterion{1, 1}, []bool{false}, false, 0},
+		{"recovered", criterion{1, 1}, []bool{false, false, true}, true, 1},
+		{"too few yet", criterion{3, 5}, []bool{true, true}, false, 2},
+		{"enough among failures", criterion{3, 5}, []bool{true, false, true, false, true}, true, 3},
+		{"successes slid out", criterion{3, 5}, []bool{true, true, false, false, false, false, true}, false, 1},
+		{"flapping", criterion{2, 3}, []bool{true, false, false, true, false}, false, 1},
+		{"met again after a failure", criterion{2, 3}, []bool{true, true, false}, true, 2},
+	}
+	for _, tt := range tests {
+		w := &checkWindow{criterion: tt.crit}
+		var met bool
+		for _, ok := range tt.outcomes {
+			met = w.add(ok)
+		}
+		if met != tt.met || w.met != tt.met || w.successes() != tt.successes {
+			t.Errorf("%s: met = %v (%v), successes = %d; want %v, %d", tt.name, met, w.met, w.successes(), tt.met, tt.successes)
+		}
+		if len(w.results) > tt.crit.window {
+			t.Errorf("%s: window keeps %d results, want at most %d", tt.name, len(w.results), tt.crit.window)
+		}
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	defer func(w io.Writer, quiet bool) { stdout, checkQuiet = w, quiet }(stdout, checkQuiet)
+	stdout, checkQuiet = io.Discard, true
+
+	tests := []struct {
+		name     string
+		crit     criterion
+		outcomes []bool
+		met      bool
+	}{
+		{"available at once", criterion{1, 1}, []bool{true}, true},
+		{"available after failures", criterion{2, 3}, []bool{false, true, false, true}, true},
+		{"never available", criterion{1, 1}, []bool{false}, false},
+	}
+	for _, tt := range tests {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		window := &checkWindow{criterion: tt.crit}
+		checkTarget(ctx, newCheckTarget("db", tt.outcomes...), window, time.Millisecond, time.Second)
+		cancel()
+		if window.met != tt.met {
+			t.Errorf("%s: met = %v, want %v", tt.name, window.met, tt.met)
+		}
+	}
+}
+
+func TestCheckOutcome(t *testing.T) {
+	defer func(w io.Writer, ready string) { stdout, checkReadyFile = w, ready }(stdout, checkReadyFile)
+	stdout, checkReadyFile = io.Discard, ""
+
+	crit := criterion{2, 3}
+	targets := []*target{newCheckTarget("db"), newCheckTarget("cache")}
+	windows := []*checkWindow{{criterion: crit}, {criterion: crit}}
+	windows[0].add(true)
+	windows[0].add(true)
+	windows[1].add(true)
+	windows[1].add(false)
+
+	err := checkOutcome(targets, windows, crit, time.Minute, time.Now())
+	if err == nil || !strings.Contains(err.Error(), "tcp://cache:5432 (1 of the last 2 probes succeeded)") || strings.Contains(err.Error(), "db") {
+		t.Fatalf("expected cache alone to be unavailable, got %v", err)
+	}
+	windows[1].add(true)
+	if err := checkOutcome(targets, windows, crit, time.Minute, time.Now()); err != nil {
+		t.Fatalf("expected all targets available, got %v", err)
+	}
+}
+
+func TestCheckOutcome_ReadyFile(t *testing.T) {
+	defer func(w io.Writer, ready string) { stdout, checkReadyFile = w, ready }(stdout, checkReadyFile)
+	stdout = io.Discard
+	checkReadyFile = filepath.Join(t.TempDir(), "ready")
+	exists := func() bool {
+		_, err := os.Stat(checkReadyFile)
+		return err == nil
+	}
+
+	// A file left by a previous run is removed before probing
+	if err := os.WriteFile(checkReadyFile, []byte("ready\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := removeReadyFile(checkReadyFile); err != nil || exists() {
+		t.Fatalf("stale ready file not removed: %v", err)
+	}
+	if err := removeReadyFile(checkReadyFile); err != nil {
+		t.Fatalf("removing a missing ready file failed: %v", err)
+	}
+
+	crit := criterion{1, 1}
+	targets := []*target{newCheckTarget("db"), newCheckTarget("cache")}
+	windows := []*checkWindow{{criterion: crit}, {criterion: crit}}
+
+	// Unhealthy: one target is down, so no file is written
+	windows[0].add(true)
+	windows[1].add(false)
+	if err := checkOutcome(targets, windows, crit, time.Minute, time.Now()); err == nil || exists() {
+		t.Fatalf("ready file written while a target is down: %v", err)
+	}
+
+	// Healthy: the file lists every target
+	windows[1].add(true)
+	if err := checkOutcome(targets, windows, crit, time.Minute, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(checkReadyFile)
+	if err != nil {
+		t.Fatalf("ready file not written: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 3 ||
+		!strings.HasPrefix(lines[0], "ready ") || lines[1] != "tcp://db:5432" || lines[2] != "tcp://cache:5432" {
+		t.Fatalf("unexpected ready file:\n%s", data)
+	}
+	if _, err := os.Stat(checkReadyFile + ".tmp"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("temporary file left behind: %v", err)
+	}
+
+	// The next run starts unhealthy again
+	if err := removeReadyFile(checkReadyFile); err != nil || exists() {
+		t.Fatalf("ready file of the last run not removed: %v", err)
+	}
+}
+
+func TestCheckTargetsOnce(t *testing.T) {
+	defer func(w io.Writer, quiet bool) { stdout, checkQuiet = w, quiet }(stdout, checkQuiet)
+	checkQuiet = false
+
+	tests := []struct {
+		name    string
+		targets []*target
+		code    int
+		lines   int
+	}{
+		{"up", []*target{newCheckTarget("db", true)}, exitPass, 1},
+		{"down", []*target{newCheckTarget("db", false)}, exitFail, 1},
+		{"all up", []*target{newCheckTarget("db", true), newCheckTarget("cache", true)}, exitPass, 2},
+		{"one down", []*target{newCheckTarget("db", true), newCheckTarget("cache", false)}, exitFail, 2},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		stdout = &buf
+		if code := checkTargetsOnce(tt.targets, time.Second); code != tt.code {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, tt.code)
+		}
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		if len(lines) != tt.lines || !strings.Contains(lines[0], "tcp://db:5432") {
+			t.Errorf("%s: expected one line per target in order, got:\n%s", tt.name, buf.String())
+		}
+		for _, target := range tt.targets {
+			if n := target.ping.(*scriptedPing).n; n != 1 {
+				t.Errorf("%s: %s probed %d times, want once", tt.name, target.url, n)
+			}
+		}
+	}
+}
+
+func TestRunCheck_OnceConflicts(t *testing.T) {
+	defer func(once bool, within, interval, timeout, require string) {
+		checkOnce, checkWithin, checkInterval, checkTimeout, checkRequire = once, within, interval, timeout, require
+	}(checkOnce, checkWithin, checkInterval, checkTimeout, checkRequire)
+	checkOnce, checkWithin, checkInterval, checkTimeout, checkRequire = true, "30s", "1s", "1s", "1-of-1"
+
+	for _, name := range []string{"within", "require", "interval", "ready-file", "oneshot"} {
+		cmd := &cobra.Command{}
+		cmd.Flags().String(name, "", "")
+		cmd.Flags().Set(name, "set")
+		err := runCheck(cmd, []string{"db:5432"})
+		if err == nil || !strings.Contains(err.Error(), "--once cannot be combined with --"+name) {
+			t.Errorf("--once with --%s: error = %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package cli
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// newRunTarget returns a target probing every 5ms with the given outcomes,
+// without a counter, as with --deadline or --continuous.
+func newRunTarget(out io.Writer, outcomes ...bool) *target {
+	u, _ := url.Parse("tcp://192.0.2.1:80")
+	t := &target{url: u, protocol: pinger.TCP, ping: &scriptedPing{outcomes: outcomes}}
+	t.pinger = pinger.NewPinger(out, u, t.ping, 5*time.Millisecond, 0, time.Second)
+	return t
+}
+
+// startRun runs the pingers of targets as a run does and returns a channel
+// closed once all of them returned.
+func startRun(targets []*target) <-chan struct{} {
+	finished := make(chan struct{})
+	remaining := make(chan struct{}, len(targets))
+	for _, t := range targets {
+		go func() {
+			t.pinger.Ping()
+			remaining <- struct{}{}
+		}()
+	}
+	go func() {
+		for range targets {
+			<-remaining
+		}
+		close(finished)
+	}()
+	return finished
+}
+
+func TestAwaitRun_Deadline(t *testing.T) {
+	policy, err := parseFailOn("any")
+	if err != nil {
+		t.Fatal(err)
+	}
+	const deadline = 50 * time.Millisecond
+	tests := []struct {
+		name     string
+		outcomes [][]bool
+		code     int
+	}{
+		{"all up", [][]bool{{true}, {true}}, exitPass},
+		{"one failing", [][]bool{{true}, {true, false}}, exitFail},
+		{"down", [][]bool{{false}}, exitFail},
+	}
+	for _, tt := range tests {
+		var targets []*target
+		for _, outcomes := range tt.outcomes {
+			targets = append(targets, newRunTarget(io.Discard, outcomes...))
+		}
+		finished := startRun(targets)
+		start := time.Now()
+		if awaitRun(targets, finished, nil, deadline, nil) {
+			t.Errorf("%s: run reported as interrupted", tt.name)
+		}
+		if elapsed := time.Since(start); elapsed < deadline || elapsed > deadline+time.Second {
+			t.Errorf("%s: run stopped after %s, want at the %s deadline", tt.name, elapsed, deadline)
+		}
+		select {
+		case <-finished:
+		default:
+			t.Fatalf("%s: pingers still running after the deadline", tt.name)
+		}
+		for _, target := range targets {
+			if total := target.pinger.State().Total; total < 2 {
+				t.Errorf("%s: %d probes before the deadline, want several", tt.name, total)
+			}
+		}
+		if code := checkFailures(io.Discard, policy, targets); code != tt.code {
+			t.Errorf("%s: exit code = %d, want %d", tt.name, code, tt.code)
+		}
+	}
+}
+
+func TestAwaitRun_Continuous(t *testing.T) {
+	targets := []*target{newRunTarget(io.Discard, true), newRunTarget(io.Discard, true, false)}
+	finished := startRun(targets)
+	sigs := make(chan os.Signal, 1)
+	time.AfterFunc(30*time.Millisecond, func() { sigs <- syscall.SIGINT })
+
+	done := make(chan bool)
+	go func() { done <- awaitRun(targets, finished, nil, 0, sigs) }()
+	select {
+	case interrupted := <-done:
+		if !interrupted {
+			t.Error("run stopped by SIGINT not reported as interrupted")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("continuous run did not stop on SIGINT")
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("pingers still running after SIGINT")
+	}
+
+	// The pingers stopped for good and summarize every probe sent
+	totals := make([]int, len(targets))
+	for i, target := range targets {
+		totals[i] = target.pinger.State().Total
+		if totals[i] == 0 {
+			t.Errorf("target %d sent no probe before SIGINT", i)
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	for i, target := range targets {
+		if total := target.pinger.State().Total; total != totals[i] {
+			t.Errorf("target %d probed after stopping: %d probes, then %d", i, totals[i], total)
+		}
+		summary := target.pinger.Summary()
+		if summary.Total != totals[i] || summary.SuccessTotal+summary.FailedTotal != totals[i] {
+			t.Errorf("target %d summary = %d probes (%d successful, %d failed), want %d",
+				i, summary.Total, summary.SuccessTotal, summary.FailedTotal, totals[i])
+		}
+	}
+	if failed := targets[1].pinger.Summary().FailedTotal; failed != totals[1]-1 {
+		t.Errorf("second target summarizes %d failures, want all but the first of %d probes", failed, totals[1])
+	}
+}
@@ -0,0 +1,33 @@
+package cli
+
+import (
+	"context"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/cloud"
+	"github.com/spf13/cobra"
+)
+
+// cloudLabels enables labelling results with the cloud placement, shared by
+// the root, daemon and serve commands.
+var cloudLabels bool
+
+// cloudDetectTimeout bounds the metadata queries; off the cloud they only
+// time out.
+const cloudDetectTimeout = 2 * time.Second
+
+// detectCloudLabels returns the cloud placement labels when --cloud-labels is
+// set. A failed detection is reported and yields no labels.
+func detectCloudLabels(cmd *cobra.Command) map[string]string {
+	if !cloudLabels {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cloudDetectTimeout)
+	defer cancel()
+	labels, err := cloud.DefaultDetector.Detect(ctx)
+	if err != nil {
+		cmd.PrintErrf("--cloud-labels: %v\n", err)
+		return nil
+	}
+	return labels
+}
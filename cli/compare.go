@@ -0,0 +1,147 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/report"
+	"github.com/circle-protocol/circle-pinger/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Compare flags
+	compareMaxRegression string
+)
+
+// compareCmd compares the statistics of two recorded sessions.
+var compareCmd = &cobra.Command{
+	Use:   "compare before.jsonl after.jsonl",
+	Short: "Compare loss and latency of two recorded sessions and flag regressions",
+	Long: `Compare the loss and the min, avg, p95 and p99 latency of two sessions
+recorded with --record, target by target, and exit 1 if any of them grew by
+more than --max-regression allows, for example to gate a deployment on the
+network behaving as well as before.
+
+--max-regression is a comma-separated list of limits, each a metric, "+" and
+how much it may grow: the loss by percentage points (loss+1%), latency by a
+share of its value before (p95+20%) or by a duration (avg+5ms). Metrics in
+limits are compared in addition to the default ones, so p50+10% adds a p50
+row. Targets recorded in only one of the sessions are listed but not judged.
+
+Unlike "report diff", compare does not test the changes for significance;
+record enough probes that the percentiles compared are stable.`,
+	Example: `
+  1. gate a deployment on its network performance
+    > circle-pinger --config targets.yaml -c 200 --record before.jsonl
+    > circle-pinger --config targets.yaml -c 200 --record after.jsonl
+    > circle-pinger compare before.jsonl after.jsonl
+  2. tolerate no additional loss and at most 2ms on the median
+    > circle-pinger compare before.jsonl after.jsonl --max-regression loss+0%,p50+2ms`,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runCompare,
+}
+
+// runCompare prints the comparison of the two sessions and fails on
+// regressions beyond the limits.
+func runCompare(cmd *cobra.Command, args []string) error {
+	limits, err := report.ParseLimits(compareMaxRegression)
+	if err != nil {
+		return fmt.Errorf("invalid --max-regression: %w", err)
+	}
+	metrics := slices.Clone(report.Metrics)
+	for _, l := range limits {
+		if !slices.Contains(metrics, l.Metric) {
+			metrics = append(metrics, l.Metric)
+		}
+	}
+	before, err := report.Load(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := report.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	var regressions []string
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tMETRIC\tBEFORE\tAFTER\tCHANGE\tVERDICT")
+	for _, c := range report.Diff(before, after) {
+		switch {
+		case c.Before == nil:
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tonly in after\n", c.Target)
+			continue
+		case c.After == nil:
+			fmt.Fprintf(tw, "%s\t-\t-\t-\t-\tonly in before\n", c.Target)
+			continue
+		}
+		exceeded := c.Regressions(limits)
+		for _, l := range exceeded {
+			regressions = append(regressions, c.Target+" "+l.Metric)
+		}
+		for _, metric := range metrics {
+			verdict := ""
+			for _, l := range exceeded {
+				if l.Metric == metric {
+					verdict = "* exceeds " + l.String()
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", c.Target, metric,
+				formatMetric(c.Before, metric), formatMetric(c.After, metric),
+				formatChange(c.Before, c.After, metric), verdict)
+		}
+	}
+	tw.Flush()
+	fmt.Fprintln(cmd.OutOrStdout())
+	if len(regressions) > 0 {
+		return fmt.Errorf("regressed beyond --max-regression %s: %s", compareMaxRegression, strings.Join(regressions, ", "))
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "no regressions beyond --max-regression %s\n", compareMaxRegression)
+	return nil
+}
+
+// formatMetric formats a metric of s, or "-" for latency without successful
+// probes.
+func formatMetric(s *stats.Sample, metric string) string {
+	v, ok := report.Value(s, metric)
+	switch {
+	case !ok:
+		return "-"
+	case metric == "loss":
+		return fmt.Sprintf("%.1f%%", v*100)
+	}
+	return time.Duration(v).Round(time.Microsecond).String()
+}
+
+// formatChange formats how a metric changed from before to after: loss in
+// percentage points, latency as a duration and a percentage.
+func formatChange(before, after *stats.Sample, metric string) string {
+	b, okBefore := report.Value(before, metric)
+	a, okAfter := report.Value(after, metric)
+	if !okBefore || !okAfter {
+		return "-"
+	}
+	if metric == "loss" {
+		return fmt.Sprintf("%+.1f pts", (a-b)*100)
+	}
+	d := time.Duration(a - b).Round(time.Microsecond).String()
+	if !strings.HasPrefix(d, "-") {
+		d = "+" + d
+	}
+	if b == 0 {
+		return d
+	}
+	return fmt.Sprintf("%s (%+.1f%%)", d, (a/b-1)*100)
+}
+
+// initCompareCommand registers the compare subcommand.
+func initCompareCommand() {
+	compareCmd.Flags().StringVar(&compareMaxRegression, "max-regression", "loss+1%,p95+20%", `comma-separated limits on how much metrics may grow, such as "loss+1%,p95+20%,avg+5ms"`)
+	RootCmd.AddCommand(compareCmd)
+}
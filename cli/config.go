@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/spf13/cobra"
+)
+
+// configCmd groups the configuration file helpers.
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with circle-pinger configuration files",
+}
+
+// configValidateCmd checks configuration files without running them.
+var configValidateCmd = &cobra.Command{
+	Use:   "validate config.yaml [config.yaml...]",
+	Short: "Validate configuration files and report errors with their position",
+	Example: `
+  1. validate a config before reloading an agent
+    > circle-pinger config validate /etc/circle-pinger/config.yaml`,
+	Args:          cobra.MinimumNArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runConfigValidate,
+}
+
+// runConfigValidate validates every file given and prints each problem found.
+func runConfigValidate(cmd *cobra.Command, args []string) error {
+	invalid := 0
+	for _, path := range args {
+		cfg, err := config.Load(path)
+		if err != nil {
+			invalid++
+			var errs config.Errors
+			if errors.As(err, &errs) {
+				for _, e := range errs {
+					cmd.PrintErrln(e)
+				}
+			} else {
+				cmd.PrintErrf("%s: %v\n", path, err)
+			}
+			continue
+		}
+		cmd.Printf("%s: OK (%d targets)\n", path, len(cfg.Targets))
+	}
+	if invalid > 0 {
+		return fmt.Errorf("%d of %d config files are invalid", invalid, len(args))
+	}
+	return nil
+}
+
+// initConfigCommands registers the config subcommands.
+func initConfigCommands() {
+	configCmd.AddCommand(configValidateCmd)
+	RootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,384 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/cloud"
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/cron"
+	"github.com/circle-protocol/circle-pinger/daemon"
+	"github.com/circle-protocol/circle-pinger/discovery"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Daemon flags
+	daemonConfig   string
+	daemonWatch    bool
+	daemonState    string
+	daemonSelf     string
+	daemonSchedule string
+)
+
+const (
+	// daemonWatchInterval is how often the config file is checked with --watch.
+	daemonWatchInterval = 2 * time.Second
+	// daemonSaveInterval is how often target states are written with --state.
+	daemonSaveInterval = 30 * time.Second
+	// daemonDiscoverInterval is how often discoveries are checked for a due
+	// refresh, and daemonDiscoverTimeout bounds a refresh.
+	daemonDiscoverInterval = time.Second
+	daemonDiscoverTimeout  = 10 * time.Second
+)
+
+// daemonCmd continuously probes the targets of a configuration file.
+var daemonCmd = &cobra.Command{
+	Use:   "daemon --config config.yaml",
+	Short: "Continuously probe configured targets, reloading them on SIGHUP",
+	Long: `Continuously probe every target of a configuration file.
+
+On SIGHUP (or a file change with --watch) the configuration is reloaded and
+only added, removed or changed targets are started or stopped; unchanged
+targets keep their statistics. An invalid configuration is reported and the
+running targets are left untouched.
+
+With --state the up/down state, counters and recent results of every target
+are saved periodically and on exit, and restored on the next start.
+
+The prober also probes itself as the target self://prober every --self-interval,
+reporting how far its loop runs behind schedule and how long goroutines wait
+to be scheduled, so that a wedged prober can be told apart from unreachable
+targets.
+
+Targets listed by the discovery section (DNS, Consul or Kubernetes) are
+refreshed in the background; added and removed instances are logged.
+
+Composites are probed as quorum://<name>, up while at least the quorum of
+their member targets are.
+
+With --schedule, or the schedule of the defaults or of a target in the
+configuration, targets are probed once at every time a crontab schedule such
+as "*/5 * * * *" fires instead of every interval, their results going to the
+configured outputs as usual. Targets with a schedule of their own keep it.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runDaemon,
+}
+
+// runDaemon runs the daemon until SIGINT or SIGTERM.
+func runDaemon(cmd *cobra.Command, args []string) error {
+	return runDaemonWith(cmd, nil, nil)
+}
+
+// runDaemonWith runs the daemon until SIGINT or SIGTERM, also sending the
+// records to extra and calling applied, if not nil, after every successful
+// (re)load of the configuration.
+func runDaemonWith(cmd *cobra.Command, extra []pinger.Sink, applied func(d *daemon.Daemon)) error {
+	cfg, err := config.Load(daemonConfig)
+	if err != nil {
+		return err
+	}
+	defineSecrets(cfg)
+	placement := detectCloudLabels(cmd)
+	cfg.Defaults.Labels = cloud.Merge(cfg.Defaults.Labels, placement)
+	selfInterval, err := utils.ParseDuration(daemonSelf)
+	if err != nil {
+		return fmt.Errorf("invalid --self-interval: %w", err)
+	}
+	if daemonSchedule != "" {
+		if _, err := cron.Parse(daemonSchedule); err != nil {
+			return fmt.Errorf("invalid --schedule: %w", err)
+		}
+		cfg.Defaults.Schedule = daemonSchedule
+	}
+
+	var budgets []pinger.Budget
+	limiter, err := newLimiter()
+	if err != nil {
+		return err
+	}
+	if limiter != nil {
+		budgets = append(budgets, limiter)
+	}
+	slots, err := newConcurrency()
+	if err != nil {
+		return err
+	}
+	if slots != nil {
+		budgets = append(budgets, slots)
+	}
+
+	bus, sinkNames, err := newSinks(stdout, cfg.Defaults.Interval.Std(), extra...)
+	if err != nil {
+		return err
+	}
+	defer closeSinks(stderr, bus, sinkNames)
+
+	d := daemon.New(summaryWriter(stdout, stderr), buildTarget)
+	d.SetSink(bus)
+	if len(budgets) > 0 {
+		d.SetBudget(pinger.MultiBudget(budgets...))
+	}
+	if daemonState != "" {
+		if err := d.LoadState(daemonState); err != nil {
+			return fmt.Errorf("load state: %w", err)
+		}
+	}
+	if selfInterval > 0 {
+		d.StartSelf(selfInterval)
+	}
+	set, err := newDiscoverySet(cmd, cfg)
+	if err != nil {
+		return err
+	}
+	changes, err := d.Apply(set.Config(cfg))
+	if err != nil {
+		return err
+	}
+	if applied != nil {
+		applied(d)
+	}
+	cmd.Printf("loaded %s: %s\n", daemonConfig, changes)
+	logMerged(cmd, changes)
+
+	var discovering *discoverer
+
+	reload := func(reason string) {
+		next, err := config.Load(daemonConfig)
+		if err != nil {
+			cmd.PrintErrf("reload (%s) rejected, keeping current targets:\n%v\n", reason, err)
+			return
+		}
+		defineSecrets(next)
+		next.Defaults.Labels = cloud.Merge(next.Defaults.Labels, placement)
+		if daemonSchedule != "" {
+			next.Defaults.Schedule = daemonSchedule
+		}
+		nextSet, err := newDiscoverySet(cmd, next)
+		if err != nil {
+			cmd.PrintErrf("reload (%s) rejected, keeping current targets: %v\n", reason, err)
+			return
+		}
+		changes, err := d.Apply(nextSet.Config(next))
+		if err != nil {
+			cmd.PrintErrf("reload (%s) failed, keeping current targets: %v\n", reason, err)
+			return
+		}
+		cfg, set = next, nextSet
+		discovering.stop()
+		discovering = startDiscovery(cmd, set)
+		if applied != nil {
+			applied(d)
+		}
+		cmd.Printf("reloaded %s (%s): %s\n", daemonConfig, reason, changes)
+		logMerged(cmd, changes)
+	}
+
+	// rediscover applies the instances added and removed by a refresh.
+	rediscover := func() {
+		changes, err := d.Apply(set.Config(cfg))
+		if err != nil {
+			cmd.PrintErrf("discovery failed, keeping current targets: %v\n", err)
+			return
+		}
+		if applied != nil {
+			applied(d)
+		}
+		for _, name := range changes.Added {
+			cmd.Printf("discovery: added %s\n", name)
+		}
+		for _, name := range changes.Removed {
+			cmd.Printf("discovery: removed %s\n", name)
+		}
+		if len(changes.Changed) > 0 {
+			cmd.Printf("discovery: changed %s\n", strings.Join(changes.Changed, ", "))
+		}
+		logMerged(cmd, changes)
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	sigs = make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	var watch <-chan time.Time
+	modTime := fileModTime(daemonConfig)
+	if daemonWatch {
+		ticker := time.NewTicker(daemonWatchInterval)
+		defer ticker.Stop()
+		watch = ticker.C
+	}
+	var save <-chan time.Time
+	if daemonState != "" {
+		ticker := time.NewTicker(daemonSaveInterval)
+		defer ticker.Stop()
+		save = ticker.C
+	}
+	discovering = startDiscovery(cmd, set)
+	defer func() { discovering.stop() }()
+
+	for {
+		select {
+		case <-hup:
+			reload("SIGHUP")
+		case <-watch:
+			if mt := fileModTime(daemonConfig); !mt.Equal(modTime) {
+				modTime = mt
+				reload("file changed")
+			}
+		case <-discovering.changed:
+			rediscover()
+		case <-save:
+			if err := d.SaveState(); err != nil {
+				cmd.PrintErrf("save state: %v\n", err)
+			}
+		case <-sigs:
+			return d.Stop()
+		}
+	}
+}
+
+// newDiscoverySet creates the discoveries of cfg and lists their instances
+// for the first time. A discovery that fails then starts out empty.
+func newDiscoverySet(cmd *cobra.Command, cfg *config.Config) (*discovery.Set, error) {
+	discoveries, err := expandDiscoveryTokens(cfg.Discovery)
+	if err != nil {
+		return nil, err
+	}
+	set, err := discovery.NewSet(discoveries, newResolver(cfg.Defaults.DNSServers))
+	if err != nil {
+		return nil, err
+	}
+	refreshDiscovery(context.Background(), cmd, set)
+	return set, nil
+}
+
+// expandDiscoveryTokens returns a copy of discoveries with the secret
+// references of their Consul tokens resolved.
+func expandDiscoveryTokens(discoveries []config.Discovery) ([]config.Discovery, error) {
+	expanded := make([]config.Discovery, len(discoveries))
+	for i, d := range discoveries {
+		if d.Consul != nil {
+			consul := *d.Consul
+			token, err := secrets.Expand(consul.Token)
+			if err != nil {
+				return nil, fmt.Errorf("discovery %s: %w", d.Name, err)
+			}
+			consul.Token = token
+			d.Consul = &consul
+		}
+		expanded[i] = d
+	}
+	return expanded, nil
+}
+
+// refreshDiscovery refreshes the discoveries that are due, logs their
+// errors and reports whether any instance was added or removed.
+func refreshDiscovery(ctx context.Context, cmd *cobra.Command, set *discovery.Set) bool {
+	ctx, cancel := context.WithTimeout(ctx, daemonDiscoverTimeout)
+	defer cancel()
+	changed, err := set.Refresh(ctx, time.Now())
+	if err != nil {
+		cmd.PrintErrf("%v\n", err)
+	}
+	return changed
+}
+
+// discoverer refreshes a discovery set in the background, so that a slow
+// registry holds up neither reloads nor signals.
+type discoverer struct {
+	changed chan struct{} // ready when instances were added or removed
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// startDiscovery refreshes the discoveries of set that are due every
+// daemonDiscoverInterval, until stopped.
+func startDiscovery(cmd *cobra.Command, set *discovery.Set) *discoverer {
+	ctx, cancel := context.WithCancel(context.Background())
+	d := &discoverer{cancel: cancel, done: make(chan struct{})}
+	if set.Len() == 0 {
+		close(d.done)
+		return d
+	}
+	d.changed = make(chan struct{}, 1)
+	go func() {
+		defer close(d.done)
+		ticker := time.NewTicker(daemonDiscoverInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if refreshDiscovery(ctx, cmd, set) {
+					select {
+					case d.changed <- struct{}{}:
+					default: // already pending
+					}
+				}
+			}
+		}
+	}()
+	return d
+}
+
+// stop stops the refreshes, cancelling one in progress, and waits for it.
+func (d *discoverer) stop() {
+	d.cancel()
+	<-d.done
+}
+
+// logMerged logs the targets merged into another probing the same endpoint.
+func logMerged(cmd *cobra.Command, changes daemon.Changes) {
+	for _, merged := range changes.Merged {
+		cmd.Printf("merged %s, which probe the same endpoint\n", merged)
+	}
+}
+
+// buildTarget creates the Ping for a configured target using the registered
+// protocol factories.
+func buildTarget(target config.Target, defaults config.Defaults) (*url.URL, pinger.Ping, error) {
+	t, err := newConfigTarget(target, defaults)
+	if err != nil {
+		return nil, nil, err
+	}
+	return t.url, t.ping, nil
+}
+
+// fileModTime returns the modification time of path, or the zero time.
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// initDaemonCommand registers the daemon subcommand.
+func initDaemonCommand() {
+	daemonCmd.Flags().StringVar(&daemonConfig, "config", "", "configuration file with the targets to probe")
+	daemonCmd.Flags().BoolVar(&daemonWatch, "watch", false, "also reload when the configuration file changes")
+	daemonCmd.Flags().StringVar(&daemonState, "state", "", "persist target states to this file and restore them on start")
+	daemonCmd.Flags().BoolVar(&cloudLabels, "cloud-labels", false, "label results with the region, zone and instance ID from the AWS, GCP or Azure metadata service")
+	daemonCmd.Flags().StringVar(&maxBandwidth, "max-bandwidth", "", "bound the traffic of all targets together, such as 50kbps; probes wait for the budget")
+	daemonCmd.Flags().IntVar(&concurrency, "concurrency", defaultConcurrency, "probe at most this many targets at once, the probes of the others waiting for one to return; 0 for no bound")
+	daemonCmd.Flags().StringVar(&daemonSelf, "self-interval", "10s", "probe the prober's own loop health as self://prober this often, 0 to disable")
+	daemonCmd.Flags().StringVar(&daemonSchedule, "schedule", "", `probe targets without a schedule of their own at the times of this crontab schedule, such as "*/5 * * * *", instead of every interval`)
+	daemonCmd.Flags().StringVar(&helperSocket, "helper-socket", helperSocket, "without raw socket privileges, send icmp, arp and ipv6eh probes through the privileged helper listening on this socket")
+	addSinkFlags(daemonCmd.Flags())
+	daemonCmd.MarkFlagRequired("config")
+	RootCmd.AddCommand(daemonCmd)
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"text/tabwriter"
+
+	"github.com/circle-protocol/circle-pinger/diagnose"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Diagnose flags
+	diagnoseTimeout   string
+	diagnoseDNSServer []string
+	diagnoseUDPServer string
+)
+
+// diagnoseCmd checks the path to a target for interfering middleboxes.
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose target",
+	Short: "Check the path to a target for middleboxes interfering with traffic",
+	Long: `Run a battery of checks for middleboxes between this host and a target and
+print a findings report:
+
+  mss  the negotiated MSS is lower than the MTU of the outgoing interface
+       allows, as when a middlebox clamps it
+  ecn  ECN requested by this host (net.ipv4.tcp_ecn = 1) is not negotiated,
+       as when a middlebox strips it
+  tls  the certificate of the target does not verify, or is issued by a
+       known TLS inspection product; https and tls targets are checked on
+       their port, others on 443
+  dns  the resolver returns wrong addresses for names with known answers,
+       or resolves a name that cannot exist
+  udp  a DNS server answers over TCP but not over UDP
+
+Each check reports "ok", "suspect" or "skipped" when it cannot run, such as
+without network access. The command exits 1 if any check is suspect.`,
+	Example: `
+  1. check the path to a web server
+    > circle-pinger diagnose https://example.com
+  2. check through a specific resolver
+    > circle-pinger diagnose example.com:22 --dns-server 10.0.0.53`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runDiagnose,
+}
+
+// runDiagnose runs the checks against the target and prints the findings.
+func runDiagnose(cmd *cobra.Command, args []string) error {
+	timeout, err := utils.ParseDuration(diagnoseTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid --timeout: %w", err)
+	}
+	u, protocol, _, err := parseTarget(args[0], "")
+	if err != nil {
+		return err
+	}
+	cfg := &diagnose.Config{Host: u.Hostname(), Port: 80, TLSPort: 443, DNSServer: diagnoseUDPServer, Timeout: timeout}
+	if port, err := strconv.Atoi(u.Port()); err == nil {
+		cfg.Port = port
+		if protocol == pinger.HTTPS || protocol == pinger.TLS {
+			cfg.TLSPort = port
+		}
+	}
+	if r := newResolver(diagnoseDNSServer); r != nil {
+		cfg.Resolver = r
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	findings := diagnose.Run(ctx, cfg, diagnose.Checks)
+
+	suspect := 0
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tSTATUS\tDETAIL")
+	for _, f := range findings {
+		if f.Status == diagnose.Suspect {
+			suspect++
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", f.Check, f.Status, f.Detail)
+	}
+	tw.Flush()
+	fmt.Fprintln(cmd.OutOrStdout())
+	if suspect > 0 {
+		return fmt.Errorf("%d of %d checks suspect interference on the path to %s", suspect, len(findings), u.Host)
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "no interference found on the path to %s\n", u.Host)
+	return nil
+}
+
+// initDiagnoseCommand registers the diagnose subcommand.
+func initDiagnoseCommand() {
+	diagnoseCmd.Flags().StringVarP(&diagnoseTimeout, "timeout", "T", "5s", "timeout of every check")
+	diagnoseCmd.Flags().StringArrayVarP(&diagnoseDNSServer, "dns-server", "D", nil, "check this resolver for hijacking instead of the system one")
+	diagnoseCmd.Flags().StringVar(&diagnoseUDPServer, "udp-server", diagnose.DefaultDNSServer, "DNS server queried over UDP and TCP to detect UDP blocking")
+	RootCmd.AddCommand(diagnoseCmd)
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net"
+	"net/url"
+	"slices"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// dualStack enables alternating the probes of dual-stack hosts between IPv4
+// and IPv6.
+var dualStack bool
+
+const (
+	// dualStackLoss is the difference in loss from which a family is
+	// considered degraded.
+	dualStackLoss = 0.05
+	// dualStackSlower and dualStackSlowerBy are the ratio and difference of
+	// average latency from which a family is considered slower.
+	dualStackSlower   = 1.2
+	dualStackSlowerBy = 5 * time.Millisecond
+)
+
+// dualStackProtocols are the protocols that can be restricted to a family.
+var dualStackProtocols = []pinger.Protocol{pinger.TCP, pinger.UDP, pinger.HTTP, pinger.HTTPS, pinger.TLS, pinger.H2C}
+
+// withDualStack replaces the Ping of a target probing u with one alternating
+// between IPv4 and IPv6 when --dual-stack is set and the host has addresses
+// of both families. Otherwise ping is returned with a note explaining why.
+func withDualStack(u *url.URL, protocol pinger.Protocol, option *pinger.Option, factory pinger.Factory, ping pinger.Ping) (pinger.Ping, string) {
+	if !dualStack {
+		return ping, ""
+	}
+	if !slices.Contains(dualStackProtocols, protocol) {
+		return ping, fmt.Sprintf("--dual-stack does not support %s", protocol)
+	}
+	if net.ParseIP(u.Hostname()) != nil {
+		return ping, fmt.Sprintf("--dual-stack needs a host name, %s is an address", u.Hostname())
+	}
+
+	resolver := option.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), max(option.Timeout, pinger.DefaultTimeout))
+	defer cancel()
+	pings := make([]pinger.Ping, 2)
+	for i, family := range []string{"ip4", "ip6"} {
+		if ips, err := resolver.LookupIP(ctx, family, u.Hostname()); err != nil || len(ips) == 0 {
+			return ping, fmt.Sprintf("%s has no %s address, probing it as usual", u.Hostname(), family)
+		}
+		familyOption := *option
+		familyOption.Family = family
+		p, err := factory(u, &familyOption)
+		if err != nil {
+			return ping, err.Error()
+		}
+		pings[i] = p
+	}
+	return pinger.DualStack(pings[0], pings[1]), ""
+}
+
+// printDualStack compares the IPv4 and IPv6 results of every dual-stack
+// target, ending with a verdict per target.
+func printDualStack(w io.Writer, samples map[string]map[string]*stats.Sample) {
+	fmt.Fprintln(w, "\nDual-stack comparison")
+	if len(samples) == 0 {
+		fmt.Fprintln(w, "    No dual-stack targets.")
+		return
+	}
+	for _, target := range slices.Sorted(maps.Keys(samples)) {
+		families := samples[target]
+		fmt.Fprintf(w, "    %s\n", target)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "        FAMILY\tLOSS\tAVG\tP95\tPROBES")
+		for _, family := range []string{pinger.FamilyIPv4, pinger.FamilyIPv6} {
+			s := families[family]
+			if s == nil {
+				continue
+			}
+			avg, p95 := "-", "-"
+			if len(s.Durations) > 0 {
+				avg, p95 = s.Mean().String(), s.Percentile(95).String()
+			}
+			fmt.Fprintf(tw, "        %s\t%.1f%%\t%s\t%s\t%d\n", family, s.Loss()*100, avg, p95, s.Total)
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "        Verdict: %s\n", dualStackVerdict(families[pinger.FamilyIPv4], families[pinger.FamilyIPv6]))
+	}
+}
+
+// dualStackVerdict compares the loss, then the average latency, of the two
+// families.
+func dualStackVerdict(v4, v6 *stats.Sample) string {
+	if v4 == nil || v6 == nil {
+		return "not enough probes to compare"
+	}
+	v4Down, v6Down := v4.Failed == v4.Total, v6.Failed == v6.Total
+	switch {
+	case v4Down && v6Down:
+		return "both families fail"
+	case v6Down:
+		return "IPv6 broken, every IPv6 probe failed"
+	case v4Down:
+		return "IPv4 broken, every IPv4 probe failed"
+	case v6.Loss()-v4.Loss() >= dualStackLoss:
+		return fmt.Sprintf("IPv6 degraded, %.1f%% loss against %.1f%% over IPv4", v6.Loss()*100, v4.Loss()*100)
+	case v4.Loss()-v6.Loss() >= dualStackLoss:
+		return fmt.Sprintf("IPv4 degraded, %.1f%% loss against %.1f%% over IPv6", v4.Loss()*100, v6.Loss()*100)
+	}
+	m4, m6 := v4.Mean(), v6.Mean()
+	switch {
+	case float64(m6) > float64(m4)*dualStackSlower && m6-m4 > dualStackSlowerBy:
+		return fmt.Sprintf("IPv6 slower, average %s against %s over IPv4", m6, m4)
+	case float64(m4) > float64(m6)*dualStackSlower && m4-m6 > dualStackSlowerBy:
+		return fmt.Sprintf("IPv4 slower, average %s against %s over IPv6", m4, m6)
+	}
+	return "IPv4 and IPv6 on par"
+}
@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/sink"
+)
+
+// explainDominant is the share from which a phase is said to dominate.
+const explainDominant = 0.4
+
+// explainHints suggest what to look at when a phase dominates.
+var explainHints = map[string]string{
+	"dns":           "DNS dominates: use a closer or caching resolver, or compare with --dns-server",
+	"connect":       "connecting dominates: the server is far away or the network is congested; compare with a tcp:// probe",
+	"tls":           "TLS dominates: check session resumption, the size of the certificate chain and OCSP stapling",
+	"request":       "sending the request dominates: check the upload bandwidth and the size of the request",
+	"wait_response": "the server dominates (time to first byte): look at the application and its backends",
+	"response_body": "the response body dominates: compress or shrink the response, or probe a lighter URL",
+}
+
+// printExplain writes, per target, the average time of every phase ranked
+// longest first with its share, and a hint when one phase dominates.
+func printExplain(w io.Writer, breakdowns []sink.Breakdown) {
+	fmt.Fprintln(w, "\nWhere the time goes")
+	if len(breakdowns) == 0 {
+		fmt.Fprintln(w, "    No successful probes with a phase breakdown; --explain covers http and https.")
+		return
+	}
+	for _, b := range breakdowns {
+		fmt.Fprintf(w, "    %s (average of %d probes, %s)\n", b.Target, b.Probes, b.Total.Round(time.Microsecond))
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		for _, phase := range b.Phases {
+			share := b.Share(phase)
+			bar := strings.TrimRight(fmt.Sprintf("%5.1f%% %s", share*100, strings.Repeat("#", int(share*20+0.5))), " ")
+			fmt.Fprintf(tw, "        %s\t%s\t%s\n", phase.Name, phase.Duration.Round(time.Microsecond), bar)
+		}
+		tw.Flush()
+		if top := b.Phases[0]; b.Share(top) >= explainDominant && explainHints[top.Name] != "" {
+			fmt.Fprintf(w, "        Hint: %s\n", explainHints[top.Name])
+		} else {
+			fmt.Fprintln(w, "        No single phase dominates.")
+		}
+	}
+}
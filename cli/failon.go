@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// failOn selects when a run without verdicts exits non-zero
+var failOn string
+
+// failPolicy decides whether a target failed the run, for the exit code of
+// runs without --max-loss, --max-rtt, --assert or --nagios.
+type failPolicy struct {
+	mode    string  // "any", "all", "loss" or "never"
+	maxLoss float64 // with mode "loss", the loss fraction a target may reach
+}
+
+// parseFailOn parses --fail-on: "any" fails a target losing any probe, "all"
+// one losing every probe, "loss>N%" one losing more than N% of its probes
+// and "never" none.
+func parseFailOn(s string) (*failPolicy, error) {
+	switch s {
+	case "any", "all", "never":
+		return &failPolicy{mode: s}, nil
+	}
+	if v, ok := strings.CutPrefix(s, "loss>"); ok {
+		loss, err := strconv.ParseFloat(strings.TrimSuffix(v, "%"), 64)
+		if err == nil && loss >= 0 && loss < 100 {
+			return &failPolicy{mode: "loss", maxLoss: loss / 100}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --fail-on %q, want any, all, never or loss>N%% such as loss>5%%", s)
+}
+
+// failed reports whether a target with state failed the run. Targets
+// without probes never fail it.
+func (f *failPolicy) failed(state pinger.State) bool {
+	if state.Total == 0 || state.Failed == 0 {
+		return false
+	}
+	switch f.mode {
+	case "any":
+		return true
+	case "all":
+		return state.Failed == state.Total
+	case "loss":
+		return float64(state.Failed)/float64(state.Total) > f.maxLoss
+	}
+	return false
+}
+
+// checkFailures notes every target that failed the run on w and returns the
+// exit code of the run.
+func checkFailures(w io.Writer, f *failPolicy, targets []*target) int {
+	code := exitPass
+	for _, t := range targets {
+		if state := t.pinger.State(); f.failed(state) {
+			fmt.Fprintf(w, "%s: %d of %d probes failed (--fail-on %s)\n", t.url, state.Failed, state.Total, failOn)
+			code = exitFail
+		}
+	}
+	return code
+}
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestParseFailOn(t *testing.T) {
+	for _, tt := range []struct {
+		in      string
+		mode    string
+		maxLoss float64
+	}{
+		{"any", "any", 0},
+		{"all", "all", 0},
+		{"never", "never", 0},
+		{"loss>5%", "loss", 0.05},
+		{"loss>0", "loss", 0},
+		{"loss>12.5%", "loss", 0.125},
+	} {
+		f, err := parseFailOn(tt.in)
+		if err != nil {
+			t.Errorf("parseFailOn(%q): %v", tt.in, err)
+			continue
+		}
+		if f.mode != tt.mode || f.maxLoss != tt.maxLoss {
+			t.Errorf("parseFailOn(%q) = %+v, expected mode %s and loss %v", tt.in, *f, tt.mode, tt.maxLoss)
+		}
+	}
+	for _, in := range []string{"", "some", "loss", "loss>", "loss>x%", "loss>-1%", "loss>100%", "loss<5%"} {
+		if _, err := parseFailOn(in); err == nil {
+			t.Errorf("parseFailOn(%q) succeeded", in)
+		}
+	}
+}
+
+func TestFailPolicy(t *testing.T) {
+	for _, tt := range []struct {
+		policy        string
+		total, failed int
+		want          bool
+	}{
+		{"any", 0, 0, false},
+		{"any", 10, 0, false},
+		{"any", 10, 1, true},
+		{"all", 10, 9, false},
+		{"all", 10, 10, true},
+		{"never", 10, 10, false},
+		{"loss>20%", 10, 2, false},
+		{"loss>20%", 10, 3, true},
+		{"loss>0%", 10, 1, true},
+		{"loss>0%", 0, 0, false},
+	} {
+		f, err := parseFailOn(tt.policy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.failed(pinger.State{Total: tt.total, Failed: tt.failed}); got != tt.want {
+			t.Errorf("--fail-on %s with %d of %d failed: expected %v, got %v", tt.policy, tt.failed, tt.total, tt.want, got)
+		}
+	}
+}
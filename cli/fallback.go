@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"net/url"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/privilege"
+)
+
+// fallback enables probing lower layers of targets that keep failing.
+var fallback bool
+
+// lowerLayers lists, top down, the layers probed below a protocol with
+// --fallback.
+var lowerLayers = map[pinger.Protocol][]pinger.Protocol{
+	pinger.HTTPS:      {pinger.TLS, pinger.TCP, pinger.ICMP},
+	pinger.HTTP:       {pinger.TCP, pinger.ICMP},
+	pinger.H2C:        {pinger.TCP, pinger.ICMP},
+	pinger.TLS:        {pinger.TCP, pinger.ICMP},
+	pinger.SOCKS5:     {pinger.TCP, pinger.ICMP},
+	pinger.SMB:        {pinger.TCP, pinger.ICMP},
+	pinger.TCP:        {pinger.ICMP},
+	pinger.UDP:        {pinger.ICMP},
+	pinger.GAMESERVER: {pinger.ICMP},
+	pinger.RPC:        {pinger.ICMP},
+}
+
+// withFallback wraps the Ping of a target probing u with protocol in a
+// pinger.Fallback when --fallback is set. ICMP is left out when the process
+// may not send it, as its TCP stand-in would only repeat the TCP layer, and
+// so are protocols missing from this build.
+func withFallback(u *url.URL, protocol pinger.Protocol, option *pinger.Option, ping pinger.Ping) pinger.Ping {
+	if !fallback {
+		return ping
+	}
+	var layers []pinger.Layer
+	for _, lower := range lowerLayers[protocol] {
+		if lower == pinger.ICMP && privilege.Detect().Require("icmp") != nil {
+			continue
+		}
+		factory, ok := pinger.Load(lower)
+		if !ok {
+			continue
+		}
+		layerURL := &url.URL{Scheme: lower.String(), Host: u.Host}
+		if lower == pinger.ICMP {
+			layerURL.Host = u.Hostname()
+		}
+		// The layers only report whether they work
+		layerOption := *option
+		layerOption.Meta = false
+		layerOption.Verbose = 0
+		p, err := factory(layerURL, &layerOption)
+		if err != nil {
+			continue
+		}
+		layers = append(layers, pinger.Layer{Name: lower.String(), Ping: p})
+	}
+	return pinger.Fallback(protocol.String(), ping, layers, pinger.FallbackAfter)
+}
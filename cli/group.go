@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// grouping assigns targets to groups for the grouped summary.
+type grouping struct {
+	name string
+	key  func(t *target) string
+}
+
+// noGroup is the group of targets lacking the grouping label.
+const noGroup = "(none)"
+
+// parseGroupBy parses the --group-by flag, returning nil when it is empty.
+func parseGroupBy(s string) (*grouping, error) {
+	switch {
+	case s == "":
+		return nil, nil
+	case s == "protocol":
+		return &grouping{name: "protocol", key: func(t *target) string { return t.protocol.String() }}, nil
+	case strings.HasPrefix(s, "label:") && len(s) > len("label:"):
+		label := strings.TrimPrefix(s, "label:")
+		return &grouping{name: label, key: func(t *target) string {
+			if v, ok := t.labels[label]; ok {
+				return v
+			}
+			return noGroup
+		}}, nil
+	default:
+		return nil, fmt.Errorf(`invalid --group-by %q, want "protocol" or "label:<name>"`, s)
+	}
+}
+
+// groupStats aggregates the statistics of the targets of a group.
+type groupStats struct {
+	targets       int
+	total         int
+	failed        int
+	minDuration   time.Duration
+	maxDuration   time.Duration
+	totalDuration time.Duration
+}
+
+// summarizeGroups writes the statistics of targets aggregated per group.
+func summarizeGroups(w io.Writer, g *grouping, targets []*target) {
+	groups := make(map[string]*groupStats)
+	for _, t := range targets {
+		key := g.key(t)
+		gs, ok := groups[key]
+		if !ok {
+			gs = &groupStats{}
+			groups[key] = gs
+		}
+		state := t.pinger.State()
+		gs.targets++
+		gs.total += state.Total
+		gs.failed += state.Failed
+		gs.totalDuration += state.TotalDuration
+		if state.Total > state.Failed {
+			if gs.minDuration == 0 || state.MinDuration < gs.minDuration {
+				gs.minDuration = state.MinDuration
+			}
+			gs.maxDuration = max(gs.maxDuration, state.MaxDuration)
+		}
+	}
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	fmt.Fprintf(w, "\nStatistics by %s\n", g.name)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "    GROUP\tTARGETS\tPROBES\tLOSS\tMIN\tAVG\tMAX")
+	for _, key := range keys {
+		gs := groups[key]
+		loss := 0.0
+		if gs.total > 0 {
+			loss = float64(gs.failed) / float64(gs.total) * 100
+		}
+		minimum, avg, maximum := "-", "-", "-"
+		if success := gs.total - gs.failed; success > 0 {
+			minimum = gs.minDuration.String()
+			avg = (gs.totalDuration / time.Duration(success)).String()
+			maximum = gs.maxDuration.String()
+		}
+		fmt.Fprintf(tw, "    %s\t%d\t%d\t%.1f%%\t%s\t%s\t%s\n", key, gs.targets, gs.total, loss, minimum, avg, maximum)
+	}
+	tw.Flush()
+}
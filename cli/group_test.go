@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// newGroupTarget returns a target of protocol with labels whose probes have
+// run with the given outcomes.
+func newGroupTarget(rawURL string, protocol pinger.Protocol, labels map[string]string, outcomes ...bool) *target {
+	u, _ := url.Parse(rawURL)
+	t := &target{url: u, protocol: protocol, labels: labels, ping: &scriptedPing{outcomes: outcomes}}
+	t.pinger = pinger.NewPinger(io.Discard, u, t.ping, time.Millisecond, len(outcomes), time.Second)
+	t.pinger.Ping()
+	return t
+}
+
+func TestParseGroupBy(t *testing.T) {
+	targets := []*target{
+		{protocol: pinger.TCP, labels: map[string]string{"region": "eu"}},
+		{protocol: pinger.HTTP},
+	}
+	tests := []struct {
+		in   string
+		name string
+		keys []string
+		err  bool
+	}{
+		{"", "", nil, false},
+		{"protocol", "protocol", []string{"tcp", "http"}, false},
+		{"label:region", "region", []string{"eu", noGroup}, false},
+		{"label:", "", nil, true},
+		{"label", "", nil, true},
+		{"host", "", nil, true},
+	}
+	for _, tt := range tests {
+		g, err := parseGroupBy(tt.in)
+		if (err != nil) != tt.err {
+			t.Errorf("parseGroupBy(%q) error = %v, want error %v", tt.in, err, tt.err)
+			continue
+		}
+		if g == nil {
+			if tt.name != "" {
+				t.Errorf("parseGroupBy(%q) = nil, want grouping by %s", tt.in, tt.name)
+			}
+			continue
+		}
+		if g.name != tt.name {
+			t.Errorf("parseGroupBy(%q) groups by %q, want %q", tt.in, g.name, tt.name)
+		}
+		for i, target := range targets {
+			if key := g.key(target); key != tt.keys[i] {
+				t.Errorf("parseGroupBy(%q) key of target %d = %q, want %q", tt.in, i, key, tt.keys[i])
+			}
+		}
+	}
+}
+
+func TestSummarizeGroups(t *testing.T) {
+	eu := map[string]string{"region": "eu"}
+	targets := []*target{
+		newGroupTarget("tcp://10.0.0.1:80", pinger.TCP, eu, true, true),
+		newGroupTarget("http://10.0.0.2/", pinger.HTTP, eu, true, false),
+		newGroupTarget("tcp://10.0.0.3:80", pinger.TCP, nil, false, false),
+	}
+	tests := []struct {
+		groupBy string
+		want    []string
+	}{
+		{"protocol", []string{
+			"Statistics by protocol",
+			"GROUP TARGETS PROBES LOSS MIN AVG MAX",
+			"http 1 2 50.0% 1ms 1ms 1ms",
+			"tcp 2 4 50.0% 1ms 1ms 1ms",
+		}},
+		{"label:region", []string{
+			"Statistics by region",
+			"GROUP TARGETS PROBES LOSS MIN AVG MAX",
+			"(none) 1 2 100.0% - - -",
+			"eu 2 4 25.0% 1ms 1ms 1ms",
+		}},
+	}
+	for _, tt := range tests {
+		g, err := parseGroupBy(tt.groupBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		summarizeGroups(&buf, g, targets)
+		var got []string
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			got = append(got, strings.Join(strings.Fields(line), " "))
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("--group-by %s:\n%s\nwant:\n%s", tt.groupBy, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
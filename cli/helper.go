@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/circle-protocol/circle-pinger/helper"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/privilege"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// helperSocket is where the privileged helper listens, shared by the root,
+// daemon, serve and helper commands.
+var helperSocket = helper.DefaultSocket()
+
+// helperGroup is the group whose members may use the helper socket.
+var helperGroup string
+
+// helperCmd runs the privileged helper.
+var helperCmd = &cobra.Command{
+	Use:   "helper",
+	Short: "Run ICMP, ARP and IPv6 extension header probes for unprivileged users",
+	Long: `Listen on a local socket and run the probes that need raw sockets on
+behalf of unprivileged circle-pinger processes, so that only the helper needs
+root, CAP_NET_RAW or administrator rights rather than every run.
+
+The helper runs nothing but icmp, arp and ipv6eh probes, each bounded to 30s,
+at most 64 at once and 20 per second for each user. The socket is open to the
+owner and group of the helper only; --helper-group hands it to another group,
+whose members may then probe through the helper. Run it as a systemd service
+with CAP_NET_RAW, a launchd daemon or a scheduled task at startup, as the
+README describes.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runHelper,
+}
+
+// runHelper serves helper clients until SIGINT or SIGTERM.
+func runHelper(cmd *cobra.Command, args []string) error {
+	if caps := privilege.Detect(); !caps.RawSocket {
+		return fmt.Errorf("the helper needs raw socket privileges (root, CAP_NET_RAW or administrator), this process is %s", caps)
+	}
+	if helper.Available(helperSocket) {
+		return fmt.Errorf("a helper already listens on %s", helperSocket)
+	}
+	if err := os.MkdirAll(filepath.Dir(helperSocket), 0755); err != nil {
+		return err
+	}
+	os.Remove(helperSocket) // left behind by a helper that did not exit cleanly
+	ln, err := net.Listen("unix", helperSocket)
+	if err != nil {
+		return err
+	}
+	if err := shareSocket(helperSocket, helperGroup); err != nil {
+		ln.Close()
+		return err
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(stop)
+	go func() {
+		<-stop
+		ln.Close()
+	}()
+
+	cmd.Printf("helper listening on %s\n", helperSocket)
+	server := &helper.Server{Build: func(u *url.URL, op *pinger.Option) (pinger.Ping, error) {
+		protocol, err := pinger.NewProtocol(u.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		factory, err := loadFactory(protocol)
+		if err != nil {
+			return nil, err
+		}
+		return factory(u, op)
+	}}
+	return server.Serve(ln)
+}
+
+// shareSocket opens socket to its owner and to group, or to the group of
+// the helper if group is empty.
+func shareSocket(socket, group string) error {
+	if group != "" {
+		g, err := user.LookupGroup(group)
+		if err != nil {
+			return fmt.Errorf("invalid --helper-group: %w", err)
+		}
+		gid, err := strconv.Atoi(g.Gid)
+		if err != nil {
+			return fmt.Errorf("invalid --helper-group: group ID %q", g.Gid)
+		}
+		if err := os.Chown(socket, -1, gid); err != nil {
+			return err
+		}
+	}
+	return os.Chmod(socket, 0660)
+}
+
+var (
+	// processCaps are the socket privileges of this process.
+	processCaps = sync.OnceValue(privilege.Detect)
+	// helperAvailable reports whether a helper answers on helperSocket,
+	// checked once when first needed.
+	helperAvailable = sync.OnceValue(func() bool { return helper.Available(helperSocket) })
+)
+
+// viaHelper reports whether probes of protocol go through the privileged
+// helper: this process lacks the privileges protocol needs, and a helper
+// answers.
+func viaHelper(protocol pinger.Protocol) bool {
+	if !helper.Privileged(protocol) {
+		return false
+	}
+	if fallback, _ := degrade(protocol, processCaps()); fallback == protocol {
+		return false
+	}
+	return helperAvailable()
+}
+
+// helperFactory creates Pings probing through the privileged helper. The
+// helper builds probes from its own flags, so it refuses the flags of the
+// protocol, such as --arp-interface, rather than silently dropping them.
+func helperFactory(u *url.URL, op *pinger.Option) (pinger.Ping, error) {
+	protocol, err := pinger.NewProtocol(u.Scheme)
+	if err != nil {
+		return nil, err
+	}
+	if flags, ok := protocolFlags[protocol]; ok {
+		var set []string
+		flags.VisitAll(func(flag *pflag.Flag) {
+			if flag.Changed {
+				set = append(set, "--"+flag.Name)
+			}
+		})
+		if len(set) > 0 {
+			return nil, fmt.Errorf("%s cannot be used for %s probes sent through the privileged helper; run with raw socket privileges to use it", strings.Join(set, ", "), protocol)
+		}
+	}
+	return helper.NewPing(helperSocket, u, op), nil
+}
+
+// initHelperCommand registers the helper subcommand.
+func initHelperCommand() {
+	helperCmd.Flags().StringVar(&helperSocket, "socket", helperSocket, "listen on this socket")
+	helperCmd.Flags().StringVar(&helperGroup, "helper-group", "", "let the members of this group use the socket, rather than the group of the helper")
+	RootCmd.AddCommand(helperCmd)
+}
@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/pflag"
+)
+
+func TestHelperFactory_ProtocolFlags(t *testing.T) {
+	saved, registered := protocolFlags[pinger.ARP]
+	defer func() {
+		if registered {
+			protocolFlags[pinger.ARP] = saved
+		} else {
+			delete(protocolFlags, pinger.ARP)
+		}
+	}()
+
+	var iface string
+	flags := pflag.NewFlagSet("arp", pflag.ContinueOnError)
+	flags.StringVar(&iface, "arp-interface", "", "")
+	protocolFlags[pinger.ARP] = flags
+	u, _ := url.Parse("arp://192.0.2.1")
+
+	if _, err := helperFactory(u, &pinger.Option{}); err != nil {
+		t.Fatalf("expected a probe through the helper without protocol flags, got %v", err)
+	}
+	flags.Set("arp-interface", "eth1")
+	flags.Lookup("arp-interface").Changed = true
+	_, err := helperFactory(u, &pinger.Option{})
+	if err == nil || !strings.Contains(err.Error(), "--arp-interface cannot be used for arp probes sent through the privileged helper") {
+		t.Fatalf("expected --arp-interface to be refused, got %v", err)
+	}
+
+	// Protocols without flags of their own are unaffected
+	u, _ = url.Parse("icmp://192.0.2.1")
+	if _, err := helperFactory(u, &pinger.Option{}); err != nil {
+		t.Fatalf("expected icmp through the helper, got %v", err)
+	}
+}
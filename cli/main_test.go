@@ -0,0 +1,13 @@
+package cli
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain registers the protocols and flags as main does, for tests to see
+// the command line of the binary.
+func TestMain(m *testing.M) {
+	Initialize()
+	os.Exit(m.Run())
+}
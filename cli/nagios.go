@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/utils"
+)
+
+var (
+	// Nagios flags
+	nagios         bool
+	nagiosWarning  string
+	nagiosCritical string
+)
+
+// nagiosStatus is a monitoring plugin service state, ordered from best to
+// worst except for UNKNOWN, and doubling as the plugin's exit code.
+type nagiosStatus int
+
+const (
+	nagiosOK nagiosStatus = iota
+	nagiosWarningStatus
+	nagiosCriticalStatus
+	nagiosUnknown
+)
+
+// String returns the label of the status in the plugin output.
+func (s nagiosStatus) String() string {
+	switch s {
+	case nagiosOK:
+		return "OK"
+	case nagiosWarningStatus:
+		return "WARNING"
+	case nagiosCriticalStatus:
+		return "CRITICAL"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// nagiosSeverity ranks the statuses for combining several targets, with
+// UNKNOWN between WARNING and CRITICAL as in most plugins.
+var nagiosSeverity = map[nagiosStatus]int{
+	nagiosOK:             0,
+	nagiosWarningStatus:  1,
+	nagiosUnknown:        2,
+	nagiosCriticalStatus: 3,
+}
+
+// worse returns the more severe of two statuses.
+func (s nagiosStatus) worse(o nagiosStatus) nagiosStatus {
+	if nagiosSeverity[o] > nagiosSeverity[s] {
+		return o
+	}
+	return s
+}
+
+// nagiosThreshold is a --warning or --critical threshold.
+type nagiosThreshold struct {
+	rtt  time.Duration // zero when unset
+	loss float64       // fraction, negative when unset
+}
+
+// parseNagiosThreshold parses a threshold in the check_ping form "RTT,LOSS%",
+// such as "200ms,20%"; either part may be left empty.
+func parseNagiosThreshold(flag, s string) (nagiosThreshold, error) {
+	th := nagiosThreshold{loss: -1}
+	if s == "" {
+		return th, nil
+	}
+	rtt, loss, _ := strings.Cut(s, ",")
+	if rtt != "" {
+		d, err := utils.ParseDuration(rtt)
+		if err != nil {
+			return th, fmt.Errorf("invalid --%s round-trip time: %w", flag, err)
+		}
+		th.rtt = d
+	}
+	if loss != "" {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(loss, "%"), 64)
+		if err != nil || v < 0 || v > 100 {
+			return th, fmt.Errorf(`invalid --%s loss %q, want a percentage such as 20%%`, flag, loss)
+		}
+		th.loss = v / 100
+	}
+	return th, nil
+}
+
+// exceeded reports whether a round-trip time and loss exceed the threshold.
+// ok is false when there was no successful probe to measure rtt.
+func (th nagiosThreshold) exceeded(rtt time.Duration, ok bool, loss float64) bool {
+	if th.rtt > 0 && (!ok || rtt > th.rtt) {
+		return true
+	}
+	return th.loss >= 0 && loss > th.loss
+}
+
+// perfdata formats the warning or critical field of a perfdata value.
+func (th nagiosThreshold) perfdata() (rtt, loss string) {
+	if th.rtt > 0 {
+		rtt = nagiosMillis(th.rtt)
+	}
+	if th.loss >= 0 {
+		loss = strconv.FormatFloat(th.loss*100, 'f', -1, 64)
+	}
+	return rtt, loss
+}
+
+// nagiosMillis formats d in milliseconds for perfdata.
+func nagiosMillis(d time.Duration) string {
+	return strconv.FormatFloat(float64(d)/float64(time.Millisecond), 'f', 3, 64)
+}
+
+// nagiosExit prints an UNKNOWN plugin result for err and exits.
+func nagiosExit(err error) {
+	fmt.Printf("PING %s - %v\n", nagiosUnknown, err)
+	os.Exit(int(nagiosUnknown))
+}
+
+// parseNagiosThresholds parses --warning and --critical.
+func parseNagiosThresholds() (warn, crit nagiosThreshold, err error) {
+	if warn, err = parseNagiosThreshold("warning", nagiosWarning); err != nil {
+		return warn, crit, err
+	}
+	crit, err = parseNagiosThreshold("critical", nagiosCritical)
+	return warn, crit, err
+}
+
+// printNagios prints the single-line plugin output with perfdata for the
+// targets and returns the overall status.
+func printNagios(w io.Writer, warn, crit nagiosThreshold, targets []*target) nagiosStatus {
+	status := nagiosOK
+	var texts, perf []string
+	for _, t := range targets {
+		state := t.pinger.State()
+		if state.Total == 0 {
+			status = status.worse(nagiosUnknown)
+			texts = append(texts, fmt.Sprintf("%s no probes", t.url))
+			continue
+		}
+
+		loss := float64(state.Failed) / float64(state.Total)
+		success := state.Total - state.Failed
+		var rta time.Duration
+		if success > 0 {
+			rta = state.TotalDuration / time.Duration(success)
+		}
+		switch {
+		case crit.exceeded(rta, success > 0, loss):
+			status = status.worse(nagiosCriticalStatus)
+		case warn.exceeded(rta, success > 0, loss):
+			status = status.worse(nagiosWarningStatus)
+		}
+
+		// Name the values after the target when there are several
+		text, rtaLabel, plLabel := "", "rta", "pl"
+		if len(targets) > 1 {
+			text = t.url.String() + " "
+			rtaLabel, plLabel = fmt.Sprintf("'%s rta'", t.url.Host), fmt.Sprintf("'%s pl'", t.url.Host)
+		}
+		rtaText, rtaPerf := "U", "U"
+		if success > 0 {
+			rtaText = rta.Round(time.Microsecond).String()
+			rtaPerf = nagiosMillis(rta) + "ms"
+		}
+		texts = append(texts, fmt.Sprintf("%srta=%s loss=%.1f%%", text, rtaText, loss*100))
+
+		warnRTT, warnLoss := warn.perfdata()
+		critRTT, critLoss := crit.perfdata()
+		perf = append(perf,
+			fmt.Sprintf("%s=%s;%s;%s;0", rtaLabel, rtaPerf, warnRTT, critRTT),
+			fmt.Sprintf("%s=%s%%;%s;%s;0;100", plLabel, strconv.FormatFloat(loss*100, 'f', 1, 64), warnLoss, critLoss),
+		)
+	}
+	fmt.Fprintf(w, "PING %s - %s | %s\n", status, strings.Join(texts, ", "), strings.Join(perf, " "))
+	return status
+}
@@ -0,0 +1,100 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestParseNagiosThreshold(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		rtt  time.Duration
+		loss float64
+	}{
+		{"", 0, -1},
+		{"200ms,20%", 200 * time.Millisecond, 0.2},
+		{"200ms", 200 * time.Millisecond, -1},
+		{",20%", 0, 0.2},
+		{"1s,0%", time.Second, 0},
+		{"1s,100", time.Second, 1},
+	} {
+		th, err := parseNagiosThreshold("warning", tt.in)
+		if err != nil || th.rtt != tt.rtt || th.loss != tt.loss {
+			t.Errorf("parseNagiosThreshold(%q) = %+v, %v", tt.in, th, err)
+		}
+	}
+	for _, in := range []string{"fast", "1s,much", "1s,-1%", "1s,101%"} {
+		if _, err := parseNagiosThreshold("warning", in); err == nil {
+			t.Errorf("parseNagiosThreshold(%q) succeeded", in)
+		}
+	}
+}
+
+// nagiosTarget returns a target whose pinger has the given totals.
+func nagiosTarget(raw string, total, failed int, rta time.Duration) *target {
+	u, _ := url.Parse(raw)
+	p := pinger.NewPinger(io.Discard, u, nil, time.Second, 0, time.Second)
+	p.Restore(pinger.State{Total: total, Failed: failed, TotalDuration: rta * time.Duration(total-failed)})
+	return &target{url: u, pinger: p}
+}
+
+func TestPrintNagios(t *testing.T) {
+	warn, _ := parseNagiosThreshold("warning", "100ms,10%")
+	crit, _ := parseNagiosThreshold("critical", "200ms,50%")
+	for _, tt := range []struct {
+		name          string
+		total, failed int
+		rta           time.Duration
+		want          nagiosStatus
+	}{
+		{"at the warning thresholds", 10, 1, 100 * time.Millisecond, nagiosOK},
+		{"rta above warning", 10, 0, 101 * time.Millisecond, nagiosWarningStatus},
+		{"loss above warning", 10, 2, time.Millisecond, nagiosWarningStatus},
+		{"at the critical thresholds", 10, 5, 200 * time.Millisecond, nagiosWarningStatus},
+		{"rta above critical", 10, 0, 201 * time.Millisecond, nagiosCriticalStatus},
+		{"loss above critical", 10, 6, time.Millisecond, nagiosCriticalStatus},
+		{"no successful probe", 10, 10, 0, nagiosCriticalStatus},
+		{"no probe", 0, 0, 0, nagiosUnknown},
+	} {
+		var out bytes.Buffer
+		got := printNagios(&out, warn, crit, []*target{nagiosTarget("tcp://db:5432", tt.total, tt.failed, tt.rta)})
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s: %s", tt.name, got, tt.want, out.String())
+		}
+	}
+
+	// Exit codes follow the plugin convention
+	for status, code := range map[nagiosStatus]int{nagiosOK: 0, nagiosWarningStatus: 1, nagiosCriticalStatus: 2, nagiosUnknown: 3} {
+		if int(status) != code {
+			t.Errorf("%s exits %d, want %d", status, int(status), code)
+		}
+	}
+}
+
+func TestPrintNagios_Perfdata(t *testing.T) {
+	warn, _ := parseNagiosThreshold("warning", "100ms,10%")
+	crit, _ := parseNagiosThreshold("critical", ",50%")
+	var out bytes.Buffer
+	printNagios(&out, warn, crit, []*target{nagiosTarget("tcp://db:5432", 10, 1, 12500*time.Microsecond)})
+	if want := "PING OK - rta=12.5ms loss=10.0% | rta=12.500ms;100.000;;0 pl=10.0%;10;50;0;100\n"; out.String() != want {
+		t.Errorf("got  %q\nwant %q", out.String(), want)
+	}
+
+	// Several targets name their values after their host, the worst status
+	// winning
+	out.Reset()
+	status := printNagios(&out, warn, crit, []*target{
+		nagiosTarget("tcp://a:80", 4, 0, time.Millisecond),
+		nagiosTarget("tcp://b:80", 4, 4, 0),
+	})
+	want := "PING CRITICAL - tcp://a:80 rta=1ms loss=0.0%, tcp://b:80 rta=U loss=100.0% | " +
+		"'a:80 rta'=1.000ms;100.000;;0 'a:80 pl'=0.0%;10;50;0;100 'b:80 rta'=U;100.000;;0 'b:80 pl'=100.0%;10;50;0;100\n"
+	if status != nagiosCriticalStatus || out.String() != want {
+		t.Errorf("got %s\n%q\nwant\n%q", status, out.String(), want)
+	}
+}
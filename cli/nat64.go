@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/nat64"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// nat64Timeout bounds the discovery of the NAT64 prefix.
+const nat64Timeout = 3 * time.Second
+
+var (
+	// nat64Prefixes are the discovered NAT64 prefixes per set of DNS
+	// servers, so that targets sharing servers look them up once
+	nat64Prefixes   = make(map[string][]netip.Prefix)
+	nat64PrefixesMu sync.Mutex
+)
+
+// translateNAT64 points u at its IPv4 literal host through NAT64 when the
+// host has no IPv4 route, such as on IPv6-only networks, and returns a note
+// with the translation used. The prefix is learnt from the DNS64 resolver of
+// servers. Other targets, and those reached through a proxy, are left
+// unchanged and get no note.
+func translateNAT64(u *url.URL, protocol pinger.Protocol, servers []string, option *pinger.Option) string {
+	v4, err := netip.ParseAddr(u.Hostname())
+	if err != nil || !v4.Is4() || protocol == pinger.ARP || proxied(protocol, option) || nat64.Routable(v4) {
+		return ""
+	}
+	prefixes, err := discoverNAT64(servers, option.Resolver)
+	if err != nil {
+		return fmt.Sprintf("no IPv4 route to %s and no NAT64 to reach it through: %v", v4, err)
+	}
+	v6, err := nat64.Synthesize(prefixes[0], v4)
+	if err != nil {
+		return fmt.Sprintf("no IPv4 route to %s: %v", v4, err)
+	}
+	if port := u.Port(); port != "" {
+		u.Host = net.JoinHostPort(v6.String(), port)
+	} else {
+		u.Host = "[" + v6.String() + "]"
+	}
+	return fmt.Sprintf("no IPv4 route to %s, probing %s through NAT64 prefix %s learnt from %s", v4, v6, prefixes[0], nat64.WellKnownName)
+}
+
+// proxied reports whether probes of protocol connect through a proxy, which
+// reaches the target in their place.
+func proxied(protocol pinger.Protocol, option *pinger.Option) bool {
+	if protocol != pinger.HTTP && protocol != pinger.HTTPS {
+		return false
+	}
+	return option.Proxy != nil || httpProxy != ""
+}
+
+// discoverNAT64 returns the NAT64 prefixes of the DNS64 resolver of servers.
+// Successful discoveries are kept for the other targets.
+func discoverNAT64(servers []string, resolver *net.Resolver) ([]netip.Prefix, error) {
+	key := strings.Join(servers, ",")
+	nat64PrefixesMu.Lock()
+	defer nat64PrefixesMu.Unlock()
+	if prefixes, ok := nat64Prefixes[key]; ok {
+		return prefixes, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), nat64Timeout)
+	defer cancel()
+	var r nat64.Resolver
+	if resolver != nil {
+		r = resolver
+	}
+	prefixes, err := nat64.Discover(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+	nat64Prefixes[key] = prefixes
+	return prefixes, nil
+}
@@ -0,0 +1,183 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// plan describes a probe run as it would be executed, without sending a
+// single probe. It backs the --dry-run flag.
+type plan struct {
+	Target    *url.URL
+	Protocol  pinger.Protocol
+	Addresses []string
+	Source    string
+	Family    string
+	Resolver  string
+	Proxy     string
+	Counter   int
+	Interval  time.Duration
+	Timeout   time.Duration
+	Retries   int
+	Sinks     []string
+
+	option     *pinger.Option
+	resolveErr error
+}
+
+// newPlan builds the plan for a single target from the effective options.
+func newPlan(target *url.URL, protocol pinger.Protocol, op *pinger.Option, counter int, interval, timeout time.Duration) *plan {
+	p := &plan{
+		Target:   target,
+		Protocol: protocol,
+		Resolver: "system",
+		Family:   "any",
+		Counter:  counter,
+		Interval: interval,
+		Timeout:  timeout,
+		Retries:  retries,
+		Sinks:    []string{"stdout"},
+		option:   op,
+	}
+	switch {
+	case dualStack:
+		p.Family = "ip4 and ip6 alternately (--dual-stack)"
+	case op.Family != "":
+		p.Family = op.Family
+	}
+	if len(dnsServer) != 0 {
+		p.Resolver = strings.Join(dnsServer, ",")
+	}
+	if op.Proxy != nil {
+		p.Proxy = op.Proxy.Redacted()
+	}
+	return p
+}
+
+// printPlans writes the plan of every target to w, separated by blank
+// lines, and reports whether the hosts of all of them resolved.
+func printPlans(w io.Writer, targets []*target, counter int, sinks []string) bool {
+	resolved := true
+	for i, t := range targets {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		p := newPlan(t.url, t.protocol, t.option, counter, t.interval, t.option.Timeout)
+		p.Sinks = sinks
+		if err := p.resolve(context.Background(), t.option.Resolver); err != nil {
+			resolved = false
+		}
+		p.Print(w)
+	}
+	return resolved
+}
+
+// resolve looks up the target host with the resolver, IP family and DNS
+// timeout the probe would use, and finds the source address the probes
+// would leave from. The lookup is the only network traffic a dry run
+// generates.
+func (p *plan) resolve(ctx context.Context, resolver *net.Resolver) error {
+	host := p.Target.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		p.Addresses = []string{ip.String()}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, p.Timeout)
+		defer cancel()
+		ips, err := p.option.LookupIP(ctx, resolver, host)
+		if err != nil {
+			p.resolveErr = err
+			return fmt.Errorf("resolve %s failed: %w", host, err)
+		}
+		for _, ip := range ips {
+			p.Addresses = append(p.Addresses, ip.String())
+		}
+	}
+	p.Source = sourceAddr(p.Addresses)
+	return nil
+}
+
+// sourceAddr returns the local address the route to the first of addrs
+// leaves from, or "" when there is none. Connecting a UDP socket selects
+// the route without sending a packet.
+func sourceAddr(addrs []string) string {
+	if len(addrs) == 0 {
+		return ""
+	}
+	conn, err := net.Dial("udp", net.JoinHostPort(addrs[0], "9"))
+	if err != nil {
+		return ""
+	}
+	defer conn.Close()
+	return conn.LocalAddr().(*net.UDPAddr).IP.String()
+}
+
+// Print writes the plan as an aligned key/value listing.
+func (p *plan) Print(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	port := p.Target.Port()
+	if port == "" {
+		port = "-"
+	}
+	addresses := strings.Join(p.Addresses, ", ")
+	if p.resolveErr != nil {
+		addresses = fmt.Sprintf("<unresolved: %v>", p.resolveErr)
+	}
+	counter := fmt.Sprint(p.Counter)
+	if p.Counter <= 0 {
+		counter = "unlimited"
+	}
+	proxy := p.Proxy
+	if proxy == "" {
+		proxy = "none"
+	}
+	source := p.Source
+	switch {
+	case p.Proxy != "":
+		source = "via the proxy"
+	case source == "":
+		source = "-"
+	}
+	resolve := "every probe"
+	if p.option.Pin != nil {
+		resolve = "once, pinning the first address"
+	}
+	dnsTimeout := "within the timeout"
+	if p.option.DNSTimeout > 0 {
+		dnsTimeout = p.option.DNSTimeout.String()
+	}
+	// Probes take between no time at all and the full timeout
+	duration := "unlimited"
+	if p.Counter > 0 {
+		fastest := pinger.Forecast(p.Counter, p.Interval, 0)
+		slowest := pinger.Forecast(p.Counter, p.Interval, p.Timeout)
+		duration = fmt.Sprintf("%s to %s, done by %s at the latest",
+			fastest, slowest, time.Now().Add(slowest).Format(time.TimeOnly))
+	}
+
+	fmt.Fprintf(tw, "Plan for %s (dry run, no probes sent)\n", p.Target.Redacted())
+	fmt.Fprintf(tw, "  protocol:\t%s\n", p.Protocol)
+	fmt.Fprintf(tw, "  host:\t%s\n", p.Target.Hostname())
+	fmt.Fprintf(tw, "  port:\t%s\n", port)
+	fmt.Fprintf(tw, "  addresses:\t%s\n", addresses)
+	fmt.Fprintf(tw, "  family:\t%s\n", p.Family)
+	fmt.Fprintf(tw, "  source:\t%s\n", source)
+	fmt.Fprintf(tw, "  resolver:\t%s\n", p.Resolver)
+	fmt.Fprintf(tw, "  proxy:\t%s\n", proxy)
+	fmt.Fprintf(tw, "  counter:\t%s\n", counter)
+	fmt.Fprintf(tw, "  interval:\t%s\n", p.Interval)
+	fmt.Fprintf(tw, "  timeout:\t%s\n", p.Timeout)
+	fmt.Fprintf(tw, "  resolve:\t%s\n", resolve)
+	fmt.Fprintf(tw, "  dns timeout:\t%s\n", dnsTimeout)
+	fmt.Fprintf(tw, "  retries:\t%d\n", p.Retries)
+	fmt.Fprintf(tw, "  duration:\t%s\n", duration)
+	fmt.Fprintf(tw, "  sinks:\t%s\n", strings.Join(p.Sinks, ", "))
+	return tw.Flush()
+}
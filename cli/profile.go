@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"github.com/spf13/pflag"
+)
+
+// profileName selects a profile of the --config file.
+var profileName string
+
+// applyProfile sets the run options of profile that were not given as flags,
+// as if they had been. The interval and timeout of the run, from the profile
+// or the command line, also replace those of the profile targets, which
+// would otherwise keep their own or the configuration defaults.
+func applyProfile(flags *pflag.FlagSet, profile config.Profile, targets []config.Target) {
+	if profile.Counter != nil && !flags.Changed("counter") {
+		counter = *profile.Counter
+	}
+	if profile.Interval > 0 && !flags.Changed("interval") {
+		interval = profile.Interval.Std().String()
+	}
+	if profile.Timeout > 0 && !flags.Changed("timeout") {
+		timeout = profile.Timeout.Std().String()
+	}
+	if profile.FailOn != "" && !flags.Changed("fail-on") {
+		failOn = profile.FailOn
+	}
+
+	// Invalid durations are reported with the flags
+	for i := range targets {
+		if profile.Interval > 0 || flags.Changed("interval") {
+			if d, err := utils.ParseDuration(interval); err == nil && d > 0 {
+				targets[i].Interval = config.Duration(d)
+			}
+		}
+		if profile.Timeout > 0 || flags.Changed("timeout") {
+			if d, err := utils.ParseDuration(timeout); err == nil && d > 0 {
+				targets[i].Timeout = config.Duration(d)
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/spf13/pflag"
+)
+
+func TestApplyProfile(t *testing.T) {
+	defer func(c int, i, o, f string) { counter, interval, timeout, failOn = c, i, o, f }(counter, interval, timeout, failOn)
+	newFlags := func(args ...string) *pflag.FlagSet {
+		flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+		flags.IntVar(&counter, "counter", 4, "")
+		flags.StringVar(&interval, "interval", "1s", "")
+		flags.StringVar(&timeout, "timeout", "1s", "")
+		flags.StringVar(&failOn, "fail-on", "any", "")
+		if err := flags.Parse(args); err != nil {
+			t.Fatal(err)
+		}
+		return flags
+	}
+	five := 5
+	profile := config.Profile{
+		Counter:  &five,
+		Interval: config.Duration(500 * time.Millisecond),
+		FailOn:   "loss>20%",
+	}
+
+	// The profile interval replaces that of its targets and the defaults
+	cfg := &config.Config{Defaults: config.Defaults{Interval: config.Duration(time.Minute)}}
+	targets := []config.Target{
+		{Name: "web", URL: "https://example.com"},
+		{Name: "db", URL: "tcp://db:5432", Interval: config.Duration(10 * time.Second), Timeout: config.Duration(3 * time.Second)},
+	}
+	applyProfile(newFlags(), profile, targets)
+	if counter != 5 || failOn != "loss>20%" {
+		t.Fatalf("expected the profile options, got counter %d and --fail-on %s", counter, failOn)
+	}
+	for _, target := range targets {
+		if got := target.Resolved(cfg.Defaults).Interval.Std(); got != 500*time.Millisecond {
+			t.Errorf("%s: expected the profile interval, got %s", target.Name, got)
+		}
+	}
+	if targets[1].Timeout.Std() != 3*time.Second {
+		t.Errorf("expected the target timeout without a profile timeout, got %s", targets[1].Timeout.Std())
+	}
+
+	// Flags take precedence over the profile
+	targets = []config.Target{{Name: "web", URL: "https://example.com"}}
+	applyProfile(newFlags("--counter", "2", "--interval", "2s", "--timeout", "300ms"), profile, targets)
+	if counter != 2 || targets[0].Interval.Std() != 2*time.Second || targets[0].Timeout.Std() != 300*time.Millisecond {
+		t.Fatalf("expected the flags to win, got counter %d and target %+v", counter, targets[0])
+	}
+}
@@ -0,0 +1,37 @@
+//go:build !slim && !no_arp
+
+package cli
+
+import (
+	"net/url"
+
+	"github.com/circle-protocol/circle-pinger/arp"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/pflag"
+)
+
+// ARP-specific flags
+var arpInterface string
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerARP)
+}
+
+// registerARP registers the ARP protocol handler and its interface flag.
+func registerARP() {
+	pinger.RegisterSpec(pinger.ARP, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			return arp.New(url.Hostname(), op, arpInterface), nil
+		},
+		Short: "ARP request to a host on the local network",
+		Long: `Send an ARP request for the address of a host on the local network and
+time the reply. It needs raw socket privileges (root or CAP_NET_RAW).
+
+Targets: arp://host
+  circle-pinger arp://192.168.1.1
+  circle-pinger --arp-interface eth1 arp://10.0.0.1`,
+	})
+	RegisterFlags(pinger.ARP, func(flags *pflag.FlagSet) {
+		flags.StringVar(&arpInterface, "arp-interface", "", `Send ARP requests on this interface instead of the one routing to the target.`)
+	})
+}
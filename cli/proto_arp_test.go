@@ -0,0 +1,13 @@
+//go:build slim || no_arp
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestARP_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.ARP, "arp-interface")
+}
@@ -0,0 +1,48 @@
+//go:build !slim && !no_gameserver
+
+package cli
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/circle-protocol/circle-pinger/gameserver"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerGameServer)
+}
+
+// registerGameServer registers the game server protocol handler; the query
+// defaults to Minecraft on its well-known port and to A2S elsewhere.
+func registerGameServer() {
+	pinger.RegisterSpec(pinger.GAMESERVER, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			port, err := strconv.Atoi(url.Port())
+			if err != nil {
+				return nil, err
+			}
+			query := gameserver.A2S
+			if port == gameserver.MinecraftPort {
+				query = gameserver.Minecraft
+			}
+			if q := url.Query().Get("query"); q != "" {
+				if query, err = gameserver.ParseQuery(q); err != nil {
+					return nil, err
+				}
+			}
+			return gameserver.New(url.Hostname(), port, op, query), nil
+		},
+		// Valve A2S; Minecraft servers usually listen on 25565
+		Port:  27015,
+		Short: "Game server status query (Valve A2S or Minecraft)",
+		Long: `Query the status of a game server and time the answer, reporting its
+players and map. The query is Minecraft on port 25565 and Valve A2S
+elsewhere, unless set with ?query=.
+
+Targets: gameserver://host[:port][?query=a2s|minecraft]
+  circle-pinger gameserver://play.example.com:25565
+  circle-pinger "gameserver://203.0.113.7:27016?query=a2s"`,
+	})
+}
@@ -0,0 +1,13 @@
+//go:build slim || no_gameserver
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestGameServer_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.GAMESERVER)
+}
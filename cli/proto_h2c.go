@@ -0,0 +1,40 @@
+//go:build !slim && !no_h2c
+
+package cli
+
+import (
+	"net/url"
+
+	"github.com/circle-protocol/circle-pinger/h2c"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerH2C)
+}
+
+// registerH2C registers the h2c protocol handler, sharing the HTTP method and
+// user agent flags. It is the only protocol that needs golang.org/x/net.
+func registerH2C() {
+	pinger.RegisterSpec(pinger.H2C, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			if op.UA == "" {
+				op.UA = httpUA
+			}
+			method := httpMethod
+			if op.Method != "" {
+				method = op.Method
+			}
+			return h2c.New(method, url, op), nil
+		},
+		Port:  80,
+		Short: "HTTP/2 request without TLS (prior knowledge)",
+		Long: `Send an HTTP/2 request in clear text, without upgrading from HTTP/1.1,
+and time the response. It takes the --http-method and --user-agent flags
+of http.
+
+Targets: h2c://host[:port][/path]
+  circle-pinger h2c://grpc.internal:8080`,
+		SharedFlags: []string{"http-method", "user-agent"},
+	})
+}
@@ -0,0 +1,13 @@
+//go:build slim || no_h2c
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestH2C_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.H2C)
+}
@@ -0,0 +1,41 @@
+//go:build !slim && !no_ipv6eh
+
+package cli
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/ipv6eh"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerIPv6EH)
+}
+
+// registerIPv6EH registers the IPv6 extension header protocol handler; the
+// headers come from the query, e.g. ipv6eh://host?headers=hbh,frag, and
+// default to all of them.
+func registerIPv6EH() {
+	pinger.RegisterSpec(pinger.IPV6EH, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			var headers []ipv6eh.Header
+			if h := url.Query().Get("headers"); h != "" {
+				var err error
+				if headers, err = ipv6eh.ParseHeaders(strings.Split(h, ",")); err != nil {
+					return nil, err
+				}
+			}
+			return ipv6eh.New(url.Hostname(), op, headers), nil
+		},
+		Short: "ICMPv6 echoes carrying IPv6 extension headers",
+		Long: `Send an ICMPv6 echo request without extension headers and one carrying
+each selected header, and report which ones the path drops. It needs raw
+socket privileges (root or CAP_NET_RAW).
+
+Targets: ipv6eh://host[?headers=hbh,dst,frag]
+  circle-pinger ipv6eh://example.com
+  circle-pinger "ipv6eh://example.com?headers=hbh,frag"`,
+	})
+}
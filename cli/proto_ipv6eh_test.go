@@ -0,0 +1,13 @@
+//go:build slim || no_ipv6eh
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestIPv6EH_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.IPV6EH)
+}
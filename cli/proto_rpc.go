@@ -0,0 +1,53 @@
+//go:build !slim && !no_rpc
+
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/rpc"
+)
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerRPC)
+}
+
+// registerRPC registers the RPC protocol handler; the program and version
+// come from the query, e.g. rpc://server?program=mountd&version=3.
+func registerRPC() {
+	pinger.RegisterSpec(pinger.RPC, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			port, err := strconv.Atoi(url.Port())
+			if err != nil {
+				return nil, err
+			}
+			prog := rpc.DefaultProgram
+			query := url.Query()
+			var version uint64
+			if v := query.Get("version"); v != "" {
+				if version, err = strconv.ParseUint(v, 10, 32); err != nil {
+					return nil, fmt.Errorf("invalid RPC version %q", v)
+				}
+				prog.Version = uint32(version)
+			}
+			if name := query.Get("program"); name != "" {
+				if prog, err = rpc.ParseProgram(name, uint32(version)); err != nil {
+					return nil, err
+				}
+			}
+			return rpc.New(url.Hostname(), port, op, prog), nil
+		},
+		Port:  111, // portmapper
+		Short: "ONC RPC call through the portmapper (NFS by default)",
+		Long: `Ask the portmapper for the port of an ONC RPC program, NFS version 3 by
+default, and call its NULL procedure there, which catches services that
+accept connections but no longer answer calls.
+
+Targets: rpc://host[:portmapper port][?program=name|number&version=N]
+  circle-pinger rpc://nfs.internal
+  circle-pinger "rpc://nfs.internal?program=mountd&version=3"`,
+	})
+}
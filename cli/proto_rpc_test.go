@@ -0,0 +1,13 @@
+//go:build slim || no_rpc
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestRPC_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.RPC)
+}
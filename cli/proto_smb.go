@@ -0,0 +1,35 @@
+//go:build !slim && !no_smb
+
+package cli
+
+import (
+	"net/url"
+	"strconv"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/smb"
+)
+
+func init() {
+	optionalProtocols = append(optionalProtocols, registerSMB)
+}
+
+// registerSMB registers the SMB protocol handler.
+func registerSMB() {
+	pinger.RegisterSpec(pinger.SMB, pinger.Spec{
+		Factory: func(url *url.URL, op *pinger.Option) (pinger.Ping, error) {
+			port, err := strconv.Atoi(url.Port())
+			if err != nil {
+				return nil, err
+			}
+			return smb.New(url.Hostname(), port, op), nil
+		},
+		Port:  445,
+		Short: "SMB protocol negotiation",
+		Long: `Negotiate the SMB dialect with a file server and time the answer,
+reporting the dialect.
+
+Targets: smb://host[:port]
+  circle-pinger smb://fileserver`,
+	})
+}
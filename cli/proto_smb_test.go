@@ -0,0 +1,13 @@
+//go:build slim || no_smb
+
+package cli
+
+import (
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestSMB_LeftOut(t *testing.T) {
+	checkLeftOut(t, pinger.SMB)
+}
@@ -0,0 +1,216 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// optionalProtocols register the protocols that can be left out of a build.
+// Each is compiled in unless the slim tag or its own no_<protocol> tag is
+// set, e.g. go build -tags slim or go build -tags no_h2c.
+var optionalProtocols []func()
+
+// loadFactory returns the factory of a protocol, explaining when the
+// protocol exists but was left out of this build.
+func loadFactory(protocol pinger.Protocol) (pinger.Factory, error) {
+	if viaHelper(protocol) {
+		return helperFactory, nil
+	}
+	factory, ok := pinger.Load(protocol)
+	if !ok {
+		return nil, fmt.Errorf("protocol %s is not included in this build, which was built with the slim or no_%s tag", protocol, protocol)
+	}
+	return factory, nil
+}
+
+// flagDefiners define the flags only each protocol uses.
+var flagDefiners = make(map[pinger.Protocol]func(flags *pflag.FlagSet))
+
+// RegisterFlags sets define to define the flags only protocol uses, which
+// Initialize adds to the root command, to the protocol's help and to its
+// subcommand. Protocols registered outside this package call it, like
+// pinger.RegisterNamed, before Initialize.
+func RegisterFlags(protocol pinger.Protocol, define func(flags *pflag.FlagSet)) {
+	flagDefiners[protocol] = define
+}
+
+// protocolFlags holds the flags each registered protocol uses, its own and
+// those it shares with others, which are all flags of the root command.
+var protocolFlags = make(map[pinger.Protocol]*pflag.FlagSet)
+
+// addProtocolFlags defines the flags of every registered protocol on the
+// root command, keeping those each protocol uses for its help and
+// subcommand.
+func addProtocolFlags() {
+	for _, protocol := range pinger.Protocols() {
+		define, ok := flagDefiners[protocol]
+		if !ok {
+			continue
+		}
+		flags := pflag.NewFlagSet(protocol.String(), pflag.ContinueOnError)
+		define(flags)
+		protocolFlags[protocol] = flags
+		RootCmd.Flags().AddFlagSet(flags)
+	}
+
+	// Shared flags are defined by another protocol, which may have been
+	// left out of this build
+	for _, protocol := range pinger.Protocols() {
+		spec, _ := pinger.LoadSpec(protocol)
+		for _, name := range spec.SharedFlags {
+			flag := RootCmd.Flags().Lookup(name)
+			if flag == nil {
+				continue
+			}
+			flags, ok := protocolFlags[protocol]
+			if !ok {
+				flags = pflag.NewFlagSet(protocol.String(), pflag.ContinueOnError)
+				protocolFlags[protocol] = flags
+			}
+			flags.AddFlag(flag)
+		}
+	}
+}
+
+// generalFlags returns the flags of the root command that protocol
+// subcommands take whatever their protocol: all but the flags of protocols,
+// and those selecting targets or printing the version.
+func generalFlags() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("general", pflag.ContinueOnError)
+	RootCmd.Flags().VisitAll(func(flag *pflag.Flag) {
+		switch flag.Name {
+		case "config", "profile", "version":
+			return
+		}
+		for _, pf := range protocolFlags {
+			if pf.Lookup(flag.Name) != nil {
+				return
+			}
+		}
+		flags.AddFlag(flag)
+	})
+	return flags
+}
+
+// withScheme returns the positional arguments of a protocol subcommand as
+// those of the root command: targets without a scheme get the scheme of
+// protocol, and targets with the scheme of another protocol are refused.
+// A numeric second argument of two stays a port, as with splitTargets.
+func withScheme(protocol pinger.Protocol, args []string) ([]string, error) {
+	addrs, port := splitTargets(args)
+	out := make([]string, 0, len(args))
+	for _, addr := range addrs {
+		if scheme, _, ok := strings.Cut(addr, "://"); ok {
+			if p, err := pinger.NewProtocol(scheme); err == nil {
+				if p != protocol {
+					return nil, fmt.Errorf("%s is not a %s target", addr, protocol)
+				}
+				out = append(out, addr)
+				continue
+			}
+		}
+		out = append(out, protocol.String()+"://"+addr)
+	}
+	if port != "" {
+		out = append(out, port)
+	}
+	return out, nil
+}
+
+// protocolsCmd lists the protocols and whether this build includes them.
+var protocolsCmd = &cobra.Command{
+	Use:   "protocols",
+	Short: "List the protocols and whether this build includes them",
+	Long: `List every protocol with its default port, whether this build includes it
+and what it probes. "protocols <protocol>" shows the usage of one, with the
+flags only it uses.
+
+Builds made with -tags slim leave out the optional protocols, which have
+heavier dependencies or narrower use; -tags no_<protocol> leaves out a
+single one.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PROTOCOL\tDEFAULT PORT\tINCLUDED\tDESCRIPTION")
+		for _, protocol := range pinger.KnownProtocols() {
+			port, included, short := "-", "no", "left out of this build"
+			if spec, ok := pinger.LoadSpec(protocol); ok {
+				included, short = "yes", spec.Short
+				if spec.Port != 0 {
+					port = strconv.Itoa(spec.Port)
+				}
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", protocol, port, included, short)
+		}
+		return tw.Flush()
+	},
+}
+
+// protocolUsage returns the help of a registered protocol: its usage, its
+// default port and its flags.
+func protocolUsage(protocol pinger.Protocol, spec pinger.Spec) string {
+	var b strings.Builder
+	b.WriteString(spec.Long)
+	if spec.Port != 0 {
+		fmt.Fprintf(&b, "\n\nDefault port: %d", spec.Port)
+	} else {
+		b.WriteString("\n\nDefault port: none, the protocol has no notion of ports")
+	}
+	if flags, ok := protocolFlags[protocol]; ok {
+		fmt.Fprintf(&b, "\n\nFlags:\n%s", strings.TrimRight(flags.FlagUsages(), "\n"))
+	}
+	return b.String()
+}
+
+// initProtocolCommands registers a subcommand per registered protocol, such
+// as "circle-pinger udp 1.1.1.1", probing targets of that protocol with only
+// the flags it uses besides the general ones. The root command keeps
+// telling the protocol of each target from its scheme.
+func initProtocolCommands() {
+	general := generalFlags()
+	for _, protocol := range pinger.Protocols() {
+		spec, _ := pinger.LoadSpec(protocol)
+		cmd := &cobra.Command{
+			Use:   protocol.String() + " host [port] | target...",
+			Short: spec.Short,
+			Long:  spec.Long,
+			Args:  cobra.ArbitraryArgs,
+			Run: func(cmd *cobra.Command, args []string) {
+				args, err := withScheme(protocol, args)
+				if err != nil {
+					usageExit(cmd, err)
+				}
+				runCommand(cmd, args)
+			},
+		}
+		if flags, ok := protocolFlags[protocol]; ok {
+			cmd.Flags().AddFlagSet(flags)
+		}
+		cmd.Flags().AddFlagSet(general)
+		cmd.SetErr(stderr)
+		RootCmd.AddCommand(cmd)
+	}
+}
+
+// initProtocolsCommand registers the protocols subcommand, with a help topic
+// for every registered protocol.
+func initProtocolsCommand() {
+	for _, protocol := range pinger.Protocols() {
+		spec, _ := pinger.LoadSpec(protocol)
+		protocolsCmd.AddCommand(&cobra.Command{
+			Use:   protocol.String(),
+			Short: spec.Short,
+			Long:  protocolUsage(protocol, spec),
+		})
+	}
+	RootCmd.AddCommand(protocolsCmd)
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestWithScheme(t *testing.T) {
+	for _, tt := range []struct {
+		args []string
+		want []string
+	}{
+		{[]string{"example.com"}, []string{"tcp://example.com"}},
+		{[]string{"example.com", "443"}, []string{"tcp://example.com", "443"}},
+		{[]string{"tcp://a:22", "b:80"}, []string{"tcp://a:22", "tcp://b:80"}},
+	} {
+		got, err := withScheme(pinger.TCP, tt.args)
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("withScheme(tcp, %q) = %q, %v, want %q", tt.args, got, err, tt.want)
+		}
+	}
+
+	// Targets of another protocol are refused
+	if _, err := withScheme(pinger.TCP, []string{"https://example.com"}); err == nil {
+		t.Fatal("expected an https target to be refused by tcp")
+	}
+}
+
+// checkLeftOut checks that protocol, left out of the build by its tag, is
+// neither registered nor on the command line, and that a target of it is
+// refused for that reason.
+func checkLeftOut(t *testing.T, protocol pinger.Protocol, flags ...string) {
+	t.Helper()
+	if _, ok := pinger.Load(protocol); ok || slices.Contains(pinger.Protocols(), protocol) {
+		t.Errorf("%s is registered", protocol)
+	}
+	if _, err := loadFactory(protocol); err == nil || !strings.Contains(err.Error(), "not included in this build") {
+		t.Errorf("loadFactory(%s) error = %v, want the protocol not included", protocol, err)
+	}
+	if _, ok := protocolFlags[protocol]; ok {
+		t.Errorf("%s has protocol flags", protocol)
+	}
+	for _, name := range flags {
+		if RootCmd.Flags().Lookup(name) != nil {
+			t.Errorf("--%s is defined", name)
+		}
+	}
+	for _, cmd := range RootCmd.Commands() {
+		if cmd.Name() == protocol.String() {
+			t.Errorf("%s has a subcommand", protocol)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/circle-protocol/circle-pinger/ratelimit"
+)
+
+var (
+	// probeRate caps the probes of all targets together per second, 0 for
+	// no cap.
+	probeRate float64
+	// flood sends every probe as soon as the previous one returns.
+	flood bool
+	// concurrency bounds the probes of all targets in flight at once,
+	// shared by the root, daemon and serve commands; 0 for no bound.
+	concurrency int
+)
+
+// defaultConcurrency is the default of --concurrency, well within the
+// file descriptor limits of common systems.
+const defaultConcurrency = 256
+
+// newRateLimiter returns the Limiter for --rate, or nil when unset.
+func newRateLimiter() (*ratelimit.Limiter, error) {
+	if probeRate == 0 {
+		return nil, nil
+	}
+	l, err := ratelimit.New(probeRate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --rate: %w", err)
+	}
+	return l, nil
+}
+
+// newConcurrency returns the Concurrency for --concurrency, or nil when
+// unbounded.
+func newConcurrency() (*ratelimit.Concurrency, error) {
+	if concurrency == 0 {
+		return nil, nil
+	}
+	c, err := ratelimit.NewConcurrency(concurrency)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --concurrency: %w", err)
+	}
+	return c, nil
+}
@@ -0,0 +1,177 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/report"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Replay flags
+	replayTargets []string
+	replaySince   string
+	replayUntil   string
+	replayFailed  bool
+)
+
+// replayCmd re-renders and re-summarizes a recorded session.
+var replayCmd = &cobra.Command{
+	Use:   "replay session.jsonl",
+	Short: "Re-render, filter and re-summarize a session recorded with --record",
+	Long: `Replay a session recorded with --record as if it ran again: every probe
+result goes to the outputs selected by the output flags, in any format and
+to any file, and the targets are summarized at the end.
+
+--target, --since, --until and --failed select the results replayed; the
+summaries cover only those. "-" reads the session from stdin.`,
+	Example: `
+  1. summarize a recorded session
+    > circle-pinger replay session.jsonl --format none
+  2. show the failures of one target during an incident
+    > circle-pinger replay session.jsonl --target db-1 --failed --since 2026-10-16T09:00:00Z --until 2026-10-16T10:00:00Z
+  3. export a session to an HdrHistogram log and JSON summaries after the fact
+    > circle-pinger replay session.jsonl --format none --hdr-out session.hlog --summary-format json`,
+	Args:          cobra.ExactArgs(1),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runReplay,
+}
+
+// replayFilter selects the recorded results replayed.
+type replayFilter struct {
+	targets      []string
+	since, until time.Time
+	failed       bool
+}
+
+// parseReplayFilter parses the replay flags.
+func parseReplayFilter() (*replayFilter, error) {
+	f := &replayFilter{targets: replayTargets, failed: replayFailed}
+	for _, bound := range []struct {
+		name, value string
+		t           *time.Time
+	}{{"since", replaySince, &f.since}, {"until", replayUntil, &f.until}} {
+		if bound.value == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, bound.value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --%s %q, want a time such as 2026-10-16T09:00:00Z", bound.name, bound.value)
+		}
+		*bound.t = t
+	}
+	return f, nil
+}
+
+// match reports whether record is replayed.
+func (f *replayFilter) match(record *pinger.Record) bool {
+	if f.failed && record.Stats.Connected {
+		return false
+	}
+	if !f.since.IsZero() && record.Timestamp.Before(f.since) {
+		return false
+	}
+	if !f.until.IsZero() && !record.Timestamp.Before(f.until) {
+		return false
+	}
+	if len(f.targets) == 0 {
+		return true
+	}
+	for _, target := range f.targets {
+		if strings.Contains(record.Target, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// runReplay replays the session to the outputs and prints the summaries.
+func runReplay(cmd *cobra.Command, args []string) error {
+	filter, err := parseReplayFilter()
+	if err != nil {
+		return err
+	}
+	summaryTpl, err := parseSummaryFormat()
+	if err != nil {
+		return err
+	}
+	var in io.Reader = os.Stdin
+	if args[0] != "-" {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+
+	// A replay runs faster than any output, which must not drop records
+	outputBlock = true
+	bus, sinkNames, err := newSinks(stdout, time.Second)
+	if err != nil {
+		return err
+	}
+
+	// Count every result towards a Pinger per target, in the order the
+	// targets first appear
+	var pingers []*pinger.Pinger
+	byTarget := make(map[string]*pinger.Pinger)
+	summary := summaryWriter(stdout, stderr)
+	err = report.Records(in, func(record *pinger.Record) error {
+		if !filter.match(record) {
+			return nil
+		}
+		p, ok := byTarget[record.Target]
+		if !ok {
+			u, err := url.Parse(record.Target)
+			if err != nil {
+				return fmt.Errorf("invalid target %q: %w", record.Target, err)
+			}
+			p = pinger.NewPinger(summary, u, nil, 0, 0, 0)
+			p.SetLabels(record.Labels)
+			p.SetSummaryTemplate(summaryTpl)
+			p.SetHistogram(showHistogram)
+			byTarget[record.Target] = p
+			pingers = append(pingers, p)
+		}
+		p.Add(record.Stats, record.Timestamp)
+
+		// Errors were recorded as shown and are replayed as such
+		record.RawError = true
+		return bus.Write(record)
+	})
+	closeSinks(stderr, bus, sinkNames)
+	if err != nil {
+		return fmt.Errorf("%s: %w", args[0], err)
+	}
+	if len(pingers) == 0 {
+		return fmt.Errorf("%s: no probe results to replay", args[0])
+	}
+
+	if summaryFormat == "json" {
+		return writeJSONSummaries(summary, pingers)
+	}
+	for _, p := range pingers {
+		p.Summarize()
+	}
+	return nil
+}
+
+// initReplayCommand registers the replay subcommand.
+func initReplayCommand() {
+	replayCmd.Flags().StringArrayVar(&replayTargets, "target", nil, "replay only the results of targets whose URL contains this text; repeat for several")
+	replayCmd.Flags().StringVar(&replaySince, "since", "", "replay only the results of probes started at or after this time, e.g. 2026-10-16T09:00:00Z")
+	replayCmd.Flags().StringVar(&replayUntil, "until", "", "replay only the results of probes started before this time")
+	replayCmd.Flags().BoolVar(&replayFailed, "failed", false, "replay only the results of failed probes")
+	replayCmd.Flags().BoolVar(&showHistogram, "histogram", false, "also show a histogram of the trip times and a sparkline of the recent ones in the summary")
+	replayCmd.Flags().StringVar(&summaryFormat, "summary-format", "", `"json" for a single JSON document, or a Go template for the summary of every target, such as '{{.URL}} loss={{percent .Loss}} avg={{.AvgDuration}}'`)
+	addSinkFlags(replayCmd.Flags())
+	RootCmd.AddCommand(replayCmd)
+}
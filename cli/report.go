@@ -0,0 +1,125 @@
+package cli
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/circle-protocol/circle-pinger/report"
+	"github.com/circle-protocol/circle-pinger/stats"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Report flags
+	reportAlpha float64
+)
+
+// reportCmd groups the commands analysing recorded sessions.
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Analyse sessions recorded with --record",
+}
+
+// reportDiffCmd compares two recorded sessions.
+var reportDiffCmd = &cobra.Command{
+	Use:   "diff before.jsonl after.jsonl",
+	Short: "Compare two recorded sessions target by target",
+	Long: `Compare two sessions recorded with --record target by target, for example
+before and after a network change.
+
+Median latency and loss changes are tested for significance (Mann-Whitney U
+test for latency, two-proportion z-test for loss); significant changes are
+flagged so that noise is not mistaken for a regression.`,
+	Example: `
+  1. validate a network change
+    > circle-pinger --config targets.yaml -c 100 --record before.jsonl
+    > circle-pinger --config targets.yaml -c 100 --record after.jsonl
+    > circle-pinger report diff before.jsonl after.jsonl`,
+	Args:          cobra.ExactArgs(2),
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runReportDiff,
+}
+
+// runReportDiff prints the comparison of the two sessions.
+func runReportDiff(cmd *cobra.Command, args []string) error {
+	before, err := report.Load(args[0])
+	if err != nil {
+		return err
+	}
+	after, err := report.Load(args[1])
+	if err != nil {
+		return err
+	}
+
+	significant := 0
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tP50 BEFORE\tP50 AFTER\tCHANGE\tLOSS BEFORE\tLOSS AFTER\tVERDICT")
+	for _, c := range report.Diff(before, after) {
+		switch {
+		case c.Before == nil:
+			fmt.Fprintf(tw, "%s\t-\t%s\t-\t-\t%s\tonly in after\n", c.Target, median(c.After), loss(c.After))
+			continue
+		case c.After == nil:
+			fmt.Fprintf(tw, "%s\t%s\t-\t-\t%s\t-\tonly in before\n", c.Target, median(c.Before), loss(c.Before))
+			continue
+		}
+
+		verdict := "unchanged"
+		if c.LatencyP < reportAlpha || c.LossP < reportAlpha {
+			significant++
+			verdict = ""
+			if c.LatencyP < reportAlpha {
+				verdict = "faster"
+				if c.LatencyShift() > 0 {
+					verdict = "slower"
+				}
+			}
+			if c.LossP < reportAlpha {
+				loss := "less loss"
+				if c.LossShift() > 0 {
+					loss = "more loss"
+				}
+				if verdict != "" {
+					verdict += ", "
+				}
+				verdict += loss
+			}
+			verdict = "* " + verdict
+		}
+		shift := "-"
+		if len(c.Before.Durations) > 0 && len(c.After.Durations) > 0 {
+			shift = c.LatencyShift().String()
+			if c.LatencyShift() > 0 {
+				shift = "+" + shift
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", c.Target,
+			median(c.Before), median(c.After), shift,
+			loss(c.Before), loss(c.After), verdict)
+	}
+	tw.Flush()
+	fmt.Fprintf(cmd.OutOrStdout(), "\n%d targets changed significantly (* p < %g)\n", significant, reportAlpha)
+	return nil
+}
+
+// median formats the median latency of s, or "-" without successful probes.
+func median(s *stats.Sample) string {
+	if len(s.Durations) == 0 {
+		return "-"
+	}
+	return s.Percentile(50).String()
+}
+
+// loss formats the loss rate of s with its 95% confidence interval.
+func loss(s *stats.Sample) string {
+	lo, hi := s.LossCI()
+	return fmt.Sprintf("%.1f%% (%.1f-%.1f%%)", s.Loss()*100, lo*100, hi*100)
+}
+
+// initReportCommands registers the report subcommands.
+func initReportCommands() {
+	reportDiffCmd.Flags().Float64Var(&reportAlpha, "alpha", 0.05, "significance level below which a change is flagged")
+	reportCmd.AddCommand(reportDiffCmd)
+	RootCmd.AddCommand(reportCmd)
+}
@@ -0,0 +1,151 @@
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+const (
+	// resumeCheckpoint is how often the session of a resumable run is saved.
+	resumeCheckpoint = 10 * time.Second
+	// sessionVersion is the version of the session file format.
+	sessionVersion = 1
+	// sessionLabel labels the records of a resumable run with its session.
+	sessionLabel = "session"
+)
+
+// session is a resumable run as checkpointed to the --resume file. The ID
+// stays the same across resumptions, so the records of a week-long campaign
+// form one session wherever they end up.
+type session struct {
+	Version int                     `json:"version"`
+	ID      string                  `json:"id"`
+	Started time.Time               `json:"started"`
+	Saved   time.Time               `json:"saved"`
+	Targets map[string]pinger.State `json:"targets"`
+}
+
+// loadSession reads the session file at path, or starts a new session when
+// there is none.
+func loadSession(path string) (s *session, resumed bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		id := make([]byte, 8)
+		rand.Read(id)
+		return &session{Version: sessionVersion, ID: hex.EncodeToString(id), Started: time.Now()}, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	s = &session{}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, false, fmt.Errorf("%s: %w", path, err)
+	}
+	if s.Version != sessionVersion || s.ID == "" {
+		return nil, false, fmt.Errorf("%s is not a session file of this version", path)
+	}
+	return s, true, nil
+}
+
+// label adds the session label to the targets.
+func (s *session) label(targets []*target) {
+	for _, t := range targets {
+		labels := make(map[string]string, len(t.labels)+1)
+		for k, v := range t.labels {
+			labels[k] = v
+		}
+		labels[sessionLabel] = s.ID
+		t.labels = labels
+	}
+}
+
+// restore seeds the pinger of t with its saved state, matched by URL, and
+// reports whether there was one.
+func (s *session) restore(t *target) bool {
+	state, ok := s.Targets[t.url.String()]
+	if ok {
+		t.pinger.Restore(state)
+	}
+	return ok
+}
+
+// save checkpoints the states of the targets to path atomically.
+func (s *session) save(path string, targets []*target) error {
+	s.Saved = time.Now()
+	s.Targets = make(map[string]pinger.State, len(targets))
+	for _, t := range targets {
+		s.Targets[t.url.String()] = t.pinger.State()
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn file
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// checkpoint saves the session every resumeCheckpoint in the background
+// until the returned stop is called. Stop waits for a save in progress, so
+// that finish has the session and the file to itself.
+func (s *session) checkpoint(path string, targets []*target) (stop func()) {
+	quit := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(resumeCheckpoint)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-quit:
+				return
+			case <-ticker.C:
+				if err := s.save(path, targets); err != nil {
+					fmt.Fprintln(stderr, "resume:", err)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(quit)
+		<-stopped
+	}
+}
+
+// finish saves the session of an interrupted run to path for a later
+// resume, and removes the file of a completed one so that the next run
+// starts a new session.
+func (s *session) finish(path string, targets []*target, completed bool) {
+	if !completed {
+		if err := s.save(path, targets); err != nil {
+			fmt.Fprintln(stderr, "resume:", err)
+			return
+		}
+		fmt.Fprintf(stderr, "session %s saved, continue it with --resume %s\n", s.ID, path)
+		return
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintln(stderr, "resume:", err)
+	}
+}
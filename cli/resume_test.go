@@ -0,0 +1,97 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// flakyPing fails every other probe.
+type flakyPing struct{ n int }
+
+func (f *flakyPing) Ping(ctx context.Context) *pinger.Stats {
+	f.n++
+	if f.n%2 == 0 {
+		return &pinger.Stats{Error: errors.New("timeout"), Duration: time.Millisecond}
+	}
+	return &pinger.Stats{Connected: true, Duration: time.Millisecond}
+}
+
+// newResumeTarget returns a target probing with a flakyPing counter times.
+func newResumeTarget(counter int) *target {
+	u, _ := url.Parse("tcp://192.0.2.1:80")
+	t := &target{url: u, ping: &flakyPing{}}
+	t.pinger = pinger.NewPinger(io.Discard, u, t.ping, time.Millisecond, counter, time.Second)
+	return t
+}
+
+func TestSession(t *testing.T) {
+	stderr = io.Discard
+	path := filepath.Join(t.TempDir(), "session.json")
+
+	s, resumed, err := loadSession(path)
+	if err != nil || resumed || s.ID == "" {
+		t.Fatalf("expected a new session, got %+v, %v, %v", s, resumed, err)
+	}
+	first := newResumeTarget(4)
+	s.label([]*target{first})
+	if first.labels[sessionLabel] != s.ID {
+		t.Fatalf("expected the session label, got %v", first.labels)
+	}
+	first.pinger.Ping()
+
+	// An interrupted run saves its session
+	s.finish(path, []*target{first}, false)
+	restored, resumed, err := loadSession(path)
+	if err != nil || !resumed || restored.ID != s.ID {
+		t.Fatalf("expected session %s to resume, got %+v, %v, %v", s.ID, restored, resumed, err)
+	}
+
+	// The next run continues where the last one stopped
+	next := newResumeTarget(4)
+	if !restored.restore(next) {
+		t.Fatal("expected the target state to be restored")
+	}
+	if state := next.pinger.State(); state.Total != 4 || state.Failed != 2 {
+		t.Fatalf("expected 4 probes with 2 failed restored, got %d with %d failed", state.Total, state.Failed)
+	}
+	u, _ := url.Parse("tcp://192.0.2.2:80")
+	if restored.restore(&target{url: u}) {
+		t.Fatal("expected no state for an unknown target")
+	}
+
+	// A completed run removes its session
+	restored.finish(path, []*target{next}, true)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected the session file to be removed, got %v", err)
+	}
+
+	// A file of another format is refused
+	os.WriteFile(path, []byte(`{"version": 99, "id": "x"}`), 0644)
+	if _, _, err := loadSession(path); err == nil {
+		t.Fatal("expected a session of another version to be refused")
+	}
+}
+
+func TestSession_Checkpoint(t *testing.T) {
+	stderr = io.Discard
+	path := filepath.Join(t.TempDir(), "session.json")
+	s, _, _ := loadSession(path)
+	targets := []*target{newResumeTarget(2)}
+	targets[0].pinger.Ping()
+
+	// Stopping the checkpoints leaves the file to finish
+	stop := s.checkpoint(path, targets)
+	stop()
+	s.finish(path, targets, true)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected no session file after a completed run, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package cli
+
+import (
+	"io"
+	"os"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/secret"
+)
+
+// secrets resolves the secret:// references of targets, proxies, webhooks
+// and discovery tokens, and redacts the values it resolved from the output.
+var secrets = secret.NewStore()
+
+// stdout and stderr are where commands print, with secrets redacted.
+var (
+	stdout io.Writer = secrets.Writer(os.Stdout)
+	stderr io.Writer = secrets.Writer(os.Stderr)
+)
+
+// defineSecrets defines the secrets of cfg, so that the references of its
+// targets resolve from their sources.
+func defineSecrets(cfg *config.Config) {
+	for name, s := range cfg.Secrets {
+		secrets.Define(name, s.Source())
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/daemon"
+	"github.com/circle-protocol/circle-pinger/exporter"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Serve flags
+	serveListen string
+)
+
+// serveCmd runs the daemon and exposes the results as Prometheus metrics.
+var serveCmd = &cobra.Command{
+	Use:   "serve --config config.yaml",
+	Short: "Continuously probe configured targets and export Prometheus metrics",
+	Long: `Continuously probe every target of a configuration file, like daemon, and
+serve the results as Prometheus metrics on /metrics:
+
+  probe_success                    whether the last probe succeeded
+  probe_duration_seconds{phase}    last probe duration, total and per phase
+  probe_ssl_earliest_cert_expiry   certificate expiry of TLS targets
+  probe_last_timestamp_seconds     when the last probe started
+  probe_delivery_lag_seconds       how long the last result took to reach the exporter
+  probes_total, probe_failures_total
+
+Every series carries the target URL and its configured labels. The heartbeat
+target self://prober reports the prober's own health: its
+probe_duration_seconds phases schedule_delay and goroutine_delay, and its
+probe_last_timestamp_seconds going stale, tell a wedged prober apart from
+unreachable targets. Per-probe
+output is off unless --format is given.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE:          runServe,
+}
+
+// runServe starts the metrics server and runs the daemon.
+func runServe(cmd *cobra.Command, args []string) error {
+	if !cmd.Flags().Changed("format") {
+		outputFormat = "none"
+	}
+
+	exp := exporter.New()
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exp)
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("circle-pinger exporter, metrics are on /metrics\n"))
+	})
+	ln, err := net.Listen("tcp", serveListen)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			cmd.PrintErrln("serve:", err)
+		}
+	}()
+	defer srv.Close()
+	cmd.Printf("serving metrics on http://%s/metrics\n", ln.Addr())
+
+	// Drop the series of targets removed by a reload
+	return runDaemonWith(cmd, []pinger.Sink{exp}, func(d *daemon.Daemon) {
+		exp.Retain(d.Targets())
+	})
+}
+
+// initServeCommand registers the serve subcommand.
+func initServeCommand() {
+	serveCmd.Flags().StringVar(&serveListen, "listen", ":9115", "address to serve metrics on")
+	serveCmd.Flags().StringVar(&daemonConfig, "config", "", "configuration file with the targets to probe")
+	serveCmd.Flags().BoolVar(&daemonWatch, "watch", false, "also reload when the configuration file changes")
+	serveCmd.Flags().StringVar(&daemonState, "state", "", "persist target states to this file and restore them on start")
+	serveCmd.Flags().BoolVar(&cloudLabels, "cloud-labels", false, "label results with the region, zone and instance ID from the AWS, GCP or Azure metadata service")
+	serveCmd.Flags().StringVar(&maxBandwidth, "max-bandwidth", "", "bound the traffic of all targets together, such as 50kbps; probes wait for the budget")
+	serveCmd.Flags().IntVar(&concurrency, "concurrency", defaultConcurrency, "probe at most this many targets at once, the probes of the others waiting for one to return; 0 for no bound")
+	serveCmd.Flags().StringVar(&daemonSelf, "self-interval", "10s", "probe the prober's own loop health as self://prober this often, 0 to disable")
+	serveCmd.Flags().StringVar(&daemonSchedule, "schedule", "", `probe targets without a schedule of their own at the times of this crontab schedule, such as "*/5 * * * *", instead of every interval`)
+	serveCmd.Flags().StringVar(&helperSocket, "helper-socket", helperSocket, "without raw socket privileges, send icmp, arp and ipv6eh probes through the privileged helper listening on this socket")
+	addSinkFlags(serveCmd.Flags())
+	serveCmd.MarkFlagRequired("config")
+	RootCmd.AddCommand(serveCmd)
+}
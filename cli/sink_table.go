@@ -0,0 +1,33 @@
+//go:build !slim && !no_table
+
+package cli
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/secret"
+	"github.com/circle-protocol/circle-pinger/sink"
+)
+
+func init() {
+	tableSink = func(out io.Writer, interval time.Duration) pinger.Sink {
+		return sink.NewTable(out, interval, isTerminal(out))
+	}
+}
+
+// isTerminal reports whether w, or the writer it redacts, is a terminal, on
+// which a table can be redrawn in place.
+func isTerminal(w io.Writer) bool {
+	if r, ok := w.(*secret.Writer); ok {
+		w = r.Unwrap()
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,23 @@
+//go:build slim || no_table
+
+package cli
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTable_LeftOut(t *testing.T) {
+	defer func(format string) { outputFormat = format }(outputFormat)
+	outputFormat = "table"
+
+	if tableSink != nil {
+		t.Error("the live table is included")
+	}
+	_, _, err := newSinks(io.Discard, time.Second)
+	if err == nil || !strings.Contains(err.Error(), "--format table is not included in this build") {
+		t.Errorf("--format table error = %v, want the table not included", err)
+	}
+}
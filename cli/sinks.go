@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/sink"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"github.com/spf13/pflag"
+)
+
+var (
+	// Output flags, shared by the root and daemon commands
+	outputFormat string
+	recordPath   string
+	statsdAddr   string
+	outputBlock  bool
+	logPath      string
+	logMaxSize   string
+	logMaxFiles  int
+	hdrPath      string
+	webhookURL   string
+	webhookOn    string
+	sampleOutput string
+
+	// Summary flags, for the root and replay commands
+	summaryFormat string
+	showHistogram bool
+)
+
+// tableSink creates the live table of --format table, redrawn in place on a
+// terminal. It is nil when the slim or no_table tag leaves the table out.
+var tableSink func(out io.Writer, interval time.Duration) pinger.Sink
+
+// addSinkFlags registers the output flags on flags.
+func addSinkFlags(flags *pflag.FlagSet) {
+	flags.StringVar(&outputFormat, "format", "text", `per-probe output format on stdout, "text", "json", "table", "none" or a Go template such as '{{.Timestamp}} {{.Duration}} {{.Meta.status}}'`)
+	flags.StringVar(&recordPath, "record", "", "also append every probe result as JSON lines to this file")
+	flags.StringVar(&logPath, "log-file", "", "also append every probe result as JSON lines to this file, rotated by size")
+	flags.StringVar(&logMaxSize, "log-max-size", "100MB", "with --log-file, rotate the file once it would grow past this size, e.g. 10MB")
+	flags.IntVar(&logMaxFiles, "log-max-files", 5, "with --log-file, keep this many rotated files as <file>.1 (newest) to <file>.N")
+	flags.StringVar(&hdrPath, "hdr-out", "", "write the latency distribution of every target to this file in HdrHistogram log format at exit")
+	flags.StringVar(&statsdAddr, "statsd", "", "also send probe metrics to this statsd host:port over UDP")
+	flags.StringVar(&webhookURL, "webhook-url", "", "also POST a JSON event to this URL for the probes selected by --webhook-on")
+	flags.StringVar(&webhookOn, "webhook-on", "change", `with --webhook-url, post "failure" for failed probes, "change" for probes changing a target between up and down, or "all"`)
+	flags.StringVar(&sampleOutput, "sample-output", "", `send only one in n probe results such as "1/100" to stdout, --record, --log-file and --statsd; statistics, tables, --hdr-out and webhooks still see every probe`)
+	flags.BoolVar(&outputBlock, "output-block", false, "wait for slow outputs instead of dropping their results, delaying probes")
+}
+
+// newSinks creates the bus delivering probe records to every sink selected
+// by the output flags and to extra, and returns the names of the sinks
+// selected by the flags for display. A table on out is refreshed every
+// interval.
+func newSinks(out io.Writer, interval time.Duration, extra ...pinger.Sink) (*sink.Bus, []string, error) {
+	var (
+		sinks []pinger.Sink
+		names []string
+	)
+	fail := func(err error) (*sink.Bus, []string, error) {
+		for _, s := range sinks {
+			s.Close()
+		}
+		return nil, nil, err
+	}
+
+	// Heavy outputs of every probe may get only a sample of the records
+	sample := 1
+	if sampleOutput != "" {
+		n, err := sink.ParseSample(sampleOutput)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --sample-output: %w", err)
+		}
+		sample = n
+	}
+	sampled := func(s pinger.Sink, name string) {
+		if sample > 1 {
+			s = sink.NewSample(s, sample)
+			name += fmt.Sprintf(" sampled 1/%d", sample)
+		}
+		sinks = append(sinks, s)
+		names = append(names, name)
+	}
+
+	switch outputFormat {
+	case "text":
+		sampled(sink.NewText(out), "stdout (text)")
+	case "json":
+		sampled(sink.NewJSON(out), "stdout (json)")
+	case "none":
+	case "table":
+		if tableSink == nil {
+			return nil, nil, fmt.Errorf("--format table is not included in this build, which was built with the slim or no_table tag")
+		}
+		sinks = append(sinks, tableSink(out, interval))
+		names = append(names, "stdout (table)")
+	default:
+		if !strings.Contains(outputFormat, "{{") {
+			return nil, nil, fmt.Errorf("unknown output format %q", outputFormat)
+		}
+		tpl, err := pinger.ParseTemplate("format", outputFormat)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid --format template: %w", err)
+		}
+		sampled(sink.NewTemplate(out, tpl), "stdout (template)")
+	}
+	if recordPath != "" {
+		r, err := sink.NewRecorder(recordPath)
+		if err != nil {
+			return fail(fmt.Errorf("record: %w", err))
+		}
+		sampled(r, "record "+recordPath)
+	}
+	if logPath != "" {
+		maxSize, err := utils.ParseSize(logMaxSize)
+		if err != nil {
+			return fail(fmt.Errorf("invalid --log-max-size: %w", err))
+		}
+		l, err := sink.NewLog(logPath, maxSize, logMaxFiles)
+		if err != nil {
+			return fail(fmt.Errorf("log file: %w", err))
+		}
+		sampled(l, "log "+logPath)
+	}
+	if hdrPath != "" {
+		h, err := sink.NewHDR(hdrPath)
+		if err != nil {
+			return fail(fmt.Errorf("hdr: %w", err))
+		}
+		sinks = append(sinks, h)
+		names = append(names, "hdr "+hdrPath)
+	}
+	if statsdAddr != "" {
+		s, err := sink.NewStatsd(statsdAddr)
+		if err != nil {
+			return fail(fmt.Errorf("statsd: %w", err))
+		}
+		s.SetSample(sample)
+		sampled(s, "statsd "+statsdAddr)
+	}
+	if webhookURL != "" {
+		mode, err := sink.ParseWebhookMode(webhookOn)
+		if err != nil {
+			return fail(fmt.Errorf("invalid --webhook-on: %w", err))
+		}
+		hook, err := secrets.Expand(webhookURL)
+		if err != nil {
+			return fail(fmt.Errorf("--webhook-url: %w", err))
+		}
+		if u, err := url.Parse(hook); err != nil || u.Scheme != "http" && u.Scheme != "https" {
+			return fail(fmt.Errorf("invalid --webhook-url %q, want an http or https URL", webhookURL))
+		}
+		sinks = append(sinks, sink.NewWebhook(hook, mode))
+		names = append(names, fmt.Sprintf("webhook %s (%s)", webhookURL, mode))
+	}
+	sinks = append(sinks, extra...)
+
+	// Secrets resolved into target URLs or quoted by errors reach no output
+	for i, s := range sinks {
+		sinks[i] = sink.NewRedact(s, secrets.Redact)
+	}
+	policy := sink.Drop
+	if outputBlock {
+		policy = sink.Block
+	}
+	return sink.NewBus(sink.DefaultBuffer, policy, sinks...), names, nil
+}
+
+// parseSummaryFormat parses --summary-format, returning nil for the default
+// summary and for JSON summaries, which writeJSONSummaries writes instead.
+func parseSummaryFormat() (*template.Template, error) {
+	if summaryFormat == "" || summaryFormat == "json" {
+		return nil, nil
+	}
+	tpl, err := pinger.ParseTemplate("summary", summaryFormat)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --summary-format template: %w", err)
+	}
+	return tpl, nil
+}
+
+// writeJSONSummaries writes the summaries of every pinger as a single JSON
+// document, for --summary-format json.
+func writeJSONSummaries(w io.Writer, pingers []*pinger.Pinger) error {
+	doc := struct {
+		Targets []pinger.Summary `json:"targets"`
+		Traffic pinger.Traffic   `json:"traffic"`
+	}{Targets: make([]pinger.Summary, 0, len(pingers))}
+	for _, p := range pingers {
+		summary := p.Summary()
+		doc.Targets = append(doc.Targets, summary)
+		doc.Traffic.Add(summary.Traffic)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// printRunTraffic prints the estimated traffic of all targets together, when
+// there are several.
+func printRunTraffic(w io.Writer, targets []*target) {
+	if len(targets) < 2 {
+		return
+	}
+	var total pinger.Traffic
+	for _, t := range targets {
+		total.Add(t.pinger.Summary().Traffic)
+	}
+	fmt.Fprintf(w, "\nEstimated traffic of the run: %s\n", total)
+}
+
+// closeSinks flushes and closes the bus, reporting sink errors and the
+// results dropped for outputs that could not keep up.
+func closeSinks(w io.Writer, bus *sink.Bus, names []string) {
+	if err := bus.Close(); err != nil {
+		fmt.Fprintln(w, "output:", err)
+	}
+	for i, n := range bus.Dropped() {
+		if n > 0 {
+			fmt.Fprintf(w, "output: %s could not keep up, %d results dropped\n", names[i], n)
+		}
+	}
+}
+
+// summaryWriter returns where summaries and runtime errors go: stdout, unless
+// it carries JSON records that they would corrupt.
+func summaryWriter(stdout, stderr io.Writer) io.Writer {
+	if outputFormat == "json" {
+		return stderr
+	}
+	return stdout
+}
+
+// notifyCompletion shows a desktop notification with the headline
+// statistics of a completed run, for runs left detached such as in tmux.
+func notifyCompletion(targets []*target) {
+	summaries := make([]pinger.Summary, 0, len(targets))
+	for _, t := range targets {
+		summaries = append(summaries, t.pinger.Summary())
+	}
+	if err := sink.Desktop("circle-pinger: run complete", sink.Headline(summaries)); err != nil {
+		fmt.Fprintln(stderr, "notify:", err)
+	}
+}
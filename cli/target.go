@@ -0,0 +1,136 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/circle-protocol/circle-pinger/dnscache"
+	"github.com/circle-protocol/circle-pinger/helper"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/privilege"
+	"github.com/circle-protocol/circle-pinger/utils"
+)
+
+// parseTarget parses a target address into its URL and protocol, resolving
+// its secret references. port, when not empty, overrides the port of the
+// address. Privileged protocols the process cannot use are degraded, and
+// note explains why.
+func parseTarget(addr, port string) (u *url.URL, protocol pinger.Protocol, note string, err error) {
+	expanded, err := secrets.Expand(addr)
+	if err != nil {
+		return nil, 0, "", err
+	}
+	u, err = utils.ParseAddress(expanded)
+	if err != nil || u.Hostname() == "" {
+		return nil, 0, "", fmt.Errorf("%s is an invalid target", addr)
+	}
+
+	// Determine protocol
+	protocol, err = pinger.NewProtocol(u.Scheme)
+	if err != nil {
+		return nil, 0, "", fmt.Errorf("invalid protocol %w", err)
+	}
+
+	// Determine port, the default one of the protocol when the target has
+	// none
+	if port == "" {
+		port = u.Port()
+	}
+	if port == "" {
+		port = defaultPort(protocol)
+	}
+
+	// Degrade privileged modes to an unprivileged probe instead of failing,
+	// unless the privileged helper can send them
+	if fallback, reason := degrade(protocol, processCaps()); fallback != protocol {
+		if viaHelper(protocol) {
+			note = fmt.Sprintf("%s, probing through the helper at %s", reason, helperSocket)
+		} else {
+			note = fmt.Sprintf("%s, falling back to %s", reason, fallback)
+			protocol = fallback
+			u.Scheme = protocol.String()
+		}
+	}
+
+	// ICMP, ARP and IPv6 extension header probes have no notion of ports,
+	// every other protocol needs one
+	if !portless(protocol) {
+		n, err := strconv.Atoi(port)
+		if err != nil {
+			return nil, 0, "", fmt.Errorf("%s is invalid port", port)
+		}
+		u.Host = net.JoinHostPort(u.Hostname(), strconv.Itoa(n))
+	}
+	return u, protocol, note, nil
+}
+
+// degrade returns the protocol to probe with in place of a privileged one the
+// process cannot use, along with a note explaining why. Protocols that need no
+// privileges are returned unchanged.
+func degrade(protocol pinger.Protocol, caps privilege.Capabilities) (pinger.Protocol, string) {
+	switch protocol {
+	case pinger.ARP, pinger.IPV6EH:
+		if caps.RawSocket {
+			return protocol, ""
+		}
+		note := fmt.Sprintf("%s requires raw socket privileges (root or CAP_NET_RAW)", protocol)
+		if caps.CanICMP() {
+			return pinger.ICMP, note
+		}
+		return pinger.TCP, note
+	case pinger.ICMP:
+		if err := caps.Require("icmp"); err != nil {
+			return pinger.TCP, err.Error()
+		}
+	}
+	return protocol, ""
+}
+
+// defaultPort returns the port registered for protocol, or 80 for protocols
+// without one, which only matters when they fall back to a protocol with
+// ports.
+func defaultPort(protocol pinger.Protocol) string {
+	if spec, ok := pinger.LoadSpec(protocol); ok && spec.Port != 0 {
+		return strconv.Itoa(spec.Port)
+	}
+	return "80"
+}
+
+// portless reports whether protocol has no notion of ports. Protocols left
+// out of this build can still be probed through the privileged helper, all
+// of which are portless.
+func portless(protocol pinger.Protocol) bool {
+	if spec, ok := pinger.LoadSpec(protocol); ok {
+		return spec.Port == 0
+	}
+	return helper.Privileged(protocol)
+}
+
+// newResolver returns a resolver querying the given DNS servers in order, or
+// nil to use the system resolver when servers is empty. Battery-aware runs
+// get a resolver sharing a DNS cache.
+func newResolver(servers []string) *net.Resolver {
+	var dial dnscache.DialFunc
+	if len(servers) != 0 {
+		dial = func(ctx context.Context, network, address string) (conn net.Conn, err error) {
+			for _, addr := range servers {
+				if conn, err = net.Dial("udp", addr+":53"); err != nil {
+					continue
+				} else {
+					return conn, nil
+				}
+			}
+			return
+		}
+	}
+	if batteryAware {
+		return dnsCache(servers, dial)
+	}
+	if dial == nil {
+		return nil
+	}
+	return &net.Resolver{PreferGo: true, Dial: dial}
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// ranked is a target with its sample, as listed by printTop.
+type ranked struct {
+	target string
+	sample *stats.Sample
+}
+
+// printTop lists the n worst targets by loss and by p95 latency. Ties are
+// broken by the other metric, then by target, to keep the order stable.
+func printTop(w io.Writer, n int, samples map[string]*stats.Sample) {
+	all := make([]ranked, 0, len(samples))
+	for target, sample := range samples {
+		all = append(all, ranked{target, sample})
+	}
+	n = min(n, len(all))
+
+	slices.SortFunc(all, func(a, b ranked) int {
+		return cmp.Or(
+			cmp.Compare(b.sample.Loss(), a.sample.Loss()),
+			cmp.Compare(b.sample.Percentile(95), a.sample.Percentile(95)),
+			cmp.Compare(a.target, b.target),
+		)
+	})
+	fmt.Fprintf(w, "\nWorst %d targets by loss\n", n)
+	printRanked(w, all[:n])
+
+	// Targets without a successful probe have no latency to rank
+	withLatency := slices.DeleteFunc(slices.Clone(all), func(r ranked) bool { return len(r.sample.Durations) == 0 })
+	slices.SortFunc(withLatency, func(a, b ranked) int {
+		return cmp.Or(
+			cmp.Compare(b.sample.Percentile(95), a.sample.Percentile(95)),
+			cmp.Compare(b.sample.Loss(), a.sample.Loss()),
+			cmp.Compare(a.target, b.target),
+		)
+	})
+	withLatency = withLatency[:min(n, len(withLatency))]
+	fmt.Fprintf(w, "\nWorst %d targets by p95 latency\n", len(withLatency))
+	printRanked(w, withLatency)
+}
+
+// printRanked writes one aligned line per target.
+func printRanked(w io.Writer, targets []ranked) {
+	if len(targets) == 0 {
+		fmt.Fprintln(w, "    No probes completed successfully.")
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "    TARGET\tLOSS\tP95\tAVG\tPROBES")
+	for _, r := range targets {
+		p95, avg := "-", "-"
+		if len(r.sample.Durations) > 0 {
+			p95 = r.sample.Percentile(95).String()
+			avg = r.sample.Mean().String()
+		}
+		fmt.Fprintf(tw, "    %s\t%.1f%%\t%s\t%s\t%d\n", r.target, r.sample.Loss()*100, p95, avg, r.sample.Total)
+	}
+	tw.Flush()
+}
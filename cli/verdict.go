@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+	"github.com/circle-protocol/circle-pinger/utils"
+)
+
+var (
+	// Verdict flags
+	maxLoss    string
+	maxRTT     string
+	assertSpec string
+	minSamples int
+)
+
+// Exit codes of a run with verdicts, matching the monitoring plugin
+// convention where 3 is UNKNOWN.
+const (
+	exitPass         = 0
+	exitFail         = 1
+	exitInconclusive = 3
+)
+
+// verdict is the outcome of judging a target against the thresholds,
+// ordered from best to worst.
+type verdict int
+
+const (
+	verdictPass verdict = iota
+	verdictInconclusive
+	verdictFail
+)
+
+// String returns the label printed for the verdict.
+func (v verdict) String() string {
+	switch v {
+	case verdictPass:
+		return "PASS"
+	case verdictFail:
+		return "FAIL"
+	default:
+		return "INCONCLUSIVE"
+	}
+}
+
+// exitCode returns the process exit code for the verdict.
+func (v verdict) exitCode() int {
+	switch v {
+	case verdictPass:
+		return exitPass
+	case verdictFail:
+		return exitFail
+	default:
+		return exitInconclusive
+	}
+}
+
+// thresholds are the SLA a target must meet to pass.
+type thresholds struct {
+	assertions []assertion
+	minSamples int
+}
+
+// assertion is a condition on a statistic of a target, such as p95<200ms.
+type assertion struct {
+	metric string        // loss, avg, min, max or a percentile such as p95
+	op     string        // <, <=, > or >=
+	loss   float64       // limit of loss, as a fraction
+	rtt    time.Duration // limit of the other metrics
+	limit  string        // limit as printed
+}
+
+// assertionPattern matches a single assertion of --assert.
+var assertionPattern = regexp.MustCompile(`^(loss|avg|min|max|p[0-9]+(?:\.[0-9]+)?)\s*(<=|>=|<|>)\s*(\S+)$`)
+
+// parseAssertion parses an assertion such as "loss<5%" or "p95<200ms".
+func parseAssertion(s string) (assertion, error) {
+	m := assertionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return assertion{}, fmt.Errorf(`invalid assertion %q, want a metric (loss, avg, min, max, p50, p95...), an operator (<, <=, >, >=) and a limit, such as "p95<200ms"`, s)
+	}
+	a := assertion{metric: m[1], op: m[2]}
+	if a.metric == "loss" {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(m[3], "%"), 64)
+		if err != nil || !strings.HasSuffix(m[3], "%") || v < 0 || v > 100 {
+			return assertion{}, fmt.Errorf("invalid assertion %q, want the loss as a percentage such as 5%%", s)
+		}
+		a.loss, a.limit = v/100, fmt.Sprintf("%.1f%%", v)
+		return a, nil
+	}
+	if p, ok := a.percentile(); ok && (p <= 0 || p > 100) {
+		return assertion{}, fmt.Errorf("invalid assertion %q, want a percentile such as p50 or p99.9, above p0 and at most p100", s)
+	}
+	d, err := utils.ParseDuration(m[3])
+	if err != nil {
+		return assertion{}, fmt.Errorf("invalid assertion %q: %w", s, err)
+	}
+	a.rtt, a.limit = d, d.String()
+	return a, nil
+}
+
+// percentile returns the percentile of a pNN metric, and false for the
+// other metrics.
+func (a assertion) percentile() (float64, bool) {
+	if !strings.HasPrefix(a.metric, "p") {
+		return 0, false
+	}
+	p, err := strconv.ParseFloat(a.metric[1:], 64)
+	return p, err == nil
+}
+
+// holds reports whether value meets the assertion against limit.
+func holds[T int64 | float64](op string, value, limit T) bool {
+	switch op {
+	case "<":
+		return value < limit
+	case "<=":
+		return value <= limit
+	case ">":
+		return value > limit
+	default:
+		return value >= limit
+	}
+}
+
+// negations are the operators printed when an assertion does not hold.
+var negations = map[string]string{"<": ">=", "<=": ">", ">": "<=", ">=": "<"}
+
+// check judges the assertion against the statistics of a target, returning
+// whether it holds and the reason. Percentiles are those of the last
+// pinger.DurationHistory successful probes, which the reason notes when the
+// run had more.
+func (a assertion) check(summary pinger.Summary) (bool, string) {
+	var (
+		ok     bool
+		value  string
+		window string
+	)
+	if a.metric == "loss" {
+		ok, value = holds(a.op, summary.Loss, a.loss), fmt.Sprintf("%.1f%%", summary.Loss*100)
+	} else {
+		if summary.SuccessTotal == 0 {
+			return false, fmt.Sprintf("%s without successful probes", a.metric)
+		}
+		var d time.Duration
+		switch a.metric {
+		case "avg":
+			d = summary.AvgDuration
+		case "min":
+			d = summary.MinDuration
+		case "max":
+			d = summary.MaxDuration
+		default:
+			p, _ := a.percentile()
+			sorted := slices.Clone(summary.Durations)
+			slices.Sort(sorted)
+			d = stats.Percentile(sorted, p)
+			if len(sorted) < summary.SuccessTotal {
+				window = fmt.Sprintf(" of the last %d", len(sorted))
+			}
+		}
+		ok, value = holds(a.op, int64(d), int64(a.rtt)), d.String()
+	}
+	op := a.op
+	if !ok {
+		op = negations[a.op]
+	}
+	return ok, fmt.Sprintf("%s %s %s %s%s", a.metric, value, op, a.limit, window)
+}
+
+// parseThresholds parses the verdict flags. It returns nil when no threshold
+// is set, in which case no verdict is given. --max-loss and --max-rtt are
+// shorthands for the assertions loss<=N% and avg<=D.
+func parseThresholds() (*thresholds, error) {
+	if maxLoss == "" && maxRTT == "" && assertSpec == "" {
+		if minSamples > 0 {
+			return nil, fmt.Errorf("--min-samples needs --max-loss, --max-rtt or --assert")
+		}
+		return nil, nil
+	}
+	th := &thresholds{minSamples: minSamples}
+	if maxLoss != "" {
+		v, err := strconv.ParseFloat(strings.TrimSuffix(maxLoss, "%"), 64)
+		if err != nil || v < 0 || v > 100 {
+			return nil, fmt.Errorf("invalid --max-loss %q, want a percentage such as 5%%", maxLoss)
+		}
+		th.assertions = append(th.assertions, assertion{metric: "loss", op: "<=", loss: v / 100, limit: fmt.Sprintf("%.1f%%", v)})
+	}
+	if maxRTT != "" {
+		d, err := utils.ParseDuration(maxRTT)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --max-rtt: %w", err)
+		}
+		th.assertions = append(th.assertions, assertion{metric: "avg", op: "<=", rtt: d, limit: d.String()})
+	}
+	if assertSpec != "" {
+		for _, s := range strings.Split(assertSpec, ",") {
+			a, err := parseAssertion(s)
+			if err != nil {
+				return nil, err
+			}
+			th.assertions = append(th.assertions, a)
+		}
+	}
+	return th, nil
+}
+
+// judge gives the verdict for a target's statistics and the reasons for it.
+// Until minSamples probes have completed the verdict is inconclusive, so
+// that a handful of probes cannot pass or fail an SLA by chance.
+func (th *thresholds) judge(summary pinger.Summary) (verdict, string) {
+	if summary.Total < th.minSamples {
+		return verdictInconclusive, fmt.Sprintf("%d of %d required samples", summary.Total, th.minSamples)
+	}
+	if summary.Total == 0 {
+		return verdictInconclusive, "no samples"
+	}
+
+	v := verdictPass
+	reasons := make([]string, 0, len(th.assertions))
+	for _, a := range th.assertions {
+		ok, reason := a.check(summary)
+		if !ok {
+			v = verdictFail
+		}
+		reasons = append(reasons, reason)
+	}
+	return v, strings.Join(reasons, ", ")
+}
+
+// printVerdicts judges every target, prints the verdicts and returns the
+// worst one.
+func printVerdicts(w io.Writer, th *thresholds, targets []*target) verdict {
+	worst := verdictPass
+	fmt.Fprintln(w)
+	for _, t := range targets {
+		v, reason := th.judge(t.pinger.Summary())
+		fmt.Fprintf(w, "Verdict %s: %s (%s)\n", t.url, v, reason)
+		worst = max(worst, v)
+	}
+	return worst
+}
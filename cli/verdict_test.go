@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestParseAssertion(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		metric string
+		op     string
+		loss   float64
+		rtt    time.Duration
+	}{
+		{"loss<5%", "loss", "<", 0.05, 0},
+		{" loss >= 0.5% ", "loss", ">=", 0.005, 0},
+		{"avg<=100ms", "avg", "<=", 0, 100 * time.Millisecond},
+		{"min>1ms", "min", ">", 0, time.Millisecond},
+		{"max<2s", "max", "<", 0, 2 * time.Second},
+		{"p95<200ms", "p95", "<", 0, 200 * time.Millisecond},
+		{"p99.9<=1s", "p99.9", "<=", 0, time.Second},
+		{"p100<1s", "p100", "<", 0, time.Second},
+	} {
+		a, err := parseAssertion(tt.in)
+		if err != nil {
+			t.Errorf("parseAssertion(%q): %v", tt.in, err)
+			continue
+		}
+		if a.metric != tt.metric || a.op != tt.op || a.loss != tt.loss || a.rtt != tt.rtt {
+			t.Errorf("parseAssertion(%q) = %+v", tt.in, a)
+		}
+	}
+	for _, in := range []string{"", "loss", "loss<5", "loss<101%", "loss<-1%", "avg<5%", "avg=5ms", "avg<fast", "p0<1s", "p101<1s", "jitter<1ms", "p95 200ms"} {
+		if a, err := parseAssertion(in); err == nil {
+			t.Errorf("parseAssertion(%q) = %+v, want an error", in, a)
+		}
+	}
+}
+
+func TestHolds(t *testing.T) {
+	for _, tt := range []struct {
+		op           string
+		value, limit int64
+		want         bool
+	}{
+		{"<", 1, 2, true}, {"<", 2, 2, false},
+		{"<=", 2, 2, true}, {"<=", 3, 2, false},
+		{">", 3, 2, true}, {">", 2, 2, false},
+		{">=", 2, 2, true}, {">=", 1, 2, false},
+	} {
+		if got := holds(tt.op, tt.value, tt.limit); got != tt.want {
+			t.Errorf("%d %s %d: got %v", tt.value, tt.op, tt.limit, got)
+		}
+	}
+}
+
+func TestAssertionCheck(t *testing.T) {
+	durations := make([]time.Duration, 100)
+	for i := range durations {
+		durations[i] = time.Duration(i+1) * time.Millisecond
+	}
+	summary := pinger.Summary{
+		Total:        110,
+		SuccessTotal: 100,
+		FailedTotal:  10,
+		Loss:         10.0 / 110,
+		MinDuration:  time.Millisecond,
+		MaxDuration:  100 * time.Millisecond,
+		AvgDuration:  50 * time.Millisecond,
+		Durations:    durations,
+	}
+	for _, tt := range []struct {
+		assertion string
+		ok        bool
+		reason    string
+	}{
+		{"loss<10%", true, "loss 9.1% < 10.0%"},
+		{"loss<5%", false, "loss 9.1% >= 5.0%"},
+		{"avg<=50ms", true, "avg 50ms <= 50ms"},
+		{"avg<50ms", false, "avg 50ms >= 50ms"},
+		{"min>=1ms", true, "min 1ms >= 1ms"},
+		{"max<100ms", false, "max 100ms >= 100ms"},
+		{"p95<200ms", true, "p95 95ms < 200ms"},
+		{"p50>60ms", false, "p50 50ms <= 60ms"},
+	} {
+		a, err := parseAssertion(tt.assertion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok, reason := a.check(summary); ok != tt.ok || reason != tt.reason {
+			t.Errorf("%s: got %v (%s), want %v (%s)", tt.assertion, ok, reason, tt.ok, tt.reason)
+		}
+	}
+
+	// Percentiles cover the durations kept, which the reason notes
+	summary.SuccessTotal = 5000
+	a, _ := parseAssertion("p95<200ms")
+	if ok, reason := a.check(summary); !ok || reason != "p95 95ms < 200ms of the last 100" {
+		t.Errorf("got %v (%s), want the window noted", ok, reason)
+	}
+
+	// Latencies fail without successful probes
+	a, _ = parseAssertion("avg<1s")
+	if ok, _ := a.check(pinger.Summary{Total: 3, FailedTotal: 3, Loss: 1}); ok {
+		t.Error("expected avg to fail without successful probes")
+	}
+}
+
+func TestThresholds_MinSamples(t *testing.T) {
+	defer func(l, r, a string, m int) { maxLoss, maxRTT, assertSpec, minSamples = l, r, a, m }(maxLoss, maxRTT, assertSpec, minSamples)
+
+	// --min-samples alone gives no verdict to defer
+	maxLoss, maxRTT, assertSpec, minSamples = "", "", "", 10
+	if _, err := parseThresholds(); err == nil {
+		t.Fatal("expected --min-samples without thresholds to be refused")
+	}
+
+	failing := pinger.Summary{Total: 5, SuccessTotal: 2, FailedTotal: 3, Loss: 0.6, AvgDuration: time.Second, Durations: []time.Duration{time.Second, time.Second}}
+	passing := pinger.Summary{Total: 10, SuccessTotal: 10, AvgDuration: time.Millisecond, MinDuration: time.Millisecond, MaxDuration: time.Millisecond, Durations: []time.Duration{time.Millisecond}}
+	for _, tt := range []struct {
+		name              string
+		loss, rtt, assert string
+		min               int
+		summary           pinger.Summary
+		want              verdict
+		code              int
+	}{
+		{"--max-loss too few samples", "1%", "", "", 10, failing, verdictInconclusive, exitInconclusive},
+		{"--max-rtt too few samples", "", "5ms", "", 10, failing, verdictInconclusive, exitInconclusive},
+		{"--assert too few samples", "", "", "p95<5ms", 10, failing, verdictInconclusive, exitInconclusive},
+		{"all of them too few samples", "1%", "5ms", "p95<5ms", 6, failing, verdictInconclusive, exitInconclusive},
+		{"enough samples failing", "1%", "5ms", "p95<5ms", 5, failing, verdictFail, exitFail},
+		{"enough samples passing", "1%", "5ms", "p95<5ms", 10, passing, verdictPass, exitPass},
+		{"no samples without --min-samples", "1%", "", "", 0, pinger.Summary{}, verdictInconclusive, exitInconclusive},
+	} {
+		maxLoss, maxRTT, assertSpec, minSamples = tt.loss, tt.rtt, tt.assert, tt.min
+		th, err := parseThresholds()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		v, reason := th.judge(tt.summary)
+		if v != tt.want || v.exitCode() != tt.code {
+			t.Errorf("%s: got %s (%s) exiting %d, want %s exiting %d", tt.name, v, reason, v.exitCode(), tt.want, tt.code)
+		}
+	}
+}
+
+func TestPrintVerdicts(t *testing.T) {
+	th := &thresholds{minSamples: 4}
+	a, _ := parseAssertion("loss<50%")
+	th.assertions = append(th.assertions, a)
+
+	// An inconclusive target outranks a passing one, a failing one both
+	pass := nagiosTarget("tcp://a:80", 4, 0, time.Millisecond)
+	short := nagiosTarget("tcp://b:80", 2, 0, time.Millisecond)
+	fail := nagiosTarget("tcp://c:80", 4, 4, 0)
+	var out bytes.Buffer
+	if v := printVerdicts(&out, th, []*target{pass, short}); v != verdictInconclusive || v.exitCode() != 3 {
+		t.Fatalf("got %s, want INCONCLUSIVE exiting 3:\n%s", v, out.String())
+	}
+	if !strings.Contains(out.String(), "Verdict tcp://b:80: INCONCLUSIVE (2 of 4 required samples)") {
+		t.Fatalf("expected the missing samples in the verdict:\n%s", out.String())
+	}
+	if v := printVerdicts(io.Discard, th, []*target{short, fail, pass}); v != verdictFail {
+		t.Fatalf("got %s, want FAIL", v)
+	}
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// Commit and BuildDate describe the build; main sets them from linker
+	// flags, and the module build info fills them in otherwise.
+	Commit    = "unknown"
+	BuildDate = "unknown"
+
+	// Version flags
+	versionJSON bool
+)
+
+// sinkKinds are the outputs probe results can be sent to.
+var sinkKinds = []string{"text", "json", "table", "template", "record", "statsd", "prometheus"}
+
+// buildInfo is the build and feature information of the binary.
+type buildInfo struct {
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit"`
+	BuildDate string   `json:"build_date"`
+	GoVersion string   `json:"go_version"`
+	Platform  string   `json:"platform"`
+	Protocols []string `json:"protocols"`
+	Sinks     []string `json:"sinks"`
+}
+
+// versionCmd prints the build information.
+var versionCmd = &cobra.Command{
+	Use:   "version [--json]",
+	Short: "Print the version, build and enabled protocols",
+	Long: `Print the version, commit, build date and Go version of the binary, with the
+protocols and outputs it was built with. --json prints the same as a JSON
+object for inventory and support tooling.`,
+	Args:          cobra.NoArgs,
+	SilenceUsage:  true,
+	SilenceErrors: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return printVersion(os.Stdout, cmd.Root().Version, versionJSON)
+	},
+}
+
+// currentBuild returns the build information of the running binary, whose
+// version is set at build time.
+func currentBuild(version string) buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+		Sinks:     sinkKinds,
+	}
+	if info.Version == "" {
+		info.Version = "dev"
+	}
+
+	// Binaries built with go install or go build carry their VCS state
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" {
+			info.Version = bi.Main.Version
+		}
+		for _, s := range bi.Settings {
+			switch {
+			case s.Key == "vcs.revision" && info.Commit == "unknown":
+				info.Commit = s.Value
+			case s.Key == "vcs.time" && info.BuildDate == "unknown":
+				info.BuildDate = s.Value
+			}
+		}
+	}
+
+	for _, p := range pinger.Protocols() {
+		info.Protocols = append(info.Protocols, p.String())
+	}
+	return info
+}
+
+// printVersion prints the build information as text or JSON.
+func printVersion(w io.Writer, version string, asJSON bool) error {
+	info := currentBuild(version)
+	if asJSON {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(info)
+	}
+	_, err := fmt.Fprintf(w, `version:    %s
+commit:     %s
+built:      %s
+go:         %s (%s)
+protocols:  %s
+sinks:      %s
+`, info.Version, info.Commit, info.BuildDate, info.GoVersion, info.Platform,
+		strings.Join(info.Protocols, ", "), strings.Join(info.Sinks, ", "))
+	return err
+}
+
+// initVersionCommand registers the version subcommand.
+func initVersionCommand() {
+	// --version, handled by cobra, prints the same as the subcommand
+	cobra.AddTemplateFunc("buildInfo", func(version string) string {
+		var b strings.Builder
+		printVersion(&b, version, false)
+		return b.String()
+	})
+	RootCmd.SetVersionTemplate("{{buildInfo .Version}}")
+	versionCmd.Flags().BoolVar(&versionJSON, "json", false, "print the build information as JSON")
+	RootCmd.AddCommand(versionCmd)
+}
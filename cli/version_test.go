@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrintVersion(t *testing.T) {
+	defer func(commit, date string) { Commit, BuildDate = commit, date }(Commit, BuildDate)
+	Commit, BuildDate = "0123abc", "2026-01-02T03:04:05Z"
+
+	var buf bytes.Buffer
+	if err := printVersion(&buf, "1.2.3", false); err != nil {
+		t.Fatal(err)
+	}
+	fields := map[string]string{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		key, value, _ := strings.Cut(line, ":")
+		fields[key] = strings.TrimSpace(value)
+	}
+	want := map[string]string{
+		"version": "1.2.3",
+		"commit":  "0123abc",
+		"built":   "2026-01-02T03:04:05Z",
+		"go":      runtime.Version() + " (" + runtime.GOOS + "/" + runtime.GOARCH + ")",
+		"sinks":   strings.Join(sinkKinds, ", "),
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("%s = %q, want %q", key, fields[key], value)
+		}
+	}
+	if !slices.Contains(strings.Split(fields["protocols"], ", "), "tcp") {
+		t.Errorf("protocols = %q, want tcp among them", fields["protocols"])
+	}
+
+	buf.Reset()
+	if err := printVersion(&buf, "1.2.3", true); err != nil {
+		t.Fatal(err)
+	}
+	var info buildInfo
+	if err := json.Unmarshal(buf.Bytes(), &info); err != nil {
+		t.Fatalf("--json output is not JSON: %v\n%s", err, buf.String())
+	}
+	if info.Version != "1.2.3" || info.Commit != "0123abc" || info.BuildDate != "2026-01-02T03:04:05Z" ||
+		info.GoVersion != runtime.Version() || !slices.Equal(info.Sinks, sinkKinds) || !slices.Contains(info.Protocols, "tcp") {
+		t.Errorf("unexpected --json build information: %+v", info)
+	}
+}
+
+func TestPrintVersion_Dev(t *testing.T) {
+	var buf bytes.Buffer
+	if err := printVersion(&buf, "", false); err != nil {
+		t.Fatal(err)
+	}
+	// Test binaries carry no module version, so an unset version shows dev
+	if !strings.HasPrefix(buf.String(), "version:    dev\n") {
+		t.Errorf("unexpected output for an unset version:\n%s", buf.String())
+	}
+}
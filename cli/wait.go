@@ -0,0 +1,24 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// printWait notes on w whether each target came up during a --wait run that
+// took elapsed, and returns the exit code of the run: exitPass when all of
+// them did.
+func printWait(w io.Writer, targets []*target, elapsed time.Duration) int {
+	code := exitPass
+	for _, t := range targets {
+		state := t.pinger.State()
+		if state.Total > state.Failed {
+			fmt.Fprintf(w, "%s is up, probe %d succeeded\n", t.url, state.Total)
+			continue
+		}
+		fmt.Fprintf(w, "%s did not come up within %s, %d probes failed\n", t.url, elapsed.Round(time.Millisecond), state.Failed)
+		code = exitFail
+	}
+	return code
+}
@@ -0,0 +1,176 @@
+// Package cloud detects the placement of the prober from the instance
+// metadata service of AWS, GCP or Azure, so that results of many agents can
+// be told apart by region and zone.
+package cloud
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Labels set from the instance metadata.
+const (
+	LabelCloud    = "cloud"
+	LabelRegion   = "region"
+	LabelZone     = "zone"
+	LabelInstance = "instance_id"
+)
+
+// Detector queries the metadata services. The endpoints are the link-local
+// addresses of the providers unless overridden, as in tests.
+type Detector struct {
+	Client *http.Client
+	AWS    string
+	GCP    string
+	Azure  string
+}
+
+// DefaultDetector queries the real metadata services.
+var DefaultDetector = &Detector{
+	Client: http.DefaultClient,
+	AWS:    "http://169.254.169.254",
+	GCP:    "http://metadata.google.internal",
+	Azure:  "http://169.254.169.254",
+}
+
+// Detect returns the labels of the instance the prober runs on. The
+// providers are queried concurrently and the first to answer wins, so ctx
+// should carry a short timeout: off the cloud, none ever answers.
+func (d *Detector) Detect(ctx context.Context) (map[string]string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		labels map[string]string
+		err    error
+	}
+	providers := []func(context.Context) (map[string]string, error){d.aws, d.gcp, d.azure}
+	results := make(chan result, len(providers))
+	for _, detect := range providers {
+		go func() {
+			labels, err := detect(ctx)
+			results <- result{labels, err}
+		}()
+	}
+	var errs []error
+	for range providers {
+		r := <-results
+		if r.err == nil {
+			return r.labels, nil
+		}
+		errs = append(errs, r.err)
+	}
+	return nil, fmt.Errorf("no cloud metadata service: %w", errors.Join(errs...))
+}
+
+// aws reads the instance identity document with an IMDSv2 session token.
+func (d *Detector) aws(ctx context.Context) (map[string]string, error) {
+	token, err := d.get(ctx, http.MethodPut, d.AWS+"/latest/api/token", map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	body, err := d.get(ctx, http.MethodGet, d.AWS+"/latest/dynamic/instance-identity/document", map[string]string{"X-aws-ec2-metadata-token": string(token)})
+	if err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	var doc struct {
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		InstanceID       string `json:"instanceId"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("aws: %w", err)
+	}
+	return labels("aws", doc.Region, doc.AvailabilityZone, doc.InstanceID), nil
+}
+
+// gcp reads the instance metadata; the region is the zone without its
+// suffix.
+func (d *Detector) gcp(ctx context.Context) (map[string]string, error) {
+	body, err := d.get(ctx, http.MethodGet, d.GCP+"/computeMetadata/v1/instance/?recursive=true", map[string]string{"Metadata-Flavor": "Google"})
+	if err != nil {
+		return nil, fmt.Errorf("gcp: %w", err)
+	}
+	var doc struct {
+		ID   json.Number `json:"id"`
+		Zone string      `json:"zone"` // projects/<number>/zones/<zone>
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("gcp: %w", err)
+	}
+	zone := doc.Zone[strings.LastIndex(doc.Zone, "/")+1:]
+	region := zone
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		region = zone[:i]
+	}
+	return labels("gcp", region, zone, doc.ID.String()), nil
+}
+
+// azure reads the compute metadata of the virtual machine.
+func (d *Detector) azure(ctx context.Context) (map[string]string, error) {
+	body, err := d.get(ctx, http.MethodGet, d.Azure+"/metadata/instance/compute?api-version=2021-02-01", map[string]string{"Metadata": "true"})
+	if err != nil {
+		return nil, fmt.Errorf("azure: %w", err)
+	}
+	var doc struct {
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+		VMID     string `json:"vmId"`
+	}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, fmt.Errorf("azure: %w", err)
+	}
+	return labels("azure", doc.Location, doc.Zone, doc.VMID), nil
+}
+
+// get sends a request with headers and returns the body of a 200 response.
+func (d *Detector) get(ctx context.Context, method, url string, headers map[string]string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+}
+
+// labels returns the non-empty labels of an instance.
+func labels(cloud, region, zone, instance string) map[string]string {
+	l := map[string]string{LabelCloud: cloud}
+	for k, v := range map[string]string{LabelRegion: region, LabelZone: zone, LabelInstance: instance} {
+		if v != "" {
+			l[k] = v
+		}
+	}
+	return l
+}
+
+// Merge returns labels with the cloud labels added, keeping labels that are
+// already set.
+func Merge(labels, cloud map[string]string) map[string]string {
+	if len(cloud) == 0 {
+		return labels
+	}
+	merged := make(map[string]string, len(labels)+len(cloud))
+	for k, v := range cloud {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
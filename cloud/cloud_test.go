@@ -0,0 +1,43 @@
+package cloud
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	gcp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			http.Error(w, "missing header", http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"id": 4520031799277581759, "zone": "projects/123/zones/europe-west1-b"}`))
+	}))
+	defer gcp.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	defer down.Close()
+
+	d := &Detector{Client: http.DefaultClient, AWS: down.URL, GCP: gcp.URL, Azure: down.URL}
+	labels, err := d.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"cloud": "gcp", "region": "europe-west1", "zone": "europe-west1-b", "instance_id": "4520031799277581759"}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Fatalf("unexpected labels %v", labels)
+		}
+	}
+
+	merged := Merge(map[string]string{"region": "override"}, labels)
+	if merged["region"] != "override" || merged["zone"] != "europe-west1-b" {
+		t.Fatalf("explicit labels should win, got %v", merged)
+	}
+
+	d.GCP = down.URL
+	if _, err := d.Detect(context.Background()); err == nil {
+		t.Fatal("expected an error off the cloud")
+	}
+}
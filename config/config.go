@@ -0,0 +1,534 @@
+// Package config loads and validates circle-pinger configuration files.
+//
+// A configuration file is YAML. Scalar values may reference environment
+// variables as ${NAME} or ${NAME:-default}; they are expanded before the
+// document is validated, and every problem is reported with the line and
+// column it was found at.
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/cron"
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/secret"
+	"github.com/circle-protocol/circle-pinger/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the top-level configuration document.
+type Config struct {
+	Defaults   Defaults           `yaml:"defaults"`
+	Targets    []Target           `yaml:"targets"`
+	Discovery  []Discovery        `yaml:"discovery"`
+	Profiles   map[string]Profile `yaml:"profiles"`
+	Secrets    map[string]Secret  `yaml:"secrets"`
+	Composites []Composite        `yaml:"composites"`
+}
+
+// Composite is a target that is up while at least Quorum of its member
+// targets are, the availability of quorum-based systems such as etcd,
+// ZooKeeper or database replicas. Targets are the names of configured
+// targets; the composite judges them every Interval.
+type Composite struct {
+	Name     string            `yaml:"name"`
+	Targets  []string          `yaml:"targets"`
+	Quorum   int               `yaml:"quorum"`
+	Interval Duration          `yaml:"interval"`
+	Labels   map[string]string `yaml:"labels"`
+}
+
+// Secret defines where the secret referenced as secret://<name> is read
+// from: an environment variable, a file or an item of the keychain. Exactly
+// one must be set.
+type Secret struct {
+	Env      string `yaml:"env"`
+	File     string `yaml:"file"`
+	Keychain string `yaml:"keychain"`
+}
+
+// Source returns the secret as a secret.Source.
+func (s Secret) Source() secret.Source {
+	return secret.Source{Env: s.Env, File: s.File, Keychain: s.Keychain}
+}
+
+// Profile is a named set of targets and run options, such as the checks of
+// a synthetic monitoring run. Targets are the names of configured targets;
+// the options apply unless given on the command line.
+type Profile struct {
+	Targets  []string `yaml:"targets"`
+	Counter  *int     `yaml:"counter"`
+	Interval Duration `yaml:"interval"`
+	Timeout  Duration `yaml:"timeout"`
+	FailOn   string   `yaml:"fail_on"`
+}
+
+// Profile returns the targets of the named profile, resolved against the
+// configured targets, along with the profile.
+func (c *Config) Profile(name string) (Profile, []Target, error) {
+	profile, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return Profile{}, nil, fmt.Errorf("unknown profile %q, the configuration defines none", name)
+		}
+		return Profile{}, nil, fmt.Errorf("unknown profile %q, want one of: %s", name, strings.Join(names, ", "))
+	}
+	targets := make([]Target, 0, len(profile.Targets))
+	for _, key := range profile.Targets {
+		for _, t := range c.Targets {
+			if t.Name == key {
+				targets = append(targets, t)
+			}
+		}
+	}
+	return profile, targets, nil
+}
+
+// Defaults holds settings inherited by every target that does not set them.
+type Defaults struct {
+	Interval   Duration          `yaml:"interval"`
+	Timeout    Duration          `yaml:"timeout"`
+	Schedule   string            `yaml:"schedule"`
+	DNSServers []string          `yaml:"dns_servers"`
+	DNSTimeout Duration          `yaml:"dns_timeout"`
+	Proxy      string            `yaml:"proxy"`
+	ProxyAuth  string            `yaml:"proxy_auth"`
+	Labels     map[string]string `yaml:"labels"`
+	Quarantine Quarantine        `yaml:"quarantine"`
+	Trend      Trend             `yaml:"trend"`
+}
+
+// DefaultQuarantineInterval is how often a quarantined target is probed when
+// the quarantine does not set an interval.
+const DefaultQuarantineInterval = 5 * time.Minute
+
+// Quarantine makes the daemon probe a target that has failed continuously
+// for After only every Interval, until it recovers. It is off when After is
+// zero.
+type Quarantine struct {
+	After    Duration `yaml:"after"`
+	Interval Duration `yaml:"interval"`
+}
+
+// IntervalOrDefault returns the quarantine interval, or
+// DefaultQuarantineInterval when unset.
+func (q Quarantine) IntervalOrDefault() time.Duration {
+	if q.Interval > 0 {
+		return q.Interval.Std()
+	}
+	return DefaultQuarantineInterval
+}
+
+// Trend makes the daemon alert when the average time of the probes of a
+// target over the last Window rises by more than Rise, a percentage such as
+// "30%", above that of the Window before. It is off when Window is zero.
+type Trend struct {
+	Window Duration `yaml:"window"`
+	Rise   string   `yaml:"rise"`
+}
+
+// RiseRatio returns Rise as a ratio, 0.3 for "30%".
+func (t Trend) RiseRatio() (float64, error) {
+	v, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(t.Rise), "%"), 64)
+	if err != nil || !strings.HasSuffix(t.Rise, "%") || v <= 0 {
+		return 0, fmt.Errorf("invalid rise %q, want a positive percentage such as 30%%", t.Rise)
+	}
+	return v / 100, nil
+}
+
+// Target is a single probe target. A target with a Schedule, a crontab
+// schedule such as "*/5 * * * *", is probed once at every time it fires
+// rather than every Interval.
+type Target struct {
+	Name     string            `yaml:"name"`
+	URL      string            `yaml:"url"`
+	Interval Duration          `yaml:"interval"`
+	Timeout  Duration          `yaml:"timeout"`
+	Schedule string            `yaml:"schedule"`
+	Labels   map[string]string `yaml:"labels"`
+	HTTP     HTTPOptions       `yaml:"http"`
+}
+
+// HTTPOptions configures http and https targets.
+type HTTPOptions struct {
+	Method    string `yaml:"method"`
+	UserAgent string `yaml:"user_agent"`
+	Meta      bool   `yaml:"meta"`
+	CacheBust string `yaml:"cache_bust"`
+}
+
+// Discovery keeps targets in sync with a service registry. Every discovered
+// instance becomes a target built from Target, whose URL may contain the
+// {host} and {port} placeholders. Exactly one provider must be set.
+type Discovery struct {
+	Name       string               `yaml:"name"`
+	Refresh    Duration             `yaml:"refresh"`
+	DNS        *DNSDiscovery        `yaml:"dns"`
+	Consul     *ConsulDiscovery     `yaml:"consul"`
+	Kubernetes *KubernetesDiscovery `yaml:"kubernetes"`
+	Target     Target               `yaml:"target"`
+}
+
+// DNSDiscovery discovers the addresses of an A/AAAA name, probed on Port, or
+// the hosts and ports of an SRV name.
+type DNSDiscovery struct {
+	Name string `yaml:"name"`
+	Type string `yaml:"type"` // "a" (the default) or "srv"
+	Port int    `yaml:"port"`
+}
+
+// ConsulDiscovery discovers the instances of a service in the Consul catalog.
+type ConsulDiscovery struct {
+	Address    string `yaml:"address"` // defaults to http://127.0.0.1:8500
+	Service    string `yaml:"service"`
+	Tag        string `yaml:"tag"`
+	Datacenter string `yaml:"datacenter"`
+	Token      string `yaml:"token"`
+}
+
+// KubernetesDiscovery discovers the endpoints of a Service, or of every
+// Service matching a label selector.
+type KubernetesDiscovery struct {
+	API       string `yaml:"api"` // defaults to the in-cluster API server
+	Namespace string `yaml:"namespace"`
+	Service   string `yaml:"service"`
+	Selector  string `yaml:"selector"`
+	Port      string `yaml:"port"` // port name or number, defaults to the first
+	TokenFile string `yaml:"token_file"`
+}
+
+// Provider returns the name of the configured provider.
+func (d Discovery) Provider() string {
+	switch {
+	case d.DNS != nil:
+		return "dns"
+	case d.Consul != nil:
+		return "consul"
+	case d.Kubernetes != nil:
+		return "kubernetes"
+	default:
+		return ""
+	}
+}
+
+// Duration is a time.Duration that unmarshals from the same notation as the
+// command-line flags ("1s", "250ms", or a bare number of milliseconds).
+type Duration time.Duration
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (d *Duration) UnmarshalYAML(node *yaml.Node) error {
+	v, err := utils.ParseDuration(node.Value)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Std returns d as a time.Duration.
+func (d Duration) Std() time.Duration {
+	return time.Duration(d)
+}
+
+// Load reads and validates the configuration file at path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(path, data)
+}
+
+// Parse validates data and decodes it into a Config. name is only used to
+// prefix error positions. Validation problems are returned as Errors.
+func Parse(name string, data []byte) (*Config, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, syntaxError(name, err)
+	}
+	var errs Errors
+	root := &doc
+	if root.Kind == yaml.DocumentNode {
+		if len(root.Content) == 0 {
+			return &Config{}, nil
+		}
+		root = root.Content[0]
+	}
+	expandEnv(name, root, &errs)
+	configSchema.validate(name, "", root, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	var cfg Config
+	if err := root.Decode(&cfg); err != nil {
+		return nil, syntaxError(name, err)
+	}
+	cfg.check(name, root, &errs)
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return &cfg, nil
+}
+
+// check performs the semantic validation that needs the decoded values.
+func (c *Config) check(name string, root *yaml.Node, errs *Errors) {
+	targets := lookup(root, "targets")
+	seen := make(map[string]int)
+	for i, target := range c.Targets {
+		node := targets
+		if targets != nil && i < len(targets.Content) {
+			node = targets.Content[i]
+		}
+		if target.Name != "" {
+			if first, ok := seen[target.Name]; ok {
+				errs.add(name, lookup(node, "name"), fmt.Sprintf("targets[%d].name", i),
+					"duplicate target name %q, first defined by targets[%d]", target.Name, first)
+			}
+			seen[target.Name] = i
+		}
+		if target.Interval < 0 {
+			errs.add(name, lookup(node, "interval"), fmt.Sprintf("targets[%d].interval", i), "must not be negative")
+		}
+		if target.Timeout < 0 {
+			errs.add(name, lookup(node, "timeout"), fmt.Sprintf("targets[%d].timeout", i), "must not be negative")
+		}
+		if target.Schedule != "" {
+			if _, err := cron.Parse(target.Schedule); err != nil {
+				errs.add(name, lookup(node, "schedule"), fmt.Sprintf("targets[%d].schedule", i), "%v", err)
+			}
+		}
+	}
+
+	if c.Defaults.Schedule != "" {
+		if _, err := cron.Parse(c.Defaults.Schedule); err != nil {
+			errs.add(name, lookup(lookup(root, "defaults"), "schedule"), "defaults.schedule", "%v", err)
+		}
+	}
+
+	if quarantine := lookup(lookup(root, "defaults"), "quarantine"); quarantine != nil {
+		if after := lookup(quarantine, "after"); after != nil && c.Defaults.Quarantine.After <= 0 {
+			errs.add(name, after, "defaults.quarantine.after", "must be positive")
+		}
+		if c.Defaults.Quarantine.Interval < 0 {
+			errs.add(name, lookup(quarantine, "interval"), "defaults.quarantine.interval", "must not be negative")
+		}
+	}
+
+	if trend := lookup(lookup(root, "defaults"), "trend"); trend != nil {
+		if window := lookup(trend, "window"); window != nil && c.Defaults.Trend.Window <= 0 {
+			errs.add(name, window, "defaults.trend.window", "must be positive")
+		}
+		if rise := lookup(trend, "rise"); rise != nil {
+			if _, err := c.Defaults.Trend.RiseRatio(); err != nil {
+				errs.add(name, rise, "defaults.trend.rise", "%v", err)
+			}
+		}
+	}
+
+	profiles := lookup(root, "profiles")
+	profileNames := make([]string, 0, len(c.Profiles))
+	for profileName := range c.Profiles {
+		profileNames = append(profileNames, profileName)
+	}
+	sort.Strings(profileNames)
+	for _, profileName := range profileNames {
+		profile := c.Profiles[profileName]
+		node := lookup(profiles, profileName)
+		path := "profiles." + profileName
+		targetNodes := lookup(node, "targets")
+		for i, key := range profile.Targets {
+			if _, ok := seen[key]; !ok {
+				at := targetNodes
+				if targetNodes != nil && i < len(targetNodes.Content) {
+					at = targetNodes.Content[i]
+				}
+				errs.add(name, at, fmt.Sprintf("%s.targets[%d]", path, i), "unknown target %q, profiles refer to targets by name", key)
+			}
+		}
+		if profile.Counter != nil && *profile.Counter < 0 {
+			errs.add(name, lookup(node, "counter"), path+".counter", "must not be negative")
+		}
+		if profile.Interval < 0 {
+			errs.add(name, lookup(node, "interval"), path+".interval", "must not be negative")
+		}
+		if profile.Timeout < 0 {
+			errs.add(name, lookup(node, "timeout"), path+".timeout", "must not be negative")
+		}
+	}
+
+	composites := lookup(root, "composites")
+	seenComposite := make(map[string]int)
+	for i, composite := range c.Composites {
+		node := composites
+		if composites != nil && i < len(composites.Content) {
+			node = composites.Content[i]
+		}
+		path := fmt.Sprintf("composites[%d]", i)
+		if first, ok := seen[composite.Name]; ok {
+			errs.add(name, lookup(node, "name"), path+".name",
+				"duplicate name %q, also the name of targets[%d]", composite.Name, first)
+		}
+		if first, ok := seenComposite[composite.Name]; ok {
+			errs.add(name, lookup(node, "name"), path+".name",
+				"duplicate name %q, first defined by composites[%d]", composite.Name, first)
+		}
+		seenComposite[composite.Name] = i
+		targetNodes := lookup(node, "targets")
+		for j, key := range composite.Targets {
+			if _, ok := seen[key]; !ok {
+				at := targetNodes
+				if targetNodes != nil && j < len(targetNodes.Content) {
+					at = targetNodes.Content[j]
+				}
+				errs.add(name, at, fmt.Sprintf("%s.targets[%d]", path, j), "unknown target %q, composites refer to targets by name", key)
+			}
+		}
+		if composite.Quorum < 1 || composite.Quorum > len(composite.Targets) {
+			errs.add(name, lookup(node, "quorum"), path+".quorum",
+				"must be between 1 and the %d targets of the composite", len(composite.Targets))
+		}
+		if composite.Interval < 0 {
+			errs.add(name, lookup(node, "interval"), path+".interval", "must not be negative")
+		}
+	}
+
+	secrets := lookup(root, "secrets")
+	secretNames := make([]string, 0, len(c.Secrets))
+	for secretName := range c.Secrets {
+		secretNames = append(secretNames, secretName)
+	}
+	sort.Strings(secretNames)
+	for _, secretName := range secretNames {
+		s := c.Secrets[secretName]
+		node := lookup(secrets, secretName)
+		path := "secrets." + secretName
+		if !secret.ValidName(secretName) {
+			errs.add(name, node, path, "invalid secret name %q, want letters, digits, '.', '-' and '_'", secretName)
+		}
+		sources := 0
+		for _, set := range []bool{s.Env != "", s.File != "", s.Keychain != ""} {
+			if set {
+				sources++
+			}
+		}
+		if sources != 1 {
+			errs.add(name, node, path, "exactly one of env, file and keychain must be set")
+		}
+	}
+
+	discovery := lookup(root, "discovery")
+	seenDiscovery := make(map[string]int)
+	for i, d := range c.Discovery {
+		node := discovery
+		if discovery != nil && i < len(discovery.Content) {
+			node = discovery.Content[i]
+		}
+		path := fmt.Sprintf("discovery[%d]", i)
+		if first, ok := seen[d.Name]; ok {
+			errs.add(name, lookup(node, "name"), path+".name",
+				"duplicate name %q, also the name of targets[%d]", d.Name, first)
+		}
+		if first, ok := seenDiscovery[d.Name]; ok {
+			errs.add(name, lookup(node, "name"), path+".name",
+				"duplicate name %q, first defined by discovery[%d]", d.Name, first)
+		}
+		seenDiscovery[d.Name] = i
+		providers := 0
+		for _, p := range []bool{d.DNS != nil, d.Consul != nil, d.Kubernetes != nil} {
+			if p {
+				providers++
+			}
+		}
+		if providers != 1 {
+			errs.add(name, node, path, "exactly one of dns, consul and kubernetes must be set")
+		}
+		if d.Refresh < 0 {
+			errs.add(name, lookup(node, "refresh"), path+".refresh", "must not be negative")
+		}
+		if d.Target.Schedule != "" {
+			if _, err := cron.Parse(d.Target.Schedule); err != nil {
+				errs.add(name, lookup(lookup(node, "target"), "schedule"), path+".target.schedule", "%v", err)
+			}
+		}
+		if d.DNS != nil {
+			dns := lookup(node, "dns")
+			switch strings.ToLower(d.DNS.Type) {
+			case "", "a":
+				if d.DNS.Port == 0 && strings.Contains(d.Target.URL, "{port}") {
+					errs.add(name, dns, path+".dns", "port is required for the {port} of A records")
+				}
+			case "srv":
+			default:
+				errs.add(name, lookup(dns, "type"), path+".dns.type", `must be "a" or "srv"`)
+			}
+		}
+		if k := d.Kubernetes; k != nil && (k.Service == "") == (k.Selector == "") {
+			errs.add(name, lookup(node, "kubernetes"), path+".kubernetes", "exactly one of service and selector must be set")
+		}
+	}
+}
+
+// Key returns the identity of a target: its name when set, its URL otherwise.
+func (t Target) Key() string {
+	if t.Name != "" {
+		return t.Name
+	}
+	return t.URL
+}
+
+// Resolved returns a copy of t with the defaults applied.
+func (t Target) Resolved(d Defaults) Target {
+	if t.Interval == 0 {
+		t.Interval = d.Interval
+	}
+	if t.Timeout == 0 {
+		t.Timeout = d.Timeout
+	}
+	if t.Schedule == "" {
+		t.Schedule = d.Schedule
+	}
+	if len(d.Labels) > 0 {
+		labels := make(map[string]string, len(d.Labels)+len(t.Labels))
+		for k, v := range d.Labels {
+			labels[k] = v
+		}
+		for k, v := range t.Labels {
+			labels[k] = v
+		}
+		t.Labels = labels
+	}
+	return t
+}
+
+// ExpandURL fills the {host} and {port} placeholders of a discovery target
+// URL, bracketing IPv6 hosts.
+func ExpandURL(url, host string, port int) string {
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return strings.NewReplacer("{host}", host, "{port}", strconv.Itoa(port)).Replace(url)
+}
+
+// validURL reports whether s is a target address with a supported protocol.
+func validURL(s string) error {
+	u, err := utils.ParseAddress(secret.Mask(s))
+	if err != nil {
+		return err
+	}
+	if u.Hostname() == "" {
+		return fmt.Errorf("missing host in %q", s)
+	}
+	_, err = pinger.NewProtocol(u.Scheme)
+	return err
+}
@@ -0,0 +1,301 @@
+package config
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	t.Setenv("PINGER_REGION", "eu-west-1")
+	cfg, err := Parse("test.yaml", []byte(`
+defaults:
+  interval: 2s
+  labels:
+    region: ${PINGER_REGION}
+targets:
+  - name: web
+    url: https://example.com
+    timeout: 500ms
+  - url: tcp://example.com:22
+    labels:
+      role: ${PINGER_ROLE:-ssh}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Targets) != 2 {
+		t.Fatalf("expected 2 targets, got %d", len(cfg.Targets))
+	}
+	target := cfg.Targets[1].Resolved(cfg.Defaults)
+	if target.Interval.Std().String() != "2s" {
+		t.Fatalf("interval should be inherited, got %s", target.Interval.Std())
+	}
+	if target.Labels["region"] != "eu-west-1" || target.Labels["role"] != "ssh" {
+		t.Fatalf("unexpected labels %v", target.Labels)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	_, err := Parse("bad.yaml", []byte(`targets:
+  - name: web
+    url: ftp://example.com
+    interval: 5x
+    colour: red
+  - name: web
+    url: tcp://example.com:${PINGER_UNSET_PORT}
+`))
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("expected validation errors, got %v", err)
+	}
+	want := map[string]bool{
+		"bad.yaml:3:10: targets[0].url":      true,
+		"bad.yaml:4:15: targets[0].interval": true,
+		"bad.yaml:5:5: targets[0].colour":    true,
+		"bad.yaml:7:10":                      true,
+	}
+	for _, e := range errs {
+		for prefix := range want {
+			if len(e.Error()) >= len(prefix) && e.Error()[:len(prefix)] == prefix {
+				delete(want, prefix)
+			}
+		}
+	}
+	if len(want) != 0 {
+		t.Fatalf("missing errors %v in:\n%v", want, err)
+	}
+}
+
+func TestParse_Quarantine(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+defaults:
+  quarantine:
+    after: 10m
+targets:
+  - url: tcp://example.com:22
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := cfg.Defaults.Quarantine; q.After.Std().String() != "10m0s" || q.IntervalOrDefault() != DefaultQuarantineInterval {
+		t.Fatalf("unexpected quarantine %+v", q)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`defaults:
+  quarantine:
+    after: 0s
+    interval: -1m
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 {
+		t.Fatalf("expected 2 quarantine errors, got %v", err)
+	}
+}
+
+func TestParse_Schedule(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+defaults:
+  schedule: "*/5 * * * *"
+targets:
+  - url: tcp://example.com:22
+  - url: tcp://example.com:80
+    schedule: "@hourly"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := cfg.Targets[0].Resolved(cfg.Defaults).Schedule; s != "*/5 * * * *" {
+		t.Fatalf("schedule should be inherited, got %q", s)
+	}
+	if s := cfg.Targets[1].Resolved(cfg.Defaults).Schedule; s != "@hourly" {
+		t.Fatalf("a target's own schedule should win, got %q", s)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`defaults:
+  schedule: "*/5 * * *"
+targets:
+  - url: tcp://example.com:22
+    schedule: "61 * * * *"
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Path != "targets[0].schedule" || errs[0].Line != 5 {
+		t.Fatalf("expected 2 schedule errors, got %v", err)
+	}
+}
+
+func TestParse_Trend(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+defaults:
+  trend:
+    window: 1h
+    rise: 30%
+targets:
+  - url: https://example.com
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rise, err := cfg.Defaults.Trend.RiseRatio(); err != nil || rise != 0.3 || cfg.Defaults.Trend.Window.Std() != time.Hour {
+		t.Fatalf("unexpected trend %+v: %v, %v", cfg.Defaults.Trend, rise, err)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`defaults:
+  trend:
+    window: 0s
+    rise: "30"
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[1].Line != 4 {
+		t.Fatalf("expected a zero window and a rise without %%, got %v", err)
+	}
+}
+
+func TestParse_Profiles(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+targets:
+  - name: web
+    url: https://example.com
+  - name: cdn
+    url: https://cdn.example.com
+  - name: db
+    url: tcp://db.example.com:5432
+profiles:
+  edge-checks:
+    targets: [web, cdn]
+    counter: 3
+    interval: 500ms
+    fail_on: loss>5%
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	profile, targets, err := cfg.Profile("edge-checks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 || targets[0].Name != "web" || targets[1].Name != "cdn" || *profile.Counter != 3 || profile.FailOn != "loss>5%" {
+		t.Fatalf("unexpected profile %+v with targets %+v", profile, targets)
+	}
+	if _, _, err := cfg.Profile("core"); err == nil || err.Error() != `unknown profile "core", want one of: edge-checks` {
+		t.Fatalf("unexpected error for an unknown profile: %v", err)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`targets:
+  - name: web
+    url: https://example.com
+profiles:
+  edge-checks:
+    targets: [web, cdn]
+    counter: -1
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 2 || errs[0].Line != 6 {
+		t.Fatalf("expected an unknown target and a negative counter, got %v", err)
+	}
+}
+
+func TestParse_Composites(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+targets:
+  - {name: etcd-1, url: "tcp://etcd-1:2379"}
+  - {name: etcd-2, url: "tcp://etcd-2:2379"}
+  - {name: etcd-3, url: "tcp://etcd-3:2379"}
+composites:
+  - name: etcd
+    targets: [etcd-1, etcd-2, etcd-3]
+    quorum: 2
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := cfg.Composites; len(c) != 1 || c[0].Quorum != 2 || len(c[0].Targets) != 3 {
+		t.Fatalf("unexpected composites %+v", c)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`targets:
+  - {name: etcd-1, url: "tcp://etcd-1:2379"}
+composites:
+  - name: etcd-1
+    targets: [etcd-1, etcd-9]
+    quorum: 3
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 3 {
+		t.Fatalf("expected a duplicate name, an unknown target and an unreachable quorum, got %v", err)
+	}
+}
+
+func TestParse_Secrets(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+defaults:
+  proxy: http://user:secret://proxy-pass@proxy:8080
+secrets:
+  proxy-pass: {env: PROXY_PASS}
+  socks: {keychain: socks-pass}
+targets:
+  - url: socks5://me:secret://socks@proxy:1080
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if src := cfg.Secrets["proxy-pass"].Source(); src.Env != "PROXY_PASS" {
+		t.Fatalf("unexpected source %+v", src)
+	}
+
+	_, err = Parse("bad.yaml", []byte(`secrets:
+  hook: {env: HOOK, file: /etc/hook}
+  "bad name": {env: X}
+  empty: {}
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 3 || errs[0].Line != 3 {
+		t.Fatalf("expected an invalid name and two secrets without exactly one source, got %v", err)
+	}
+}
+
+func TestParse_Discovery(t *testing.T) {
+	cfg, err := Parse("test.yaml", []byte(`
+discovery:
+  - name: api
+    refresh: 15s
+    consul:
+      service: api
+      tag: primary
+    target:
+      url: http://{host}:{port}/health
+  - name: broken
+    dns:
+      name: api.example.com
+      port: 80
+    kubernetes:
+      service: api
+    target:
+      url: tcp://{host}:{port}
+`))
+	var errs Errors
+	if !errors.As(err, &errs) || len(errs) != 1 {
+		t.Fatalf("expected one validation error, got %v", err)
+	}
+	if cfg != nil {
+		t.Fatalf("expected no configuration on error")
+	}
+
+	cfg, err = Parse("test.yaml", []byte(`
+discovery:
+  - name: api
+    consul:
+      service: api
+    target:
+      url: http://{host}:{port}/health
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := cfg.Discovery[0]; d.Provider() != "consul" || d.Consul.Service != "api" {
+		t.Fatalf("unexpected discovery %+v", d)
+	}
+	if got := ExpandURL(cfg.Discovery[0].Target.URL, "::1", 8080); got != "http://[::1]:8080/health" {
+		t.Fatalf("unexpected url %s", got)
+	}
+}
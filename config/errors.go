@@ -0,0 +1,81 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Error is a single configuration problem located in the source file.
+type Error struct {
+	File   string
+	Line   int
+	Column int
+	Path   string // dotted path of the offending value, e.g. targets[2].interval
+	Msg    string
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	var b strings.Builder
+	b.WriteString(e.File)
+	if e.Line > 0 {
+		fmt.Fprintf(&b, ":%d:%d", e.Line, e.Column)
+	}
+	b.WriteString(": ")
+	if e.Path != "" {
+		b.WriteString(e.Path)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// Errors collects every problem found in a configuration file.
+type Errors []*Error
+
+// Error implements the error interface, one problem per line.
+func (errs Errors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// add records a problem at node's position.
+func (errs *Errors) add(file string, node *yaml.Node, path, format string, args ...any) {
+	e := &Error{File: file, Path: path, Msg: fmt.Sprintf(format, args...)}
+	if node != nil {
+		e.Line, e.Column = node.Line, node.Column
+	}
+	*errs = append(*errs, e)
+}
+
+// syntaxError converts a yaml parse error into Errors, keeping the line
+// numbers the parser reports.
+func syntaxError(file string, err error) error {
+	var typeErr *yaml.TypeError
+	if errors.As(err, &typeErr) {
+		errs := make(Errors, 0, len(typeErr.Errors))
+		for _, msg := range typeErr.Errors {
+			errs = append(errs, parseYAMLMessage(file, msg))
+		}
+		return errs
+	}
+	return Errors{parseYAMLMessage(file, err.Error())}
+}
+
+// parseYAMLMessage extracts the "line N:" prefix yaml.v3 puts in its messages.
+func parseYAMLMessage(file, msg string) *Error {
+	msg = strings.TrimPrefix(msg, "yaml: ")
+	e := &Error{File: file, Msg: msg}
+	var line int
+	if n, _ := fmt.Sscanf(msg, "line %d:", &line); n == 1 {
+		e.Line, e.Column = line, 1
+		e.Msg = strings.TrimSpace(msg[strings.Index(msg, ":")+1:])
+	}
+	return e
+}
@@ -0,0 +1,368 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// kind is the type a schema node expects.
+type kind int
+
+const (
+	kindString kind = iota
+	kindBool
+	kindInt
+	kindDuration
+	kindURL
+	kindURLTemplate // a target url with {host} and {port} placeholders
+	kindList
+	kindMap    // free-form string keys with values of schema.item
+	kindObject // fixed keys described by schema.fields
+)
+
+// String returns the name used for the kind in error messages.
+func (k kind) String() string {
+	switch k {
+	case kindString:
+		return "string"
+	case kindBool:
+		return "boolean"
+	case kindInt:
+		return "integer"
+	case kindDuration:
+		return "duration"
+	case kindURL:
+		return "target url"
+	case kindURLTemplate:
+		return "target url template"
+	case kindList:
+		return "list"
+	case kindMap:
+		return "mapping"
+	case kindObject:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+// schema describes the expected shape of a node in the configuration file.
+type schema struct {
+	kind     kind
+	fields   map[string]*schema
+	item     *schema
+	required []string
+}
+
+var (
+	stringSchema   = &schema{kind: kindString}
+	boolSchema     = &schema{kind: kindBool}
+	durationSchema = &schema{kind: kindDuration}
+	intSchema      = &schema{kind: kindInt}
+	labelsSchema   = &schema{kind: kindMap, item: stringSchema}
+)
+
+// targetSchema returns the schema of a target whose url is of kind url.
+func targetSchema(url kind) *schema {
+	return &schema{
+		kind:     kindObject,
+		required: []string{"url"},
+		fields: map[string]*schema{
+			"name":     stringSchema,
+			"url":      {kind: url},
+			"interval": durationSchema,
+			"timeout":  durationSchema,
+			"schedule": stringSchema,
+			"labels":   labelsSchema,
+			"http": {
+				kind: kindObject,
+				fields: map[string]*schema{
+					"method":     stringSchema,
+					"user_agent": stringSchema,
+					"meta":       boolSchema,
+					"cache_bust": stringSchema,
+				},
+			},
+		},
+	}
+}
+
+// configSchema is the schema of the whole configuration document.
+var configSchema = &schema{
+	kind: kindObject,
+	fields: map[string]*schema{
+		"defaults": {
+			kind: kindObject,
+			fields: map[string]*schema{
+				"interval":    durationSchema,
+				"timeout":     durationSchema,
+				"schedule":    stringSchema,
+				"dns_servers": {kind: kindList, item: stringSchema},
+				"dns_timeout": durationSchema,
+				"proxy":       stringSchema,
+				"proxy_auth":  stringSchema,
+				"labels":      labelsSchema,
+				"quarantine": {
+					kind:     kindObject,
+					required: []string{"after"},
+					fields: map[string]*schema{
+						"after":    durationSchema,
+						"interval": durationSchema,
+					},
+				},
+				"trend": {
+					kind:     kindObject,
+					required: []string{"window", "rise"},
+					fields: map[string]*schema{
+						"window": durationSchema,
+						"rise":   stringSchema,
+					},
+				},
+			},
+		},
+		"targets": {
+			kind: kindList,
+			item: targetSchema(kindURL),
+		},
+		"profiles": {
+			kind: kindMap,
+			item: &schema{
+				kind:     kindObject,
+				required: []string{"targets"},
+				fields: map[string]*schema{
+					"targets":  {kind: kindList, item: stringSchema},
+					"counter":  intSchema,
+					"interval": durationSchema,
+					"timeout":  durationSchema,
+					"fail_on":  stringSchema,
+				},
+			},
+		},
+		"composites": {
+			kind: kindList,
+			item: &schema{
+				kind:     kindObject,
+				required: []string{"name", "targets", "quorum"},
+				fields: map[string]*schema{
+					"name":     stringSchema,
+					"targets":  {kind: kindList, item: stringSchema},
+					"quorum":   intSchema,
+					"interval": durationSchema,
+					"labels":   labelsSchema,
+				},
+			},
+		},
+		"secrets": {
+			kind: kindMap,
+			item: &schema{
+				kind: kindObject,
+				fields: map[string]*schema{
+					"env":      stringSchema,
+					"file":     stringSchema,
+					"keychain": stringSchema,
+				},
+			},
+		},
+		"discovery": {
+			kind: kindList,
+			item: &schema{
+				kind:     kindObject,
+				required: []string{"name", "target"},
+				fields: map[string]*schema{
+					"name":    stringSchema,
+					"refresh": durationSchema,
+					"dns": {
+						kind:     kindObject,
+						required: []string{"name"},
+						fields: map[string]*schema{
+							"name": stringSchema,
+							"type": stringSchema,
+							"port": intSchema,
+						},
+					},
+					"consul": {
+						kind:     kindObject,
+						required: []string{"service"},
+						fields: map[string]*schema{
+							"address":    stringSchema,
+							"service":    stringSchema,
+							"tag":        stringSchema,
+							"datacenter": stringSchema,
+							"token":      stringSchema,
+						},
+					},
+					"kubernetes": {
+						kind: kindObject,
+						fields: map[string]*schema{
+							"api":        stringSchema,
+							"namespace":  stringSchema,
+							"service":    stringSchema,
+							"selector":   stringSchema,
+							"port":       stringSchema,
+							"token_file": stringSchema,
+						},
+					},
+					"target": targetSchema(kindURLTemplate),
+				},
+			},
+		},
+	},
+}
+
+// validate checks node against s, appending every mismatch to errs.
+func (s *schema) validate(file, path string, node *yaml.Node, errs *Errors) {
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	if isNull(node) {
+		return
+	}
+	switch s.kind {
+	case kindObject, kindMap:
+		if node.Kind != yaml.MappingNode {
+			errs.add(file, node, path, "expected %s, got %s", s.kind, describe(node))
+			return
+		}
+		present := make(map[string]bool)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			present[key.Value] = true
+			child := s.item
+			if s.kind == kindObject {
+				child = s.fields[key.Value]
+				if child == nil {
+					errs.add(file, key, join(path, key.Value), "unknown field, expected one of: %s", strings.Join(s.fieldNames(), ", "))
+					continue
+				}
+			}
+			child.validate(file, join(path, key.Value), value, errs)
+		}
+		for _, name := range s.required {
+			if !present[name] {
+				errs.add(file, node, path, "missing required field %q", name)
+			}
+		}
+	case kindList:
+		if node.Kind != yaml.SequenceNode {
+			errs.add(file, node, path, "expected %s, got %s", s.kind, describe(node))
+			return
+		}
+		for i, item := range node.Content {
+			s.item.validate(file, fmt.Sprintf("%s[%d]", path, i), item, errs)
+		}
+	default:
+		if node.Kind != yaml.ScalarNode {
+			errs.add(file, node, path, "expected %s, got %s", s.kind, describe(node))
+			return
+		}
+		if err := s.checkScalar(node.Value); err != nil {
+			errs.add(file, node, path, "%v", err)
+		}
+	}
+}
+
+// checkScalar validates the textual value of a scalar node.
+func (s *schema) checkScalar(value string) error {
+	switch s.kind {
+	case kindBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+	case kindInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+	case kindDuration:
+		if _, err := utils.ParseDuration(value); err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+	case kindURL:
+		if err := validURL(value); err != nil {
+			return fmt.Errorf("invalid target %q: %v", value, err)
+		}
+	case kindURLTemplate:
+		if err := validURL(ExpandURL(value, "example.com", 1)); err != nil {
+			return fmt.Errorf("invalid target %q: %v", value, err)
+		}
+	}
+	return nil
+}
+
+// fieldNames returns the sorted field names of an object schema.
+func (s *schema) fieldNames() []string {
+	names := make([]string, 0, len(s.fields))
+	for name := range s.fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// envPattern matches ${NAME} and ${NAME:-default} references.
+var envPattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces environment references in every scalar below node.
+func expandEnv(file string, node *yaml.Node, errs *Errors) {
+	if node.Kind == yaml.ScalarNode {
+		node.Value = envPattern.ReplaceAllStringFunc(node.Value, func(ref string) string {
+			m := envPattern.FindStringSubmatch(ref)
+			if v, ok := os.LookupEnv(m[1]); ok {
+				return v
+			}
+			if m[2] != "" {
+				return m[3]
+			}
+			errs.add(file, node, "", "environment variable %s is not set", m[1])
+			return ref
+		})
+		return
+	}
+	for _, child := range node.Content {
+		expandEnv(file, child, errs)
+	}
+}
+
+// lookup returns the value node of key in a mapping node, or nil.
+func lookup(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// isNull reports whether node is an explicit or implicit YAML null.
+func isNull(node *yaml.Node) bool {
+	return node.Kind == yaml.ScalarNode && node.Tag == "!!null"
+}
+
+// describe names the kind of a YAML node for error messages.
+func describe(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "mapping"
+	case yaml.SequenceNode:
+		return "list"
+	default:
+		return fmt.Sprintf("%q", node.Value)
+	}
+}
+
+// join appends a field name to a dotted path.
+func join(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
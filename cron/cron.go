@@ -0,0 +1,189 @@
+// Package cron parses crontab schedules, such as "*/5 * * * *", and
+// computes the times they fire, so that the daemon can probe targets on a
+// schedule instead of at a fixed interval.
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule is a parsed crontab schedule: minute, hour, day of month, month
+// and day of week. Times are in the schedule's location.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64 // bit n is set when n matches
+	domAny, dowAny                bool   // the field was "*"
+	loc                           *time.Location
+	spec                          string
+}
+
+// field describes the range and names of a crontab field.
+type field struct {
+	name     string
+	min, max int
+	names    []string // names of min, min+1..., if any
+}
+
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day of month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}},
+	{name: "day of week", min: 0, max: 7, names: []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}},
+}
+
+// macros are the shorthands of common schedules.
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses a schedule of five space-separated fields, each "*", a
+// number, a name (jan, mon...), a range "a-b", a step "*/n" or "a-b/n", or
+// a comma-separated list of them, or one of the macros @hourly, @daily,
+// @weekly, @monthly and @yearly. Times are in the local time zone. As with
+// cron, a day matches when either the day of month or the day of week does
+// if both are restricted.
+func Parse(spec string) (*Schedule, error) {
+	return ParseIn(spec, time.Local)
+}
+
+// ParseIn parses a schedule like Parse, with times in loc.
+func ParseIn(spec string, loc *time.Location) (*Schedule, error) {
+	expanded := strings.TrimSpace(spec)
+	if macro, ok := macros[strings.ToLower(expanded)]; ok {
+		expanded = macro
+	}
+	parts := strings.Fields(expanded)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid schedule %q, want five fields (minute hour day-of-month month day-of-week) such as \"*/5 * * * *\"", spec)
+	}
+	s := &Schedule{loc: loc, spec: spec}
+	for i, dst := range []*uint64{&s.minute, &s.hour, &s.dom, &s.month, &s.dow} {
+		bits, err := fields[i].parse(parts[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		*dst = bits
+	}
+	s.domAny, s.dowAny = parts[2] == "*", parts[4] == "*"
+	// Sunday is both 0 and 7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	return s, nil
+}
+
+// parse parses a field into its bits.
+func (f field) parse(s string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(s, ",") {
+		rng, stepText, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			n, err := strconv.Atoi(stepText)
+			if err != nil || n < 1 {
+				return 0, fmt.Errorf("%s: invalid step %q", f.name, stepText)
+			}
+			step = n
+		}
+		lo, hi := f.min, f.max
+		switch {
+		case rng == "*":
+			if f.max == 7 {
+				hi = 6 // Sunday only once
+			}
+		case strings.Contains(rng, "-"):
+			a, b, _ := strings.Cut(rng, "-")
+			var err error
+			if lo, err = f.value(a); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(b); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%s: invalid range %q", f.name, rng)
+			}
+		default:
+			v, err := f.value(rng)
+			if err != nil {
+				return 0, err
+			}
+			lo = v
+			if hasStep {
+				hi = f.max
+			} else {
+				hi = v
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << v
+		}
+	}
+	return bits, nil
+}
+
+// value parses a single number or name of the field.
+func (f field) value(s string) (int, error) {
+	for i, name := range f.names {
+		if strings.EqualFold(s, name) {
+			return f.min + i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%s: invalid value %q, want %d-%d", f.name, s, f.min, f.max)
+	}
+	return v, nil
+}
+
+// String returns the schedule as given.
+func (s *Schedule) String() string {
+	return s.spec
+}
+
+// Next returns the first time the schedule fires after t, or the zero time
+// if it never does, such as on the 30th of February.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.In(s.loc).Truncate(time.Minute).Add(time.Minute)
+	// A schedule that fires at all does within a leap cycle
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.loc)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches reports whether the day of t matches the schedule.
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,44 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	from := time.Date(2026, 10, 16, 9, 3, 30, 0, time.UTC) // a Friday
+	for _, test := range []struct {
+		spec string
+		want string
+	}{
+		{"*/5 * * * *", "2026-10-16 09:05"},
+		{"* * * * *", "2026-10-16 09:04"},
+		{"0 * * * *", "2026-10-16 10:00"},
+		{"@daily", "2026-10-17 00:00"},
+		{"30 8 * * mon-fri", "2026-10-19 08:30"},
+		{"0 0 * * 7", "2026-10-18 00:00"},
+		{"0 12 1 jan *", "2027-01-01 12:00"},
+		{"15,45 9-17/4 * * *", "2026-10-16 09:15"},
+		{"0 0 13 * fri", "2026-10-23 00:00"}, // day of month or day of week
+		{"0 0 29 2 *", "2028-02-29 00:00"},
+		{"0 0 30 2 *", ""},
+	} {
+		s, err := ParseIn(test.spec, time.UTC)
+		if err != nil {
+			t.Fatalf("%s: %v", test.spec, err)
+		}
+		got := ""
+		if next := s.Next(from); !next.IsZero() {
+			got = next.Format("2006-01-02 15:04")
+		}
+		if got != test.want {
+			t.Errorf("%s: expected %q, got %q", test.spec, test.want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "* * * * 8", "*/0 * * * *", "5-1 * * * *", "@often"} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
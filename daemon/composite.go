@@ -0,0 +1,126 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// QuorumScheme is the scheme of the URLs of composite targets,
+// quorum://<name>.
+const QuorumScheme = "quorum"
+
+// quorumPoll is how often a quorumPing checks whether all members have been
+// probed once.
+const quorumPoll = 50 * time.Millisecond
+
+// quorumPing judges a composite target from the states of its members'
+// Pingers: it is up while at least quorum of them are. It waits for the first
+// probe of every member up to its timeout, after which members that have not
+// been probed count as down.
+type quorumPing struct {
+	quorum  int
+	names   []string
+	members []*pinger.Pinger
+}
+
+// Ping implements pinger.Ping.
+func (q *quorumPing) Ping(ctx context.Context) *pinger.Stats {
+	start := time.Now()
+	q.awaitMembers(ctx)
+	var down []string
+	for i, member := range q.members {
+		if state := member.State(); state.Total == 0 || !state.Up {
+			down = append(down, q.names[i])
+		}
+	}
+	up := len(q.members) - len(down)
+	stats := &pinger.Stats{
+		Connected: up >= q.quorum,
+		Address:   QuorumScheme,
+		Meta: map[string]fmt.Stringer{
+			"up":     pinger.StringerFunc(func() string { return fmt.Sprintf("%d/%d", up, len(q.members)) }),
+			"quorum": pinger.StringerFunc(func() string { return fmt.Sprint(q.quorum) }),
+		},
+	}
+	if len(down) > 0 {
+		list := strings.Join(down, ",")
+		stats.Meta["down"] = pinger.StringerFunc(func() string { return list })
+	}
+	if !stats.Connected {
+		stats.Error = fmt.Errorf("quorum lost, %d of %d members up and %d needed, down: %s",
+			up, len(q.members), q.quorum, strings.Join(down, ", "))
+	}
+	stats.Duration = time.Since(start)
+	return stats
+}
+
+// awaitMembers waits until every member has been probed once or ctx is
+// done.
+func (q *quorumPing) awaitMembers(ctx context.Context) {
+	ticker := time.NewTicker(quorumPoll)
+	defer ticker.Stop()
+	for !q.probed() {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probed reports whether every member has been probed.
+func (q *quorumPing) probed() bool {
+	for _, member := range q.members {
+		if member.State().Total == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// composite builds the probe of a composite target judging members, keyed
+// by the names of the composite's targets. It returns the unchanged probe
+// when the composite and its members are the same as before.
+func (d *Daemon) composite(c config.Composite, defaults config.Defaults, members map[string]*probe) (*probe, error) {
+	target := config.Target{
+		Name:     c.Name,
+		URL:      QuorumScheme + "://" + c.Name,
+		Interval: c.Interval,
+		Labels:   c.Labels,
+	}.Resolved(defaults)
+	ping := &quorumPing{quorum: c.Quorum, names: c.Targets}
+	for _, name := range c.Targets {
+		member, ok := members[name]
+		if !ok {
+			return nil, fmt.Errorf("composite %s: unknown target %q", c.Name, name)
+		}
+		ping.members = append(ping.members, member.pinger)
+	}
+
+	if old, ok := d.probes[c.Name]; ok && old.quorum != nil && old.sameAs(target, defaults) &&
+		old.quorum.quorum == ping.quorum && slices.Equal(old.quorum.members, ping.members) {
+		return old, nil
+	}
+	u := &url.URL{Scheme: QuorumScheme, Host: c.Name}
+	interval := target.Interval.Std()
+	p := &probe{
+		target:   target,
+		defaults: defaults,
+		url:      u,
+		pinger:   pinger.NewPinger(d.out, u, ping, interval, 0, interval),
+		quorum:   ping,
+		done:     make(chan struct{}),
+	}
+	if d.sink != nil {
+		p.pinger.SetSink(d.sink)
+	}
+	p.pinger.SetLabels(target.Labels)
+	return p, nil
+}
@@ -0,0 +1,455 @@
+// Package daemon keeps a set of configured targets probed continuously and
+// applies configuration changes without restarting the targets that did not
+// change, so their statistics survive a reload.
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/cron"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Builder creates the Ping for a configured target. The target already has
+// the configuration defaults applied.
+type Builder func(target config.Target, defaults config.Defaults) (*url.URL, pinger.Ping, error)
+
+// Daemon runs one Pinger per configured target.
+type Daemon struct {
+	out   io.Writer
+	build Builder
+	sink  pinger.Sink // receives the records of every target, if set
+
+	budget pinger.Budget // shared by every target, if set
+
+	// lookup resolves the host of a target to tell the targets probing the
+	// same endpoint
+	lookup func(ctx context.Context, host string) ([]string, error)
+
+	applying sync.Mutex // serialises Apply, which resolves hosts without mu
+
+	mu     sync.Mutex
+	probes map[string]*probe
+	self   *probe // the heartbeat target, if started
+
+	statePath string                 // where SaveState writes, empty to disable
+	saved     map[string]targetState // states loaded by LoadState, not yet restored
+}
+
+// lookupTimeout bounds the lookups of the hosts of all targets when merging.
+const lookupTimeout = 2 * time.Second
+
+// probe is a running Pinger together with the configuration it was built from.
+type probe struct {
+	source   config.Target // the target as configured, before merging
+	target   config.Target
+	defaults config.Defaults
+	url      *url.URL
+	pinger   *pinger.Pinger
+	quorum   *quorumPing // judging the members of a composite target, if it is one
+	done     chan struct{}
+}
+
+// New creates a Daemon writing probe results to out.
+func New(out io.Writer, build Builder) *Daemon {
+	return &Daemon{
+		out:    &lockedWriter{w: out},
+		build:  build,
+		lookup: net.DefaultResolver.LookupHost,
+		probes: make(map[string]*probe),
+	}
+}
+
+// SetSink sends the probe records of every target to sink instead of out,
+// which then only receives summaries. It must be called before Apply.
+func (d *Daemon) SetSink(sink pinger.Sink) {
+	d.sink = sink
+}
+
+// SetBudget makes every target wait for and charge budget around its
+// probes, such as to bound the bandwidth of all targets together. It must be
+// called before Apply.
+func (d *Daemon) SetBudget(budget pinger.Budget) {
+	d.budget = budget
+}
+
+// Changes summarises the effect of applying a configuration.
+type Changes struct {
+	Added     []string
+	Removed   []string
+	Changed   []string
+	Unchanged []string
+	Merged    []string // "<target> into <target>" for targets probing the same endpoint
+}
+
+// String returns a compact summary such as "+2 -1 ~0 =5".
+func (c Changes) String() string {
+	return fmt.Sprintf("+%d -%d ~%d =%d", len(c.Added), len(c.Removed), len(c.Changed), len(c.Unchanged))
+}
+
+// Apply makes the running target set match cfg. Targets whose effective
+// configuration is unchanged keep running with their statistics; changed
+// targets are restarted and removed ones stopped. Targets probing the same
+// endpoint are merged into one. If any target cannot be built, nothing is
+// changed and the error is returned.
+func (d *Daemon) Apply(cfg *config.Config) (Changes, error) {
+	d.applying.Lock()
+	defer d.applying.Unlock()
+
+	// Hosts are resolved without holding mu, so that slow lookups hold up
+	// neither the readers of the running targets nor the reload
+	d.mu.Lock()
+	built, err := d.candidates(cfg)
+	d.mu.Unlock()
+	if err != nil {
+		return Changes{}, err
+	}
+	candidates, merged := mergeCandidates(built, d.resolve(built))
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// A target kept from the running set is built after all when merging
+	// changed its labels
+	for i := range candidates {
+		c := &candidates[i]
+		if c.ping != nil {
+			continue
+		}
+		if old := d.probes[c.target.Key()]; old.sameAs(c.target, cfg.Defaults) {
+			continue
+		}
+		if _, c.ping, err = d.build(c.source, cfg.Defaults); err != nil {
+			return Changes{}, fmt.Errorf("target %s: %w", c.target.Key(), err)
+		}
+	}
+	changes := Changes{Merged: merged}
+	desired := make(map[string]*probe, len(candidates))
+	for _, c := range candidates {
+		target := c.target
+		key := target.Key()
+		if old, ok := d.probes[key]; ok && old.sameAs(target, cfg.Defaults) {
+			desired[key] = old
+			changes.Unchanged = append(changes.Unchanged, key)
+			continue
+		}
+		p := &probe{
+			source:   c.source,
+			target:   target,
+			defaults: cfg.Defaults,
+			url:      c.url,
+			pinger:   pinger.NewPinger(d.out, c.url, c.ping, target.Interval.Std(), 0, target.Timeout.Std()),
+			done:     make(chan struct{}),
+		}
+		if d.sink != nil {
+			p.pinger.SetSink(d.sink)
+		}
+		if d.budget != nil {
+			p.pinger.SetBudget(d.budget)
+		}
+		if c.schedule != nil {
+			p.pinger.SetSchedule(c.schedule.Next)
+		}
+		if q := cfg.Defaults.Quarantine; q.After > 0 {
+			p.pinger.SetQuarantine(q.After.Std(), q.IntervalOrDefault())
+		}
+		if trend := cfg.Defaults.Trend; trend.Window > 0 {
+			rise, _ := trend.RiseRatio() // validated with the configuration
+			p.pinger.SetTrend(trend.Window.Std(), rise)
+		}
+		p.pinger.SetLabels(target.Labels)
+		desired[key] = p
+		if _, ok := d.probes[key]; ok {
+			changes.Changed = append(changes.Changed, key)
+		} else {
+			changes.Added = append(changes.Added, key)
+			d.restore(key, p)
+		}
+	}
+
+	// Composites judge the Pingers of their members; a merged target is
+	// judged by the one it was merged into
+	members := make(map[string]*probe, len(candidates))
+	for _, c := range candidates {
+		p := desired[c.target.Key()]
+		members[c.target.Key()] = p
+		for _, key := range c.merged {
+			members[key] = p
+		}
+	}
+	for _, composite := range cfg.Composites {
+		p, err := d.composite(composite, cfg.Defaults, members)
+		if err != nil {
+			return Changes{}, err
+		}
+		desired[composite.Name] = p
+		switch old, ok := d.probes[composite.Name]; {
+		case old == p:
+			changes.Unchanged = append(changes.Unchanged, composite.Name)
+		case ok:
+			changes.Changed = append(changes.Changed, composite.Name)
+		default:
+			changes.Added = append(changes.Added, composite.Name)
+			d.restore(composite.Name, p)
+		}
+	}
+
+	// Stop what was removed or replaced, then start what is new
+	for key, old := range d.probes {
+		if desired[key] != old {
+			if _, ok := desired[key]; !ok {
+				changes.Removed = append(changes.Removed, key)
+			}
+			old.stop()
+			old.pinger.Summarize()
+		}
+	}
+	for key, p := range desired {
+		if d.probes[key] != p {
+			p.start()
+		}
+	}
+	d.probes = desired
+
+	sort.Strings(changes.Added)
+	sort.Strings(changes.Removed)
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Unchanged)
+	return changes, nil
+}
+
+// candidate is a configured target with the URL and Ping built from it.
+type candidate struct {
+	source   config.Target // the target as configured
+	target   config.Target // with the labels of the targets merged into it
+	url      *url.URL
+	ping     pinger.Ping    // nil while the running probe of the target is kept
+	schedule *cron.Schedule // probing the target instead of its interval, if set
+	merged   []string       // the keys of the targets merged into this one
+}
+
+// candidates builds every target of cfg that is not already running with
+// the same configuration, whose running probe is kept instead.
+func (d *Daemon) candidates(cfg *config.Config) ([]candidate, error) {
+	var list []candidate
+	for _, t := range cfg.Targets {
+		target := t.Resolved(cfg.Defaults)
+		var (
+			u    *url.URL
+			ping pinger.Ping
+			err  error
+		)
+		if old, ok := d.probes[target.Key()]; ok && old.builtFrom(target, cfg.Defaults) {
+			u = old.url
+		} else if u, ping, err = d.build(target, cfg.Defaults); err != nil {
+			return nil, fmt.Errorf("target %s: %w", target.Key(), err)
+		}
+		var schedule *cron.Schedule
+		if target.Schedule != "" {
+			if schedule, err = cron.Parse(target.Schedule); err != nil {
+				return nil, fmt.Errorf("target %s: %w", target.Key(), err)
+			}
+		}
+		list = append(list, candidate{source: target, target: target, url: u, ping: ping, schedule: schedule})
+	}
+	return list, nil
+}
+
+// resolve looks up the hosts of the candidates concurrently, all within
+// lookupTimeout, and returns their sorted addresses joined by commas by
+// host. A host that is an address, or does not resolve in time, stands for
+// itself.
+func (d *Daemon) resolve(list []candidate) map[string]string {
+	resolved := make(map[string]string)
+	var hosts []string
+	for _, c := range list {
+		host := strings.ToLower(c.url.Hostname())
+		if _, ok := resolved[host]; !ok {
+			resolved[host] = host
+			hosts = append(hosts, host)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	defer cancel()
+	addrs := make([]string, len(hosts))
+	var wg sync.WaitGroup
+	for i, host := range hosts {
+		if host == "" || net.ParseIP(host) != nil {
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if list, err := d.lookup(ctx, host); err == nil && len(list) > 0 {
+				sort.Strings(list)
+				addrs[i] = strings.Join(list, ",")
+			}
+		}()
+	}
+	wg.Wait()
+	for i, host := range hosts {
+		if addrs[i] != "" {
+			resolved[host] = addrs[i]
+		}
+	}
+	return resolved
+}
+
+// mergeCandidates merges the candidates probing the same endpoint, the same
+// protocol and port on the same resolved addresses such as a static target
+// that discovery also finds, into the first of them, which gets the labels
+// of all with its own taking precedence. The merged targets are returned as
+// "<merged> into <kept>".
+func mergeCandidates(list []candidate, resolved map[string]string) ([]candidate, []string) {
+	var kept []candidate
+	var merged []string
+	byEndpoint := make(map[string]int)
+	for _, c := range list {
+		endpoint := endpoint(c.url, resolved)
+		if i, ok := byEndpoint[endpoint]; ok {
+			target := &kept[i].target
+			target.Labels = mergeLabels(target.Labels, c.target.Labels)
+			kept[i].merged = append(kept[i].merged, c.target.Key())
+			merged = append(merged, c.target.Key()+" into "+target.Key())
+			continue
+		}
+		byEndpoint[endpoint] = len(kept)
+		kept = append(kept, c)
+	}
+	return kept, merged
+}
+
+// endpoint returns what a probe of u reaches: its protocol, the addresses
+// its host resolved to, its port, defaulting to that of the protocol, and
+// the path and query of URL protocols.
+func endpoint(u *url.URL, resolved map[string]string) string {
+	addrs := resolved[strings.ToLower(u.Hostname())]
+	port := u.Port()
+	if port == "" {
+		if protocol, err := pinger.NewProtocol(u.Scheme); err == nil {
+			if spec, ok := pinger.LoadSpec(protocol); ok && spec.Port > 0 {
+				port = strconv.Itoa(spec.Port)
+			}
+		}
+	}
+	if port == "" {
+		if n, err := net.LookupPort("tcp", u.Scheme); err == nil {
+			port = strconv.Itoa(n)
+		}
+	}
+	return strings.ToLower(u.Scheme) + "://" + addrs + ":" + port + u.RequestURI()
+}
+
+// mergeLabels returns the labels of both, those of first taking precedence.
+func mergeLabels(first, second map[string]string) map[string]string {
+	labels := make(map[string]string, len(first)+len(second))
+	for k, v := range second {
+		labels[k] = v
+	}
+	for k, v := range first {
+		labels[k] = v
+	}
+	return labels
+}
+
+// Targets returns the URLs of the running targets, sorted.
+func (d *Daemon) Targets() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	urls := make([]string, 0, len(d.probes))
+	for _, p := range d.probes {
+		urls = append(urls, p.url.String())
+	}
+	if d.self != nil {
+		urls = append(urls, d.self.url.String())
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// Stop stops every target, saves their state when a state file is set and
+// prints their summaries.
+func (d *Daemon) Stop() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	keys := make([]string, 0, len(d.probes))
+	for key, p := range d.probes {
+		p.pinger.Stop()
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		<-d.probes[key].done
+	}
+	if d.self != nil {
+		d.self.stop()
+		d.self = nil
+	}
+	err := d.saveState()
+	for _, key := range keys {
+		d.probes[key].pinger.Summarize()
+	}
+	d.probes = make(map[string]*probe)
+	return err
+}
+
+// sameAs reports whether the probe was built from an equivalent configuration,
+// labels merged from other targets included.
+func (p *probe) sameAs(target config.Target, defaults config.Defaults) bool {
+	return p != nil && reflect.DeepEqual(p.target, target) && p.sameDefaults(defaults)
+}
+
+// builtFrom reports whether the probe was built from target as configured,
+// before merging, with equivalent defaults, so that it probes the same way.
+func (p *probe) builtFrom(target config.Target, defaults config.Defaults) bool {
+	return p.quorum == nil && reflect.DeepEqual(p.source, target) && p.sameDefaults(defaults)
+}
+
+// sameDefaults reports whether the defaults that shape the probe are
+// equivalent.
+func (p *probe) sameDefaults(defaults config.Defaults) bool {
+	return reflect.DeepEqual(p.defaults.DNSServers, defaults.DNSServers) &&
+		p.defaults.DNSTimeout == defaults.DNSTimeout &&
+		p.defaults.Proxy == defaults.Proxy &&
+		p.defaults.ProxyAuth == defaults.ProxyAuth &&
+		p.defaults.Quarantine == defaults.Quarantine &&
+		p.defaults.Trend == defaults.Trend
+}
+
+// start runs the probe's Pinger in the background.
+func (p *probe) start() {
+	go func() {
+		defer close(p.done)
+		p.pinger.Ping()
+	}()
+}
+
+// stop stops the probe's Pinger and waits for its loop to return.
+func (p *probe) stop() {
+	p.pinger.Stop()
+	<-p.done
+}
+
+// lockedWriter serialises writes from the concurrently running Pingers.
+type lockedWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(b)
+}
@@ -0,0 +1,326 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+type fakePing struct{}
+
+func (fakePing) Ping(ctx context.Context) *pinger.Stats {
+	return &pinger.Stats{Connected: true}
+}
+
+// noLookup resolves no host.
+func noLookup(ctx context.Context, host string) ([]string, error) {
+	return nil, errors.New("no such host")
+}
+
+func build(target config.Target, defaults config.Defaults) (*url.URL, pinger.Ping, error) {
+	u, err := url.Parse(target.URL)
+	return u, fakePing{}, err
+}
+
+func TestApply(t *testing.T) {
+	d := New(io.Discard, build)
+	d.lookup = noLookup
+	defer d.Stop()
+
+	changes, err := d.Apply(&config.Config{Targets: []config.Target{
+		{Name: "a", URL: "tcp://a:80"},
+		{Name: "b", URL: "tcp://b:80"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+2 -0 ~0 =0" {
+		t.Fatalf("unexpected changes %s", changes)
+	}
+	kept := d.probes["a"].pinger
+
+	changes, err = d.Apply(&config.Config{Targets: []config.Target{
+		{Name: "a", URL: "tcp://a:80"},
+		{Name: "b", URL: "tcp://b:443"},
+		{Name: "c", URL: "tcp://c:80"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+1 -0 ~1 =1" {
+		t.Fatalf("unexpected changes %s", changes)
+	}
+	if d.probes["a"].pinger != kept {
+		t.Fatalf("unchanged target must keep its pinger")
+	}
+
+	changes, err = d.Apply(&config.Config{Targets: []config.Target{{Name: "a", URL: "tcp://a:80"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+0 -2 ~0 =1" {
+		t.Fatalf("unexpected changes %s", changes)
+	}
+}
+
+func TestApply_Merge(t *testing.T) {
+	d := New(io.Discard, build)
+	d.lookup = noLookup
+	defer d.Stop()
+
+	changes, err := d.Apply(&config.Config{Targets: []config.Target{
+		{Name: "db", URL: "tcp://10.0.0.5:5432", Labels: map[string]string{"role": "database"}},
+		{Name: "pg/10.0.0.5:5432", URL: "tcp://10.0.0.5:5432", Labels: map[string]string{"discovery": "pg", "role": "other"}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.probes) != 1 || len(changes.Merged) != 1 || changes.Merged[0] != "pg/10.0.0.5:5432 into db" {
+		t.Fatalf("expected one probe for the shared endpoint, got %v", changes)
+	}
+	if labels := d.probes["db"].target.Labels; labels["role"] != "database" || labels["discovery"] != "pg" {
+		t.Fatalf("unexpected merged labels %v", labels)
+	}
+}
+
+func TestApply_MergeResolved(t *testing.T) {
+	d := New(io.Discard, build)
+	defer d.Stop()
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		if host == "db.internal" {
+			return []string{"10.0.0.5"}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	// A host name resolving to a discovered address, with the default port,
+	// probes the same endpoint
+	changes, err := d.Apply(&config.Config{Targets: []config.Target{
+		{Name: "db", URL: "http://DB.internal/health"},
+		{Name: "web/10.0.0.5:80", URL: "http://10.0.0.5:80/health"},
+		{Name: "web/10.0.0.5:80/ready", URL: "http://10.0.0.5:80/ready"},
+		{Name: "other", URL: "http://other.internal/health"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.probes) != 3 || !slices.Equal(changes.Merged, []string{"web/10.0.0.5:80 into db"}) {
+		t.Fatalf("expected the resolved endpoint to merge, got %v", changes.Merged)
+	}
+}
+
+func TestApply_ResolvesConcurrently(t *testing.T) {
+	d := New(io.Discard, build)
+	defer d.Stop()
+	const n = 5
+	var started sync.WaitGroup
+	started.Add(n)
+	release := make(chan struct{})
+	d.lookup = func(ctx context.Context, host string) ([]string, error) {
+		started.Done()
+		select {
+		case <-release:
+			return nil, errors.New("no such host")
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	var targets []config.Target
+	for i := range n {
+		targets = append(targets, config.Target{URL: fmt.Sprintf("http://host%d.internal/", i)})
+	}
+	applied := make(chan error, 1)
+	go func() {
+		_, err := d.Apply(&config.Config{Targets: targets})
+		applied <- err
+	}()
+
+	// All hosts are looked up at once, leaving the running targets readable
+	lookingUp := make(chan struct{})
+	go func() {
+		started.Wait()
+		close(lookingUp)
+	}()
+	select {
+	case <-lookingUp:
+	case <-time.After(time.Second):
+		t.Fatal("hosts are not looked up concurrently")
+	}
+	read := make(chan []string, 1)
+	go func() { read <- d.Targets() }()
+	select {
+	case <-read:
+	case <-time.After(time.Second):
+		t.Fatal("reading the targets waits for the lookups")
+	}
+	close(release)
+	if err := <-applied; err != nil {
+		t.Fatal(err)
+	}
+	if got := d.Targets(); len(got) != n {
+		t.Fatalf("expected %d targets, got %v", n, got)
+	}
+}
+
+func TestApply_KeepsUnchanged(t *testing.T) {
+	var built []string
+	d := New(io.Discard, func(target config.Target, defaults config.Defaults) (*url.URL, pinger.Ping, error) {
+		built = append(built, target.Key())
+		return build(target, defaults)
+	})
+	defer d.Stop()
+
+	targets := []config.Target{
+		{Name: "a", URL: "tcp://10.0.0.1:80"},
+		{Name: "b", URL: "tcp://10.0.0.2:80"},
+	}
+	if _, err := d.Apply(&config.Config{Targets: targets}); err != nil {
+		t.Fatal(err)
+	}
+	built = nil
+
+	// Only the changed and added targets are built again
+	targets[1].URL = "tcp://10.0.0.2:443"
+	targets = append(targets, config.Target{Name: "c", URL: "tcp://10.0.0.3:80"})
+	changes, err := d.Apply(&config.Config{Targets: targets})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+1 -0 ~1 =1" || !slices.Equal(built, []string{"b", "c"}) {
+		t.Fatalf("expected only b and c to be built, got %s and %v", changes, built)
+	}
+
+	// A kept target whose merged labels change is built after all
+	built = nil
+	targets = append(targets, config.Target{Name: "a2", URL: "tcp://10.0.0.1:80", Labels: map[string]string{"zone": "b"}})
+	if changes, err = d.Apply(&config.Config{Targets: targets}); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(changes.Changed, []string{"a"}) || !slices.Equal(built, []string{"a2", "a"}) {
+		t.Fatalf("expected a to be rebuilt with merged labels, got %s and %v", changes, built)
+	}
+}
+
+// downPing fails every probe.
+type downPing struct{}
+
+func (downPing) Ping(ctx context.Context) *pinger.Stats {
+	return &pinger.Stats{Error: errors.New("connection refused")}
+}
+
+func TestApply_Composite(t *testing.T) {
+	d := New(io.Discard, func(target config.Target, defaults config.Defaults) (*url.URL, pinger.Ping, error) {
+		u, err := url.Parse(target.URL)
+		if u.Hostname() == "down" {
+			return u, downPing{}, err
+		}
+		return u, fakePing{}, err
+	})
+	defer d.Stop()
+
+	interval := config.Duration(10 * time.Millisecond)
+	targets := []config.Target{
+		{Name: "etcd-1", URL: "tcp://up:2379", Interval: interval},
+		{Name: "etcd-1b", URL: "tcp://up:2379", Interval: interval},
+		{Name: "etcd-2", URL: "tcp://down:2379", Interval: interval},
+	}
+	changes, err := d.Apply(&config.Config{Targets: targets, Composites: []config.Composite{
+		{Name: "etcd", Targets: []string{"etcd-1b", "etcd-2"}, Quorum: 1, Interval: interval},
+		{Name: "etcd-all", Targets: []string{"etcd-1", "etcd-2"}, Quorum: 2, Interval: interval},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+4 -0 ~0 =0" {
+		t.Fatalf("unexpected changes %s", changes)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if state := d.probes["etcd"].pinger.State(); !state.Up || state.Total == 0 {
+		t.Fatalf("a composite with its quorum up is down: %+v", state)
+	}
+	if state := d.probes["etcd-all"].pinger.State(); state.Up || state.Total == 0 {
+		t.Fatalf("a composite short of its quorum is up: %+v", state)
+	}
+	if targets := d.Targets(); !slices.Contains(targets, "quorum://etcd") {
+		t.Fatalf("composite not listed in %v", targets)
+	}
+
+	// Composites of unchanged members keep running
+	kept := d.probes["etcd"].pinger
+	changes, err = d.Apply(&config.Config{Targets: targets, Composites: []config.Composite{
+		{Name: "etcd", Targets: []string{"etcd-1b", "etcd-2"}, Quorum: 1, Interval: interval},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes.String() != "+0 -1 ~0 =3" || d.probes["etcd"].pinger != kept {
+		t.Fatalf("unexpected changes %s", changes)
+	}
+}
+
+func TestState(t *testing.T) {
+	path := t.TempDir() + "/state.json"
+	cfg := &config.Config{Targets: []config.Target{{Name: "a", URL: "tcp://a:80", Interval: config.Duration(time.Millisecond)}}}
+
+	d := New(io.Discard, build)
+	d.lookup = noLookup
+	if err := d.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	restarted := New(io.Discard, build)
+	if err := restarted.LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.Apply(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer restarted.Stop()
+	if state := restarted.probes["a"].pinger.State(); state.Total == 0 || !state.Up {
+		t.Fatalf("state was not restored: %+v", state)
+	}
+}
+
+func TestSelf(t *testing.T) {
+	ping := &selfPing{interval: 20 * time.Millisecond}
+	stats := ping.Ping(context.Background())
+	if !stats.Connected || stats.Meta["schedule_delay"] != time.Duration(0) {
+		t.Fatalf("first heartbeat: connected=%v meta=%s", stats.Connected, stats.FormatMeta())
+	}
+
+	// A loop stalled for more than an interval is reported as behind
+	time.Sleep(50 * time.Millisecond)
+	stats = ping.Ping(context.Background())
+	if stats.Connected || stats.Meta["schedule_delay"].(time.Duration) < 30*time.Millisecond {
+		t.Fatalf("stalled heartbeat: connected=%v meta=%s", stats.Connected, stats.FormatMeta())
+	}
+
+	d := New(io.Discard, build)
+	d.lookup = noLookup
+	d.StartSelf(time.Second)
+	if targets := d.Targets(); len(targets) != 1 || targets[0] != SelfURL.String() {
+		t.Fatalf("unexpected targets %v", targets)
+	}
+	if err := d.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	if targets := d.Targets(); len(targets) != 0 {
+		t.Fatalf("heartbeat still listed after Stop: %v", targets)
+	}
+}
@@ -0,0 +1,86 @@
+package daemon
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// SelfURL is the target of the prober's heartbeat.
+var SelfURL = &url.URL{Scheme: "self", Host: "prober"}
+
+// selfPing probes the prober itself: how late its loop runs compared to the
+// schedule and how long a new goroutine waits to be scheduled. Its results
+// keep flowing while the prober is healthy, so missing metrics of external
+// targets can be told apart from a wedged prober.
+type selfPing struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	last time.Time // when the previous probe returned
+}
+
+// Ping implements pinger.Ping.
+func (s *selfPing) Ping(ctx context.Context) *pinger.Stats {
+	start := time.Now()
+	s.mu.Lock()
+	var delay time.Duration
+	if !s.last.IsZero() {
+		delay = max(start.Sub(s.last.Add(s.interval)), 0)
+	}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.last = time.Now()
+		s.mu.Unlock()
+	}()
+
+	woke := make(chan time.Time, 1)
+	go func() { woke <- time.Now() }()
+	var wakeup time.Duration
+	select {
+	case t := <-woke:
+		wakeup = t.Sub(start)
+	case <-ctx.Done():
+		return &pinger.Stats{Error: ctx.Err(), Duration: time.Since(start)}
+	}
+
+	stats := &pinger.Stats{
+		Connected: true,
+		Address:   "self",
+		Duration:  time.Since(start),
+		Meta: map[string]fmt.Stringer{
+			"schedule_delay":  delay,
+			"goroutine_delay": wakeup,
+			"goroutines":      pinger.StringerFunc(func() string { return fmt.Sprint(runtime.NumGoroutine()) }),
+		},
+	}
+	// A loop a whole interval behind schedule can no longer keep up
+	if delay > s.interval {
+		stats.Connected = false
+		stats.Error = fmt.Errorf("prober loop is %s behind schedule", delay.Round(time.Millisecond))
+	}
+	return stats
+}
+
+// StartSelf starts the heartbeat target SelfURL, probed every interval. It is
+// listed by Targets and stopped by Stop like the configured targets.
+func (d *Daemon) StartSelf(interval time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p := &probe{
+		url:    SelfURL,
+		pinger: pinger.NewPinger(d.out, SelfURL, &selfPing{interval: interval}, interval, 0, interval),
+		done:   make(chan struct{}),
+	}
+	if d.sink != nil {
+		p.pinger.SetSink(d.sink)
+	}
+	d.self = p
+	p.start()
+}
@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// stateVersion is the version of the state file format.
+const stateVersion = 1
+
+// stateFile is the on-disk representation of the daemon's target states.
+type stateFile struct {
+	Version int                    `json:"version"`
+	Saved   time.Time              `json:"saved"`
+	Targets map[string]targetState `json:"targets"`
+}
+
+// targetState is the saved state of one target. The URL guards against
+// restoring the state of a different target that reuses the same name.
+type targetState struct {
+	URL   string       `json:"url"`
+	State pinger.State `json:"state"`
+}
+
+// LoadState reads the state file at path, whose target states are restored
+// when the matching targets are started, and remembers path for SaveState.
+// A missing file is not an error.
+func (d *Daemon) LoadState(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.statePath = path
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var file stateFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return err
+	}
+	d.saved = file.Targets
+	return nil
+}
+
+// SaveState writes the state of every running target to the state file
+// configured with LoadState. It does nothing when no state file is set.
+func (d *Daemon) SaveState() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.saveState()
+}
+
+// saveState writes the state file atomically. The caller must hold mu.
+func (d *Daemon) saveState() error {
+	if d.statePath == "" {
+		return nil
+	}
+	file := stateFile{
+		Version: stateVersion,
+		Saved:   time.Now(),
+		Targets: make(map[string]targetState, len(d.probes)),
+	}
+	for key, p := range d.probes {
+		file.Targets[key] = targetState{URL: p.url.String(), State: p.pinger.State()}
+	}
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so a crash never leaves a torn file
+	tmp, err := os.CreateTemp(filepath.Dir(d.statePath), filepath.Base(d.statePath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), d.statePath)
+}
+
+// restore seeds a newly built probe with its saved state, if any. Saved
+// states are used once so a target re-added later starts fresh.
+func (d *Daemon) restore(key string, p *probe) {
+	saved, ok := d.saved[key]
+	if !ok {
+		return
+	}
+	delete(d.saved, key)
+	if saved.URL == p.url.String() {
+		p.pinger.Restore(saved.State)
+	}
+}
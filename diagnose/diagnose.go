@@ -0,0 +1,129 @@
+// Package diagnose runs a battery of checks for middleboxes interfering with
+// traffic to a target: MSS clamping, ECN stripping, TLS interception, DNS
+// hijacking and UDP blocking. Each check reports a finding rather than
+// failing, since a check that cannot run says little about the others.
+package diagnose
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds every check unless configured otherwise.
+const DefaultTimeout = 5 * time.Second
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	OK      Status = iota // No interference found
+	Suspect               // Interference is likely
+	Skipped               // The check could not run
+)
+
+// String returns the status as shown in reports.
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Suspect:
+		return "suspect"
+	}
+	return "skipped"
+}
+
+// Finding is the outcome of a check with an explanation.
+type Finding struct {
+	Check  string
+	Status Status
+	Detail string
+}
+
+// Resolver looks up host names; *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// Config is what the checks probe.
+type Config struct {
+	Host    string // Target host
+	Port    int    // TCP port of the target, for the mss and ecn checks
+	TLSPort int    // Port of the target speaking TLS, for the tls check
+
+	// Resolver is checked for hijacking; nil for the system resolver.
+	Resolver Resolver
+	// KnownAnswers maps names to every address they may resolve to; nil
+	// for DefaultKnownAnswers.
+	KnownAnswers map[string][]string
+	// DNSServer is queried over UDP and TCP by the udp check; empty for
+	// DefaultDNSServer.
+	DNSServer string
+	// Roots verifies the certificate of the tls check; nil for the system
+	// roots.
+	Roots *x509.CertPool
+	// Timeout bounds every check; zero for DefaultTimeout.
+	Timeout time.Duration
+}
+
+// Check is a named diagnostic.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context, cfg *Config) Finding
+}
+
+// Checks are all checks in report order.
+var Checks = []Check{
+	{Name: "mss", Run: checkMSS},
+	{Name: "ecn", Run: checkECN},
+	{Name: "tls", Run: checkTLS},
+	{Name: "dns", Run: checkDNS},
+	{Name: "udp", Run: checkUDP},
+}
+
+// Run runs checks concurrently, each within the timeout of cfg, and returns
+// their findings in the order of checks.
+func Run(ctx context.Context, cfg *Config, checks []Check) []Finding {
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	findings := make([]Finding, len(checks))
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			findings[i] = c.Run(checkCtx, cfg)
+			findings[i].Check = c.Name
+		}()
+	}
+	wg.Wait()
+	return findings
+}
+
+// hostPort joins the target host with port.
+func (cfg *Config) hostPort(port int) string {
+	return net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+}
+
+// skipped returns a finding for a check that could not run.
+func skipped(format string, args ...any) Finding {
+	return Finding{Status: Skipped, Detail: fmt.Sprintf(format, args...)}
+}
+
+// suspect returns a finding of likely interference.
+func suspect(format string, args ...any) Finding {
+	return Finding{Status: Suspect, Detail: fmt.Sprintf(format, args...)}
+}
+
+// ok returns a finding of no interference.
+func ok(format string, args ...any) Finding {
+	return Finding{Status: OK, Detail: fmt.Sprintf(format, args...)}
+}
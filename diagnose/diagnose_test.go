@@ -0,0 +1,78 @@
+package diagnose
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+)
+
+func TestMSSFinding(t *testing.T) {
+	for _, tc := range []struct {
+		mss, mtu   int
+		ipv6, ts   bool
+		wantStatus Status
+	}{
+		{mss: 1448, mtu: 1500, ts: true, wantStatus: OK},
+		{mss: 1460, mtu: 1500, wantStatus: OK},
+		{mss: 1428, mtu: 1500, ipv6: true, ts: true, wantStatus: OK},
+		{mss: 1448, mtu: 65536, ts: true, wantStatus: OK},
+		{mss: 1380, mtu: 1500, ts: true, wantStatus: Suspect},
+	} {
+		if f := mssFinding(tc.mss, tc.mtu, tc.ipv6, tc.ts); f.Status != tc.wantStatus {
+			t.Errorf("mss %d, mtu %d: got %s (%s), want %s", tc.mss, tc.mtu, f.Status, f.Detail, tc.wantStatus)
+		}
+	}
+}
+
+func TestCheckTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	port, _ := strconv.Atoi(u.Port())
+	// The test certificate is issued for example.com and 127.0.0.1
+	cfg := &Config{Host: "127.0.0.1", TLSPort: port}
+
+	findings := Run(context.Background(), cfg, []Check{{Name: "tls", Run: checkTLS}})
+	if f := findings[0]; f.Check != "tls" || f.Status != Suspect {
+		t.Fatalf("an untrusted certificate should be suspect, got %+v", f)
+	}
+	cfg.Roots = srv.Client().Transport.(*http.Transport).TLSClientConfig.RootCAs
+	if f := Run(context.Background(), cfg, []Check{{Name: "tls", Run: checkTLS}})[0]; f.Status != OK {
+		t.Fatalf("a trusted certificate should be ok, got %+v", f)
+	}
+}
+
+// resolver answers lookups from a map.
+type resolver map[string][]string
+
+func (r resolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	if addrs, ok := r["*"]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func TestCheckDNS(t *testing.T) {
+	known := map[string][]string{"dns.example": {"192.0.2.1", "2001:db8::1"}}
+	for name, tc := range map[string]struct {
+		resolver resolver
+		want     Status
+	}{
+		"honest":    {resolver: resolver{"dns.example": {"192.0.2.1"}}, want: OK},
+		"rewritten": {resolver: resolver{"dns.example": {"198.51.100.7"}}, want: Suspect},
+		"nxdomain":  {resolver: resolver{"dns.example": {"192.0.2.1"}, "*": {"198.51.100.7"}}, want: Suspect},
+		"offline":   {resolver: resolver{}, want: Skipped},
+	} {
+		f := checkDNS(context.Background(), &Config{Resolver: tc.resolver, KnownAnswers: known})
+		if f.Status != tc.want {
+			t.Errorf("%s: got %s (%s), want %s", name, f.Status, f.Detail, tc.want)
+		}
+	}
+}
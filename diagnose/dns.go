@@ -0,0 +1,152 @@
+package diagnose
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net"
+	"slices"
+	"strings"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DefaultKnownAnswers are names of public resolvers with the addresses
+// their operators publish.
+var DefaultKnownAnswers = map[string][]string{
+	"one.one.one.one": {"1.1.1.1", "1.0.0.1", "2606:4700:4700::1111", "2606:4700:4700::1001"},
+	"dns.google":      {"8.8.8.8", "8.8.4.4", "2001:4860:4860::8888", "2001:4860:4860::8844"},
+}
+
+// DefaultDNSServer is queried by the udp check.
+const DefaultDNSServer = "1.1.1.1:53"
+
+// checkDNS looks up names with known answers and a name that cannot exist.
+// Resolvers rewriting answers, or answering NXDOMAIN with an address of
+// their own, are hijacking lookups.
+func checkDNS(ctx context.Context, cfg *Config) Finding {
+	var r Resolver = net.DefaultResolver
+	if cfg.Resolver != nil {
+		r = cfg.Resolver
+	}
+	known := cfg.KnownAnswers
+	if known == nil {
+		known = DefaultKnownAnswers
+	}
+
+	names := make([]string, 0, len(known))
+	for name := range known {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	answered := 0
+	for _, name := range names {
+		addrs, err := r.LookupHost(ctx, name)
+		if err != nil {
+			continue
+		}
+		answered++
+		for _, addr := range addrs {
+			if !slices.Contains(known[name], addr) {
+				return suspect("%s resolved to %s instead of %s", name, addr, strings.Join(known[name], ", "))
+			}
+		}
+	}
+	if answered == 0 {
+		return skipped("none of %s resolved", strings.Join(names, ", "))
+	}
+
+	// RFC 6761 reserves .invalid, so no name below it exists
+	nx := randomLabel() + ".invalid"
+	if addrs, err := r.LookupHost(ctx, nx); err == nil {
+		return suspect("the nonexistent %s resolved to %s: the resolver rewrites NXDOMAIN", nx, strings.Join(addrs, ", "))
+	}
+	return ok("%d known answers matched and a nonexistent name did not resolve", answered)
+}
+
+// randomLabel returns a DNS label unlikely to exist or be cached.
+func randomLabel() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return "circle-pinger-" + hex.EncodeToString(b)
+}
+
+// checkUDP sends the same DNS query over UDP and TCP. An answer over TCP
+// alone means UDP is blocked on the path.
+func checkUDP(ctx context.Context, cfg *Config) Finding {
+	server := cfg.DNSServer
+	if server == "" {
+		server = DefaultDNSServer
+	}
+	tcpErr := exchange(ctx, "tcp", server)
+	udpErr := exchange(ctx, "udp", server)
+	switch {
+	case udpErr == nil:
+		return ok("%s answered over UDP", server)
+	case tcpErr == nil:
+		return suspect("%s answered over TCP but not over UDP (%v): UDP is blocked", server, udpErr)
+	}
+	return skipped("%s answered over neither UDP nor TCP: %v", server, tcpErr)
+}
+
+// exchange sends a DNS query for "." to server over network and reads the
+// answer.
+func exchange(ctx context.Context, network, server string) error {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, server)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var idBytes [2]byte
+	rand.Read(idBytes[:])
+	id := binary.BigEndian.Uint16(idBytes[:])
+	query, err := (&dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: dnsmessage.MustNewName("."), Type: dnsmessage.TypeNS, Class: dnsmessage.ClassINET}},
+	}).Pack()
+	if err != nil {
+		return err
+	}
+	answer := make([]byte, 4096)
+	n := 0
+	if network == "tcp" {
+		// DNS over TCP prefixes messages with their length
+		query = append(binary.BigEndian.AppendUint16(nil, uint16(len(query))), query...)
+		if _, err := conn.Write(query); err != nil {
+			return err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return err
+		}
+		n = min(int(binary.BigEndian.Uint16(size[:])), len(answer))
+		if _, err := io.ReadFull(conn, answer[:n]); err != nil {
+			return err
+		}
+	} else {
+		if _, err := conn.Write(query); err != nil {
+			return err
+		}
+		if n, err = conn.Read(answer); err != nil {
+			return err
+		}
+	}
+
+	var p dnsmessage.Parser
+	header, err := p.Start(answer[:n])
+	if err != nil {
+		return err
+	}
+	if header.ID != id {
+		return errors.New("answer to another query")
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package diagnose
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/tcpinfo"
+)
+
+// Header sizes subtracted from the MTU to get the MSS.
+const (
+	ipv4Headers    = 40 // IPv4 and TCP
+	ipv6Headers    = 60 // IPv6 and TCP
+	timestampsSize = 12 // TCP timestamps option, padded
+	// ethernetMTU caps the expected MTU: beyond the local network the path
+	// rarely carries larger packets, and loopback MTUs are huge.
+	ethernetMTU = 1500
+)
+
+// ecnSysctl tells whether this host requests ECN on outgoing connections.
+const ecnSysctl = "/proc/sys/net/ipv4/tcp_ecn"
+
+// connect opens a TCP connection to the target and reads what it
+// negotiated.
+func connect(ctx context.Context, cfg *Config) (net.Conn, *tcpinfo.Info, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", cfg.hostPort(cfg.Port))
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := tcpinfo.Read(conn)
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	return conn, info, nil
+}
+
+// checkMSS compares the negotiated MSS with what the MTU of the outgoing
+// interface allows. Middleboxes rewriting the MSS option, often to work
+// around broken path MTU discovery, lower it.
+func checkMSS(ctx context.Context, cfg *Config) Finding {
+	conn, info, err := connect(ctx, cfg)
+	if err != nil {
+		return skipped("%v", err)
+	}
+	defer conn.Close()
+	local := conn.LocalAddr().(*net.TCPAddr).IP
+	mtu, err := interfaceMTU(local)
+	if err != nil {
+		return skipped("MTU of the interface of %s: %v", local, err)
+	}
+	return mssFinding(info.MSS, mtu, local.To4() == nil, info.Timestamps)
+}
+
+// mssFinding judges the negotiated mss against the mtu of the outgoing
+// interface.
+func mssFinding(mss, mtu int, ipv6, timestamps bool) Finding {
+	want := min(mtu, ethernetMTU) - ipv4Headers
+	if ipv6 {
+		want = min(mtu, ethernetMTU) - ipv6Headers
+	}
+	if timestamps {
+		want -= timestampsSize
+	}
+	if mss < want {
+		return suspect("MSS is %d where the MTU of %d allows %d: a middlebox clamps it, or the server or path has a smaller MTU", mss, mtu, want)
+	}
+	return ok("MSS is %d, as the MTU of %d allows", mss, mtu)
+}
+
+// interfaceMTU returns the MTU of the interface holding ip.
+func interfaceMTU(ip net.IP) (int, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return 0, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if n, ok := addr.(*net.IPNet); ok && n.IP.Equal(ip) {
+				return iface.MTU, nil
+			}
+		}
+	}
+	return 0, errors.New("no interface found")
+}
+
+// checkECN tells whether ECN, requested by this host, was negotiated.
+// Middleboxes clearing the ECN bits of the SYN make it fail.
+func checkECN(ctx context.Context, cfg *Config) Finding {
+	b, err := os.ReadFile(ecnSysctl)
+	if err != nil {
+		return skipped("cannot tell whether this host requests ECN: %v", err)
+	}
+	if mode := strings.TrimSpace(string(b)); mode != "1" {
+		return skipped("this host does not request ECN (net.ipv4.tcp_ecn = %s); set it to 1 to run this check", mode)
+	}
+	conn, info, err := connect(ctx, cfg)
+	if err != nil {
+		return skipped("%v", err)
+	}
+	conn.Close()
+	if !info.ECN {
+		return suspect("ECN was requested but not negotiated: a middlebox strips it, or the server does not support it")
+	}
+	return ok("ECN was negotiated")
+}
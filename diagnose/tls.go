@@ -0,0 +1,66 @@
+package diagnose
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"strings"
+)
+
+// InterceptionIssuers are parts of the issuer names of TLS inspection
+// products. Their certificates verify on machines with the product's root
+// installed, so a trusted chain does not rule out interception.
+var InterceptionIssuers = []string{
+	"Zscaler", "Fortinet", "FortiGate", "Palo Alto", "Blue Coat", "Cisco Umbrella",
+	"Netskope", "Sophos", "Forcepoint", "Kaspersky", "Avast", "ESET", "Bitdefender",
+	"mitmproxy", "Charles Proxy", "Fiddler", "Burp",
+}
+
+// checkTLS verifies the certificate of the target and looks for issuers of
+// TLS inspection products in its chain.
+func checkTLS(ctx context.Context, cfg *Config) Finding {
+	d := tls.Dialer{Config: &tls.Config{ServerName: cfg.Host, InsecureSkipVerify: true}}
+	conn, err := d.DialContext(ctx, "tcp", cfg.hostPort(cfg.TLSPort))
+	if err != nil {
+		return skipped("%v", err)
+	}
+	defer conn.Close()
+	return tlsFinding(cfg.Host, conn.(*tls.Conn).ConnectionState().PeerCertificates, cfg.Roots)
+}
+
+// tlsFinding judges the certificate chain presented for host.
+func tlsFinding(host string, chain []*x509.Certificate, roots *x509.CertPool) Finding {
+	if len(chain) == 0 {
+		return skipped("no certificate presented")
+	}
+	for _, cert := range chain {
+		if product := interceptor(cert); product != "" {
+			return suspect("the chain is issued by %q, a TLS inspection product", product)
+		}
+	}
+
+	leaf := chain[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range chain[1:] {
+		intermediates.AddCert(cert)
+	}
+	issuer := leaf.Issuer.String()
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots, Intermediates: intermediates}); err != nil {
+		return suspect("the certificate issued by %q does not verify (%v): TLS is intercepted, or the server uses a private CA", issuer, err)
+	}
+	return ok("the certificate is issued by %q and verifies", issuer)
+}
+
+// interceptor returns the issuer of cert naming an inspection product, or
+// "".
+func interceptor(cert *x509.Certificate) string {
+	names := append([]string{cert.Issuer.CommonName}, cert.Issuer.Organization...)
+	for _, name := range names {
+		for _, product := range InterceptionIssuers {
+			if strings.Contains(strings.ToLower(name), strings.ToLower(product)) {
+				return name
+			}
+		}
+	}
+	return ""
+}
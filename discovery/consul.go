@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/config"
+)
+
+// DefaultConsulAddress is the address of the local Consul agent.
+const DefaultConsulAddress = "http://127.0.0.1:8500"
+
+// Consul discovers the instances of a service in the Consul catalog.
+type Consul struct {
+	Config config.ConsulDiscovery
+	Client *http.Client
+}
+
+// consulService is the part of a catalog entry discovery uses.
+type consulService struct {
+	Node           string
+	Address        string
+	ServiceID      string
+	ServiceAddress string
+	ServicePort    int
+}
+
+// Discover implements Provider.
+func (c *Consul) Discover(ctx context.Context) ([]Instance, error) {
+	address := c.Config.Address
+	if address == "" {
+		address = DefaultConsulAddress
+	}
+	query := url.Values{}
+	if c.Config.Tag != "" {
+		query.Set("tag", c.Config.Tag)
+	}
+	if c.Config.Datacenter != "" {
+		query.Set("dc", c.Config.Datacenter)
+	}
+	endpoint := strings.TrimSuffix(address, "/") + "/v1/catalog/service/" + url.PathEscape(c.Config.Service)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.Config.Token != "" {
+		req.Header.Set("X-Consul-Token", c.Config.Token)
+	}
+
+	var services []consulService
+	if err := getJSON(c.Client, req, &services); err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, 0, len(services))
+	for _, s := range services {
+		// The service address is empty when it is the node's
+		host := s.ServiceAddress
+		if host == "" {
+			host = s.Address
+		}
+		instances = append(instances, Instance{
+			Host:   host,
+			Port:   s.ServicePort,
+			Labels: map[string]string{"consul_node": s.Node, "consul_service_id": s.ServiceID},
+		})
+	}
+	return instances, nil
+}
+
+// getJSON sends req and decodes the JSON response into v.
+func getJSON(client *http.Client, req *http.Request, v any) error {
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", req.URL.Redacted(), resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,161 @@
+// Package discovery keeps the targets of a daemon in sync with service
+// registries: DNS A and SRV records, the Consul catalog and Kubernetes
+// Endpoints. Every discovered instance becomes a target of its own, so its
+// statistics are kept for as long as the instance exists.
+package discovery
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+)
+
+// DefaultRefresh is how often instances are refreshed when the
+// configuration does not say.
+const DefaultRefresh = 30 * time.Second
+
+// Instance is a discovered endpoint of a service.
+type Instance struct {
+	Host   string
+	Port   int
+	Labels map[string]string // provider details, such as the Kubernetes pod
+}
+
+// ID returns the identity of the instance, its host:port.
+func (i Instance) ID() string {
+	return net.JoinHostPort(i.Host, strconv.Itoa(i.Port))
+}
+
+// Provider lists the current instances of a service.
+type Provider interface {
+	Discover(ctx context.Context) ([]Instance, error)
+}
+
+// New creates the provider configured by d. DNS discovery uses resolver, or
+// the default resolver when it is nil.
+func New(d config.Discovery, resolver *net.Resolver) (Provider, error) {
+	switch {
+	case d.DNS != nil:
+		if resolver == nil {
+			resolver = net.DefaultResolver
+		}
+		return &DNS{Config: *d.DNS, Resolver: resolver}, nil
+	case d.Consul != nil:
+		return &Consul{Config: *d.Consul, Client: http.DefaultClient}, nil
+	case d.Kubernetes != nil:
+		return NewKubernetes(*d.Kubernetes)
+	default:
+		return nil, fmt.Errorf("discovery %s: no provider", d.Name)
+	}
+}
+
+// Set refreshes the discoveries of a configuration and turns their
+// instances into targets. Targets and Config may be called while a Refresh
+// runs.
+type Set struct {
+	mu      sync.Mutex // guards the instances of the entries
+	entries []*entry
+}
+
+// entry is a discovery with its last instances.
+type entry struct {
+	config    config.Discovery
+	provider  Provider
+	next      time.Time
+	instances []Instance // sorted by ID
+}
+
+// NewSet creates the providers of discoveries.
+func NewSet(discoveries []config.Discovery, resolver *net.Resolver) (*Set, error) {
+	s := &Set{}
+	for _, d := range discoveries {
+		provider, err := New(d, resolver)
+		if err != nil {
+			return nil, err
+		}
+		s.entries = append(s.entries, &entry{config: d, provider: provider})
+	}
+	return s, nil
+}
+
+// Len returns the number of discoveries.
+func (s *Set) Len() int {
+	return len(s.entries)
+}
+
+// Refresh queries the providers whose refresh interval has elapsed by now
+// and reports whether any instance was added or removed. A provider that
+// fails keeps its previous instances, so a registry outage does not remove
+// targets; the errors are returned joined.
+func (s *Set) Refresh(ctx context.Context, now time.Time) (bool, error) {
+	changed := false
+	var errs []error
+	for _, e := range s.entries {
+		if now.Before(e.next) {
+			continue
+		}
+		refresh := e.config.Refresh.Std()
+		if refresh <= 0 {
+			refresh = DefaultRefresh
+		}
+		e.next = now.Add(refresh)
+
+		instances, err := e.provider.Discover(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("discovery %s: %w", e.config.Name, err))
+			continue
+		}
+		slices.SortFunc(instances, func(a, b Instance) int { return cmp.Compare(a.ID(), b.ID()) })
+		instances = slices.CompactFunc(instances, func(a, b Instance) bool { return a.ID() == b.ID() })
+		s.mu.Lock()
+		if !reflect.DeepEqual(instances, e.instances) {
+			e.instances = instances
+			changed = true
+		}
+		s.mu.Unlock()
+	}
+	return changed, errors.Join(errs...)
+}
+
+// Targets returns a target per discovered instance, named
+// "<discovery>/<host>:<port>" and labelled with the discovery name and the
+// details of the instance.
+func (s *Set) Targets() []config.Target {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var targets []config.Target
+	for _, e := range s.entries {
+		for _, instance := range e.instances {
+			t := e.config.Target
+			t.Name = e.config.Name + "/" + instance.ID()
+			t.URL = config.ExpandURL(t.URL, instance.Host, instance.Port)
+			labels := map[string]string{"discovery": e.config.Name}
+			for k, v := range instance.Labels {
+				labels[k] = v
+			}
+			for k, v := range e.config.Target.Labels {
+				labels[k] = v
+			}
+			t.Labels = labels
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// Config returns a copy of cfg probing the discovered targets too.
+func (s *Set) Config(cfg *config.Config) *config.Config {
+	merged := *cfg
+	merged.Targets = append(slices.Clip(cfg.Targets), s.Targets()...)
+	return &merged
+}
@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/config"
+)
+
+func TestConsul(t *testing.T) {
+	services := []consulService{
+		{Node: "n1", Address: "10.0.0.1", ServiceID: "api-1", ServicePort: 8080},
+		{Node: "n2", Address: "10.0.0.2", ServiceID: "api-2", ServiceAddress: "10.1.0.2", ServicePort: 8080},
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/catalog/service/api" || r.URL.Query().Get("tag") != "primary" || r.Header.Get("X-Consul-Token") != "secret" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(services)
+	}))
+	defer server.Close()
+
+	set, err := NewSet([]config.Discovery{{
+		Name:    "api",
+		Refresh: config.Duration(time.Minute),
+		Consul:  &config.ConsulDiscovery{Address: server.URL, Service: "api", Tag: "primary", Token: "secret"},
+		Target:  config.Target{URL: "http://{host}:{port}/health", Labels: map[string]string{"team": "core"}},
+	}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if changed, err := set.Refresh(context.Background(), now); err != nil || !changed {
+		t.Fatalf("expected a change, got %v %v", changed, err)
+	}
+	targets := set.Targets()
+	if len(targets) != 2 || targets[1].Name != "api/10.1.0.2:8080" || targets[1].URL != "http://10.1.0.2:8080/health" {
+		t.Fatalf("unexpected targets %+v", targets)
+	}
+	if labels := targets[0].Labels; labels["discovery"] != "api" || labels["team"] != "core" || labels["consul_node"] != "n1" {
+		t.Fatalf("unexpected labels %v", labels)
+	}
+
+	// Not due yet, so the removal is only seen on the next refresh.
+	services = services[:1]
+	if changed, _ := set.Refresh(context.Background(), now.Add(time.Second)); changed {
+		t.Fatal("refreshed before the refresh interval")
+	}
+	if changed, _ := set.Refresh(context.Background(), now.Add(time.Minute)); !changed || len(set.Targets()) != 1 {
+		t.Fatalf("expected the removal, got %+v", set.Targets())
+	}
+
+	// A failing registry keeps the last instances.
+	server.Close()
+	if _, err := set.Refresh(context.Background(), now.Add(2*time.Minute)); err == nil || len(set.Targets()) != 1 {
+		t.Fatalf("expected an error and the last instances, got %v %+v", err, set.Targets())
+	}
+}
+
+func TestKubernetes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/prod/endpoints" || r.URL.Query().Get("labelSelector") != "app=api" || r.Header.Get("Authorization") != "Bearer token" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"items": [{
+			"metadata": {"name": "api"},
+			"subsets": [{
+				"addresses": [{"ip": "10.2.0.1", "targetRef": {"kind": "Pod", "name": "api-7f9c"}}],
+				"notReadyAddresses": [{"ip": "10.2.0.2"}],
+				"ports": [{"name": "metrics", "port": 9090}, {"name": "http", "port": 8080}]
+			}]
+		}]}`))
+	}))
+	defer server.Close()
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("token\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	k, err := NewKubernetes(config.KubernetesDiscovery{API: server.URL, Namespace: "prod", Selector: "app=api", Port: "http", TokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances, err := k.Discover(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(instances) != 1 || instances[0].ID() != "10.2.0.1:8080" || instances[0].Labels["kubernetes_pod"] != "api-7f9c" {
+		t.Fatalf("unexpected instances %+v", instances)
+	}
+
+	// A rotated token is read again, and a vanished one keeps the last
+	os.WriteFile(tokenFile, []byte("rotated\n"), 0o600)
+	if _, err := k.Discover(context.Background()); err == nil {
+		t.Fatal("expected the rotated token to be sent")
+	}
+	os.WriteFile(tokenFile, []byte("token\n"), 0o600)
+	if _, err := k.Discover(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(tokenFile)
+	if _, err := k.Discover(context.Background()); err != nil {
+		t.Fatalf("expected the last token without the file, got %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/config"
+)
+
+// DNS discovers the addresses of an A/AAAA name, or the hosts and ports of
+// an SRV name.
+type DNS struct {
+	Config   config.DNSDiscovery
+	Resolver *net.Resolver
+}
+
+// Discover implements Provider.
+func (d *DNS) Discover(ctx context.Context) ([]Instance, error) {
+	if strings.EqualFold(d.Config.Type, "srv") {
+		_, records, err := d.Resolver.LookupSRV(ctx, "", "", d.Config.Name)
+		if err != nil {
+			return nil, err
+		}
+		instances := make([]Instance, 0, len(records))
+		for _, r := range records {
+			instances = append(instances, Instance{Host: strings.TrimSuffix(r.Target, "."), Port: int(r.Port)})
+		}
+		return instances, nil
+	}
+
+	addrs, err := d.Resolver.LookupIPAddr(ctx, d.Config.Name)
+	if err != nil {
+		return nil, err
+	}
+	instances := make([]Instance, 0, len(addrs))
+	for _, addr := range addrs {
+		instances = append(instances, Instance{Host: addr.IP.String(), Port: d.Config.Port})
+	}
+	return instances, nil
+}
@@ -0,0 +1,162 @@
+package discovery
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/circle-protocol/circle-pinger/config"
+)
+
+const (
+	// DefaultKubernetesAPI is the API server as seen from inside a cluster.
+	DefaultKubernetesAPI = "https://kubernetes.default.svc"
+	// serviceAccountDir holds the credentials of the pod's service account.
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// Kubernetes discovers the ready endpoints of a Service, or of every
+// Service matching a label selector.
+type Kubernetes struct {
+	Config config.KubernetesDiscovery
+	Client *http.Client
+
+	tokenFile string
+	mu        sync.Mutex
+	token     string
+}
+
+// NewKubernetes creates a Kubernetes provider. Unless configured otherwise
+// it talks to the in-cluster API server with the pod's service account,
+// in the pod's namespace.
+func NewKubernetes(c config.KubernetesDiscovery) (*Kubernetes, error) {
+	k := &Kubernetes{Config: c, Client: http.DefaultClient}
+	if k.Config.API == "" {
+		k.Config.API = DefaultKubernetesAPI
+		pool := x509.NewCertPool()
+		if ca, err := os.ReadFile(serviceAccountDir + "/ca.crt"); err == nil && pool.AppendCertsFromPEM(ca) {
+			k.Client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}}}
+		}
+	}
+	if k.Config.Namespace == "" {
+		k.Config.Namespace = "default"
+		if ns, err := os.ReadFile(serviceAccountDir + "/namespace"); err == nil {
+			k.Config.Namespace = strings.TrimSpace(string(ns))
+		}
+	}
+	k.tokenFile = k.Config.TokenFile
+	if k.tokenFile == "" {
+		k.tokenFile = serviceAccountDir + "/token"
+	}
+	token, err := os.ReadFile(k.tokenFile)
+	switch {
+	case err == nil:
+		k.token = strings.TrimSpace(string(token))
+	case k.Config.TokenFile != "" || !errors.Is(err, os.ErrNotExist):
+		return nil, fmt.Errorf("kubernetes token: %w", err)
+	}
+	return k, nil
+}
+
+// bearer returns the service account token, read again for every discovery
+// since the kubelet rotates projected tokens. The last token read is kept
+// while the file cannot be read.
+func (k *Kubernetes) bearer() string {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.tokenFile == "" {
+		return k.token
+	}
+	if token, err := os.ReadFile(k.tokenFile); err == nil {
+		k.token = strings.TrimSpace(string(token))
+	}
+	return k.token
+}
+
+// kubeEndpoints is the part of an Endpoints object discovery uses.
+type kubeEndpoints struct {
+	Metadata struct {
+		Name string `json:"name"`
+	} `json:"metadata"`
+	Subsets []struct {
+		Addresses []struct {
+			IP        string `json:"ip"`
+			TargetRef *struct {
+				Kind string `json:"kind"`
+				Name string `json:"name"`
+			} `json:"targetRef"`
+		} `json:"addresses"`
+		Ports []struct {
+			Name string `json:"name"`
+			Port int    `json:"port"`
+		} `json:"ports"`
+	} `json:"subsets"`
+}
+
+// Discover implements Provider.
+func (k *Kubernetes) Discover(ctx context.Context) ([]Instance, error) {
+	endpoint := strings.TrimSuffix(k.Config.API, "/") + "/api/v1/namespaces/" + url.PathEscape(k.Config.Namespace) + "/endpoints"
+	if k.Config.Service != "" {
+		endpoint += "/" + url.PathEscape(k.Config.Service)
+	} else {
+		endpoint += "?labelSelector=" + url.QueryEscape(k.Config.Selector)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := k.bearer(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	var list []kubeEndpoints
+	if k.Config.Service != "" {
+		var endpoints kubeEndpoints
+		err = getJSON(k.Client, req, &endpoints)
+		list = append(list, endpoints)
+	} else {
+		var endpoints struct {
+			Items []kubeEndpoints `json:"items"`
+		}
+		err = getJSON(k.Client, req, &endpoints)
+		list = endpoints.Items
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for _, endpoints := range list {
+		for _, subset := range endpoints.Subsets {
+			port, ok := 0, false
+			for _, p := range subset.Ports {
+				if k.Config.Port == "" || p.Name == k.Config.Port || strconv.Itoa(p.Port) == k.Config.Port {
+					port, ok = p.Port, true
+					break
+				}
+			}
+			if !ok {
+				continue
+			}
+			for _, addr := range subset.Addresses {
+				labels := map[string]string{
+					"kubernetes_namespace": k.Config.Namespace,
+					"kubernetes_service":   endpoints.Metadata.Name,
+				}
+				if addr.TargetRef != nil && addr.TargetRef.Kind == "Pod" {
+					labels["kubernetes_pod"] = addr.TargetRef.Name
+				}
+				instances = append(instances, Instance{Host: addr.IP, Port: port, Labels: labels})
+			}
+		}
+	}
+	return instances, nil
+}
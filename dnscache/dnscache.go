@@ -0,0 +1,219 @@
+// Package dnscache reuses DNS answers for a window of time while enabled,
+// so that probes sent in quick succession, or to several targets on one
+// host, share a single query. It sits between the Go resolver and the DNS
+// servers, so every lookup through its resolver goes through it, including
+// those of HTTP and TLS dialers.
+package dnscache
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// DialFunc dials a DNS server, as net.Resolver.Dial.
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// Cache answers DNS queries from earlier answers no older than its window
+// while enabled reports true, and forwards them to the server otherwise.
+type Cache struct {
+	dial    DialFunc
+	window  time.Duration
+	enabled func() bool
+
+	mu      sync.Mutex
+	answers map[key]entry
+}
+
+// key identifies a cached answer.
+type key struct {
+	server   string
+	question dnsmessage.Question
+}
+
+// entry is a cached answer without its length prefix.
+type entry struct {
+	msg []byte
+	at  time.Time
+}
+
+// New creates a Cache forwarding queries with dial, nil for a plain dialer.
+func New(dial DialFunc, window time.Duration, enabled func() bool) *Cache {
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+	return &Cache{dial: dial, window: window, enabled: enabled, answers: make(map[key]entry)}
+}
+
+// Resolver returns a resolver looking up names through the cache.
+func (c *Cache) Resolver() *net.Resolver {
+	return &net.Resolver{PreferGo: true, Dial: c.Dial}
+}
+
+// Dial returns a connection to the DNS server at address that answers from
+// the cache when it can. Like the connections the Go resolver dials itself,
+// it is a net.PacketConn for "udp" and a stream with length-prefixed
+// messages otherwise.
+func (c *Cache) Dial(ctx context.Context, network, address string) (net.Conn, error) {
+	base := &conn{cache: c, ctx: ctx, network: network, address: address}
+	if network == "udp" || network == "udp4" || network == "udp6" {
+		return &packetConn{base}, nil
+	}
+	return base, nil
+}
+
+// lookup returns the cached answer to q from server, if fresh.
+func (c *Cache) lookup(k key) ([]byte, bool) {
+	if c.enabled != nil && !c.enabled() {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.answers[k]
+	if !ok || time.Since(e.at) > c.window {
+		return nil, false
+	}
+	return e.msg, true
+}
+
+// store caches msg, dropping stale answers.
+func (c *Cache) store(k key, msg []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	for old, e := range c.answers {
+		if now.Sub(e.at) > c.window {
+			delete(c.answers, old)
+		}
+	}
+	c.answers[k] = entry{msg: msg, at: now}
+}
+
+// conn exchanges one message at a time: a write is answered from the cache
+// or by the server, and the answer is read back.
+type conn struct {
+	cache    *Cache
+	ctx      context.Context
+	network  string
+	address  string
+	deadline time.Time
+	pending  bytes.Buffer
+}
+
+// Write answers the query in b, length-prefixed on streams.
+func (c *conn) Write(b []byte) (int, error) {
+	query := b
+	stream := c.network != "udp" && c.network != "udp4" && c.network != "udp6"
+	if stream {
+		if len(b) < 2 {
+			return 0, errors.New("dnscache: short write")
+		}
+		query = b[2:]
+	}
+	var p dnsmessage.Parser
+	header, err := p.Start(query)
+	if err != nil {
+		return 0, err
+	}
+	question, err := p.Question()
+	if err != nil {
+		return 0, err
+	}
+	k := key{server: c.address, question: question}
+
+	answer, ok := c.cache.lookup(k)
+	if ok {
+		// The answer must carry the ID of this query
+		answer = append([]byte(nil), answer...)
+		binary.BigEndian.PutUint16(answer, header.ID)
+	} else if answer, err = c.forward(b, stream); err != nil {
+		return 0, err
+	} else if cacheable(answer) {
+		c.cache.store(k, answer)
+	}
+	c.pending.Reset()
+	if stream {
+		c.pending.Write(binary.BigEndian.AppendUint16(nil, uint16(len(answer))))
+	}
+	c.pending.Write(answer)
+	return len(b), nil
+}
+
+// forward sends b to the server and returns its answer.
+func (c *conn) forward(b []byte, stream bool) ([]byte, error) {
+	server, err := c.cache.dial(c.ctx, c.network, c.address)
+	if err != nil {
+		return nil, err
+	}
+	defer server.Close()
+	if !c.deadline.IsZero() {
+		server.SetDeadline(c.deadline)
+	}
+	if _, err := server.Write(b); err != nil {
+		return nil, err
+	}
+	if !stream {
+		answer := make([]byte, 65535)
+		n, err := server.Read(answer)
+		return answer[:n], err
+	}
+	var size [2]byte
+	if _, err := io.ReadFull(server, size[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(size[:]))
+	_, err = io.ReadFull(server, answer)
+	return answer, err
+}
+
+// cacheable reports whether answer is complete and final: a success or a
+// name that does not exist, not truncated.
+func cacheable(answer []byte) bool {
+	var p dnsmessage.Parser
+	header, err := p.Start(answer)
+	return err == nil && !header.Truncated &&
+		(header.RCode == dnsmessage.RCodeSuccess || header.RCode == dnsmessage.RCodeNameError)
+}
+
+// Read reads the answer to the last query.
+func (c *conn) Read(b []byte) (int, error) {
+	if c.pending.Len() == 0 {
+		return 0, io.EOF
+	}
+	return c.pending.Read(b)
+}
+
+func (c *conn) Close() error                       { return nil }
+func (c *conn) LocalAddr() net.Addr                { return addr(c.network) }
+func (c *conn) RemoteAddr() net.Addr               { return addr(c.network) }
+func (c *conn) SetDeadline(t time.Time) error      { c.deadline = t; return nil }
+func (c *conn) SetReadDeadline(t time.Time) error  { return nil }
+func (c *conn) SetWriteDeadline(t time.Time) error { return nil }
+
+// packetConn is a conn the Go resolver treats as datagram-oriented.
+type packetConn struct {
+	*conn
+}
+
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, err := c.Read(b)
+	return n, c.RemoteAddr(), err
+}
+
+func (c *packetConn) WriteTo(b []byte, _ net.Addr) (int, error) {
+	return c.Write(b)
+}
+
+// addr is the address of a cache connection.
+type addr string
+
+func (a addr) Network() string { return string(a) }
+func (a addr) String() string  { return "dnscache" }
@@ -0,0 +1,77 @@
+package dnscache
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// serve answers every A query on conn with 192.0.2.1, counting the queries.
+func serve(conn net.PacketConn, queries *atomic.Int32) {
+	b := make([]byte, 512)
+	for {
+		n, from, err := conn.ReadFrom(b)
+		if err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if query.Unpack(b[:n]) != nil || len(query.Questions) != 1 {
+			continue
+		}
+		queries.Add(1)
+		q := query.Questions[0]
+		answer := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+		if q.Type == dnsmessage.TypeA {
+			answer.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: q.Class, TTL: 1},
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 1}},
+			}}
+		}
+		if msg, err := answer.Pack(); err == nil {
+			conn.WriteTo(msg, from)
+		}
+	}
+}
+
+func TestCache(t *testing.T) {
+	server, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	var queries atomic.Int32
+	go serve(server, &queries)
+
+	var enabled atomic.Bool
+	enabled.Store(true)
+	dial := func(ctx context.Context, network, address string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, server.LocalAddr().String())
+	}
+	r := New(dial, time.Minute, enabled.Load).Resolver()
+
+	lookup := func() {
+		t.Helper()
+		ips, err := r.LookupIP(context.Background(), "ip4", "probe.example.test.")
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.IPv4(192, 0, 2, 1)) {
+			t.Fatalf("unexpected answer %v, %v", ips, err)
+		}
+	}
+	lookup()
+	lookup()
+	if n := queries.Load(); n != 1 {
+		t.Fatalf("expected the second lookup to be answered from the cache, got %d queries", n)
+	}
+	enabled.Store(false)
+	lookup()
+	if n := queries.Load(); n != 2 {
+		t.Fatalf("expected a disabled cache to forward the query, got %d queries", n)
+	}
+}
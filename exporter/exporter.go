@@ -0,0 +1,263 @@
+// Package exporter exposes probe results as Prometheus metrics, so that
+// circle-pinger can stand in for a blackbox exporter on simple checks.
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	pinghttp "github.com/circle-protocol/circle-pinger/http"
+	"github.com/circle-protocol/circle-pinger/meta"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Exporter implements the pinger.Sink and http.Handler interfaces
+var (
+	_ pinger.Sink  = (*Exporter)(nil)
+	_ http.Handler = (*Exporter)(nil)
+)
+
+// Exporter keeps the last result of every target and serves them, with
+// probe counters, in the Prometheus text exposition format.
+type Exporter struct {
+	mu      sync.Mutex
+	targets map[string]*series
+}
+
+// series holds what is exported for one target.
+type series struct {
+	labels   string // formatted label set, including the target
+	last     *pinger.Record
+	lag      time.Duration // from the end of the last probe to Write
+	total    int
+	failures int
+	traffic  pinger.Traffic
+}
+
+// New creates an empty Exporter.
+func New() *Exporter {
+	return &Exporter{targets: make(map[string]*series)}
+}
+
+// Write implements pinger.Sink.
+func (e *Exporter) Write(record *pinger.Record) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	s, ok := e.targets[record.Target]
+	if !ok {
+		s = &series{labels: formatLabels(record.Target, record.Labels)}
+		e.targets[record.Target] = s
+	}
+	s.last = record
+	s.lag = time.Since(record.Timestamp.Add(record.Stats.Duration))
+	s.total++
+	s.traffic.Add(record.Stats.Traffic)
+	if !record.Stats.Connected {
+		s.failures++
+	}
+	return nil
+}
+
+// Close implements pinger.Sink.
+func (e *Exporter) Close() error {
+	return nil
+}
+
+// Retain drops the series of every target not in targets, so that targets
+// removed from the configuration disappear from the metrics.
+func (e *Exporter) Retain(targets []string) {
+	keep := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		keep[t] = true
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for t := range e.targets {
+		if !keep[t] {
+			delete(e.targets, t)
+		}
+	}
+}
+
+// ServeHTTP writes the metrics.
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var b bytes.Buffer
+	e.WriteMetrics(&b)
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(b.Bytes())
+}
+
+// metric is one exported metric family.
+type metric struct {
+	name, help, typ string
+	samples         func(s *series, add func(extra string, value float64))
+}
+
+// metrics lists the exported families in output order.
+var metrics = []metric{
+	{"probe_success", "Whether the last probe of the target succeeded.", "gauge",
+		func(s *series, add func(string, float64)) {
+			add("", boolValue(s.last.Stats.Connected))
+		}},
+	{"probe_duration_seconds", "Duration of the last probe of the target by phase.", "gauge",
+		func(s *series, add func(string, float64)) {
+			for _, p := range phases(s.last.Stats) {
+				add(`phase="`+p.name+`"`, p.d.Seconds())
+			}
+		}},
+	{"probe_ssl_earliest_cert_expiry", "Expiry of the target's TLS certificate as a Unix timestamp.", "gauge",
+		func(s *series, add func(string, float64)) {
+			if t, ok := certExpiry(s.last.Stats); ok {
+				add("", float64(t.Unix()))
+			}
+		}},
+	{"probe_last_timestamp_seconds", "Start time of the last probe of the target as a Unix timestamp.", "gauge",
+		func(s *series, add func(string, float64)) {
+			if !s.last.Timestamp.IsZero() {
+				add("", float64(s.last.Timestamp.UnixNano())/1e9)
+			}
+		}},
+	{"probe_delivery_lag_seconds", "Time the last record of the target took from the end of its probe to the exporter.", "gauge",
+		func(s *series, add func(string, float64)) {
+			if !s.last.Timestamp.IsZero() {
+				add("", s.lag.Seconds())
+			}
+		}},
+	{"probes_total", "Number of probes sent to the target.", "counter",
+		func(s *series, add func(string, float64)) { add("", float64(s.total)) }},
+	{"probe_failures_total", "Number of failed probes of the target.", "counter",
+		func(s *series, add func(string, float64)) { add("", float64(s.failures)) }},
+	{"probe_packets_total", "Estimated packets of the target's probes by direction.", "counter",
+		func(s *series, add func(string, float64)) {
+			add(`direction="sent"`, float64(s.traffic.PacketsSent))
+			add(`direction="received"`, float64(s.traffic.PacketsReceived))
+		}},
+	{"probe_bytes_total", "Estimated bytes of the target's probes by direction, IP headers included.", "counter",
+		func(s *series, add func(string, float64)) {
+			add(`direction="sent"`, float64(s.traffic.BytesSent))
+			add(`direction="received"`, float64(s.traffic.BytesReceived))
+		}},
+}
+
+// WriteMetrics writes the metrics in the Prometheus text format to b.
+func (e *Exporter) WriteMetrics(b *bytes.Buffer) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	keys := make([]string, 0, len(e.targets))
+	for key := range e.targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, m := range metrics {
+		header := false
+		for _, key := range keys {
+			s := e.targets[key]
+			m.samples(s, func(extra string, value float64) {
+				if !header {
+					fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+					header = true
+				}
+				labels := s.labels
+				if extra != "" {
+					labels += "," + extra
+				}
+				fmt.Fprintf(b, "%s{%s} %s\n", m.name, labels, strconv.FormatFloat(value, 'f', -1, 64))
+			})
+		}
+	}
+}
+
+// phase is a named part of a probe's duration.
+type phase struct {
+	name string
+	d    time.Duration
+}
+
+// phases returns the total duration of a probe and every phase reported by
+// its protocol, as DNS time, duration metadata or an HTTP trace.
+func phases(stats *pinger.Stats) []phase {
+	list := []phase{{"total", stats.Duration}}
+	if stats.DNSDuration > 0 {
+		list = append(list, phase{"resolve", stats.DNSDuration})
+	}
+	if trace, ok := stats.Extra.(*pinghttp.Trace); ok {
+		list = append(list,
+			phase{"connect", trace.ConnectDuration},
+			phase{"tls", trace.TLSDuration},
+			phase{"processing", trace.WaitResponseDuration},
+			phase{"transfer", trace.BodyDuration},
+		)
+	}
+	var names []string
+	for name, value := range stats.Meta {
+		if _, ok := value.(time.Duration); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		list = append(list, phase{name, stats.Meta[name].(time.Duration)})
+	}
+	return list
+}
+
+// certExpiry returns the expiry of the certificate a probe saw, if any.
+func certExpiry(stats *pinger.Stats) (time.Time, bool) {
+	switch extra := stats.Extra.(type) {
+	case meta.Meta:
+		return extra.NotAfter, !extra.NotAfter.IsZero()
+	case *pinghttp.Trace:
+		if certs := extra.TLSState().PeerCertificates; len(certs) > 0 {
+			return certs[0].NotAfter, true
+		}
+	}
+	if expires, ok := stats.Meta["expires"]; ok {
+		t, err := time.Parse(time.RFC3339, expires.String())
+		return t, err == nil
+	}
+	return time.Time{}, false
+}
+
+// formatLabels formats the label set of a target, sorted by name.
+func formatLabels(target string, labels map[string]string) string {
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		if name != "target" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	parts := []string{`target="` + escape(target) + `"`}
+	for _, name := range names {
+		parts = append(parts, labelName(name)+`="`+escape(labels[name])+`"`)
+	}
+	return strings.Join(parts, ",")
+}
+
+// labelName replaces the characters not allowed in label names.
+func labelName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// escape escapes a label value.
+var escape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
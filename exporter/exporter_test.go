@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	e := New()
+	e.Write(&pinger.Record{
+		Target:    "tls://example.com:443",
+		Timestamp: time.Unix(1700000000, 0),
+		Labels:    map[string]string{"region": "eu-west"},
+		Stats: &pinger.Stats{
+			Connected: true,
+			Duration:  30 * time.Millisecond,
+			Traffic:   pinger.Traffic{PacketsSent: 6, PacketsReceived: 6, BytesSent: 868, BytesReceived: 4620},
+			Meta: map[string]fmt.Stringer{
+				"handshake": 20 * time.Millisecond,
+				"expires":   pinger.StringerFunc(func() string { return "2030-01-01T00:00:00Z" }),
+			},
+		},
+	})
+	e.Write(&pinger.Record{Target: "tcp://example.com:80", Stats: &pinger.Stats{}})
+
+	var b bytes.Buffer
+	e.WriteMetrics(&b)
+	for _, want := range []string{
+		`probe_success{target="tcp://example.com:80"} 0`,
+		`probe_success{target="tls://example.com:443",region="eu-west"} 1`,
+		`probe_duration_seconds{target="tls://example.com:443",region="eu-west",phase="handshake"} 0.02`,
+		`probe_ssl_earliest_cert_expiry{target="tls://example.com:443",region="eu-west"} 1893456000`,
+		`probe_failures_total{target="tcp://example.com:80"} 1`,
+		`probe_bytes_total{target="tls://example.com:443",region="eu-west",direction="received"} 4620`,
+		`# TYPE probe_delivery_lag_seconds gauge`,
+	} {
+		if !strings.Contains(b.String(), want+"\n") {
+			t.Fatalf("metrics lack %s:\n%s", want, b.String())
+		}
+	}
+
+	if strings.Contains(b.String(), `probe_delivery_lag_seconds{target="tcp://example.com:80"}`) {
+		t.Fatal("lag exported for a record without timestamp")
+	}
+
+	e.Retain([]string{"tcp://example.com:80"})
+	b.Reset()
+	e.WriteMetrics(&b)
+	if strings.Contains(b.String(), "tls://") {
+		t.Fatal("removed target still exported")
+	}
+}
@@ -0,0 +1,127 @@
+package gameserver
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	a2sInfoRequest   = 'T'
+	a2sInfoResponse  = 'I'
+	a2sChallenge     = 'A'
+	a2sMaxPacketSize = 1400
+)
+
+// a2sSimpleHeader prefixes every unsplit A2S packet.
+var a2sSimpleHeader = []byte{0xff, 0xff, 0xff, 0xff}
+
+// errSplitPacket is returned for multi-packet responses, which A2S_INFO
+// answers do not need and this implementation does not reassemble.
+var errSplitPacket = errors.New("split A2S response not supported")
+
+// queryA2S sends A2S_INFO requests over rw, answering a challenge if the
+// server asks for one, and parses the response.
+func queryA2S(rw io.ReadWriter) (*Info, error) {
+	var challenge []byte
+	buf := make([]byte, a2sMaxPacketSize)
+	// A server answers with at most one challenge before the info
+	for range 2 {
+		if _, err := rw.Write(marshalA2SInfo(challenge)); err != nil {
+			return nil, fmt.Errorf("write A2S_INFO: %w", err)
+		}
+		n, err := rw.Read(buf)
+		if err != nil {
+			return nil, fmt.Errorf("read A2S_INFO: %w", err)
+		}
+		msg := buf[:n]
+		if !bytes.HasPrefix(msg, a2sSimpleHeader) {
+			return nil, errSplitPacket
+		}
+		if len(msg) < 5 {
+			return nil, errors.New("short A2S response")
+		}
+		switch msg[4] {
+		case a2sChallenge:
+			if len(msg) < 9 {
+				return nil, errors.New("short A2S challenge")
+			}
+			challenge = append([]byte(nil), msg[5:9]...)
+		case a2sInfoResponse:
+			return parseA2SInfo(msg[5:])
+		default:
+			return nil, fmt.Errorf("unexpected A2S response type 0x%02x", msg[4])
+		}
+	}
+	return nil, errors.New("A2S challenge not accepted")
+}
+
+// marshalA2SInfo builds an A2S_INFO request, with the challenge if not nil.
+func marshalA2SInfo(challenge []byte) []byte {
+	b := append([]byte(nil), a2sSimpleHeader...)
+	b = append(b, a2sInfoRequest)
+	b = append(b, "Source Engine Query\x00"...)
+	return append(b, challenge...)
+}
+
+// parseA2SInfo parses the body of an A2S_INFO response, after the type byte.
+func parseA2SInfo(b []byte) (*Info, error) {
+	r := &a2sReader{b: b}
+	info := &Info{}
+	r.byte() // protocol version
+	info.Name = r.string()
+	info.Map = r.string()
+	r.string() // game folder
+	r.string() // game name
+	r.skip(2)  // steam application ID
+	info.Players = int(r.byte())
+	info.MaxPlayers = int(r.byte())
+	r.byte()  // bots
+	r.skip(4) // server type, environment, visibility, VAC
+	info.Version = r.string()
+	if r.err != nil {
+		return nil, fmt.Errorf("parse A2S_INFO: %w", r.err)
+	}
+	return info, nil
+}
+
+// a2sReader reads the fields of an A2S response, remembering the first error.
+type a2sReader struct {
+	b   []byte
+	err error
+}
+
+func (r *a2sReader) skip(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+	if len(r.b) < n {
+		r.err = io.ErrUnexpectedEOF
+		return nil
+	}
+	v := r.b[:n]
+	r.b = r.b[n:]
+	return v
+}
+
+func (r *a2sReader) byte() byte {
+	if v := r.skip(1); v != nil {
+		return v[0]
+	}
+	return 0
+}
+
+func (r *a2sReader) string() string {
+	if r.err != nil {
+		return ""
+	}
+	i := bytes.IndexByte(r.b, 0)
+	if i < 0 {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.b[:i])
+	r.b = r.b[i+1:]
+	return s
+}
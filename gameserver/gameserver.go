@@ -0,0 +1,155 @@
+// Package gameserver provides game server query ping functionality for the
+// circle-pinger tool. Each probe asks the server for its status, using the
+// Valve A2S_INFO query over UDP or the Minecraft Server List Ping over TCP,
+// and reports the player count and server name from the answer.
+package gameserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// Query is a game server query protocol.
+type Query int
+
+const (
+	// A2S is the Valve Source engine A2S_INFO query, answered by Source and
+	// GoldSrc games and many others hosted on the Steam platform.
+	A2S Query = iota
+	// Minecraft is the Minecraft Java Edition Server List Ping.
+	Minecraft
+)
+
+// MinecraftPort is the default Minecraft server port.
+const MinecraftPort = 25565
+
+// String returns the name of the query protocol.
+func (q Query) String() string {
+	switch q {
+	case A2S:
+		return "a2s"
+	case Minecraft:
+		return "minecraft"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseQuery parses a query protocol name as returned by Query.String.
+func ParseQuery(s string) (Query, error) {
+	switch s {
+	case "a2s":
+		return A2S, nil
+	case "minecraft":
+		return Minecraft, nil
+	default:
+		return 0, fmt.Errorf("unknown game server query %q, want a2s or minecraft", s)
+	}
+}
+
+// Info is the server status reported by either query protocol.
+type Info struct {
+	Name       string
+	Map        string
+	Version    string
+	Players    int
+	MaxPlayers int
+}
+
+// New creates a new game server Ping instance.
+func New(host string, port int, op *pinger.Option, query Query) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{
+		host:   host,
+		port:   port,
+		query:  query,
+		option: op,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+}
+
+// Ping is the game server ping implementation.
+type Ping struct {
+	option *pinger.Option
+	host   string
+	port   int
+	query  Query
+	dialer *net.Dialer
+}
+
+// Ping queries the server status.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+
+	network := "udp"
+	if p.query == Minecraft {
+		network = "tcp"
+	}
+	start := time.Now()
+	conn, err := p.dialer.DialContext(ctx, network, net.JoinHostPort(p.host, strconv.Itoa(p.port)))
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			stats.Address = oe.Addr.String()
+		}
+		return stats
+	}
+	defer conn.Close()
+	stats.Address = conn.RemoteAddr().String()
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	var info *Info
+	if p.query == Minecraft {
+		info, err = queryMinecraft(conn, p.host, p.port)
+	} else {
+		info, err = queryA2S(conn)
+	}
+	stats.Duration = time.Since(start)
+	stats.Meta["query"] = p.query
+	if err != nil {
+		stats.Error = err
+		return stats
+	}
+	stats.Connected = true
+	stats.Meta["players"] = pinger.StringerFunc(func() string {
+		return fmt.Sprintf("%d/%d", info.Players, info.MaxPlayers)
+	})
+	if info.Name != "" {
+		stats.Meta["motd"] = pinger.StringerFunc(func() string { return strconv.Quote(info.Name) })
+	}
+	if info.Map != "" {
+		stats.Meta["map"] = pinger.StringerFunc(func() string { return strconv.Quote(info.Map) })
+	}
+	if info.Version != "" {
+		stats.Meta["version"] = pinger.StringerFunc(func() string { return strconv.Quote(info.Version) })
+	}
+	return stats
+}
@@ -0,0 +1,138 @@
+package gameserver
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+// serveA2S answers A2S_INFO requests, requiring a challenge first.
+func serveA2S(t *testing.T) int {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	challenge := []byte{1, 2, 3, 4}
+	go func() {
+		buf := make([]byte, a2sMaxPacketSize)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			resp := append([]byte(nil), a2sSimpleHeader...)
+			if !bytes.HasSuffix(buf[:n], challenge) {
+				resp = append(append(resp, a2sChallenge), challenge...)
+			} else {
+				resp = append(resp, a2sInfoResponse, 17)
+				for _, s := range []string{"My Server", "de_dust2", "csgo", "Counter-Strike"} {
+					resp = append(append(resp, s...), 0)
+				}
+				resp = append(resp, 0xda, 0x02, 7, 24, 0, 'd', 'l', 0, 1)
+				resp = append(append(resp, "1.38.7.9"...), 0)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr).Port
+}
+
+func TestPing_A2S(t *testing.T) {
+	port := serveA2S(t)
+	stats := New("127.0.0.1", port, &pinger.Option{Timeout: time.Second}, A2S).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["players"].String(); got != "7/24" {
+		t.Fatalf("players = %s", got)
+	}
+	if got := stats.Meta["map"].String(); got != `"de_dust2"` {
+		t.Fatalf("map = %s", got)
+	}
+}
+
+// serveMinecraft answers one Server List Ping.
+func serveMinecraft(t *testing.T, status string) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		for range 2 {
+			size, err := binary.ReadUvarint(r)
+			if err != nil {
+				return
+			}
+			io.ReadFull(r, make([]byte, size))
+		}
+		conn.Write(appendPacket(nil, appendString([]byte{0x00}, status)))
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestPing_Minecraft(t *testing.T) {
+	port := serveMinecraft(t, `{"version":{"name":"1.21.1","protocol":767},"players":{"max":20,"online":3},"description":{"text":"A ","extra":[{"text":"Minecraft Server"}]}}`)
+	stats := New("127.0.0.1", port, &pinger.Option{Timeout: time.Second}, Minecraft).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["players"].String(); got != "3/20" {
+		t.Fatalf("players = %s", got)
+	}
+	if got := stats.Meta["motd"].String(); got != `"A Minecraft Server"` {
+		t.Fatalf("motd = %s", got)
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", serveA2S(t), &pinger.Option{Timeout: timeout}, A2S)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout}, A2S)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.NewUDPBlackhole(t).Port, &pinger.Option{Timeout: timeout}, A2S)
+		},
+	})
+}
+
+func FuzzParseA2SInfo(f *testing.F) {
+	info := []byte{17}
+	for _, s := range []string{"My Server", "de_dust2", "csgo", "Counter-Strike"} {
+		info = append(append(info, s...), 0)
+	}
+	f.Add(append(info, 0xda, 0x02, 7, 24, 0, 'd', 'l', 0, 1))
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		parseA2SInfo(b)
+	})
+}
+
+func FuzzParseMinecraftStatus(f *testing.F) {
+	f.Add(appendString([]byte{0x00}, `{"version":{"name":"1.21.1"},"players":{"max":20,"online":3},"description":"hi"}`))
+	f.Add(appendString([]byte{0x00}, `{"description":{"extra":[{"extra":[{"text":"x"}]}]}}`))
+	f.Add([]byte{0x00, 0xff, 0xff, 0xff, 0xff, 0x0f})
+	f.Fuzz(func(t *testing.T, packet []byte) {
+		parseMinecraftStatus(packet)
+	})
+}
@@ -0,0 +1,127 @@
+package gameserver
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	// minecraftProtocol is sent in the handshake; -1 asks the server to report
+	// its own version rather than reject a mismatch.
+	minecraftProtocol = -1
+	// minecraftStatusState is the handshake next state for a status request.
+	minecraftStatusState = 1
+	// minecraftMaxPacket bounds the status response accepted.
+	minecraftMaxPacket = 1 << 20
+)
+
+// queryMinecraft performs the Server List Ping handshake and status request
+// over rw and parses the status response.
+func queryMinecraft(rw io.ReadWriter, host string, port int) (*Info, error) {
+	// Handshake and status request, each a length-prefixed packet
+	var handshake []byte
+	handshake = binary.AppendUvarint(handshake, 0x00)
+	handshake = appendVarint(handshake, minecraftProtocol)
+	handshake = appendString(handshake, host)
+	handshake = binary.BigEndian.AppendUint16(handshake, uint16(port))
+	handshake = binary.AppendUvarint(handshake, minecraftStatusState)
+
+	var req []byte
+	req = appendPacket(req, handshake)
+	req = appendPacket(req, []byte{0x00})
+	if _, err := rw.Write(req); err != nil {
+		return nil, fmt.Errorf("write status request: %w", err)
+	}
+
+	r := bufio.NewReader(rw)
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, fmt.Errorf("read status response: %w", err)
+	}
+	if size == 0 || size > minecraftMaxPacket {
+		return nil, fmt.Errorf("invalid status response length %d", size)
+	}
+	packet := make([]byte, size)
+	if _, err := io.ReadFull(r, packet); err != nil {
+		return nil, fmt.Errorf("read status response: %w", err)
+	}
+	return parseMinecraftStatus(packet)
+}
+
+// parseMinecraftStatus parses a status response packet, without its length.
+func parseMinecraftStatus(packet []byte) (*Info, error) {
+	id, n := binary.Uvarint(packet)
+	if n <= 0 || id != 0x00 {
+		return nil, errors.New("unexpected status response packet")
+	}
+	packet = packet[n:]
+	length, n := binary.Uvarint(packet)
+	if n <= 0 || uint64(len(packet)-n) < length {
+		return nil, errors.New("truncated status response")
+	}
+
+	var status struct {
+		Version struct {
+			Name string `json:"name"`
+		} `json:"version"`
+		Players struct {
+			Max    int `json:"max"`
+			Online int `json:"online"`
+		} `json:"players"`
+		Description json.RawMessage `json:"description"`
+	}
+	if err := json.Unmarshal(packet[n:n+int(length)], &status); err != nil {
+		return nil, fmt.Errorf("parse status response: %w", err)
+	}
+	return &Info{
+		Name:       chatText(status.Description),
+		Version:    status.Version.Name,
+		Players:    status.Players.Online,
+		MaxPlayers: status.Players.Max,
+	}, nil
+}
+
+// chatText flattens a MOTD, which is either a plain string or a chat
+// component with nested extra components, to plain text.
+func chatText(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var component struct {
+		Text  string            `json:"text"`
+		Extra []json.RawMessage `json:"extra"`
+	}
+	if json.Unmarshal(raw, &component) != nil {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString(component.Text)
+	for _, extra := range component.Extra {
+		b.WriteString(chatText(extra))
+	}
+	return b.String()
+}
+
+// appendVarint appends v as a protocol VarInt, which encodes negative
+// numbers as their 32-bit two's complement.
+func appendVarint(b []byte, v int32) []byte {
+	return binary.AppendUvarint(b, uint64(uint32(v)))
+}
+
+// appendString appends s prefixed by its length.
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+// appendPacket appends data prefixed by its length.
+func appendPacket(b, data []byte) []byte {
+	b = binary.AppendUvarint(b, uint64(len(data)))
+	return append(b, data...)
+}
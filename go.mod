@@ -5,7 +5,10 @@ go 1.24.2
 require (
 	github.com/smartystreets/goconvey v1.8.1
 	github.com/spf13/cobra v1.2.1
+	github.com/spf13/pflag v1.0.5
+	golang.org/x/net v0.39.0
 	golang.org/x/sync v0.13.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -13,5 +16,5 @@ require (
 	github.com/inconshreveable/mousetrap v1.0.0 // indirect
 	github.com/jtolds/gls v4.20.0+incompatible // indirect
 	github.com/smarty/assertions v1.15.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/text v0.24.0 // indirect
 )
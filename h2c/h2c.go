@@ -0,0 +1,180 @@
+// Package h2c provides cleartext HTTP/2 ping functionality for the
+// circle-pinger tool. Each probe opens an HTTP/2 connection with prior
+// knowledge, as gRPC clients do, sends its SETTINGS and a request, and times
+// the server's SETTINGS acknowledgement and first HEADERS frame.
+package h2c
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// streamID is the stream of the single request a probe sends.
+const streamID = 1
+
+// New creates a new h2c Ping instance for target, whose path is requested.
+// If method is empty, it defaults to GET.
+func New(method string, target *url.URL, op *pinger.Option) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	if method == "" {
+		method = "GET"
+	}
+	path := target.RequestURI()
+	if path == "" {
+		path = "/"
+	}
+	return &Ping{
+		host:   target.Hostname(),
+		port:   target.Port(),
+		method: method,
+		path:   path,
+		option: op,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+}
+
+// Ping is the h2c ping implementation.
+type Ping struct {
+	option *pinger.Option
+	host   string
+	port   string
+	method string
+	path   string
+	dialer *net.Dialer
+}
+
+// Ping opens a connection and performs one request over it.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+
+	start := time.Now()
+	conn, err := p.option.Dial(ctx, p.dialer, p.option.Network("tcp"), net.JoinHostPort(p.host, p.port))
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			stats.Address = oe.Addr.String()
+		}
+		return stats
+	}
+	defer conn.Close()
+	stats.Address = conn.RemoteAddr().String()
+	stats.Meta["connect"] = time.Since(start)
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	sent := time.Now()
+	status, err := p.exchange(conn, func(settingsAck time.Time) {
+		stats.Meta["settings_ack"] = settingsAck.Sub(sent)
+	})
+	stats.Duration = time.Since(start)
+	if err != nil {
+		stats.Error = err
+		return stats
+	}
+	stats.Connected = true
+	stats.Meta["headers"] = time.Since(sent)
+	stats.Meta["status"] = pinger.StringerFunc(func() string { return strconv.Itoa(status) })
+	return stats
+}
+
+// exchange sends the connection preface, SETTINGS and the request over rw
+// and reads frames until the response HEADERS arrive, returning the
+// response status. onSettingsAck is called when the SETTINGS are
+// acknowledged.
+func (p *Ping) exchange(rw io.ReadWriter, onSettingsAck func(time.Time)) (int, error) {
+	var headers bytes.Buffer
+	enc := hpack.NewEncoder(&headers)
+	for _, f := range []hpack.HeaderField{
+		{Name: ":method", Value: p.method},
+		{Name: ":scheme", Value: "http"},
+		{Name: ":authority", Value: net.JoinHostPort(p.host, p.port)},
+		{Name: ":path", Value: p.path},
+		{Name: "user-agent", Value: p.option.UA},
+	} {
+		if f.Value != "" {
+			enc.WriteField(f)
+		}
+	}
+
+	var out bytes.Buffer
+	out.WriteString(http2.ClientPreface)
+	framer := http2.NewFramer(&out, rw)
+	framer.WriteSettings()
+	framer.WriteHeaders(http2.HeadersFrameParam{
+		StreamID:      streamID,
+		BlockFragment: headers.Bytes(),
+		EndStream:     true,
+		EndHeaders:    true,
+	})
+	if _, err := rw.Write(out.Bytes()); err != nil {
+		return 0, fmt.Errorf("write request: %w", err)
+	}
+
+	// Later frames are written straight to the connection
+	framer = http2.NewFramer(rw, rw)
+	framer.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	for {
+		frame, err := framer.ReadFrame()
+		if err != nil {
+			return 0, fmt.Errorf("read frame: %w", err)
+		}
+		switch f := frame.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				onSettingsAck(time.Now())
+			} else if err := framer.WriteSettingsAck(); err != nil {
+				return 0, fmt.Errorf("write settings ack: %w", err)
+			}
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				framer.WritePing(true, f.Data)
+			}
+		case *http2.MetaHeadersFrame:
+			if f.StreamID != streamID {
+				continue
+			}
+			status, err := strconv.Atoi(f.PseudoValue("status"))
+			if err != nil {
+				return 0, errors.New("response without a valid :status")
+			}
+			return status, nil
+		case *http2.RSTStreamFrame:
+			return 0, fmt.Errorf("stream reset: %s", f.ErrCode)
+		case *http2.GoAwayFrame:
+			return 0, fmt.Errorf("connection closed by server: %s", f.ErrCode)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package h2c
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+// newServer starts a local server speaking HTTP/2 without TLS and returns
+// its host:port.
+func newServer(t *testing.T, handler http.Handler) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	srv := &http.Server{Protocols: protocols, Handler: handler}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return ln.Addr().String()
+}
+
+func TestPing(t *testing.T) {
+	addr := newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ProtoMajor != 2 || r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusTeapot)
+	}))
+
+	target := &url.URL{Scheme: "h2c", Host: addr, Path: "/health"}
+	stats := New("", target, &pinger.Option{Timeout: time.Second}).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["status"].String(); got != "418" {
+		t.Fatalf("status = %s", got)
+	}
+	if _, ok := stats.Meta["settings_ack"]; !ok {
+		t.Fatal("settings ack time missing")
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	newPing := func(host string, timeout time.Duration) pinger.Ping {
+		return New("", &url.URL{Scheme: "h2c", Host: host, Path: "/"}, &pinger.Option{Timeout: timeout})
+	}
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(newServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})), timeout)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(fmt.Sprintf("127.0.0.1:%d", pingertest.ClosedPort(t)), timeout)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(pingertest.NewTCPBlackhole(t).Addr(), timeout)
+		},
+	})
+}
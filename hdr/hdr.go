@@ -0,0 +1,129 @@
+// Package hdr records latencies in an HdrHistogram and writes them in the
+// HdrHistogram log format, so that the distributions of many runs and hosts
+// can be merged and plotted with the standard HdrHistogram tools.
+package hdr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// Cookies of the V2 encodings; the 0x10 marks the zero-run length encoding
+// of the counts.
+const (
+	encodingCookie           = 0x1c849303 | 0x10
+	compressedEncodingCookie = 0x1c849304 | 0x10
+)
+
+// Histogram counts values between 1 and a highest trackable value with a
+// number of significant decimal digits, as HdrHistogram does.
+type Histogram struct {
+	highest          int64
+	digits           int
+	subBucketHalfMag int
+	subBucketHalf    int
+	subBucketMask    int64
+	leadingZeroBase  int
+	counts           []int64
+	total            int64
+	min, max         int64
+}
+
+// New creates a Histogram of the values from 1 to highest with digits (1 to
+// 5) significant decimal digits.
+func New(highest int64, digits int) *Histogram {
+	largest := 2 * int64(math.Pow10(digits))
+	subBucketCountMag := int(math.Ceil(math.Log2(float64(largest))))
+	subBucketCount := int64(1) << subBucketCountMag
+	h := &Histogram{
+		highest:          highest,
+		digits:           digits,
+		subBucketHalfMag: subBucketCountMag - 1,
+		subBucketHalf:    int(subBucketCount / 2),
+		subBucketMask:    subBucketCount - 1,
+		leadingZeroBase:  64 - subBucketCountMag,
+		min:              math.MaxInt64,
+	}
+	buckets := 1
+	for smallest := subBucketCount; smallest <= highest; smallest <<= 1 {
+		buckets++
+		if smallest > math.MaxInt64/2 {
+			break
+		}
+	}
+	h.counts = make([]int64, (buckets+1)*h.subBucketHalf)
+	return h
+}
+
+// index returns the position of the count of v.
+func (h *Histogram) index(v int64) int {
+	bucket := h.leadingZeroBase - bits.LeadingZeros64(uint64(v|h.subBucketMask))
+	subBucket := int(v >> bucket)
+	return (bucket+1)<<h.subBucketHalfMag + subBucket - h.subBucketHalf
+}
+
+// Record counts v, clamped to the trackable range.
+func (h *Histogram) Record(v int64) {
+	v = min(max(v, 1), h.highest)
+	h.counts[h.index(v)]++
+	h.total++
+	h.min, h.max = min(h.min, v), max(h.max, v)
+}
+
+// Total returns the number of values recorded.
+func (h *Histogram) Total() int64 {
+	return h.total
+}
+
+// Max returns the largest value recorded, or 0.
+func (h *Histogram) Max() int64 {
+	return h.max
+}
+
+// Encode returns the compressed V2 encoding of the histogram, as carried
+// base64-encoded in histogram logs.
+func (h *Histogram) Encode() []byte {
+	// Counts up to the largest value, with runs of zeros as negative
+	// lengths, in ZigZag LEB128
+	var payload []byte
+	limit := 0
+	if h.total > 0 {
+		limit = h.index(h.max) + 1
+	}
+	for i := 0; i < limit; {
+		count := h.counts[i]
+		i++
+		if count == 0 {
+			zeros := int64(1)
+			for i < limit && h.counts[i] == 0 {
+				zeros++
+				i++
+			}
+			if zeros > 1 {
+				count = -zeros
+			}
+		}
+		payload = binary.AppendVarint(payload, count)
+	}
+
+	var raw []byte
+	raw = binary.BigEndian.AppendUint32(raw, encodingCookie)
+	raw = binary.BigEndian.AppendUint32(raw, uint32(len(payload)))
+	raw = binary.BigEndian.AppendUint32(raw, 0) // normalizing index offset
+	raw = binary.BigEndian.AppendUint32(raw, uint32(h.digits))
+	raw = binary.BigEndian.AppendUint64(raw, 1) // lowest discernible value
+	raw = binary.BigEndian.AppendUint64(raw, uint64(h.highest))
+	raw = binary.BigEndian.AppendUint64(raw, math.Float64bits(1)) // integer to double ratio
+	raw = append(raw, payload...)
+
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	zw.Write(raw)
+	zw.Close()
+	out := binary.BigEndian.AppendUint32(nil, compressedEncodingCookie)
+	out = binary.BigEndian.AppendUint32(out, uint32(compressed.Len()))
+	return append(out, compressed.Bytes()...)
+}
@@ -0,0 +1,96 @@
+package hdr
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// decode reverses Encode, returning the counts by index.
+func decode(t *testing.T, data []byte) map[int]int64 {
+	if binary.BigEndian.Uint32(data) != compressedEncodingCookie {
+		t.Fatalf("unexpected compressed cookie %x", data[:4])
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(data[8:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if binary.BigEndian.Uint32(raw) != encodingCookie || int(binary.BigEndian.Uint32(raw[4:])) != len(raw)-40 {
+		t.Fatalf("unexpected header %x", raw[:40])
+	}
+	counts := make(map[int]int64)
+	payload := raw[40:]
+	for i := 0; len(payload) > 0; {
+		count, n := binary.Varint(payload)
+		payload = payload[n:]
+		if count < 0 {
+			i += int(-count)
+			continue
+		}
+		if count > 0 {
+			counts[i] = count
+		}
+		i++
+	}
+	return counts
+}
+
+func TestHistogram(t *testing.T) {
+	h := New(int64(time.Hour), 3)
+	for _, v := range []int64{1, 2047, 2048, 2049, 1500000, 1500000, int64(2 * time.Hour)} {
+		h.Record(v)
+	}
+	// 2048 and 2049 share a bucket at 3 significant digits, and values
+	// past the highest are clamped
+	want := map[int]int64{
+		h.index(1):                1,
+		h.index(2047):             1,
+		h.index(2048):             2,
+		h.index(1500000):          2,
+		h.index(int64(time.Hour)): 1,
+	}
+	if h.index(2048) != 2048 || h.index(2049) != 2048 {
+		t.Fatalf("unexpected indexes %d %d", h.index(2048), h.index(2049))
+	}
+	got := decode(t, h.Encode())
+	if len(got) != len(want) {
+		t.Fatalf("decoded %v, want %v", got, want)
+	}
+	for i, n := range want {
+		if got[i] != n {
+			t.Fatalf("decoded %v, want %v", got, want)
+		}
+	}
+}
+
+func TestWriteLog(t *testing.T) {
+	h := New(int64(time.Hour), 3)
+	h.Record(int64(12 * time.Millisecond))
+	start := time.Unix(1700000000, 0)
+	var buf bytes.Buffer
+	err := WriteLog(&buf, start, 1e6, []Interval{{Tag: "tcp://a b:80", Start: start.Add(time.Second), End: start.Add(3 * time.Second), Histogram: h}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if lines[0] != "#[Histogram log format version 1.3]" || len(lines) != 5 {
+		t.Fatalf("unexpected log:\n%s", buf.String())
+	}
+	fields := strings.Split(lines[4], ",")
+	if len(fields) != 5 || fields[0] != "Tag=tcp://a_b:80" || fields[1] != "1.000" || fields[2] != "2.000" || fields[3] != "12.000" {
+		t.Fatalf("unexpected interval %q", lines[4])
+	}
+	data, err := base64.StdEncoding.DecodeString(fields[4])
+	if err != nil || len(decode(t, data)) != 1 {
+		t.Fatalf("undecodable histogram %q: %v", fields[4], err)
+	}
+}
@@ -0,0 +1,51 @@
+package hdr
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Interval is a histogram of the values recorded between Start and End,
+// tagged to tell the histograms of one log apart.
+type Interval struct {
+	Tag        string
+	Start, End time.Time
+	Histogram  *Histogram
+}
+
+// WriteLog writes intervals as an HdrHistogram log (format version 1.3)
+// whose timestamps are relative to start. Interval maximums are divided by
+// maxRatio, such as 1e6 for nanoseconds shown as milliseconds.
+func WriteLog(w io.Writer, start time.Time, maxRatio float64, intervals []Interval) error {
+	epoch := float64(start.UnixMilli()) / 1000
+	var b strings.Builder
+	b.WriteString("#[Histogram log format version 1.3]\n")
+	fmt.Fprintf(&b, "#[StartTime: %.3f (seconds since epoch), %s]\n", epoch, start.Format(time.UnixDate))
+	fmt.Fprintf(&b, "#[BaseTime: %.3f (seconds since epoch)]\n", epoch)
+	b.WriteString("\"StartTimestamp\",\"Interval_Length\",\"Interval_Max\",\"Interval_Compressed_Histogram\"\n")
+	for _, interval := range intervals {
+		if interval.Tag != "" {
+			b.WriteString("Tag=" + sanitizeTag(interval.Tag) + ",")
+		}
+		fmt.Fprintf(&b, "%.3f,%.3f,%.3f,%s\n",
+			interval.Start.Sub(start).Seconds(),
+			interval.End.Sub(interval.Start).Seconds(),
+			float64(interval.Histogram.Max())/maxRatio,
+			base64.StdEncoding.EncodeToString(interval.Histogram.Encode()))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// sanitizeTag replaces the characters a tag cannot hold.
+func sanitizeTag(tag string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ',' || r == ' ' || r == '\t' || r == '\n' {
+			return '_'
+		}
+		return r
+	}, tag)
+}
@@ -0,0 +1,307 @@
+// Package helper lets an unprivileged circle-pinger send the probes that
+// need raw sockets through a privileged helper process. The helper listens
+// on a local socket and runs ICMP, ARP and IPv6 extension header probes on
+// behalf of its clients and nothing else. A Server bounds the probes in
+// flight and the rate at which each peer may ask for them, so that the users
+// allowed on the socket cannot turn the helper into a packet flooder.
+//
+// Every probe is one exchange on its own connection: the client sends a JSON
+// Request and the helper answers with the JSON form of the pinger.Record of
+// the probe.
+package helper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// MaxTimeout bounds the timeout of a probe the helper runs, so that a client
+// cannot tie it up.
+const MaxTimeout = 30 * time.Second
+
+// maxPings is how many probe targets a Server keeps before starting afresh.
+const maxPings = 1024
+
+// DefaultMaxInFlight is how many probes a Server runs at once unless told
+// otherwise.
+const DefaultMaxInFlight = 64
+
+// DefaultPeerRate is how many probes per second a Server runs for each peer
+// unless told otherwise.
+const DefaultPeerRate = 20
+
+// DefaultSocket returns the path the helper listens on by default.
+func DefaultSocket() string {
+	switch runtime.GOOS {
+	case "windows":
+		return filepath.Join(os.Getenv("ProgramData"), "circle-pinger", "helper.sock")
+	case "darwin":
+		return "/var/run/circle-pinger-helper.sock"
+	default:
+		return "/run/circle-pinger/helper.sock"
+	}
+}
+
+// Privileged reports whether protocol needs raw sockets, and so is one the
+// helper runs.
+func Privileged(protocol pinger.Protocol) bool {
+	return protocol == pinger.ICMP || protocol == pinger.ARP || protocol == pinger.IPV6EH
+}
+
+// Available reports whether a helper answers on socket.
+func Available(socket string) bool {
+	conn, err := net.DialTimeout("unix", socket, time.Second)
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
+
+// Request asks the helper for one probe. The options of the probe travel
+// with it; protocol options go in the query of the URL, as with the headers
+// of ipv6eh, since the helper builds probes for all of its clients.
+type Request struct {
+	URL        string        `json:"url"`
+	Timeout    time.Duration `json:"timeout"`
+	DNSTimeout time.Duration `json:"dns_timeout,omitempty"`
+	Family     string        `json:"family,omitempty"`
+	Verbose    int           `json:"verbose,omitempty"`
+	Meta       bool          `json:"meta,omitempty"`
+}
+
+// Ping sends the probes of a target through the helper.
+type Ping struct {
+	socket string
+	url    *url.URL
+	option *pinger.Option
+}
+
+// NewPing creates a Ping probing u through the helper listening on socket.
+func NewPing(socket string, u *url.URL, op *pinger.Option) *Ping {
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{socket: socket, url: u, option: op}
+}
+
+// Ping implements pinger.Ping. The helper measures the probe; failing to
+// reach it fails the probe.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	start := time.Now()
+	fail := func(err error) *pinger.Stats {
+		return &pinger.Stats{Error: fmt.Errorf("privileged helper: %w", err), Duration: time.Since(start)}
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", p.socket)
+	if err != nil {
+		return fail(err)
+	}
+	defer conn.Close()
+	deadline := start.Add(timeout + time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	req := Request{
+		URL:        p.url.String(),
+		Timeout:    timeout,
+		DNSTimeout: p.option.DNSTimeout,
+		Family:     p.option.Family,
+		Verbose:    p.option.Verbose,
+		Meta:       p.option.Meta,
+	}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return fail(err)
+	}
+	var record pinger.Record
+	if err := json.NewDecoder(conn).Decode(&record); err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return fail(err)
+	}
+	return record.Stats
+}
+
+// Server runs the probes of helper clients.
+type Server struct {
+	// Build creates the Ping of a privileged protocol, normally the
+	// registered factory.
+	Build func(u *url.URL, op *pinger.Option) (pinger.Ping, error)
+	// MaxInFlight bounds the probes running at once, DefaultMaxInFlight if
+	// zero. A request waits for a free slot for at most its timeout.
+	MaxInFlight int
+	// PeerRate bounds the probes per second of each peer, told apart by
+	// their user ID where the platform reports it (SO_PEERCRED on Linux),
+	// DefaultPeerRate if zero. Elsewhere all peers share the one budget.
+	PeerRate float64
+
+	mu    sync.Mutex
+	pings map[Request]pinger.Ping // kept so that sequence numbers advance
+	peers map[int]*bucket         // probe budgets by peer user ID
+
+	slotsOnce sync.Once
+	slots     chan struct{}
+}
+
+// bucket is the token bucket of a peer.
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Serve answers the clients connecting to ln until it is closed.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// handle runs the probe a client asks for and answers with its record.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var req Request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	start := time.Now()
+	var stats *pinger.Stats
+	if !s.allow(peerUID(conn), start) {
+		stats = &pinger.Stats{Error: errors.New("too many probes, slow down")}
+	} else {
+		stats = s.probe(req)
+	}
+	conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
+	json.NewEncoder(conn).Encode(&pinger.Record{
+		Target:    req.URL,
+		Seq:       1,
+		Timestamp: start,
+		Stats:     stats,
+		RawError:  true,
+	})
+}
+
+// probe runs the probe of req, refusing the protocols that need no
+// privileges.
+func (s *Server) probe(req Request) *pinger.Stats {
+	if req.Timeout <= 0 {
+		req.Timeout = pinger.DefaultTimeout
+	}
+	req.Timeout = min(req.Timeout, MaxTimeout)
+	ping, err := s.ping(req)
+	if err != nil {
+		return &pinger.Stats{Error: err}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), req.Timeout)
+	defer cancel()
+	slots := s.inFlight()
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-ctx.Done():
+		return &pinger.Stats{Error: errors.New("too many probes in flight")}
+	}
+	return ping.Ping(ctx)
+}
+
+// inFlight returns the semaphore bounding the probes running at once.
+func (s *Server) inFlight() chan struct{} {
+	s.slotsOnce.Do(func() {
+		n := s.MaxInFlight
+		if n <= 0 {
+			n = DefaultMaxInFlight
+		}
+		s.slots = make(chan struct{}, n)
+	})
+	return s.slots
+}
+
+// allow reports whether the peer with user ID uid may run a probe now,
+// taking it from the budget of the peer.
+func (s *Server) allow(uid int, now time.Time) bool {
+	rate := s.PeerRate
+	if rate <= 0 {
+		rate = DefaultPeerRate
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.peers[uid]
+	if !ok {
+		if s.peers == nil || len(s.peers) >= maxPings {
+			s.peers = make(map[int]*bucket)
+		}
+		b = &bucket{tokens: rate, last: now}
+		s.peers[uid] = b
+	}
+	b.tokens = min(rate, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// ping returns the Ping for req, reusing the one of an earlier request.
+func (s *Server) ping(req Request) (pinger.Ping, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target: %w", err)
+	}
+	protocol, err := pinger.NewProtocol(u.Scheme)
+	if err != nil || !Privileged(protocol) {
+		return nil, fmt.Errorf("the helper only runs icmp, arp and ipv6eh probes, not %s", u.Scheme)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ping, ok := s.pings[req]; ok {
+		return ping, nil
+	}
+	ping, err := s.Build(u, &pinger.Option{
+		Timeout:    req.Timeout,
+		DNSTimeout: req.DNSTimeout,
+		Family:     req.Family,
+		Verbose:    req.Verbose,
+		Meta:       req.Meta,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if s.pings == nil || len(s.pings) >= maxPings {
+		s.pings = make(map[Request]pinger.Ping)
+	}
+	s.pings[req] = ping
+	return ping, nil
+}
@@ -0,0 +1,139 @@
+package helper
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// countingPing succeeds with the number of probes it sent as meta.
+type countingPing struct{ n int }
+
+func (c *countingPing) Ping(ctx context.Context) *pinger.Stats {
+	c.n++
+	return &pinger.Stats{
+		Connected: true,
+		Address:   "192.0.2.1",
+		Duration:  2 * time.Millisecond,
+		Meta:      map[string]fmt.Stringer{"seq": pinger.StringerFunc(func() string { return fmt.Sprint(c.n) })},
+	}
+}
+
+// blockingPing succeeds once it is closed.
+type blockingPing chan struct{}
+
+func (b blockingPing) Ping(ctx context.Context) *pinger.Stats {
+	<-b
+	return &pinger.Stats{Connected: true}
+}
+
+func TestHelper(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "helper.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	server := &Server{Build: func(u *url.URL, op *pinger.Option) (pinger.Ping, error) {
+		return &countingPing{}, nil
+	}}
+	go server.Serve(ln)
+	if !Available(socket) {
+		t.Fatal("helper not available on its socket")
+	}
+
+	u, _ := url.Parse("icmp://192.0.2.1")
+	ping := NewPing(socket, u, &pinger.Option{Timeout: time.Second})
+	ping.Ping(context.Background())
+	stats := ping.Ping(context.Background())
+	if !stats.Connected || stats.Address != "192.0.2.1" || stats.Duration != 2*time.Millisecond || stats.FormatMeta() != "seq=2" {
+		t.Fatalf("unexpected stats through the helper: %+v %s", stats, stats.FormatMeta())
+	}
+
+	// Protocols that need no privileges are refused
+	u, _ = url.Parse("tcp://192.0.2.1:80")
+	stats = NewPing(socket, u, nil).Ping(context.Background())
+	if stats.Connected || stats.Error == nil || !strings.Contains(stats.Error.Error(), "only runs") {
+		t.Fatalf("expected tcp to be refused, got %+v", stats)
+	}
+
+	// An absent helper fails the probe
+	stats = NewPing(filepath.Join(t.TempDir(), "absent.sock"), u, nil).Ping(context.Background())
+	if stats.Error == nil || !strings.Contains(stats.Error.Error(), "privileged helper") {
+		t.Fatalf("expected an error without a helper, got %+v", stats)
+	}
+}
+
+func TestServer_Limits(t *testing.T) {
+	// Each peer has a budget of PeerRate probes, refilled at PeerRate a second
+	server := &Server{PeerRate: 2}
+	now := time.Now()
+	if !server.allow(1000, now) || !server.allow(1000, now) {
+		t.Fatal("expected the first probes of a peer to be allowed")
+	}
+	if server.allow(1000, now) {
+		t.Fatal("expected a peer over its rate to be refused")
+	}
+	if !server.allow(1001, now) {
+		t.Fatal("expected another peer to have its own budget")
+	}
+	if !server.allow(1000, now.Add(500*time.Millisecond)) {
+		t.Fatal("expected the budget of a peer to refill")
+	}
+
+	// A probe waits for a free slot for at most its timeout
+	release := make(chan struct{})
+	server = &Server{MaxInFlight: 1, Build: func(u *url.URL, op *pinger.Option) (pinger.Ping, error) {
+		return blockingPing(release), nil
+	}}
+	done := make(chan *pinger.Stats)
+	go func() { done <- server.probe(Request{URL: "icmp://192.0.2.1", Timeout: time.Second}) }()
+	for len(server.inFlight()) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	stats := server.probe(Request{URL: "icmp://192.0.2.2", Timeout: 50 * time.Millisecond})
+	if stats.Error == nil || !strings.Contains(stats.Error.Error(), "in flight") {
+		t.Fatalf("expected a probe over the bound to be refused, got %+v", stats)
+	}
+	close(release)
+	if stats := <-done; !stats.Connected {
+		t.Fatalf("expected the running probe to succeed, got %+v", stats)
+	}
+}
+
+func TestHelper_Options(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "helper.sock")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	built := make(chan *url.URL, 1)
+	options := make(chan *pinger.Option, 1)
+	server := &Server{Build: func(u *url.URL, op *pinger.Option) (pinger.Ping, error) {
+		built <- u
+		options <- op
+		return &countingPing{}, nil
+	}}
+	go server.Serve(ln)
+
+	u, _ := url.Parse("ipv6eh://2001:db8::1?headers=hbh,frag")
+	want := pinger.Option{Timeout: time.Second, DNSTimeout: 200 * time.Millisecond, Family: "ip6", Verbose: 2, Meta: true}
+	op := want
+	if stats := NewPing(socket, u, &op).Ping(context.Background()); !stats.Connected {
+		t.Fatalf("probe through the helper failed: %v", stats.Error)
+	}
+	if got := <-built; got.String() != u.String() {
+		t.Errorf("helper built %s, want the options in the query of %s", got, u)
+	}
+	if got := <-options; *got != want {
+		t.Errorf("helper built the probe with %+v, want %+v", *got, want)
+	}
+}
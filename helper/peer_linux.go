@@ -0,0 +1,26 @@
+package helper
+
+import (
+	"net"
+	"syscall"
+)
+
+// peerUID returns the user ID of the process at the other end of conn, from
+// SO_PEERCRED, or -1 if it cannot be told.
+func peerUID(conn net.Conn) int {
+	unix, ok := conn.(*net.UnixConn)
+	if !ok {
+		return -1
+	}
+	raw, err := unix.SyscallConn()
+	if err != nil {
+		return -1
+	}
+	uid := -1
+	raw.Control(func(fd uintptr) {
+		if cred, err := syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED); err == nil {
+			uid = int(cred.Uid)
+		}
+	})
+	return uid
+}
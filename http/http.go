@@ -3,20 +3,35 @@ package http
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
 	pkgurl "net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/proxyauth"
 )
 
 // Ensure Ping implements the pinger.Ping interface
 var _ pinger.Ping = (*Ping)(nil)
 
+// Cache bust modes of pinger.Option.CacheBust.
+const (
+	CacheBustQuery   = "query"
+	CacheBustHeaders = "headers"
+	CacheBustBoth    = "both"
+)
+
+// CacheBustParam is the query parameter carrying a random value with
+// CacheBustQuery, so that every request misses caches keyed by URL.
+const CacheBustParam = "_cb"
+
 // New creates a new HTTP Ping instance.
 // It validates the method and URL, then configures an HTTP client with appropriate settings.
 // If method is empty, it defaults to GET.
@@ -32,12 +47,25 @@ func New(method string, url string, op *pinger.Option, trace bool) (*Ping, error
 	}
 
 	// Validate the URL and method by attempting to create a request
-	_, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequest(method, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("url or method is invalid: %w", err)
 	}
 
+	switch op.CacheBust {
+	case "", CacheBustQuery, CacheBustHeaders, CacheBustBoth:
+	default:
+		return nil, fmt.Errorf("invalid cache bust %q, want %s, %s or %s", op.CacheBust, CacheBustQuery, CacheBustHeaders, CacheBustBoth)
+	}
+
 	// Create transport with appropriate settings
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dialer := &net.Dialer{
+			Resolver: op.Resolver,
+			Timeout:  30 * time.Second, // Reasonable default dial timeout
+		}
+		return op.Dial(ctx, dialer, op.Network(network), addr)
+	}
 	transport := &http.Transport{
 		Proxy: func(r *http.Request) (*pkgurl.URL, error) {
 			if op.Proxy != nil {
@@ -45,10 +73,7 @@ func New(method string, url string, op *pinger.Option, trace bool) (*Ping, error
 			}
 			return http.ProxyFromEnvironment(r)
 		},
-		DialContext: (&net.Dialer{
-			Resolver: op.Resolver,
-			Timeout:  30 * time.Second, // Reasonable default dial timeout
-		}).DialContext,
+		DialContext:           dial,
 		DisableKeepAlives:     true,  // Don't reuse connections
 		ForceAttemptHTTP2:     false, // Stick to HTTP/1.1 for simplicity
 		MaxIdleConnsPerHost:   -1,    // Disable idle connections since we're not reusing them
@@ -57,6 +82,21 @@ func New(method string, url string, op *pinger.Option, trace bool) (*Ping, error
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
+	// Connection-based proxy authentication needs its own CONNECT tunnels,
+	// also for http targets
+	if op.ProxyAuth != "" {
+		proxy, err := transport.Proxy(req)
+		if err != nil || proxy == nil {
+			return nil, fmt.Errorf("%s proxy authentication needs a proxy", op.ProxyAuth)
+		}
+		tunnels, err := proxyauth.New(proxy, op.ProxyAuth, dial)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = nil
+		transport.DialContext = tunnels.DialContext
+	}
+
 	// Create client with appropriate settings
 	client := &http.Client{
 		Transport: transport,
@@ -105,10 +145,18 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	}
 
 	// Initialize trace if enabled
+	// The trace also provides the DNS duration and address, so it is always
+	// installed but only shown when enabled
 	trace := Trace{}
-	if p.trace {
+	ctx = trace.WithTrace(ctx)
+	verbose := p.option.Verbose
+	if p.trace || verbose >= pinger.VerboseTrace {
 		stats.Extra = &trace
-		ctx = trace.WithTrace(ctx)
+		// The budget is what is left of the timeout, which a caller's
+		// earlier deadline may shorten
+		if deadline, ok := ctx.Deadline(); ok {
+			trace.Budget = time.Until(deadline)
+		}
 	}
 
 	// Start timing
@@ -126,6 +174,7 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	if p.option != nil && p.option.UA != "" {
 		req.Header.Set("User-Agent", p.option.UA)
 	}
+	bustCache(req, p.option.CacheBust)
 
 	// Execute request
 	resp, err := p.client.Do(req)
@@ -133,11 +182,20 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	// Capture DNS and address info from trace
 	stats.DNSDuration = trace.DNSDuration
 	stats.Address = trace.address
+	if verbose >= pinger.VerboseAddresses {
+		if len(trace.resolved) > 0 {
+			stats.Meta["resolved"] = pinger.JoinAddrs(trace.resolved)
+		}
+		if trace.local != nil {
+			stats.Meta["local"] = trace.local
+		}
+	}
 
 	// Handle request error
 	if err != nil {
 		stats.Error = err
 		stats.Duration = time.Since(start)
+		trace.Total = stats.Duration
 		return stats
 	}
 
@@ -145,6 +203,10 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	defer resp.Body.Close()
 	stats.Connected = true
 	stats.Meta["status"] = Int(resp.StatusCode)
+	cacheMeta(stats, resp.Header)
+	if verbose >= pinger.VerboseRaw {
+		trace.Header = resp.Header
+	}
 
 	// Measure body read time
 	bodyStart := time.Now()
@@ -159,6 +221,8 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 
 	// Calculate total duration
 	stats.Duration = time.Since(start)
+	trace.Total = stats.Duration
+	stats.Phases = trace.Phases()
 
 	// Handle body read error
 	if err != nil {
@@ -169,6 +233,35 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	return stats
 }
 
+// bustCache makes req bypass caches as mode asks.
+func bustCache(req *http.Request, mode string) {
+	if mode == CacheBustQuery || mode == CacheBustBoth {
+		b := make([]byte, 8)
+		rand.Read(b)
+		q := req.URL.Query()
+		q.Set(CacheBustParam, hex.EncodeToString(b))
+		req.URL.RawQuery = q.Encode()
+	}
+	if mode == CacheBustHeaders || mode == CacheBustBoth {
+		req.Header.Set("Cache-Control", "no-cache")
+		req.Header.Set("Pragma", "no-cache")
+	}
+}
+
+// cacheMeta reports the headers telling whether a cache answered: Age, how
+// long the response sat in a cache, and X-Cache, a hit or miss as CDNs and
+// proxies report it.
+func cacheMeta(stats *pinger.Stats, header http.Header) {
+	for key, name := range map[string]string{"age": "Age", "x_cache": "X-Cache"} {
+		if v := strings.Join(header.Values(name), ", "); v != "" {
+			if strings.ContainsAny(v, " \t") {
+				v = strconv.Quote(v)
+			}
+			stats.Meta[key] = pinger.StringerFunc(func() string { return v })
+		}
+	}
+}
+
 // Int is a simple wrapper around int that implements fmt.Stringer.
 type Int int
 
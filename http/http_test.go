@@ -1 +1,137 @@
-package http
\ No newline at end of file
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+func TestPing_Budget(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer srv.Close()
+
+	ping, err := New(http.MethodGet, srv.URL, &pinger.Option{Timeout: time.Second}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats := ping.Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed: %v", stats.Error)
+	}
+
+	trace := stats.Extra.(*Trace)
+	if trace.Budget <= 0 || trace.Budget > time.Second {
+		t.Fatalf("unexpected budget %s", trace.Budget)
+	}
+	if used := trace.BudgetUsed(); used < 0.02 || used > 1 {
+		t.Fatalf("unexpected budget used %.2f", used)
+	}
+	if trace.Remaining() != trace.Budget-trace.Total {
+		t.Fatalf("remaining %s, want %s", trace.Remaining(), trace.Budget-trace.Total)
+	}
+	for _, want := range []string{"budget_used=", "budget_left=", "budget_phases=dns:0%,connect:"} {
+		if !strings.Contains(trace.String(), want) {
+			t.Fatalf("trace lacks %q: %s", want, trace)
+		}
+	}
+}
+
+func TestPing_Verbose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Probe", "yes")
+	}))
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		verbose           int
+		local, trace, hdr bool
+	}{
+		{0, false, false, false},
+		{pinger.VerboseAddresses, true, false, false},
+		{pinger.VerboseTrace, true, true, false},
+		{pinger.VerboseRaw, true, true, true},
+	} {
+		ping, err := New(http.MethodGet, srv.URL, &pinger.Option{Timeout: time.Second, Verbose: tc.verbose}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := ping.Ping(context.Background())
+		if !stats.Connected {
+			t.Fatalf("ping failed: %v", stats.Error)
+		}
+		if _, ok := stats.Meta["local"]; ok != tc.local {
+			t.Errorf("-V level %d: local address shown = %v", tc.verbose, ok)
+		}
+		if (stats.Extra != nil) != tc.trace {
+			t.Errorf("-V level %d: trace shown = %v", tc.verbose, stats.Extra != nil)
+		}
+		if tc.trace && strings.Contains(stats.Extra.String(), "X-Probe: yes") != tc.hdr {
+			t.Errorf("-V level %d: unexpected headers in %s", tc.verbose, stats.Extra)
+		}
+	}
+}
+
+func TestPing_CacheBust(t *testing.T) {
+	var queries []string
+	var pragmas []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Query().Get(CacheBustParam))
+		pragmas = append(pragmas, r.Header.Get("Pragma"))
+		w.Header().Set("Age", "42")
+		w.Header().Set("X-Cache", "HIT from edge")
+	}))
+	defer srv.Close()
+
+	for _, mode := range []string{"", CacheBustQuery, CacheBustHeaders, CacheBustBoth} {
+		ping, err := New(http.MethodGet, srv.URL+"/?keep=1", &pinger.Option{Timeout: time.Second, CacheBust: mode}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats := ping.Ping(context.Background())
+		if !stats.Connected {
+			t.Fatalf("ping failed: %v", stats.Error)
+		}
+		if got := stats.FormatMeta(); !strings.Contains(got, `age=42`) || !strings.Contains(got, `x_cache="HIT from edge"`) {
+			t.Fatalf("cache headers not reported: %s", got)
+		}
+	}
+	if queries[0] != "" || len(queries[1]) != 16 || queries[2] != "" || queries[3] == "" || queries[1] == queries[3] {
+		t.Fatalf("unexpected cache bust parameters %q", queries)
+	}
+	if pragmas[0] != "" || pragmas[1] != "" || pragmas[2] != "no-cache" || pragmas[3] != "no-cache" {
+		t.Fatalf("unexpected Pragma headers %q", pragmas)
+	}
+	if _, err := New(http.MethodGet, srv.URL, &pinger.Option{CacheBust: "always"}, false); err == nil {
+		t.Fatal("expected an error for an unknown cache bust mode")
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	newPing := func(t *testing.T, url string, timeout time.Duration) pinger.Ping {
+		ping, err := New(http.MethodGet, url, &pinger.Option{Timeout: timeout}, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ping
+	}
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(t, pingertest.NewHTTPServer(t, nil).URL, timeout)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(t, fmt.Sprintf("http://127.0.0.1:%d/", pingertest.ClosedPort(t)), timeout)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(t, "http://"+pingertest.NewTCPBlackhole(t).Addr()+"/", timeout)
+		},
+	})
+}
@@ -5,10 +5,15 @@ import (
 	"context"
 	"crypto/tls"
 	"fmt"
+	"maps"
 	"net"
+	"net/http"
 	"net/http/httptrace"
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
 )
 
 // Ensure Trace implements fmt.Stringer
@@ -31,9 +36,19 @@ type Trace struct {
 
 	BodyDuration time.Duration `json:"body_duration"`
 
+	// Budget is the time the probe was allowed, the rest of its timeout when
+	// it started, and Total the time it took
+	Budget time.Duration `json:"budget,omitempty"`
+	Total  time.Duration `json:"total"`
+
 	tlsState tls.ConnectionState
 
-	address string
+	address  string
+	resolved []net.IP
+	local    net.Addr
+
+	// Header holds the response headers, shown after the timings when set
+	Header http.Header
 }
 
 // String returns a formatted string representation of the trace data.
@@ -64,9 +79,77 @@ func (t *Trace) String() string {
 	builder.WriteString(" response_body=")
 	builder.WriteString(t.BodyDuration.String())
 
+	// Add the share of the timeout the phases consumed
+	if t.Budget > 0 {
+		builder.WriteString(" budget=")
+		builder.WriteString(t.Budget.Round(time.Millisecond).String())
+		builder.WriteString(" budget_used=")
+		builder.WriteString(budgetPercent(t.Total, t.Budget))
+		builder.WriteString(" budget_left=")
+		builder.WriteString(t.Remaining().Round(time.Microsecond).String())
+		builder.WriteString(" budget_phases=")
+		for i, phase := range t.Phases() {
+			if i > 0 {
+				builder.WriteByte(',')
+			}
+			builder.WriteString(phase.Name)
+			builder.WriteByte(':')
+			builder.WriteString(budgetPercent(phase.Duration, t.Budget))
+		}
+	}
+
+	// Add the response headers, one per line
+	keys := slices.Sorted(maps.Keys(t.Header))
+	for _, key := range keys {
+		for _, value := range t.Header[key] {
+			builder.WriteString("\n ")
+			builder.WriteString(key)
+			builder.WriteString(": ")
+			builder.WriteString(value)
+		}
+	}
+
 	return builder.String()
 }
 
+// BudgetUsed returns the fraction of the timeout budget the probe consumed,
+// or 0 without a budget.
+func (t *Trace) BudgetUsed() float64 {
+	if t.Budget <= 0 {
+		return 0
+	}
+	return float64(t.Total) / float64(t.Budget)
+}
+
+// Remaining returns how much of the timeout budget was left when the probe
+// completed, never less than zero.
+func (t *Trace) Remaining() time.Duration {
+	return max(t.Budget-t.Total, 0)
+}
+
+// Phases returns the phases of the request in order.
+func (t *Trace) Phases() []pinger.Phase {
+	phases := []pinger.Phase{{Name: "dns", Duration: t.DNSDuration}, {Name: "connect", Duration: t.ConnectDuration}}
+	if t.tls {
+		phases = append(phases, pinger.Phase{Name: "tls", Duration: t.TLSDuration})
+	}
+	return append(phases,
+		pinger.Phase{Name: "request", Duration: t.WroteRequestDuration},
+		pinger.Phase{Name: "wait_response", Duration: t.WaitResponseDuration},
+		pinger.Phase{Name: "response_body", Duration: t.BodyDuration},
+	)
+}
+
+// budgetPercent formats d as a whole percentage of budget.
+func budgetPercent(d, budget time.Duration) string {
+	return fmt.Sprintf("%.0f%%", float64(d)/float64(budget)*100)
+}
+
+// TLSState returns the state of the TLS connection, if the request used one.
+func (t *Trace) TLSState() tls.ConnectionState {
+	return t.tlsState
+}
+
 // WithTrace adds HTTP tracing to the provided context.
 // It returns a new context with trace hooks installed.
 func (t *Trace) WithTrace(ctx context.Context) context.Context {
@@ -79,6 +162,12 @@ func (t *Trace) WithTrace(ctx context.Context) context.Context {
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
 			t.DNSDuration = time.Since(dnsStart)
+			for _, addr := range info.Addrs {
+				t.resolved = append(t.resolved, addr.IP)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.local = info.Conn.LocalAddr()
 		},
 		ConnectStart: func(network, addr string) {
 			connectStart = time.Now()
@@ -0,0 +1,237 @@
+// Package icmp provides ICMP echo ping functionality for the circle-pinger tool.
+package icmp
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"runtime"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// ICMP message types used by the echo exchange.
+const (
+	echoReplyV4   = 0
+	echoRequestV4 = 8
+	echoRequestV6 = 128
+	echoReplyV6   = 129
+)
+
+// payload is the body carried by every echo request.
+var payload = []byte("circle-pinger")
+
+// New creates a new ICMP Ping instance for the given host.
+func New(host string, op *pinger.Option) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{
+		host:   host,
+		option: op,
+		id:     uint16(os.Getpid() & 0xffff),
+	}
+}
+
+// Ping is the ICMP echo ping implementation. It prefers a raw socket and falls
+// back to an unprivileged ICMP datagram socket where the platform allows it.
+type Ping struct {
+	option *pinger.Option
+	host   string
+	id     uint16
+	seq    atomic.Uint32
+}
+
+// Ping sends a single echo request and waits for the matching echo reply.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+	start := time.Now()
+
+	ip, err := p.resolve(ctx, stats)
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	stats.Address = ip.String()
+	v6 := ip.To4() == nil
+
+	conn, privileged, err := listen(v6)
+	if err != nil {
+		stats.Error = fmt.Errorf("open icmp socket failed: %w", err)
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	defer conn.Close()
+
+	// Unblock the read as soon as the context is cancelled.
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	seq := uint16(p.seq.Add(1))
+	msg := marshalEcho(v6, p.id, seq, payload)
+	var dst net.Addr = &net.IPAddr{IP: ip}
+	if !privileged {
+		dst = &net.UDPAddr{IP: ip}
+	}
+
+	sendStart := time.Now()
+	if _, err := conn.WriteTo(msg, dst); err != nil {
+		stats.Error = fmt.Errorf("write failed: %w", err)
+		stats.Duration = time.Since(start)
+		return stats
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			stats.Error = fmt.Errorf("read failed: %w", err)
+			break
+		}
+		reply := buf[:n]
+		if !privileged && !v6 && runtime.GOOS == "darwin" {
+			reply = stripIPv4Header(reply)
+		}
+		if matchEchoReply(reply, v6, p.id, seq, privileged) {
+			stats.Connected = true
+			break
+		}
+	}
+	stats.Duration = time.Since(sendStart)
+
+	stats.Meta["seq"] = pinger.StringerFunc(func() string { return strconv.Itoa(int(seq)) })
+	mode := "dgram"
+	if privileged {
+		mode = "raw"
+	}
+	stats.Meta["socket"] = pinger.StringerFunc(func() string { return mode })
+	return stats
+}
+
+// resolve returns the IP address to probe, recording the DNS time in stats.
+func (p *Ping) resolve(ctx context.Context, stats *pinger.Stats) (net.IP, error) {
+	if ip := net.ParseIP(p.host); ip != nil {
+		return ip, nil
+	}
+	resolver := net.DefaultResolver
+	if p.option.Resolver != nil {
+		resolver = p.option.Resolver
+	}
+	dnsStart := time.Now()
+	addrs, err := resolver.LookupIPAddr(ctx, p.host)
+	stats.DNSDuration = time.Since(dnsStart)
+	if err != nil {
+		return nil, fmt.Errorf("dns lookup failed: %w", err)
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("dns lookup returned no IP addresses for %s", p.host)
+	}
+	return addrs[0].IP, nil
+}
+
+// listen opens a raw ICMP socket, falling back to a datagram socket.
+func listen(v6 bool) (conn net.PacketConn, privileged bool, err error) {
+	network, address := "ip4:icmp", "0.0.0.0"
+	if v6 {
+		network, address = "ip6:ipv6-icmp", "::"
+	}
+	conn, rawErr := net.ListenPacket(network, address)
+	if rawErr == nil {
+		return conn, true, nil
+	}
+	conn, err = listenDatagram(v6)
+	if err != nil {
+		return nil, false, errors.Join(rawErr, err)
+	}
+	return conn, false, nil
+}
+
+// marshalEcho builds an ICMP echo request. The ICMPv6 checksum is left for
+// the kernel to fill in, as it covers a pseudo-header we do not know.
+func marshalEcho(v6 bool, id, seq uint16, data []byte) []byte {
+	b := make([]byte, 8+len(data))
+	b[0] = echoRequestV4
+	if v6 {
+		b[0] = echoRequestV6
+	}
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	copy(b[8:], data)
+	if !v6 {
+		binary.BigEndian.PutUint16(b[2:], checksum(b))
+	}
+	return b
+}
+
+// matchEchoReply reports whether b is the echo reply for id and seq. Datagram
+// sockets have their identifier rewritten by the kernel, so only the sequence
+// number is compared for them.
+func matchEchoReply(b []byte, v6 bool, id, seq uint16, checkID bool) bool {
+	if len(b) < 8 {
+		return false
+	}
+	want := byte(echoReplyV4)
+	if v6 {
+		want = echoReplyV6
+	}
+	if b[0] != want || b[1] != 0 {
+		return false
+	}
+	if checkID && binary.BigEndian.Uint16(b[4:]) != id {
+		return false
+	}
+	return binary.BigEndian.Uint16(b[6:]) == seq
+}
+
+// stripIPv4Header removes the IPv4 header that macOS datagram sockets prepend.
+func stripIPv4Header(b []byte) []byte {
+	if len(b) < 20 || b[0]>>4 != 4 {
+		return b
+	}
+	hl := int(b[0]&0x0f) * 4
+	if hl > len(b) {
+		return b
+	}
+	return b[hl:]
+}
+
+// checksum computes the Internet checksum (RFC 1071) of b.
+func checksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,36 @@
+package icmp
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/privilege"
+)
+
+func TestMarshalEcho(t *testing.T) {
+	msg := marshalEcho(false, 0x1234, 7, payload)
+	if checksum(msg) != 0 {
+		t.Fatalf("checksum of marshalled message should verify to zero")
+	}
+	reply := append([]byte(nil), msg...)
+	reply[0] = echoReplyV4
+	if !matchEchoReply(reply, false, 0x1234, 7, true) {
+		t.Fatalf("reply should match the request")
+	}
+	if matchEchoReply(reply, false, 0x1234, 8, true) {
+		t.Fatalf("reply with another sequence should not match")
+	}
+}
+
+func TestPing(t *testing.T) {
+	if !privilege.Detect().CanICMP() {
+		t.Skip("no privileges to open an icmp socket")
+	}
+	ping := New("127.0.0.1", &pinger.Option{Timeout: 2 * time.Second})
+	stats := ping.Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+}
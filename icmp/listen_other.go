@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package icmp
+
+import (
+	"errors"
+	"net"
+)
+
+// listenDatagram is unsupported on this platform.
+func listenDatagram(v6 bool) (net.PacketConn, error) {
+	return nil, errors.New("icmp datagram sockets are not supported on this platform")
+}
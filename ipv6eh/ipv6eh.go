@@ -0,0 +1,317 @@
+// Package ipv6eh probes how a path treats IPv6 extension headers. Every probe
+// sends an ICMPv6 echo request without extension headers as a baseline and
+// one carrying each selected header, and reports which of them are answered.
+// Routers and firewalls commonly drop packets with hop-by-hop options or
+// fragments (RFC 7872), which breaks protocols relying on them while plain
+// pings keep working.
+package ipv6eh
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// Header is an IPv6 extension header a probe can carry.
+type Header string
+
+const (
+	// None is the baseline echo request without extension headers.
+	None Header = "none"
+	// HopByHop carries a hop-by-hop options header with padding only.
+	HopByHop Header = "hbh"
+	// DestOpts carries a destination options header with padding only.
+	DestOpts Header = "dst"
+	// Fragment is sent in two fragments of at most the minimum MTU,
+	// carrying fragment headers.
+	Fragment Header = "frag"
+)
+
+// Headers are the extension headers probed by default.
+var Headers = []Header{HopByHop, DestOpts, Fragment}
+
+// ParseHeaders parses a list of header names such as "hbh,frag".
+func ParseHeaders(names []string) ([]Header, error) {
+	var headers []Header
+	for _, name := range names {
+		h := Header(strings.ToLower(strings.TrimSpace(name)))
+		switch h {
+		case HopByHop, DestOpts, Fragment:
+			headers = append(headers, h)
+		default:
+			return nil, fmt.Errorf("unknown extension header %q, want %s, %s or %s", name, HopByHop, DestOpts, Fragment)
+		}
+	}
+	return headers, nil
+}
+
+// ICMPv6 message types of the echo exchange.
+const (
+	echoRequest = 128
+	echoReply   = 129
+)
+
+const (
+	// minMTU is the IPv6 minimum MTU, to which fragmented probes are cut.
+	minMTU = 1280
+	// fragmentPayload makes an echo request exceed minMTU.
+	fragmentPayload = 1300
+
+	ipv6Header     = 40
+	fragmentHeader = 8
+	protoICMPv6    = 58
+	protoFragment  = 44
+)
+
+// padOptions is a hop-by-hop or destination options header holding a PadN
+// option only: next header and length (filled in by the kernel), then PadN
+// with 4 bytes of padding.
+var padOptions = []byte{0, 0, 1, 4, 0, 0, 0, 0}
+
+// payload is the body of the echo requests without fragments.
+var payload = []byte("circle-pinger")
+
+// New creates a new Ping probing host with headers besides the baseline.
+func New(host string, op *pinger.Option, headers []Header) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	if len(headers) == 0 {
+		headers = Headers
+	}
+	return &Ping{
+		host:     host,
+		option:   op,
+		headers:  headers,
+		id:       uint16(os.Getpid() & 0xffff),
+		sent:     make(map[Header]int),
+		answered: make(map[Header]int),
+	}
+}
+
+// Ping is the IPv6 extension header probe.
+type Ping struct {
+	option  *pinger.Option
+	host    string
+	headers []Header
+	id      uint16
+	seq     atomic.Uint32
+
+	mu       sync.Mutex
+	sent     map[Header]int // echo requests sent per header over all probes
+	answered map[Header]int // of which were answered
+}
+
+// result is the outcome of the echo request carrying one header.
+type result struct {
+	err      error
+	duration time.Duration
+}
+
+// Ping sends the baseline and one echo request per header concurrently and
+// waits for their replies. The probe succeeds when the baseline is answered;
+// the metadata tells which headers were.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{Meta: make(map[string]fmt.Stringer)}
+	start := time.Now()
+	ip, err := p.resolve(ctx, stats)
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		return stats
+	}
+	stats.Address = ip.String()
+
+	headers := append([]Header{None}, p.headers...)
+	results := make([]result, len(headers))
+	var wg sync.WaitGroup
+	for i, h := range headers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = p.echo(ctx, ip, h, uint16(p.seq.Add(1)))
+		}()
+	}
+	wg.Wait()
+
+	stats.Duration = results[0].duration
+	if results[0].err != nil {
+		stats.Error = fmt.Errorf("no reply without extension headers: %w", results[0].err)
+	} else {
+		stats.Connected = true
+	}
+
+	outcomes := make([]string, 0, len(p.headers))
+	p.mu.Lock()
+	for i, h := range p.headers {
+		outcome := "ok"
+		p.sent[h]++
+		if results[i+1].err != nil {
+			outcome = "lost"
+		} else {
+			p.answered[h]++
+		}
+		outcomes = append(outcomes, string(h)+":"+outcome)
+	}
+	answered := make([]string, 0, len(p.headers))
+	for _, h := range p.headers {
+		answered = append(answered, fmt.Sprintf("%s:%d/%d", h, p.answered[h], p.sent[h]))
+	}
+	p.mu.Unlock()
+	stats.Meta["eh"] = pinger.StringerFunc(func() string { return strings.Join(outcomes, ",") })
+	stats.Meta["eh_answered"] = pinger.StringerFunc(func() string { return strings.Join(answered, ",") })
+	return stats
+}
+
+// echo sends an echo request carrying h to ip and waits for its reply.
+func (p *Ping) echo(ctx context.Context, ip net.IP, h Header, seq uint16) result {
+	conn, err := listen(h)
+	if err != nil {
+		return result{err: fmt.Errorf("open icmpv6 socket with %s failed: %w", h, err)}
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	data := payload
+	if h == Fragment {
+		data = make([]byte, fragmentPayload)
+		copy(data, payload)
+	}
+	start := time.Now()
+	if _, err := conn.WriteTo(marshalEcho(p.id, seq, data), &net.IPAddr{IP: ip}); err != nil {
+		return result{err: fmt.Errorf("write failed: %w", err), duration: time.Since(start)}
+	}
+	buf := make([]byte, 2*fragmentPayload)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				err = ctx.Err()
+			}
+			return result{err: fmt.Errorf("read failed: %w", err), duration: time.Since(start)}
+		}
+		if matchEchoReply(buf[:n], p.id, seq) {
+			return result{duration: time.Since(start)}
+		}
+	}
+}
+
+// resolve returns the IPv6 address to probe, recording the DNS time in
+// stats.
+func (p *Ping) resolve(ctx context.Context, stats *pinger.Stats) (net.IP, error) {
+	if ip := net.ParseIP(p.host); ip != nil {
+		if ip.To4() != nil {
+			return nil, fmt.Errorf("%s is not an IPv6 address", p.host)
+		}
+		return ip, nil
+	}
+	resolver := net.DefaultResolver
+	if p.option.Resolver != nil {
+		resolver = p.option.Resolver
+	}
+	dnsStart := time.Now()
+	ips, err := resolver.LookupIP(ctx, "ip6", p.host)
+	stats.DNSDuration = time.Since(dnsStart)
+	if err != nil {
+		return nil, fmt.Errorf("dns lookup failed: %w", err)
+	}
+	if len(ips) == 0 {
+		return nil, errors.New("dns lookup returned no IPv6 addresses for " + p.host)
+	}
+	return ips[0], nil
+}
+
+// marshalEcho builds an ICMPv6 echo request. The checksum is left for the
+// kernel to fill in, as it covers a pseudo-header we do not know.
+func marshalEcho(id, seq uint16, data []byte) []byte {
+	b := make([]byte, 8+len(data))
+	b[0] = echoRequest
+	binary.BigEndian.PutUint16(b[4:], id)
+	binary.BigEndian.PutUint16(b[6:], seq)
+	copy(b[8:], data)
+	return b
+}
+
+// fragment splits the ICMPv6 message msg from src to dst into IPv6 packets of
+// at most minMTU bytes with fragment headers, filling in the checksum of msg.
+// The packets are complete, for sockets that include the IPv6 header.
+func fragment(src, dst net.IP, msg []byte, id uint32) [][]byte {
+	msg = append([]byte(nil), msg...)
+	binary.BigEndian.PutUint16(msg[2:], checksum(src, dst, msg))
+
+	// Every fragment but the last carries a multiple of 8 bytes
+	size := (minMTU - ipv6Header - fragmentHeader) &^ 7
+	var packets [][]byte
+	for offset := 0; offset < len(msg); offset += size {
+		chunk := msg[offset:min(offset+size, len(msg))]
+		b := make([]byte, ipv6Header+fragmentHeader+len(chunk))
+		b[0] = 6 << 4
+		binary.BigEndian.PutUint16(b[4:], uint16(fragmentHeader+len(chunk)))
+		b[6] = protoFragment
+		b[7] = 64 // hop limit
+		copy(b[8:24], src.To16())
+		copy(b[24:40], dst.To16())
+		b[40] = protoICMPv6
+		flags := uint16(offset)
+		if offset+len(chunk) < len(msg) {
+			flags |= 1 // more fragments
+		}
+		binary.BigEndian.PutUint16(b[42:], flags)
+		binary.BigEndian.PutUint32(b[44:], id)
+		copy(b[48:], chunk)
+		packets = append(packets, b)
+	}
+	return packets
+}
+
+// checksum computes the ICMPv6 checksum of msg, whose checksum field is
+// zero, over the IPv6 pseudo-header (RFC 8200) and msg.
+func checksum(src, dst net.IP, msg []byte) uint16 {
+	pseudo := make([]byte, 0, 40+len(msg))
+	pseudo = append(pseudo, src.To16()...)
+	pseudo = append(pseudo, dst.To16()...)
+	pseudo = binary.BigEndian.AppendUint32(pseudo, uint32(len(msg)))
+	pseudo = append(pseudo, 0, 0, 0, protoICMPv6)
+	pseudo = append(pseudo, msg...)
+	var sum uint32
+	for i := 0; i+1 < len(pseudo); i += 2 {
+		sum += uint32(pseudo[i])<<8 | uint32(pseudo[i+1])
+	}
+	if len(pseudo)%2 == 1 {
+		sum += uint32(pseudo[len(pseudo)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
+
+// matchEchoReply reports whether b is the echo reply for id and seq.
+func matchEchoReply(b []byte, id, seq uint16) bool {
+	return len(b) >= 8 && b[0] == echoReply && b[1] == 0 &&
+		binary.BigEndian.Uint16(b[4:]) == id && binary.BigEndian.Uint16(b[6:]) == seq
+}
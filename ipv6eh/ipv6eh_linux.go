@@ -0,0 +1,86 @@
+//go:build linux
+
+package ipv6eh
+
+import (
+	"math/rand/v2"
+	"net"
+	"os"
+	"syscall"
+)
+
+// listen opens a raw ICMPv6 socket whose packets carry h. Options headers
+// are set as sticky options (RFC 3542); fragments are built by hand and
+// sent on a socket including the IPv6 header, as the kernel only fragments
+// packets exceeding the MTU of the interface.
+func listen(h Header) (net.PacketConn, error) {
+	conn, err := net.ListenPacket("ip6:ipv6-icmp", "::")
+	if err != nil {
+		return nil, err
+	}
+	var opt int
+	switch h {
+	case HopByHop:
+		opt = syscall.IPV6_HOPOPTS
+	case DestOpts:
+		opt = syscall.IPV6_DSTOPTS
+	case Fragment:
+		raw, err := net.ListenPacket("ip6:255", "::") // IPPROTO_RAW
+		if err != nil {
+			conn.Close()
+			return nil, err
+		}
+		return &fragmentConn{PacketConn: conn, raw: raw}, nil
+	default:
+		return conn, nil
+	}
+
+	sc, err := conn.(*net.IPConn).SyscallConn()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	var sockErr error
+	if err := sc.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptString(int(fd), syscall.IPPROTO_IPV6, opt, string(padOptions))
+	}); err != nil {
+		sockErr = err
+	}
+	if sockErr != nil {
+		conn.Close()
+		return nil, os.NewSyscallError("setsockopt", sockErr)
+	}
+	return conn, nil
+}
+
+// fragmentConn receives on an ICMPv6 socket and sends ICMPv6 messages in
+// fragments on a raw socket.
+type fragmentConn struct {
+	net.PacketConn
+	raw net.PacketConn
+}
+
+// WriteTo sends the ICMPv6 message b to addr in fragments.
+func (c *fragmentConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	dst := addr.(*net.IPAddr).IP
+	// The source address the kernel would pick, which the checksum covers
+	probe, err := net.DialUDP("udp6", nil, &net.UDPAddr{IP: dst, Port: 9})
+	if err != nil {
+		return 0, err
+	}
+	src := probe.LocalAddr().(*net.UDPAddr).IP
+	probe.Close()
+
+	for _, packet := range fragment(src, dst, b, rand.Uint32()) {
+		if _, err := c.raw.WriteTo(packet, addr); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Close closes both sockets.
+func (c *fragmentConn) Close() error {
+	c.raw.Close()
+	return c.PacketConn.Close()
+}
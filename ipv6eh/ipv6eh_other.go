@@ -0,0 +1,13 @@
+//go:build !linux
+
+package ipv6eh
+
+import (
+	"errors"
+	"net"
+)
+
+// listen is unsupported on this platform.
+func listen(h Header) (net.PacketConn, error) {
+	return nil, errors.New("ipv6 extension header probes are only supported on linux")
+}
@@ -0,0 +1,62 @@
+package ipv6eh
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/privilege"
+)
+
+func TestParseHeaders(t *testing.T) {
+	headers, err := ParseHeaders([]string{"HBH", " frag"})
+	if err != nil || len(headers) != 2 || headers[0] != HopByHop || headers[1] != Fragment {
+		t.Fatalf("unexpected headers %v, %v", headers, err)
+	}
+	if _, err := ParseHeaders([]string{"routing"}); err == nil {
+		t.Fatal("expected an error for an unknown header")
+	}
+}
+
+func TestFragment(t *testing.T) {
+	src, dst := net.ParseIP("2001:db8::1"), net.ParseIP("2001:db8::2")
+	msg := marshalEcho(1, 2, make([]byte, fragmentPayload))
+	packets := fragment(src, dst, msg, 42)
+	if len(packets) != 2 || len(packets[0]) != minMTU {
+		t.Fatalf("expected a full first fragment and a second, got %d packets", len(packets))
+	}
+
+	var reassembled []byte
+	for i, p := range packets {
+		offset, more := int(binary.BigEndian.Uint16(p[42:])&^7), p[43]&1 == 1
+		if p[6] != protoFragment || p[40] != protoICMPv6 || binary.BigEndian.Uint32(p[44:]) != 42 ||
+			offset != len(reassembled) || more != (i == 0) {
+			t.Fatalf("unexpected fragment header % x", p[40:48])
+		}
+		reassembled = append(reassembled, p[48:]...)
+	}
+	if checksum(src, dst, reassembled) != 0 {
+		t.Fatal("checksum of the reassembled message should verify to zero")
+	}
+}
+
+func TestPing(t *testing.T) {
+	if runtime.GOOS != "linux" || !privilege.Detect().RawSocket {
+		t.Skip("no privileges to open a raw icmpv6 socket")
+	}
+	p := New("::1", &pinger.Option{Timeout: 2 * time.Second}, nil)
+	stats := p.Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["eh"].String(); got != "hbh:ok,dst:ok,frag:ok" {
+		t.Fatalf("loopback should answer every header, got %s", got)
+	}
+	if got := p.Ping(context.Background()).Meta["eh_answered"].String(); got != "hbh:2/2,dst:2/2,frag:2/2" {
+		t.Fatalf("unexpected counts %s", got)
+	}
+}
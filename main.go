@@ -11,11 +11,14 @@ import (
 var (
 	version   = "dev"
 	gitCommit = "unknown"
+	buildDate = "unknown"
 )
 
 func main() {
 	// Set version information
 	cli.RootCmd.Version = version
+	cli.Commit = gitCommit
+	cli.BuildDate = buildDate
 
 	// Initialize the CLI
 	cli.Initialize()
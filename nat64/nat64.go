@@ -0,0 +1,159 @@
+// Package nat64 lets IPv6-only hosts reach IPv4 addresses through NAT64. It
+// learns the prefix a DNS64 resolver synthesizes addresses with from the
+// well-known name ipv4only.arpa (RFC 7050) and embeds IPv4 addresses in it
+// (RFC 6052).
+package nat64
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+)
+
+// WellKnownName is the name whose only IPv4 addresses are WellKnownAddrs, so
+// that its AAAA records reveal the NAT64 prefix.
+const WellKnownName = "ipv4only.arpa"
+
+// WellKnownAddrs are the IPv4 addresses of WellKnownName.
+var WellKnownAddrs = []netip.Addr{
+	netip.AddrFrom4([4]byte{192, 0, 0, 170}),
+	netip.AddrFrom4([4]byte{192, 0, 0, 171}),
+}
+
+// PrefixLengths are the NAT64 prefix lengths RFC 6052 allows, most common
+// first.
+var PrefixLengths = []int{96, 64, 56, 48, 40, 32}
+
+// ErrNoPrefix is returned by Discover when the resolver synthesizes no
+// addresses, that is when the network has no DNS64.
+var ErrNoPrefix = errors.New("no NAT64 prefix: " + WellKnownName + " has no synthesized AAAA records")
+
+// Resolver looks up the addresses of a host, as net.Resolver does.
+type Resolver interface {
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+}
+
+// Discover returns the NAT64 prefixes r synthesizes addresses with, learnt
+// from the AAAA records of WellKnownName. A nil r uses the system resolver.
+func Discover(ctx context.Context, r Resolver) ([]netip.Prefix, error) {
+	if r == nil {
+		r = net.DefaultResolver
+	}
+	addrs, err := r.LookupNetIP(ctx, "ip6", WellKnownName)
+	if err != nil {
+		var dnsErr *net.DNSError
+		if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+			return nil, ErrNoPrefix
+		}
+		return nil, err
+	}
+	var prefixes []netip.Prefix
+	for _, addr := range addrs {
+		if prefix, ok := Extract(addr); ok && !contains(prefixes, prefix) {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return nil, ErrNoPrefix
+	}
+	return prefixes, nil
+}
+
+// contains reports whether prefixes holds prefix.
+func contains(prefixes []netip.Prefix, prefix netip.Prefix) bool {
+	for _, p := range prefixes {
+		if p == prefix {
+			return true
+		}
+	}
+	return false
+}
+
+// Extract returns the prefix of addr, a synthesized address of
+// WellKnownName, by finding where one of WellKnownAddrs is embedded.
+func Extract(addr netip.Addr) (netip.Prefix, bool) {
+	if !addr.Is6() || addr.Is4In6() {
+		return netip.Prefix{}, false
+	}
+	for _, bits := range PrefixLengths {
+		v4, ok := embedded(addr, bits)
+		if !ok {
+			continue
+		}
+		for _, known := range WellKnownAddrs {
+			if v4 == known {
+				return netip.PrefixFrom(addr, bits).Masked(), true
+			}
+		}
+	}
+	return netip.Prefix{}, false
+}
+
+// Synthesize returns the IPv6 address reaching v4 through prefix.
+func Synthesize(prefix netip.Prefix, v4 netip.Addr) (netip.Addr, error) {
+	v4 = v4.Unmap()
+	if !v4.Is4() {
+		return netip.Addr{}, fmt.Errorf("%s is not an IPv4 address", v4)
+	}
+	positions, ok := octets(prefix.Bits())
+	if !prefix.Addr().Is6() || !ok {
+		return netip.Addr{}, fmt.Errorf("%s is not a NAT64 prefix", prefix)
+	}
+	b := prefix.Masked().Addr().As16()
+	for i, octet := range v4.As4() {
+		b[positions[i]] = octet
+	}
+	return netip.AddrFrom16(b), nil
+}
+
+// embedded returns the IPv4 address embedded in addr under a prefix of bits.
+// Bits 64 to 71, the u octet, must be zero.
+func embedded(addr netip.Addr, bits int) (netip.Addr, bool) {
+	positions, ok := octets(bits)
+	if !ok {
+		return netip.Addr{}, false
+	}
+	b := addr.As16()
+	if bits < 96 && b[8] != 0 {
+		return netip.Addr{}, false
+	}
+	var v4 [4]byte
+	for i, pos := range positions {
+		v4[i] = b[pos]
+	}
+	return netip.AddrFrom4(v4), true
+}
+
+// octets returns the positions in an IPv6 address of the four octets of an
+// embedded IPv4 address under a prefix of bits, which skip the u octet.
+func octets(bits int) ([4]int, bool) {
+	var positions [4]int
+	switch bits {
+	case 32, 40, 48, 56, 64, 96:
+	default:
+		return positions, false
+	}
+	pos := bits / 8
+	for i := range positions {
+		if pos == 8 {
+			pos++
+		}
+		positions[i] = pos
+		pos++
+	}
+	return positions, true
+}
+
+// Routable reports whether the host has a route to the IPv4 address v4.
+// Hosts on IPv6-only networks have none, and need NAT64 to reach it.
+func Routable(v4 netip.Addr) bool {
+	// Connecting a UDP socket only looks up the route, no packet is sent
+	conn, err := net.DialUDP("udp4", nil, net.UDPAddrFromAddrPort(netip.AddrPortFrom(v4, 9)))
+	if err != nil {
+		return false
+	}
+	conn.Close()
+	return true
+}
@@ -0,0 +1,68 @@
+package nat64
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+// fakeResolver answers with fixed addresses.
+type fakeResolver struct {
+	addrs []netip.Addr
+	err   error
+}
+
+func (r fakeResolver) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return r.addrs, r.err
+}
+
+func TestSynthesize(t *testing.T) {
+	// The examples of RFC 6052 section 2.4 for 192.0.2.33
+	v4 := netip.MustParseAddr("192.0.2.33")
+	for prefix, want := range map[string]string{
+		"2001:db8::/32":         "2001:db8:c000:221::",
+		"2001:db8:100::/40":     "2001:db8:1c0:2:21::",
+		"2001:db8:122::/48":     "2001:db8:122:c000:2:2100::",
+		"2001:db8:122:300::/56": "2001:db8:122:3c0:0:221::",
+		"2001:db8:122:344::/64": "2001:db8:122:344:c0:2:2100:0",
+		"2001:db8:122:344::/96": "2001:db8:122:344::192.0.2.33",
+		"64:ff9b::/96":          "64:ff9b::192.0.2.33",
+	} {
+		got, err := Synthesize(netip.MustParsePrefix(prefix), v4)
+		if err != nil || got != netip.MustParseAddr(want) {
+			t.Errorf("Synthesize(%s) = %s, %v, want %s", prefix, got, err, want)
+		}
+		if back, ok := embedded(got, netip.MustParsePrefix(prefix).Bits()); !ok || back != v4 {
+			t.Errorf("embedded(%s) = %s, %v", got, back, ok)
+		}
+	}
+	if _, err := Synthesize(netip.MustParsePrefix("64:ff9b::/80"), v4); err == nil {
+		t.Error("expected an error for a /80 prefix")
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	r := fakeResolver{addrs: []netip.Addr{
+		netip.MustParseAddr("64:ff9b::c000:aa"),
+		netip.MustParseAddr("64:ff9b::c000:ab"),
+		netip.MustParseAddr("2001:db8:122:344:c0:0:aa00:0"),
+	}}
+	prefixes, err := Discover(context.Background(), r)
+	if err != nil || len(prefixes) != 2 ||
+		prefixes[0] != netip.MustParsePrefix("64:ff9b::/96") ||
+		prefixes[1] != netip.MustParsePrefix("2001:db8:122:344::/64") {
+		t.Fatalf("Discover = %v, %v", prefixes, err)
+	}
+
+	// Networks without DNS64 have no AAAA records for the name
+	notFound := fakeResolver{err: &net.DNSError{Err: "no such host", Name: WellKnownName, IsNotFound: true}}
+	if _, err := Discover(context.Background(), notFound); !errors.Is(err, ErrNoPrefix) {
+		t.Fatalf("expected ErrNoPrefix, got %v", err)
+	}
+	unrelated := fakeResolver{addrs: []netip.Addr{netip.MustParseAddr("2001:db8::1")}}
+	if _, err := Discover(context.Background(), unrelated); !errors.Is(err, ErrNoPrefix) {
+		t.Fatalf("expected ErrNoPrefix, got %v", err)
+	}
+}
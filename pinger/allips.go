@@ -0,0 +1,34 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+)
+
+// allIPsPing rotates between the probes of the addresses of a host.
+type allIPsPing struct {
+	pings []Ping
+	ips   []string
+	next  int
+}
+
+// AllIPs rotates probes between pings, the probes of one target each pinned
+// to the address of ips at the same index with Option.Pin, starting with
+// the first. Each probe carries the metadata "ip", the address it probed, so
+// that outputs can keep statistics per address.
+func AllIPs(pings []Ping, ips []string) Ping {
+	return &allIPsPing{pings: pings, ips: ips}
+}
+
+// Ping implements Ping.
+func (a *allIPsPing) Ping(ctx context.Context) *Stats {
+	i := a.next % len(a.pings)
+	a.next++
+	stats := a.pings[i].Ping(ctx)
+	if stats.Meta == nil {
+		stats.Meta = make(map[string]fmt.Stringer)
+	}
+	ip := a.ips[i]
+	stats.Meta["ip"] = StringerFunc(func() string { return ip })
+	return stats
+}
@@ -18,4 +18,25 @@ const (
 	HTTPS
 	// UDP is the UDP protocol.
 	UDP
+	// ICMP is the ICMP echo protocol.
+	ICMP
+	// SOCKS5 is the SOCKS5 proxy negotiation protocol.
+	SOCKS5
+	// ARP is the ARP who-has protocol for local networks.
+	ARP
+	// SMB is the SMB2 negotiate protocol.
+	SMB
+	// GAMESERVER is the game server status query protocol (A2S_INFO or Minecraft Server List Ping).
+	GAMESERVER
+	// RPC is the ONC RPC portmapper and NULL procedure protocol.
+	RPC
+	// TLS is the TLS handshake protocol.
+	TLS
+	// H2C is the cleartext HTTP/2 with prior knowledge protocol.
+	H2C
+	// IPV6EH is the ICMPv6 echo protocol with IPv6 extension headers.
+	IPV6EH
+	// firstNamed is the first Protocol RegisterNamed allocates, after the
+	// built-in ones.
+	firstNamed
 )
\ No newline at end of file
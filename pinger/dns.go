@@ -0,0 +1,205 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+// ErrDNSTimeout is returned when the DNS lookup of a probe outlasts
+// Option.DNSTimeout, which tells a slow resolver apart from a slow target.
+var ErrDNSTimeout = errors.New("dns timeout")
+
+// Pin remembers the first address each host resolved to, for the probes of
+// a target to go on using it rather than resolving the host again. It is
+// safe for concurrent use; copies of an Option share it.
+type Pin struct {
+	mu    sync.Mutex
+	ips   map[string]net.IP // by network and host
+	fixed net.IP
+}
+
+// NewPin returns a Pin holding no address yet.
+func NewPin() *Pin {
+	return &Pin{ips: make(map[string]net.IP)}
+}
+
+// NewPinTo returns a Pin holding ip for any host, which probes ip without
+// resolving the host at all.
+func NewPinTo(ip net.IP) *Pin {
+	return &Pin{fixed: ip}
+}
+
+// lookup returns the address pinned for host in network, or pins the first
+// address lookup returns. A failed lookup pins nothing, so the next probe
+// tries again.
+func (p *Pin) lookup(network, host string, lookup func() ([]net.IP, error)) ([]net.IP, error) {
+	if p.fixed != nil {
+		return []net.IP{p.fixed}, nil
+	}
+	key := network + "/" + host
+	p.mu.Lock()
+	ip, ok := p.ips[key]
+	p.mu.Unlock()
+	if ok {
+		return []net.IP{ip}, nil
+	}
+	ips, err := lookup()
+	if err != nil || len(ips) == 0 {
+		return ips, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// A concurrent probe may have pinned an address in the meantime
+	if ip, ok := p.ips[key]; ok {
+		return []net.IP{ip}, nil
+	}
+	p.ips[key] = ips[0]
+	return ips[:1], nil
+}
+
+// LookupIP resolves host with resolver, or the default one when nil,
+// restricted to the IP family of the option. With a DNS timeout the lookup
+// gets no more than it, and fails with ErrDNSTimeout when it runs out before
+// ctx does. With a Pin only the first lookup of host goes to the resolver.
+func (o *Option) LookupIP(ctx context.Context, resolver *net.Resolver, host string) ([]net.IP, error) {
+	if o != nil && o.Pin != nil {
+		return o.Pin.lookup(o.Network("ip"), host, func() ([]net.IP, error) {
+			return o.lookupIP(ctx, resolver, host)
+		})
+	}
+	return o.lookupIP(ctx, resolver, host)
+}
+
+// lookupIP resolves host as LookupIP does, without the pin.
+func (o *Option) lookupIP(ctx context.Context, resolver *net.Resolver, host string) ([]net.IP, error) {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	if o == nil || o.DNSTimeout <= 0 {
+		return resolver.LookupIP(ctx, o.Network("ip"), host)
+	}
+	lookupCtx, cancel := context.WithTimeout(ctx, o.DNSTimeout)
+	defer cancel()
+	ips, err := resolver.LookupIP(lookupCtx, o.Network("ip"), host)
+	if err != nil && ctx.Err() == nil && errors.Is(lookupCtx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w after %s looking up %s", ErrDNSTimeout, o.DNSTimeout, host)
+	}
+	return ips, err
+}
+
+// Dial connects to addr with dialer as its DialContext does, except that
+// with a DNS timeout or a Pin the host of addr is resolved with LookupIP
+// first, so that the DNS timeout bounds the lookup alone. The addresses are
+// then dialed as dialer would, racing the two IP families (Happy Eyeballs),
+// or in turn with Failover when the option has FailoverIPs.
+func (o *Option) Dial(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if o == nil || (o.DNSTimeout <= 0 && o.Pin == nil) || err != nil || net.ParseIP(host) != nil {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	ips, err := o.LookupIP(ctx, dialer.Resolver, host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, len(ips))
+	for i, ip := range ips {
+		addrs[i] = net.JoinHostPort(ip.String(), port)
+	}
+	if o.FailoverIPs {
+		var conn net.Conn
+		_, err = Failover(ctx, addrs, func(ctx context.Context, addr string) error {
+			var err error
+			conn, err = dialer.DialContext(ctx, network, addr)
+			return err
+		})
+		return conn, err
+	}
+	return dialParallel(ctx, dialer, network, ips, port)
+}
+
+// defaultFallbackDelay is how long the addresses of the first IP family get
+// before those of the other are raced, as net.Dialer waits by default.
+const defaultFallbackDelay = 300 * time.Millisecond
+
+// dialParallel dials the addresses of a host as dialer does for a host name:
+// those of the family of the first address in turn, racing those of the
+// other family once the fallback delay of dialer passed without a
+// connection. It returns the first connection, or the error of the first
+// family when both fail.
+func dialParallel(ctx context.Context, dialer *net.Dialer, network string, ips []net.IP, port string) (net.Conn, error) {
+	var primaries, fallbacks []string
+	for _, ip := range ips {
+		addr := net.JoinHostPort(ip.String(), port)
+		if (ip.To4() != nil) == (ips[0].To4() != nil) {
+			primaries = append(primaries, addr)
+		} else {
+			fallbacks = append(fallbacks, addr)
+		}
+	}
+	serial := func(ctx context.Context, addrs []string) (net.Conn, error) {
+		var conn net.Conn
+		_, err := Failover(ctx, addrs, func(ctx context.Context, addr string) error {
+			var err error
+			conn, err = dialer.DialContext(ctx, network, addr)
+			return err
+		})
+		return conn, err
+	}
+	delay := dialer.FallbackDelay
+	if delay == 0 {
+		delay = defaultFallbackDelay
+	}
+	if len(fallbacks) == 0 || delay < 0 {
+		return serial(ctx, append(primaries, fallbacks...))
+	}
+
+	type result struct {
+		conn    net.Conn
+		err     error
+		primary bool
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	results := make(chan result)
+	race := func(addrs []string, primary bool) {
+		conn, err := serial(ctx, addrs)
+		select {
+		case results <- result{conn, err, primary}:
+		case <-ctx.Done():
+			if conn != nil {
+				conn.Close()
+			}
+		}
+	}
+	go race(primaries, true)
+	fallback := time.NewTimer(delay)
+	defer fallback.Stop()
+
+	var primaryErr, fallbackErr error
+	for {
+		select {
+		case <-fallback.C:
+			go race(fallbacks, false)
+		case res := <-results:
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if res.primary {
+				primaryErr = res.err
+			} else {
+				fallbackErr = res.err
+			}
+			if primaryErr != nil && fallbackErr != nil {
+				return nil, primaryErr
+			}
+			// Race the fallbacks at once when the primaries failed early
+			if res.primary && fallback.Stop() {
+				go race(fallbacks, false)
+			}
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+)
+
+// Families of the "family" metadata of DualStack probes.
+const (
+	FamilyIPv4 = "ipv4"
+	FamilyIPv6 = "ipv6"
+)
+
+// dualStackPing alternates between the probes of two IP families.
+type dualStackPing struct {
+	v4, v6 Ping
+	next   int
+}
+
+// DualStack alternates probes between v4 and v6, the probes of one target
+// restricted to IPv4 and IPv6 with Option.Family, starting with IPv4. Each
+// probe carries the metadata "family", FamilyIPv4 or FamilyIPv6, so that
+// outputs can keep statistics per family.
+func DualStack(v4, v6 Ping) Ping {
+	return &dualStackPing{v4: v4, v6: v6}
+}
+
+// Ping implements Ping.
+func (d *dualStackPing) Ping(ctx context.Context) *Stats {
+	ping, family := d.v4, FamilyIPv4
+	if d.next%2 == 1 {
+		ping, family = d.v6, FamilyIPv6
+	}
+	d.next++
+	stats := ping.Ping(ctx)
+	if stats.Meta == nil {
+		stats.Meta = make(map[string]fmt.Stringer)
+	}
+	stats.Meta["family"] = StringerFunc(func() string { return family })
+	return stats
+}
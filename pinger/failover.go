@@ -0,0 +1,41 @@
+package pinger
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Failover calls attempt with each address in turn until one succeeds, as
+// probes with Option.FailoverIPs do. Like net.Dialer, every attempt gets
+// what is left of ctx's deadline divided among the remaining addresses, so a
+// hanging address cannot use up the budget of the others. It returns the
+// index of the address that succeeded, or the error of the last attempt.
+func Failover(ctx context.Context, addrs []string, attempt func(ctx context.Context, addr string) error) (int, error) {
+	var err error
+	for i, addr := range addrs {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if deadline, ok := ctx.Deadline(); ok && i < len(addrs)-1 {
+			share := time.Until(deadline) / time.Duration(len(addrs)-i)
+			attemptCtx, cancel = context.WithTimeout(ctx, share)
+		}
+		err = attempt(attemptCtx, addr)
+		cancel()
+		if err == nil {
+			return i, nil
+		}
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	if len(addrs) > 1 {
+		err = fmt.Errorf("all %d addresses failed, last: %w", len(addrs), err)
+	}
+	return -1, err
+}
+
+// FailoverMeta formats which of n addresses answered, for the "failover"
+// metadata of probes.
+func FailoverMeta(i, n int) fmt.Stringer {
+	return StringerFunc(func() string { return fmt.Sprintf("%d/%d", i+1, n) })
+}
@@ -0,0 +1,76 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FallbackAfter is how many probes of a target must fail in a row before
+// Fallback starts probing the layers below its protocol.
+const FallbackAfter = 3
+
+// Layer is a probe of a lower network layer of a target, such as the TCP
+// connect below an HTTPS request.
+type Layer struct {
+	Name string
+	Ping Ping
+}
+
+// fallbackPing probes lower layers once its Ping keeps failing.
+type fallbackPing struct {
+	name     string
+	ping     Ping
+	layers   []Layer
+	after    int
+	failures int
+}
+
+// Fallback wraps ping, the probe of protocol name, so that once it failed
+// after times in a row every failing probe also probes layers from the top
+// down, until one works. The metadata "layers" lists the outcome of each
+// layer probed, and "broken" names the lowest failing layer above the first
+// working one: name itself when the layer right below works, the lowest
+// layer when none does. The layers run after the probe, with their own
+// timeouts, until the Pinger running the probe stops.
+func Fallback(name string, ping Ping, layers []Layer, after int) Ping {
+	return &fallbackPing{name: name, ping: ping, layers: layers, after: after}
+}
+
+// Ping implements Ping.
+func (f *fallbackPing) Ping(ctx context.Context) *Stats {
+	stats := f.ping.Ping(ctx)
+	if stats.Connected {
+		f.failures = 0
+		return stats
+	}
+	f.failures++
+	// A stopped probe is not a failure worth triaging
+	if f.failures < f.after || len(f.layers) == 0 || errors.Is(ctx.Err(), context.Canceled) {
+		return stats
+	}
+
+	// The probe may have used up the deadline of ctx, so the layers run
+	// until the Pinger stops rather than until ctx is done
+	layerCtx, ok := ctx.Value(runKey{}).(context.Context)
+	if !ok {
+		layerCtx = context.WithoutCancel(ctx)
+	}
+	broken := f.name
+	var outcomes []string
+	for _, layer := range f.layers {
+		if layer.Ping.Ping(layerCtx).Connected {
+			outcomes = append(outcomes, layer.Name+":ok")
+			break
+		}
+		outcomes = append(outcomes, layer.Name+":failed")
+		broken = layer.Name
+	}
+	if stats.Meta == nil {
+		stats.Meta = make(map[string]fmt.Stringer)
+	}
+	stats.Meta["layers"] = StringerFunc(func() string { return strings.Join(outcomes, ",") })
+	stats.Meta["broken"] = StringerFunc(func() string { return broken })
+	return stats
+}
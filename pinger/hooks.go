@@ -0,0 +1,28 @@
+package pinger
+
+// OnProbe makes the Pinger call f with the result of every probe once it is
+// counted, whether it is written to the writer or sink, or yielded by
+// Probes. It must be called before the Pinger runs.
+func (p *Pinger) OnProbe(f func(*Stats)) {
+	p.onProbe = f
+}
+
+// OnStateChange makes the Pinger call f when its target goes up or down,
+// with whether it is up, starting with the first probe, which establishes
+// the state. It must be called before the Pinger runs.
+func (p *Pinger) OnStateChange(f func(up bool)) {
+	p.onStateChange = f
+}
+
+// OnFinish makes the Pinger call f with the result of the run once Ping or
+// the loop over Probes returns. It must be called before the Pinger runs.
+func (p *Pinger) OnFinish(f func(Result)) {
+	p.onFinish = f
+}
+
+// finish calls the OnFinish hook, when set.
+func (p *Pinger) finish() {
+	if p.onFinish != nil {
+		p.onFinish(p.Snapshot())
+	}
+}
@@ -6,46 +6,53 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"iter"
 	"math"
 	"net"
 	"net/url"
 	"os"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"text/template" // Use text/template for non-HTML output
 	"time"
 
+	"github.com/circle-protocol/circle-pinger/stats"
 	"golang.org/x/sync/errgroup"
 )
 
-var (
-	// pinger contains registered Factory functions for each Protocol.
-	// Access should ideally be guarded if registration can happen concurrently
-	// after init, but for typical package init registration, a simple map is fine.
-	pinger = make(map[Protocol]Factory)
-
-	// ErrProtocolNotSupported is returned when a requested protocol is not registered.
-	ErrProtocolNotSupported = errors.New("protocol not supported")
-)
+// ErrProtocolNotSupported is returned when a requested protocol is not registered.
+var ErrProtocolNotSupported = errors.New("protocol not supported")
 
 // Factory is a function that creates a Ping instance for a given URL and options.
 type Factory func(url *url.URL, op *Option) (Ping, error)
 
-// Register registers a Factory function for a given Protocol.
-// It's typically called during package initialization (init functions).
-func Register(protocol Protocol, factory Factory) {
-	if _, exists := pinger[protocol]; exists {
-		// Log or panic if a protocol is registered multiple times?
-	}
-	pinger[protocol] = factory
+// Spec describes a registered Protocol: how to create its Pings, and what
+// the command line needs to offer it.
+type Spec struct {
+	Factory Factory
+	// Port is used when a target has none; 0 for protocols with no notion
+	// of ports.
+	Port int
+	// Short describes the protocol in one line, and Long is the usage shown
+	// by its help, with the form of its targets and examples.
+	Short, Long string
+	// SharedFlags names the flags of other protocols the protocol also
+	// uses, such as the --http-method of http for h2c. The command line
+	// defines the flags of a protocol, see cli.RegisterFlags.
+	SharedFlags []string
 }
 
-// Load retrieves the Factory function for a given Protocol.
-// Returns the Factory and a boolean indicating if it was found.
-func Load(protocol Protocol) (Factory, bool) {
-	factory, ok := pinger[protocol]
-	return factory, ok
+// KnownProtocols returns every protocol in ascending order, whether it is
+// registered or not, the built-in ones followed by those of RegisterNamed.
+func KnownProtocols() []Protocol {
+	var protocols []Protocol
+	for protocol := TCP; protocol.String() != "unknown"; protocol++ {
+		protocols = append(protocols, protocol)
+	}
+	return protocols
 }
 
 // Protocol represents a network protocol for pinging.
@@ -64,7 +71,28 @@ func (protocol Protocol) String() string {
 		return "https"
 	case UDP:
 		return "udp"
+	case ICMP:
+		return "icmp"
+	case SOCKS5:
+		return "socks5"
+	case ARP:
+		return "arp"
+	case SMB:
+		return "smb"
+	case GAMESERVER:
+		return "gameserver"
+	case RPC:
+		return "rpc"
+	case TLS:
+		return "tls"
+	case H2C:
+		return "h2c"
+	case IPV6EH:
+		return "ipv6eh"
 	default:
+		if name, ok := namedProtocol(protocol); ok {
+			return name
+		}
 		// Return a specific string for unknown protocols
 		return "unknown"
 	}
@@ -82,7 +110,28 @@ func NewProtocol(protocolStr string) (Protocol, error) {
 		return HTTPS, nil
 	case UDP.String():
 		return UDP, nil
+	case ICMP.String():
+		return ICMP, nil
+	case SOCKS5.String():
+		return SOCKS5, nil
+	case ARP.String():
+		return ARP, nil
+	case SMB.String():
+		return SMB, nil
+	case GAMESERVER.String():
+		return GAMESERVER, nil
+	case RPC.String():
+		return RPC, nil
+	case TLS.String():
+		return TLS, nil
+	case H2C.String():
+		return H2C, nil
+	case IPV6EH.String():
+		return IPV6EH, nil
 	default:
+		if protocol, ok := protocolNamed(strings.ToLower(protocolStr)); ok {
+			return protocol, nil
+		}
 		// Use the defined error constant
 		return 0, fmt.Errorf("%w: %s", ErrProtocolNotSupported, protocolStr)
 	}
@@ -91,16 +140,68 @@ func NewProtocol(protocolStr string) (Protocol, error) {
 // Option contains configuration options for creating a Ping instance.
 type Option struct {
 	Timeout time.Duration // Timeout for the entire ping sequence or related operations
+	// DNSTimeout bounds the DNS lookup of a probe within Timeout, so that a
+	// slow resolver fails it with ErrDNSTimeout instead of using up the
+	// budget of the connect. 0 leaves the lookup to Timeout. Ping
+	// implementations apply it with LookupIP and Dial.
+	DNSTimeout time.Duration
 	// Resolver is used to customize DNS resolution. Ping implementations might use this.
 	Resolver *net.Resolver
+	// Pin, when set, keeps the first address the host resolved to for all
+	// later probes, rather than resolving it for every probe. Ping
+	// implementations apply it with LookupIP and Dial.
+	Pin *Pin
 	// Proxy is used to configure proxy settings. Ping implementations might use this.
 	Proxy *url.URL
+	// ProxyAuth is the connection-based scheme authenticating to the proxy,
+	// "ntlm" or "negotiate". Empty sends the credentials of the proxy URL as
+	// Basic, left to the HTTP client for HTTP probes.
+	ProxyAuth string
 	// UA is the User-Agent string for HTTP/S pings. Ping implementations might use this.
 	UA string
+	// Method is the HTTP method for HTTP/S pings. Empty means the factory default.
+	Method string
+	// CacheBust makes HTTP/S pings bypass caches by adding a random query
+	// parameter ("query"), no-cache request headers ("headers") or both
+	// ("both"). Empty sends requests as they are.
+	CacheBust string
+	// Meta requests extra metadata (TLS details, HTTP trace) from Ping implementations.
+	Meta bool
+	// Verbose is the level of detail requested from Ping implementations,
+	// from 0 to VerboseRaw.
+	Verbose int
+	// FailoverIPs makes a failed probe retry the next address of the target
+	// within the same timeout, see Failover.
+	FailoverIPs bool
+	// Family restricts probes to the addresses of one IP family, "ip4" or
+	// "ip6"; empty allows both. Ping implementations apply it with Network.
+	Family string
 
 	// Add other relevant options here as needed
 }
 
+// Network returns network, such as "tcp", "udp" or "ip", restricted to the
+// IP family of the option: "tcp4" for "ip4", for instance.
+func (o *Option) Network(network string) string {
+	if o == nil {
+		return network
+	}
+	switch o.Family {
+	case "ip4":
+		return network + "4"
+	case "ip6":
+		return network + "6"
+	}
+	return network
+}
+
+// Verbosity levels of Option.Verbose, each including the ones below it.
+const (
+	VerboseAddresses = 1 // The resolved IPs and the source address
+	VerboseTrace     = 2 // Timing breakdowns and TLS details, as with Meta
+	VerboseRaw       = 3 // Errors as returned and HTTP response headers
+)
+
 // Target represents the destination for a ping operation.
 // Note: The Proxy field is a string here. If the Ping implementation
 // uses this for connection setup, converting it to *url.URL would be more robust
@@ -137,6 +238,18 @@ func (f StringerFunc) String() string {
 	return f()
 }
 
+// JoinAddrs formats resolved addresses as a comma-separated list, for the
+// "resolved" metadata of verbose probes.
+func JoinAddrs[T fmt.Stringer](addrs []T) fmt.Stringer {
+	return StringerFunc(func() string {
+		s := make([]string, len(addrs))
+		for i, addr := range addrs {
+			s[i] = addr.String()
+		}
+		return strings.Join(s, ",")
+	})
+}
+
 // Stats holds the results of a single ping attempt.
 type Stats struct {
 	Connected   bool                    `json:"connected"`   // True if connection was successful
@@ -146,6 +259,15 @@ type Stats struct {
 	Address     string                  `json:"address"`     // The actual address connected to (IP:Port)
 	Meta        map[string]fmt.Stringer `json:"meta"`        // Extra metadata
 	Extra       fmt.Stringer            `json:"extra"`       // Additional output, typically multi-line
+	Phases      []Phase                 `json:"phases"`      // Breakdown of Duration, if the protocol traces one
+	Traffic     Traffic                 `json:"traffic"`     // Packets and bytes of the probe, estimated unless the protocol counts them
+}
+
+// Phase is the time a probe spent in one of its steps, such as the DNS
+// lookup or the TLS handshake.
+type Phase struct {
+	Name     string
+	Duration time.Duration
 }
 
 // FormatMeta formats the metadata map into a space-separated key=value string.
@@ -204,28 +326,81 @@ type Ping interface {
 
 // Pinger manages the pinging process for a single target.
 type Pinger struct {
-	ping Ping     // The specific Ping implementation (TCP, HTTP, etc.)
-	url  *url.URL // The target URL
+	ping     Ping     // The specific Ping implementation (TCP, HTTP, etc.)
+	url      *url.URL // The target URL
+	protocol Protocol // The protocol of url, for estimating traffic
 
 	stopOnce sync.Once     // Ensures the stop channel is closed only once
 	stopC    chan struct{} // Channel to signal stopping the pinger
 
 	out io.Writer // Where to write output (e.g., os.Stdout)
 
-	interval time.Duration // Time between pings
-	counter  int           // Number of pings to send (0 means infinite)
-	timeout  time.Duration // Timeout for each individual ping attempt
+	interval   time.Duration                     // Time between pings
+	pace       func(time.Duration) time.Duration // Adjusts interval before every wait, when set
+	schedule   func(time.Time) time.Time         // When the next ping is due after a time, replacing interval, when set
+	budget     Budget                            // Delays and charges every probe, when set
+	counter    int                               // Number of pings to send (0 means infinite)
+	until      time.Time                         // No pings start after this time, when set
+	untilUp    bool                              // Stop after the first successful ping
+	untilDown  bool                              // Stop after the first failed ping
+	timeout    time.Duration                     // Timeout for each individual ping attempt
+	retries    int                               // Attempts after a failed one before a ping fails
+	retryDelay time.Duration                     // Time between two attempts
+
+	// Quarantine of a target failing continuously, when set
+	quarantineAfter    time.Duration // How long a target fails before quarantine
+	quarantineInterval time.Duration // Time between pings while quarantined
+	quarantined        bool          // Whether the target is quarantined, guarded by statsMu
+
+	// Latency trend alert, when set; guarded by statsMu
+	trendWindow time.Duration // How far back each of the compared windows reaches
+	trendRise   float64       // The rise of the average above which the trend alerts
+	trendSince  time.Time     // When the first probe of the trend started
+	trend       []trendBucket // Probe times of both windows, oldest first
+	trendChange float64       // The change of the average when last judged
+	trendAlert  bool          // Whether the trend alerts
 
 	// Stats tracking
-	minDuration   time.Duration // Minimum duration seen
-	maxDuration   time.Duration // Maximum duration seen
-	totalDuration time.Duration // Sum of all successful durations
-	total         int           // Total number of pings sent
-	failedTotal   int           // Total number of failed pings
+	minDuration   time.Duration   // Minimum duration seen
+	maxDuration   time.Duration   // Maximum duration seen
+	totalDuration time.Duration   // Sum of all successful durations
+	sumSquares    float64         // Sum of the squares of successful durations in seconds
+	durations     []time.Duration // The last successful durations, at most DurationHistory
+	total         int             // Total number of pings sent
+	failedTotal   int             // Total number of failed pings
+	traffic       Traffic         // Packets and bytes of all pings
+
+	// Output
+	sink       Sink               // Receives per-probe records instead of out when set
+	labels     map[string]string  // Labels attached to every record
+	summaryTpl *template.Template // Replaces the default summary format when set
+	histogram  bool               // The default summary shows the trip time distribution
+	rawErrors  bool               // Records show errors as returned
+
+	// Lifecycle hooks, when set
+	onProbe       func(*Stats)  // Called with every counted probe
+	onStateChange func(up bool) // Called when the target goes up or down
+	onFinish      func(Result)  // Called when the run ends
+
+	// State tracking
+	up         bool          // Whether the last probe connected
+	since      time.Time     // When up last changed
+	streak     int           // Consecutive probes with the outcome of the last one
+	failStreak int           // Longest run of consecutive failures
+	recent     []ProbeResult // The most recent results, oldest first
+
+	// Mutex protecting the stats and state fields, which State and Restore
+	// access from other goroutines while the ping loop is running
+	statsMu sync.Mutex
+}
 
-	// Mutex for protecting stats updates if logStats could be called concurrently
-	// (not the case in the current Ping loop, but good practice if it could be)
-	// statsMu sync.Mutex
+// Forecast estimates how long a Pinger takes to send counter probes lasting
+// probe each: it waits interval after every probe but the last.
+func Forecast(counter int, interval, probe time.Duration) time.Duration {
+	if counter <= 0 {
+		return 0
+	}
+	return time.Duration(counter)*probe + time.Duration(counter-1)*interval
 }
 
 // NewPinger creates a new Pinger instance.
@@ -239,19 +414,258 @@ func NewPinger(out io.Writer, url *url.URL, ping Ping, interval time.Duration, c
 		timeout = DefaultTimeout
 	}
 
+	// Unknown schemes get the estimate of an unknown protocol
+	protocol, err := NewProtocol(url.Scheme)
+	if err != nil {
+		protocol = -1
+	}
+
 	return &Pinger{
 		ping:     ping,
 		url:      url,
+		protocol: protocol,
 		stopC:    make(chan struct{}),
 		out:      out,
 		interval: interval,
 		counter:  counter,
 		timeout:  timeout, // Store the individual ping timeout
-		// minDuration is initialized to a large value in Ping() before the loop
+		// minDuration starts large so the first successful probe replaces it
+		minDuration: time.Duration(math.MaxInt64),
+	}
+}
+
+// SetSink sends per-probe records to sink. Once a sink is set the per-probe
+// lines are no longer written to the Pinger's writer, which then only receives
+// the summary; add a text sink to keep them. It must be called before Ping.
+func (p *Pinger) SetSink(sink Sink) {
+	p.sink = sink
+}
+
+// SetLabels attaches labels to every record the Pinger produces. It must be
+// called before Ping.
+func (p *Pinger) SetLabels(labels map[string]string) {
+	p.labels = labels
+}
+
+// SetHistogram makes the default summary also show the distribution of the
+// trip times as a histogram and a sparkline of the recent ones. It must be
+// called before Summarize.
+func (p *Pinger) SetHistogram(show bool) {
+	p.histogram = show
+}
+
+// SetRawErrors makes records show errors as returned by the Ping instead of
+// their simplified form, such as "timeout". It must be called before Ping.
+func (p *Pinger) SetRawErrors(raw bool) {
+	p.rawErrors = raw
+}
+
+// SetPace makes the Pinger wait pace(interval) between probes instead of
+// its interval, such as to probe less often while on battery. pace is
+// called before every wait. It must be called before Ping or Probes.
+func (p *Pinger) SetPace(pace func(interval time.Duration) time.Duration) {
+	p.pace = pace
+}
+
+// SetSchedule makes the Pinger send its probes at the times schedule
+// returns instead of every interval, such as on a cron schedule: the first
+// at schedule(now), and every next one at schedule(now) once the previous
+// returned, or once its quarantine interval elapsed while quarantined. The
+// Pinger stops when schedule returns the zero time. It must be called
+// before Ping or Probes.
+func (p *Pinger) SetSchedule(schedule func(after time.Time) time.Time) {
+	p.schedule = schedule
+}
+
+// SetFlood makes the Pinger send every probe as soon as the previous one
+// returns instead of waiting its interval, limited only by its budget. It
+// must be called before Ping or Probes.
+func (p *Pinger) SetFlood() {
+	p.interval = 0
+}
+
+// SetRetries makes the Pinger retry a failed probe up to retries times,
+// delay after the previous attempt, before counting it as failed, so that
+// transient drops such as a lost SYN do not count as loss. Each attempt has
+// the Pinger's timeout and its traffic adds to the probe's; the stats are
+// those of the last attempt, with the meta retries once retried. It must be
+// called before Ping or Probes.
+func (p *Pinger) SetRetries(retries int, delay time.Duration) {
+	p.retries = retries
+	p.retryDelay = delay
+}
+
+// SetUntil makes the Pinger send no probe starting after until, for runs of
+// a fixed duration rather than a counter. A probe in flight at until
+// completes. It must be called before Ping or Probes.
+func (p *Pinger) SetUntil(until time.Time) {
+	p.until = until
+}
+
+// SetUntilUp makes the Pinger stop after its first successful probe, for
+// waiting until a target comes up. It must be called before Ping or Probes.
+func (p *Pinger) SetUntilUp() {
+	p.untilUp = true
+}
+
+// SetUntilDown makes the Pinger stop after its first failed probe, for
+// failing fast. It must be called before Ping or Probes.
+func (p *Pinger) SetUntilDown() {
+	p.untilDown = true
+}
+
+// settled reports whether the Pinger stops after a probe with stats because
+// of SetUntilUp or SetUntilDown.
+func (p *Pinger) settled(stats *Stats) bool {
+	return p.untilUp && stats.Connected || p.untilDown && !stats.Connected
+}
+
+// next returns the time to wait before the next probe, and false when the
+// next probe would start after the Pinger's until.
+func (p *Pinger) next() (time.Duration, bool) {
+	return p.scheduled(p.wait())
+}
+
+// first returns the time to wait before the first probe, and false when it
+// would start after the Pinger's until.
+func (p *Pinger) first() (time.Duration, bool) {
+	if p.schedule == nil {
+		return 0, true
+	}
+	return p.scheduled(0)
+}
+
+// scheduled returns wait, or with a schedule the time until it is next due
+// once wait elapsed, and false when that is after the Pinger's until or
+// never.
+func (p *Pinger) scheduled(wait time.Duration) (time.Duration, bool) {
+	if p.schedule != nil {
+		now := time.Now()
+		at := p.schedule(now.Add(wait))
+		if at.IsZero() {
+			return 0, false
+		}
+		wait = max(at.Sub(now), 0)
+	}
+	if !p.until.IsZero() && time.Now().Add(wait).After(p.until) {
+		return 0, false
+	}
+	return wait, true
+}
+
+// Budget bounds what probes may cost, such as the bandwidth shared by the
+// Pingers of all targets.
+type Budget interface {
+	// Wait blocks until a probe may be sent or ctx is done.
+	Wait(ctx context.Context) error
+	// Spend charges the cost of a completed probe.
+	Spend(stats *Stats)
+}
+
+// SetBudget makes the Pinger wait for budget before every probe and charge
+// it with the probe once done. It must be called before Ping or Probes.
+func (p *Pinger) SetBudget(budget Budget) {
+	p.budget = budget
+}
+
+// MultiBudget returns a Budget waiting for each of budgets in turn and
+// charging all of them, such as a bandwidth and a probe rate limit.
+func MultiBudget(budgets ...Budget) Budget {
+	return multiBudget(budgets)
+}
+
+type multiBudget []Budget
+
+func (m multiBudget) Wait(ctx context.Context) error {
+	for _, b := range m {
+		if err := b.Wait(ctx); err != nil {
+			return err
+		}
 	}
+	return nil
 }
 
-// Stop signals the Pinger to stop after the current ping attempt finishes.
+func (m multiBudget) Spend(stats *Stats) {
+	for _, b := range m {
+		b.Spend(stats)
+	}
+}
+
+// probe sends one probe within the Pinger's budget. It returns the error of
+// ctx when ctx is done while waiting for the budget.
+func (p *Pinger) probe(ctx context.Context) (*Stats, time.Time, error) {
+	start := time.Now()
+	stats, err := p.attempt(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	for retry := 1; retry <= p.retries && !stats.Connected; retry++ {
+		timer := time.NewTimer(p.retryDelay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return stats, start, nil
+		case <-timer.C:
+		}
+		traffic := stats.Traffic
+		if stats, err = p.attempt(ctx); err != nil {
+			return nil, time.Time{}, err
+		}
+		stats.Traffic.Add(traffic)
+		if stats.Meta == nil {
+			stats.Meta = make(map[string]fmt.Stringer)
+		}
+		n := retry
+		stats.Meta["retries"] = StringerFunc(func() string { return strconv.Itoa(n) })
+	}
+	return stats, start, nil
+}
+
+// runKey is the context key of the context of the Pinger's run, done when
+// it stops, which the context of every probe carries besides its timeout.
+type runKey struct{}
+
+// attempt sends a single attempt of a probe with the Pinger's timeout,
+// within its budget. It returns an error only when ctx is done while
+// waiting for the budget.
+func (p *Pinger) attempt(ctx context.Context) (*Stats, error) {
+	if p.budget != nil {
+		if err := p.budget.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+	pingCtx, pingCancel := context.WithTimeout(ctx, p.timeout)
+	pingCtx = context.WithValue(pingCtx, runKey{}, ctx)
+	stats := p.ping.Ping(pingCtx)
+	pingCancel()
+	if stats.Traffic == (Traffic{}) {
+		stats.Traffic = EstimateTraffic(p.protocol, stats)
+	}
+	if p.budget != nil {
+		p.budget.Spend(stats)
+	}
+	return stats, nil
+}
+
+// wait returns the time to wait before the next probe.
+func (p *Pinger) wait() time.Duration {
+	if wait, ok := p.quarantineWait(); ok {
+		return wait
+	}
+	if p.schedule != nil {
+		// The schedule alone spaces the probes out
+		return 0
+	}
+	if p.pace != nil {
+		return p.pace(p.interval)
+	}
+	return p.interval
+}
+
+// Stop stops the Pinger at once: the probe in flight is cancelled and not
+// counted, and so is the wait for the next one.
+//
+// Library code can cancel the context given to Run instead.
 func (p *Pinger) Stop() {
 	p.stopOnce.Do(func() {
 		close(p.stopC)
@@ -259,16 +673,39 @@ func (p *Pinger) Stop() {
 }
 
 // Done returns a channel that is closed when the Pinger has stopped.
+//
+// Library code can rely on Run returning when the Pinger has stopped, and
+// on OnFinish being called then.
 func (p *Pinger) Done() <-chan struct{} {
 	return p.stopC
 }
 
 // Ping starts the pinging process. It runs until the counter is reached,
 // an error occurs, or Stop() is called.
+//
+// Library code can use Run, which can be cancelled with a context and
+// returns the Result instead of leaving it to Summarize.
 func (p *Pinger) Ping() {
+	p.run(context.Background())
+}
+
+// Run probes as Ping does, writing every probe to the writer or sink, until
+// the counter is reached, Stop is called or ctx is done, and returns the
+// Result of the run. The error is that of ctx when it ended the run, which
+// stops the Pinger.
+func (p *Pinger) Run(ctx context.Context) (Result, error) {
+	err := p.run(ctx)
+	return p.Snapshot(), err
+}
+
+// run runs the ping loop of Ping and Run within parent, returning its error
+// when it ended the run.
+func (p *Pinger) run(parent context.Context) error {
+	defer p.finish()
+
 	// Use errgroup.WithContext for structured concurrency and cancellation propagation
 	// The context returned by WithContext is cancelled if any goroutine returns a non-nil error.
-	group, ctx := errgroup.WithContext(context.Background())
+	group, ctx := errgroup.WithContext(parent)
 
 	// Goroutine to listen for the stop signal and cancel the context
 	group.Go(func() error {
@@ -282,15 +719,18 @@ func (p *Pinger) Ping() {
 		}
 	})
 
-	// Initialize minDuration before the loop starts
-	p.minDuration = time.Duration(math.MaxInt64)
-
 	// Start the main ping loop goroutine
 	group.Go(func() error {
 		// Trigger the first ping immediately or after a short initial delay
 		// The original code used NewTimer(1), which gives an immediate/very short delay.
-		// Let's match that by creating a timer that fires immediately.
-		timer := time.NewTimer(time.Duration(0)) // Fire immediately
+		// Let's match that by creating a timer that fires immediately, unless
+		// the first ping waits for its schedule.
+		first, more := p.first()
+		if !more {
+			p.Stop()
+			return nil
+		}
+		timer := time.NewTimer(first)
 		defer timer.Stop()
 
 		for {
@@ -298,22 +738,42 @@ func (p *Pinger) Ping() {
 			case <-timer.C:
 				// Time to send a ping
 
-				// Create a context with the configured timeout for this specific ping
-				pingCtx, pingCancel := context.WithTimeout(ctx, p.timeout)
-				stats := p.ping.Ping(pingCtx) // Perform the ping
-				pingCancel()                  // Release resources associated with the timeout context
+				// Perform the ping, with the configured timeout and within the budget
+				stats, start, err := p.probe(ctx)
+				if err != nil {
+					// Context cancelled while waiting for the budget, exit
+					return err
+				}
+				if ctx.Err() != nil {
+					// Stopped while the probe was in flight, which is not
+					// its result, as with Probes
+					return ctx.Err()
+				}
 
 				// Log and update statistics for the completed ping
-				p.logStats(stats)
+				p.logStats(stats, start)
 
 				// Check if we've reached the desired number of pings
-				p.total++
 				if p.counter > 0 && p.total >= p.counter {
 					// Reached counter limit, stop the pinger gracefully
 					p.Stop()   // Signal stop to the other goroutine
 					return nil // Exit this goroutine
 				}
 
+				// Check if the target came up when waiting for it, or went
+				// down when failing fast
+				if p.settled(stats) {
+					p.Stop()
+					return nil
+				}
+
+				// Check if the next ping would start after the end of the run
+				wait, more := p.next()
+				if !more {
+					p.Stop()
+					return nil
+				}
+
 				// Reset the timer for the next interval, but only if the loop continues
 				select {
 				case <-ctx.Done():
@@ -321,7 +781,7 @@ func (p *Pinger) Ping() {
 					return ctx.Err()
 				default:
 					// Context is still active, reset timer for the next ping
-					timer.Reset(p.interval)
+					timer.Reset(wait)
 				}
 
 			case <-ctx.Done():
@@ -335,13 +795,82 @@ func (p *Pinger) Ping() {
 	// Wait for all goroutines in the group to finish.
 	// g.Wait() returns the error from the first goroutine that failed,
 	// or context.Canceled if the context was cancelled.
-	if err := group.Wait(); err != nil && !errors.Is(err, context.Canceled) {
+	err := group.Wait()
+	if parent.Err() != nil {
+		// Cancelled from outside, which stops the Pinger as Stop does
+		p.Stop()
+		return parent.Err()
+	}
+	if err != nil && !errors.Is(err, context.Canceled) {
 		// Log the error if it's not just a cancellation
 		p.logError(err)
 	}
+	return nil
+}
 
-	// Defer p.Stop() is handled by the function defer, ensuring it's called
-	// even if Ping() exits early due to an error or return.
+// Probes returns an iterator over the results of the Pinger's probes, for
+// embedding it in other programs:
+//
+//	for stats := range p.Probes(ctx) {
+//		...
+//	}
+//
+// Probes are sent at the Pinger's interval, each with its timeout, until the
+// counter is reached, ctx is done, Stop is called or the loop is left. The
+// results are counted towards the statistics, so Summarize and State work as
+// with Ping, but are not written to the Pinger's writer or sink.
+func (p *Pinger) Probes(ctx context.Context) iter.Seq[*Stats] {
+	return func(yield func(*Stats) bool) {
+		defer p.finish()
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		go func() {
+			select {
+			case <-p.Done():
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+
+		wait, more := p.first()
+		for ; more; wait, more = p.next() {
+			if wait > 0 {
+				timer := time.NewTimer(wait)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return
+				}
+			}
+
+			stats, start, err := p.probe(ctx)
+			if err != nil || ctx.Err() != nil {
+				return
+			}
+			p.count(stats, start)
+			if !yield(stats) {
+				return
+			}
+
+			p.statsMu.Lock()
+			total := p.total
+			p.statsMu.Unlock()
+			if p.counter > 0 && total >= p.counter || p.settled(stats) {
+				return
+			}
+		}
+	}
+}
+
+// Add counts the result of a probe started at start that was sent
+// elsewhere, such as one of a recorded session, as if the Pinger had sent
+// it, and returns its record without writing it.
+func (p *Pinger) Add(stats *Stats, start time.Time) *Record {
+	if stats.Traffic == (Traffic{}) {
+		stats.Traffic = EstimateTraffic(p.protocol, stats)
+	}
+	return p.count(stats, start)
 }
 
 // logError writes a formatted error message to the output writer.
@@ -352,75 +881,155 @@ func (p *Pinger) logError(err error) {
 	}
 }
 
-// Summarize prints the ping statistics summary to the output writer.
-func (p *Pinger) Summarize() {
-	// Use a text template for formatting the summary
-	const summaryTpl = `
+// DurationHistory is the number of recent successful probe durations a
+// Pinger keeps for the distribution in its summary.
+const DurationHistory = 4096
+
+// summaryText is the default summary format, and distributionText the trip
+// time distribution SetHistogram adds to it.
+const (
+	summaryText = `
 Ping statistics {{.URL}}
     {{.Total}} probes sent.
-    {{.SuccessTotal}} successful, {{.FailedTotal}} failed.
-Approximate trip times:{{if .Total}}
-    Minimum = {{.MinDuration}}, Maximum = {{.MaxDuration}}, Average = {{.AvgDuration}}{{else}}
-    No probes completed successfully.{{end}}` // Add conditional for no probes
-
-	t := template.Must(template.New("summary").Parse(summaryTpl))
-
-	// Create a data structure for template execution, including calculated values
-	summaryData := struct {
-		URL          *url.URL
-		Total        int
-		SuccessTotal int
-		FailedTotal  int
-		MinDuration  time.Duration
-		MaxDuration  time.Duration
-		AvgDuration  time.Duration
-	}{
+    {{.SuccessTotal}} successful, {{.FailedTotal}} failed.{{if .Total}} Loss = {{percent .Loss}} (95% CI {{percent .LossLow}}-{{percent .LossHigh}})
+    Current streak = {{.Streak}} {{if .Up}}successful{{else}}failed{{end}}, longest failure streak = {{.FailStreak}}
+    Estimated traffic: {{.Traffic}}{{end}}
+Approximate trip times:{{if .SuccessTotal}}
+    Minimum = {{.MinDuration}}, Maximum = {{.MaxDuration}}, Average = {{.AvgDuration}}{{if .HasAvgCI}} (95% CI {{.AvgLow}}-{{.AvgHigh}}){{end}}{{else}}
+    No probes completed successfully.{{end}}`
+	distributionText = `{{if gt (len .Durations) 1}}
+Trip time distribution:
+{{histogram .Durations}}
+    Recent: {{sparkline .Durations}}{{end}}`
+)
+
+var (
+	// summaryTemplate is the default summary format
+	summaryTemplate = template.Must(template.New("summary").Funcs(TemplateFuncs()).Parse(summaryText + "\n"))
+	// histogramSummaryTemplate is the default summary format with the trip
+	// time distribution
+	histogramSummaryTemplate = template.Must(template.New("summary").Funcs(TemplateFuncs()).Parse(summaryText + distributionText + "\n"))
+)
+
+// SetSummaryTemplate replaces the format of Summarize with tpl, which is
+// executed with a Summary. It must be called before Summarize.
+func (p *Pinger) SetSummaryTemplate(tpl *template.Template) {
+	p.summaryTpl = tpl
+}
+
+// Summary returns the statistics of the probes so far. It is safe to call
+// while the Pinger is running.
+func (p *Pinger) Summary() Summary {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+
+	summary := Summary{
 		URL:          p.url,
 		Total:        p.total,
 		SuccessTotal: p.total - p.failedTotal,
 		FailedTotal:  p.failedTotal,
 		MinDuration:  p.minDuration,
 		MaxDuration:  p.maxDuration,
-		AvgDuration:  0, // Initialize to 0, calculate below
+		Up:           p.up,
+		Streak:       p.streak,
+		FailStreak:   p.failStreak,
+		Durations:    slices.Clone(p.durations),
+		Labels:       p.labels,
+		Traffic:      p.traffic,
 	}
 
-	// Calculate average only if total is greater than 0 to avoid division by zero
+	// The average and its interval are over the successful probes, the
+	// only ones with a duration in totalDuration
+	if summary.SuccessTotal > 0 {
+		summary.AvgDuration = p.totalDuration / time.Duration(summary.SuccessTotal)
+		lo, hi, ok := stats.MeanCI(summary.SuccessTotal, p.totalDuration.Seconds(), p.sumSquares)
+		summary.HasAvgCI = ok
+		summary.AvgLow = roundCI(stats.Seconds(max(lo, 0)))
+		summary.AvgHigh = roundCI(stats.Seconds(hi))
+	}
 	if p.total > 0 {
-		summaryData.AvgDuration = p.totalDuration / time.Duration(p.total)
-	} else {
+		summary.Loss = float64(p.failedTotal) / float64(p.total)
+		summary.LossLow, summary.LossHigh = stats.WilsonCI(p.failedTotal, p.total)
+	}
+	if summary.SuccessTotal <= 0 {
 		// Set min/max to 0 or a placeholder if no pings completed
-		summaryData.MinDuration = 0
-		summaryData.MaxDuration = 0
+		summary.MinDuration = 0
+		summary.MaxDuration = 0
+	}
+	return summary
+}
+
+// Snapshot returns the Result of the probes so far. It is safe to call
+// while the Pinger is running, for instance from a status endpoint.
+func (p *Pinger) Snapshot() Result {
+	port, _ := strconv.Atoi(p.url.Port())
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	result := Result{
+		Counter:        p.total,
+		SuccessCounter: p.total - p.failedTotal,
+		Target: &Target{
+			Protocol: p.protocol,
+			Host:     p.url.Hostname(),
+			Port:     port,
+			Counter:  p.counter,
+			Interval: p.interval,
+			Timeout:  p.timeout,
+		},
+		TotalDuration: p.totalDuration,
+	}
+	if result.SuccessCounter > 0 {
+		result.MinDuration = p.minDuration
+		result.MaxDuration = p.maxDuration
+	}
+	return result
+}
+
+// Summarize prints the ping statistics summary to the output writer.
+//
+// Library code wanting the numbers rather than the text reads Summary, which
+// holds everything the summary renders, such as the confidence interval of
+// the loss, streaks and traffic; the Result of Run holds only the counts and
+// durations.
+func (p *Pinger) Summarize() {
+	if p.out == nil {
+		return
+	}
+	t := summaryTemplate
+	if p.summaryTpl != nil {
+		t = p.summaryTpl
+	} else if p.histogram {
+		t = histogramSummaryTemplate
 	}
 
 	// Use a bytes.Buffer to capture the template output before writing
 	var buf bytes.Buffer
 	// Execute the template, writing to the buffer
-	if err := t.Execute(&buf, summaryData); err != nil {
+	if err := t.Execute(&buf, p.Summary()); err != nil {
 		// Handle template execution error - perhaps log it or write an error message
 		fmt.Fprintf(p.out, "Error formatting summary: %v\n", err)
 		return // Stop if template execution failed
 	}
 
 	// Write the buffer content to the output writer
-	if p.out != nil {
-		_, err := buf.WriteTo(p.out)
-		if err != nil {
-			// Handle write error - log or ignore depending on context
-			// For typical stdout, ignoring is often acceptable, but let's log
-			// for robustness in case out is something else.
-			fmt.Fprintf(os.Stderr, "Error writing summary output: %v\n", err)
-		}
+	if _, err := buf.WriteTo(p.out); err != nil {
+		// Handle write error - log or ignore depending on context
+		// For typical stdout, ignoring is often acceptable, but let's log
+		// for robustness in case out is something else.
+		fmt.Fprintf(os.Stderr, "Error writing summary output: %v\n", err)
 	}
 }
 
 // formatError provides a user-friendly string representation of an error.
-func (p *Pinger) formatError(err error) string {
+func formatError(err error) string {
 	if err == nil {
 		return "" // No error
 	}
 
 	// Use errors.Is for checking specific error types/values
+	if errors.Is(err, ErrDNSTimeout) {
+		return "dns timeout"
+	}
 	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
 		return "timeout"
 	}
@@ -429,7 +1038,7 @@ func (p *Pinger) formatError(err error) string {
 	var urlErr *url.Error
 	if errors.As(err, &urlErr) {
 		// Recurse into the underlying error if it's a URL error
-		return p.formatError(urlErr.Err)
+		return formatError(urlErr.Err)
 	}
 
 	var netErr net.Error
@@ -454,11 +1063,40 @@ func (p *Pinger) formatError(err error) string {
 	return err.Error()
 }
 
-// logStats logs the results of a single ping attempt and updates the statistics.
-func (p *Pinger) logStats(stats *Stats) {
-	// Use mutex if stats updates could be concurrent (not currently needed but good practice)
-	// p.statsMu.Lock()
-	// defer p.statsMu.Unlock()
+// roundCI rounds a confidence interval bound for display; its precision is
+// never better than a microsecond.
+func roundCI(d time.Duration) time.Duration {
+	return d.Round(time.Microsecond)
+}
+
+// logStats logs the results of a single ping attempt started at start and
+// updates the statistics.
+func (p *Pinger) logStats(stats *Stats, start time.Time) {
+	record := p.count(stats, start)
+
+	// Sinks take over per-probe output entirely when configured
+	if p.sink != nil {
+		if err := p.sink.Write(record); err != nil {
+			p.logError(err)
+		}
+		return
+	}
+
+	// Write the whole line at once so concurrent pingers sharing a writer
+	// never interleave partial lines
+	if p.out != nil {
+		_, _ = io.WriteString(p.out, record.String())
+	}
+}
+
+// count updates the statistics and state with a probe started at start and
+// returns its record.
+func (p *Pinger) count(stats *Stats, start time.Time) *Record {
+	p.statsMu.Lock()
+	p.total++
+	p.traffic.Add(stats.Traffic)
+	changed := p.recordState(stats)
+	note := p.updateQuarantine(stats) + p.updateTrend(stats, start)
 
 	// Update statistics only if the ping was successful in connecting,
 	// but count failed attempts regardless.
@@ -471,6 +1109,11 @@ func (p *Pinger) logStats(stats *Stats) {
 			p.maxDuration = stats.Duration
 		}
 		p.totalDuration += stats.Duration
+		p.sumSquares += stats.Duration.Seconds() * stats.Duration.Seconds()
+		if len(p.durations) >= DurationHistory {
+			p.durations = slices.Delete(p.durations, 0, len(p.durations)-DurationHistory+1)
+		}
+		p.durations = append(p.durations, stats.Duration)
 	}
 
 	// Count failures, but ignore context cancellation errors as explicit failures
@@ -478,64 +1121,24 @@ func (p *Pinger) logStats(stats *Stats) {
 		p.failedTotal++
 	}
 
-	// Format the main output line using a single fmt.Fprintf
-	status := "Failed"
-	errorDetail := ""
-	if stats.Connected {
-		status = "connected"
-	}
-	if stats.Error != nil {
-		errorDetail = fmt.Sprintf("(%s)", p.formatError(stats.Error))
-	}
-
-	// Build the basic format string dynamically based on error presence
-	// Example: "Ping %s(%s) %s%s - time=%s dns=%s"
-	// URL, Address, Status, ErrorDetail, Duration, DNSDuration
-
-	// Check for nil values before calling String() or accessing fields
-	urlStr := "<nil>"
-	if p.url != nil {
-		urlStr = p.url.String()
+	seq := p.total
+	labels := p.labels
+	p.statsMu.Unlock()
+	p.note(note)
+	if p.onProbe != nil {
+		p.onProbe(stats)
 	}
-	addrStr := "<unknown>"
-	if stats != nil { // Ensure stats is not nil
-		addrStr = stats.Address
+	if changed && p.onStateChange != nil {
+		p.onStateChange(stats.Connected)
 	}
-	durationStr := "<N/A>"
-	if stats != nil {
-		durationStr = stats.Duration.String()
-	}
-	dnsDurationStr := "<N/A>"
-	if stats != nil {
-		dnsDurationStr = stats.DNSDuration.String()
-	}
-
-	// Using Fprintf directly for efficiency and control over output writer
-	if p.out != nil {
-		_, _ = fmt.Fprintf(p.out, "Ping %s(%s) %s%s - time=%s dns=%s",
-			urlStr,
-			addrStr,
-			status,
-			errorDetail,
-			durationStr,
-			dnsDurationStr,
-		)
-
-		// Append metadata if present
-		if stats != nil && len(stats.Meta) > 0 {
-			_, _ = fmt.Fprintf(p.out, " %s", stats.FormatMeta())
-		}
-
-		// Append a newline
-		_, _ = fmt.Fprint(p.out, "\n")
 
-		// Append extra info if present
-		if stats != nil && stats.Extra != nil {
-			extraStr := strings.TrimSpace(stats.Extra.String())
-			if extraStr != "" {
-				_, _ = fmt.Fprintf(p.out, " %s\n", extraStr)
-			}
-		}
+	return &Record{
+		Target:    p.url.String(),
+		Seq:       seq,
+		Timestamp: start,
+		Labels:    labels,
+		Stats:     stats,
+		RawError:  p.rawErrors,
 	}
 }
 
@@ -568,6 +1171,14 @@ func (result Result) Failed() int {
 	return result.Counter - result.SuccessCounter
 }
 
+// Loss returns the fraction of failed pings, 0 before the first one.
+func (result Result) Loss() float64 {
+	if result.Counter == 0 {
+		return 0
+	}
+	return float64(result.Failed()) / float64(result.Counter)
+}
+
 // String returns a formatted summary string for the Result.
 func (result Result) String() string {
 	// Use a text template for formatting the summary
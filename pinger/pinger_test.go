@@ -1 +1,820 @@
-package pinger
\ No newline at end of file
+package pinger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sequencePing returns the given outcomes in order, repeating the last one.
+type sequencePing struct {
+	results []bool
+	calls   int
+}
+
+func (s *sequencePing) Ping(ctx context.Context) *Stats {
+	i := s.calls
+	if i >= len(s.results) {
+		i = len(s.results) - 1
+	}
+	s.calls++
+	if s.results[i] {
+		return &Stats{Connected: true, Duration: time.Millisecond}
+	}
+	return &Stats{Error: errors.New("refused")}
+}
+
+func newTestPinger(results ...bool) *Pinger {
+	u, _ := url.Parse("tcp://example.com:80")
+	return NewPinger(io.Discard, u, &sequencePing{results: results}, time.Millisecond, len(results), time.Second)
+}
+
+func TestState(t *testing.T) {
+	p := newTestPinger(true, false, true, true)
+	p.Ping()
+
+	state := p.State()
+	if state.Total != 4 || state.Failed != 1 || !state.Up {
+		t.Fatalf("unexpected state %+v", state)
+	}
+	if len(state.Recent) != 4 || state.Recent[1].Connected {
+		t.Fatalf("unexpected recent results %+v", state.Recent)
+	}
+
+	restored := newTestPinger(true)
+	restored.Restore(state)
+	if got := restored.State(); got.Total != 4 || got.Since != state.Since {
+		t.Fatalf("restore lost state: %+v", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	p := newTestPinger(true, false, true, true)
+	var buf bytes.Buffer
+	p.out = &buf
+	p.Ping()
+	p.Summarize()
+
+	// Averages are over successful probes only, with intervals for both
+	for _, want := range []string{
+		"Loss = 25.0% (95% CI 4.6%-69.9%)",
+		"Average = 1ms (95% CI 1ms-1ms)",
+		"Current streak = 2 successful, longest failure streak = 1",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("summary lacks %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "Trip time distribution") {
+		t.Fatalf("default summary shows the distribution:\n%s", buf.String())
+	}
+
+	// The distribution of equal times collapses to a single bucket
+	buf.Reset()
+	p.SetHistogram(true)
+	p.Summarize()
+	for _, want := range []string{
+		"Trip time distribution:\n           1ms - 1ms        | ############################## 3\n",
+		"Recent: ▁▁▁",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Fatalf("summary lacks %q:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestRenderHistogram(t *testing.T) {
+	ms := time.Millisecond
+	tests := []struct {
+		name      string
+		durations []time.Duration
+		buckets   int
+	}{
+		{"equal", []time.Duration{272 * time.Microsecond, 272 * time.Microsecond}, 1},
+		{"below resolution", []time.Duration{272 * time.Microsecond, 272*time.Microsecond + 900*time.Nanosecond}, 1},
+		{"just below 8 steps", []time.Duration{0, 7 * time.Microsecond}, 1},
+		{"8 steps", []time.Duration{0, 8 * time.Microsecond}, HistogramBuckets},
+		{"spread", []time.Duration{10 * ms, 12 * ms, 50 * ms}, HistogramBuckets},
+	}
+	for _, tt := range tests {
+		lines := strings.Split(renderHistogram(tt.durations), "\n")
+		if len(lines) != tt.buckets {
+			t.Errorf("%s: %d buckets, want %d:\n%s", tt.name, len(lines), tt.buckets, strings.Join(lines, "\n"))
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, line := range lines {
+			bounds, _, _ := strings.Cut(line, "|")
+			if seen[bounds] {
+				t.Errorf("%s: repeated bucket %q", tt.name, strings.TrimSpace(bounds))
+			}
+			seen[bounds] = true
+		}
+	}
+}
+
+func TestSummary_JSON(t *testing.T) {
+	p := newTestPinger(true, false, true, true)
+	p.Ping()
+	data, err := json.Marshal(p.Summary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v struct {
+		Target        string             `json:"target"`
+		Failed        int                `json:"failed"`
+		Loss          float64            `json:"loss"`
+		AvgMS         float64            `json:"avg_ms"`
+		PercentilesMS map[string]float64 `json:"percentiles_ms"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Target != "tcp://example.com:80" || v.Failed != 1 || v.Loss != 0.25 || v.AvgMS != 1 || v.PercentilesMS["p99"] != 1 {
+		t.Fatalf("unexpected summary %s", data)
+	}
+}
+
+func TestProbes(t *testing.T) {
+	p := newTestPinger(true, false, true)
+
+	var got []bool
+	for stats := range p.Probes(context.Background()) {
+		got = append(got, stats.Connected)
+	}
+	if len(got) != 3 || !got[0] || got[1] || !got[2] {
+		t.Fatalf("unexpected probes %v", got)
+	}
+	if state := p.State(); state.Total != 3 || state.Failed != 1 {
+		t.Fatalf("probes not counted: %+v", state)
+	}
+
+	// Leaving the loop early stops probing
+	p = newTestPinger(true, true, true)
+	for range p.Probes(context.Background()) {
+		break
+	}
+	if state := p.State(); state.Total != 1 {
+		t.Fatalf("probed after break: %+v", state)
+	}
+}
+
+func TestRunner(t *testing.T) {
+	r := NewRunner(newTestPinger(true, false, true))
+	results, err := r.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []bool
+	for stats := range results {
+		got = append(got, stats.Connected)
+	}
+	if len(got) != 3 || !got[0] || got[1] || !got[2] {
+		t.Fatalf("unexpected probes %v", got)
+	}
+	if state := r.Pinger().State(); state.Total != 3 || state.Failed != 1 {
+		t.Fatalf("probes not counted: %+v", state)
+	}
+	if _, err := r.Run(context.Background()); !errors.Is(err, ErrStarted) {
+		t.Fatalf("expected a second run to fail, got %v", err)
+	}
+
+	// Cancelling the context closes the channel without receiving
+	p := newTestPinger(true, true, true)
+	p.SetFlood()
+	ctx, cancel := context.WithCancel(context.Background())
+	results, _ = NewRunner(p).Run(ctx)
+	<-results
+	cancel()
+	for range results {
+	}
+
+	p = newTestPinger(true)
+	p.Stop()
+	if _, err := NewRunner(p).Run(context.Background()); !errors.Is(err, ErrStopped) {
+		t.Fatalf("expected a stopped pinger to fail, got %v", err)
+	}
+}
+
+func TestHooks(t *testing.T) {
+	p := newTestPinger(true, true, false, true)
+	var probes int
+	var changes []bool
+	var result Result
+	p.OnProbe(func(*Stats) { probes++ })
+	p.OnStateChange(func(up bool) { changes = append(changes, up) })
+	p.OnFinish(func(r Result) { result = r })
+	p.Ping()
+
+	if probes != 4 {
+		t.Fatalf("OnProbe called %d times", probes)
+	}
+	if fmt.Sprint(changes) != "[true false true]" {
+		t.Fatalf("unexpected state changes %v", changes)
+	}
+	if result.Counter != 4 || result.SuccessCounter != 3 || result.Target.Host != "example.com" || result.Target.Port != 80 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestRun(t *testing.T) {
+	result, err := newTestPinger(true, false, true).Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Counter != 3 || result.SuccessCounter != 2 || result.Failed() != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// A context ending the run is its error, and stops the Pinger
+	u, _ := url.Parse("tcp://example.com:80")
+	p := NewPinger(io.Discard, u, &sequencePing{results: []bool{true}}, time.Millisecond, 0, time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	result, err = p.Run(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline, got %v", err)
+	}
+	if result.Counter == 0 {
+		t.Fatal("expected the probes before the deadline in the result")
+	}
+	select {
+	case <-p.Done():
+	default:
+		t.Fatal("the Pinger did not stop")
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	p := newTestPinger(true, false, true, true, false, true, true, true)
+	if snapshot := p.Snapshot(); snapshot.Counter != 0 || snapshot.Loss() != 0 {
+		t.Fatalf("unexpected snapshot before the run %+v", snapshot)
+	}
+
+	// Read while the loop runs, which the race detector checks
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			select {
+			case <-p.Done():
+				return
+			default:
+				p.Snapshot()
+			}
+		}
+	}()
+	p.Ping()
+	<-done
+
+	snapshot := p.Snapshot()
+	if snapshot.Counter != 8 || snapshot.Failed() != 2 || snapshot.Loss() != 0.25 || snapshot.MinDuration > snapshot.Avg() {
+		t.Fatalf("unexpected snapshot %+v", snapshot)
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	factory := func(*url.URL, *Option) (Ping, error) { return &sequencePing{}, nil }
+	a, b := NewRegistry(), NewRegistry()
+	a.RegisterSpec(TCP, Spec{Factory: factory, Port: 80})
+	b.Register(UDP, factory)
+
+	if _, ok := a.Load(UDP); ok {
+		t.Fatal("a registration leaked into another registry")
+	}
+	if spec, ok := a.LoadSpec(TCP); !ok || spec.Port != 80 {
+		t.Fatalf("unexpected spec %+v", spec)
+	}
+	// Register keeps the rest of the Spec
+	a.Register(TCP, factory)
+	if spec, _ := a.LoadSpec(TCP); spec.Port != 80 {
+		t.Fatalf("Register dropped the port, %+v", spec)
+	}
+	if got := b.Protocols(); len(got) != 1 || got[0] != UDP {
+		t.Fatalf("unexpected protocols %v", got)
+	}
+}
+
+func TestRegisterNamed(t *testing.T) {
+	factory := func(*url.URL, *Option) (Ping, error) { return &sequencePing{}, nil }
+	r := NewRegistry()
+	redis, err := r.RegisterNamed("Redis", Spec{Factory: factory, Port: 6379})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redis.String() != "redis" {
+		t.Fatalf("named %q", redis)
+	}
+	if p, err := NewProtocol("REDIS"); err != nil || p != redis {
+		t.Fatalf("NewProtocol returned %v, %v", p, err)
+	}
+	if known := KnownProtocols(); !slices.Contains(known, redis) {
+		t.Fatalf("%v missing from the known protocols %v", redis, known)
+	}
+	if _, ok := r.Load(redis); !ok {
+		t.Fatal("the spec was not registered")
+	}
+
+	// A name keeps its protocol across registries, and built-in names theirs
+	if again, _ := NewRegistry().RegisterNamed("redis", Spec{}); again != redis {
+		t.Fatalf("registered again as %v", again)
+	}
+	if tcp, _ := NewRegistry().RegisterNamed("tcp", Spec{}); tcp != TCP {
+		t.Fatalf("tcp registered as %v", tcp)
+	}
+	for _, name := range []string{"", "1redis", "re dis", "unknown"} {
+		if _, err := r.RegisterNamed(name, Spec{}); err == nil {
+			t.Errorf("expected %q to be refused", name)
+		}
+	}
+}
+
+func TestSetPace(t *testing.T) {
+	p := newTestPinger(true, true, true)
+	var waits []time.Duration
+	p.SetPace(func(interval time.Duration) time.Duration {
+		waits = append(waits, interval)
+		return 2 * interval
+	})
+	start := time.Now()
+	p.Ping()
+	if len(waits) != 2 || waits[0] != time.Millisecond || time.Since(start) < 4*time.Millisecond {
+		t.Fatalf("expected two paced waits of 2ms, got %v in %s", waits, time.Since(start))
+	}
+}
+
+func TestSetQuarantine(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	var out bytes.Buffer
+	p := NewPinger(&out, u, &sequencePing{results: []bool{false}}, time.Millisecond, 0, time.Second)
+	p.SetQuarantine(10*time.Millisecond, time.Hour)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.Ping()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	state := p.State()
+	p.Stop()
+	<-done
+	if !state.Quarantined || state.Total > 50 {
+		t.Fatalf("expected the failing target quarantined after a few probes, got %+v", state)
+	}
+	if !strings.Contains(out.String(), "quarantined=true") || !strings.Contains(out.String(), "quarantined after failing for 10ms") {
+		t.Fatalf("quarantine not flagged in output:\n%s", out.String())
+	}
+}
+
+func TestSetTrend(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	var out bytes.Buffer
+	p := NewPinger(&out, u, &sequencePing{}, time.Minute, 0, time.Second)
+	p.SetTrend(time.Hour, 0.3)
+
+	// Two steady hours at 20ms, then latency creeps up to 28ms
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var last *Record
+	probe := func(minutes int, d time.Duration) {
+		for i := 0; i < minutes; i++ {
+			last = p.count(&Stats{Connected: true, Duration: d}, start)
+			start = start.Add(time.Minute)
+		}
+	}
+	probe(120, 20*time.Millisecond)
+	if strings.Contains(out.String(), "latency") || last.Stats.Meta["latency_trend"] != nil {
+		t.Fatalf("a steady latency alerted:\n%s", out.String())
+	}
+	probe(60, 28*time.Millisecond)
+	if !strings.Contains(out.String(), "tcp://example.com:80: latency rising, average") {
+		t.Fatalf("expected a rising trend noted, got:\n%s", out.String())
+	}
+	if meta := last.Stats.Meta["latency_trend"]; meta == nil || meta.String() != "+40%" {
+		t.Fatalf("expected the latency_trend meta +40%% on records, got %v", last.Stats.Meta)
+	}
+	probe(60, 28*time.Millisecond)
+	if !strings.Contains(out.String(), "latency trend back within 30% of the baseline") || last.Stats.Meta["latency_trend"] != nil {
+		t.Fatalf("expected the trend to clear once latency settles, got:\n%s", out.String())
+	}
+}
+
+func TestAdd(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	p := NewPinger(io.Discard, u, nil, 0, 0, 0)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	p.Add(&Stats{Connected: true, Duration: 10 * time.Millisecond}, start)
+	record := p.Add(&Stats{Error: errors.New("refused")}, start.Add(time.Second))
+	if record.Seq != 2 || !record.Timestamp.Equal(start.Add(time.Second)) {
+		t.Fatalf("unexpected record %+v", record)
+	}
+	if s := p.Summary(); s.Total != 2 || s.FailedTotal != 1 || s.AvgDuration != 10*time.Millisecond || s.Traffic.PacketsSent == 0 {
+		t.Fatalf("expected both results counted with estimated traffic, got %+v", s)
+	}
+}
+
+func TestSetFlood(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	p := NewPinger(io.Discard, u, &sequencePing{results: []bool{true, true, true}}, time.Hour, 3, time.Second)
+	p.SetFlood()
+	start := time.Now()
+	p.Ping()
+	if state := p.State(); state.Total != 3 || time.Since(start) > time.Second {
+		t.Fatalf("expected 3 probes without waiting, got %+v in %s", state, time.Since(start))
+	}
+}
+
+func TestSetUntil(t *testing.T) {
+	p := newTestPinger(true, true, true)
+	p.SetUntil(time.Now())
+	p.Ping()
+	if state := p.State(); state.Total != 1 {
+		t.Fatalf("expected one probe before until, got %+v", state)
+	}
+}
+
+func TestSetUntilUp(t *testing.T) {
+	p := newTestPinger(false, false, true, true)
+	p.SetUntilUp()
+	p.Ping()
+	if state := p.State(); state.Total != 3 || !state.Up {
+		t.Fatalf("expected to stop after the first successful probe, got %+v", state)
+	}
+}
+
+func TestSetUntilDown(t *testing.T) {
+	p := newTestPinger(true, true, false, true)
+	p.SetUntilDown()
+	p.Ping()
+	if state := p.State(); state.Total != 3 || state.Failed != 1 {
+		t.Fatalf("expected to stop after the first failed probe, got %+v", state)
+	}
+}
+
+func TestSetRetries(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	p := NewPinger(io.Discard, u, &sequencePing{results: []bool{false, false, false, true}}, time.Millisecond, 2, time.Second)
+	p.SetRetries(1, time.Millisecond)
+	var retries []string
+	for stats := range p.Probes(context.Background()) {
+		retries = append(retries, stats.Meta["retries"].String())
+	}
+	if state := p.State(); state.Total != 2 || state.Failed != 1 {
+		t.Fatalf("expected a failed and a retried successful probe, got %+v", state)
+	}
+	if !slices.Equal(retries, []string{"1", "1"}) {
+		t.Fatalf("expected the meta retries on both probes, got %v", retries)
+	}
+}
+
+func TestSetSchedule(t *testing.T) {
+	// Due 20ms after every probe, and never after the third
+	start := time.Now()
+	var due []time.Time
+	schedule := func(after time.Time) time.Time {
+		if len(due) == 3 {
+			return time.Time{}
+		}
+		due = append(due, after.Add(20*time.Millisecond))
+		return due[len(due)-1]
+	}
+	for _, run := range []func(p *Pinger){
+		func(p *Pinger) { p.Ping() },
+		func(p *Pinger) {
+			for range p.Probes(context.Background()) {
+			}
+		},
+	} {
+		p := newTestPinger(true, true, true, true, true)
+		p.SetSchedule(schedule)
+		run(p)
+		if state := p.State(); state.Total != 3 {
+			t.Fatalf("expected to stop when nothing is due after three probes, got %+v", state)
+		}
+		if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+			t.Fatalf("expected every probe to wait for its schedule, took %s", elapsed)
+		}
+		start, due = time.Now(), nil
+	}
+}
+
+// countingBudget counts the waits and spends of a Pinger.
+type countingBudget struct{ waits, spends int }
+
+func (b *countingBudget) Wait(ctx context.Context) error { b.waits++; return nil }
+func (b *countingBudget) Spend(stats *Stats)             { b.spends++ }
+
+func TestSetBudget(t *testing.T) {
+	p := newTestPinger(true, true, true)
+	budget := &countingBudget{}
+	p.SetBudget(budget)
+	p.Ping()
+	if budget.waits != 3 || budget.spends != 3 {
+		t.Fatalf("expected 3 waits and spends, got %d and %d", budget.waits, budget.spends)
+	}
+}
+
+func TestMultiBudget(t *testing.T) {
+	p := newTestPinger(true, true)
+	first, second := &countingBudget{}, &countingBudget{}
+	p.SetBudget(MultiBudget(first, second))
+	p.Ping()
+	if first.waits != 2 || second.spends != 2 {
+		t.Fatalf("expected both budgets to see 2 probes, got %+v and %+v", first, second)
+	}
+}
+
+func TestEstimateTraffic(t *testing.T) {
+	tcp := EstimateTraffic(TCP, &Stats{Connected: true})
+	if tcp.PacketsSent != 4 || tcp.PacketsReceived != 2 || tcp.BytesSent != 216 || tcp.BytesReceived != 112 {
+		t.Fatalf("unexpected tcp traffic %+v", tcp)
+	}
+	if lost := EstimateTraffic(TCP, &Stats{}); lost.PacketsSent != 1 || lost.PacketsReceived != 0 {
+		t.Fatalf("unexpected traffic of a failed tcp probe %+v", lost)
+	}
+	body := &Stats{Connected: true, Meta: map[string]fmt.Stringer{"bytes": StringerFunc(func() string { return "3000" })}}
+	if got := EstimateTraffic(HTTP, body).BytesReceived - EstimateTraffic(HTTP, &Stats{Connected: true}).BytesReceived; got != 3000+2*52 {
+		t.Fatalf("a 3000 byte body added %d bytes in two more segments", got)
+	}
+	if got := (Traffic{BytesSent: 1536, BytesReceived: 100}).String(); got != "0 packets sent (1.5 KiB), 0 received (100 B)" {
+		t.Fatalf("unexpected traffic string %q", got)
+	}
+
+	p := newTestPinger(true, true, true)
+	p.Ping()
+	if got := p.Summary().Traffic; got.PacketsSent == 0 || got != p.State().Traffic {
+		t.Fatalf("unexpected traffic %+v in summary and %+v in state", got, p.State().Traffic)
+	}
+}
+
+func TestEstimateTraffic_Refused(t *testing.T) {
+	// A dial to a port nobody listens on is refused with a RST
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+	conn, err := net.Dial("tcp", addr)
+	if err == nil {
+		conn.Close()
+		t.Skipf("%s accepted a connection after its listener closed", addr)
+	}
+	refused := &Stats{Error: fmt.Errorf("dial failed: %w", err)}
+
+	tests := []struct {
+		protocol Protocol
+		want     Traffic
+	}{
+		{TCP, Traffic{PacketsSent: 1, BytesSent: syn, PacketsReceived: 1, BytesReceived: rst}},
+		{HTTPS, Traffic{PacketsSent: 1, BytesSent: syn, PacketsReceived: 1, BytesReceived: rst}},
+		{SMB, Traffic{PacketsSent: 1, BytesSent: syn, PacketsReceived: 1, BytesReceived: rst}},
+		{UDP, Traffic{PacketsSent: 1, BytesSent: udpHeader + udpPayload, PacketsReceived: 1, BytesReceived: unreach}},
+	}
+	for _, tt := range tests {
+		if got := EstimateTraffic(tt.protocol, refused); got != tt.want {
+			t.Errorf("%s: refused probe traffic = %+v, want %+v", tt.protocol, got, tt.want)
+		}
+	}
+
+	// Without an answer, as on a timeout, nothing is received
+	timeout := &Stats{Error: context.DeadlineExceeded}
+	if got := EstimateTraffic(TCP, timeout); got.PacketsReceived != 0 || !strings.Contains(got.String(), "0 received") {
+		t.Errorf("unexpected traffic of a timed out tcp probe %+v", got)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+
+	// The first address hangs, the second refuses, the third answers
+	var budgets []time.Duration
+	i, err := Failover(ctx, []string{"a", "b", "c"}, func(ctx context.Context, addr string) error {
+		deadline, _ := ctx.Deadline()
+		budgets = append(budgets, time.Until(deadline))
+		switch addr {
+		case "a":
+			<-ctx.Done()
+			return ctx.Err()
+		case "b":
+			return errors.New("refused")
+		}
+		return nil
+	})
+	if err != nil || i != 2 {
+		t.Fatalf("Failover = %d, %v, want 2, nil", i, err)
+	}
+	if budgets[0] > 110*time.Millisecond {
+		t.Fatalf("first of three addresses got %s of a 300ms budget", budgets[0])
+	}
+	if FailoverMeta(i, 3).String() != "3/3" {
+		t.Fatalf("unexpected failover meta %s", FailoverMeta(i, 3))
+	}
+
+	_, err = Failover(context.Background(), []string{"a", "b"}, func(ctx context.Context, addr string) error {
+		return errors.New("refused")
+	})
+	if err == nil || !strings.Contains(err.Error(), "all 2 addresses failed") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestFallback(t *testing.T) {
+	tls := &sequencePing{results: []bool{false}}
+	tcp := &sequencePing{results: []bool{true}}
+	icmp := &sequencePing{results: []bool{true}}
+	p := Fallback("https", &sequencePing{results: []bool{false, false, false, true, false}}, []Layer{
+		{Name: "tls", Ping: tls},
+		{Name: "tcp", Ping: tcp},
+		{Name: "icmp", Ping: icmp},
+	}, 3)
+
+	// Only the third failure in a row probes the layers, stopping at tcp
+	for i := 0; i < 2; i++ {
+		if stats := p.Ping(context.Background()); stats.Meta["broken"] != nil {
+			t.Fatalf("probed layers after %d failures", i+1)
+		}
+	}
+	stats := p.Ping(context.Background())
+	if stats.Meta["broken"].String() != "tls" || stats.Meta["layers"].String() != "tls:failed,tcp:ok" {
+		t.Fatalf("unexpected fallback meta %s", stats.FormatMeta())
+	}
+	if icmp.calls != 0 {
+		t.Fatal("probed icmp below a working tcp")
+	}
+
+	// A success resets the count
+	p.Ping(context.Background())
+	if stats := p.Ping(context.Background()); stats.Meta["broken"] != nil {
+		t.Fatal("probed layers after the count was reset")
+	}
+}
+
+// hangPing blocks every probe until its context is done.
+type hangPing struct{}
+
+func (hangPing) Ping(ctx context.Context) *Stats {
+	<-ctx.Done()
+	return &Stats{Error: ctx.Err()}
+}
+
+func TestStop(t *testing.T) {
+	u, _ := url.Parse("tcp://example.com:80")
+	for name, ping := range map[string]Ping{
+		"probe":  hangPing{},
+		"layers": Fallback("tcp", &sequencePing{results: []bool{false}}, []Layer{{Name: "icmp", Ping: hangPing{}}}, 1),
+	} {
+		p := NewPinger(io.Discard, u, ping, time.Hour, 0, time.Hour)
+		done := make(chan struct{})
+		go func() {
+			p.Ping()
+			close(done)
+		}()
+		time.Sleep(20 * time.Millisecond)
+		p.Stop()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: Stop did not interrupt the probe in flight", name)
+		}
+	}
+}
+
+func TestDualStack(t *testing.T) {
+	p := DualStack(&sequencePing{results: []bool{true}}, &sequencePing{results: []bool{false}})
+	var got []string
+	for i := 0; i < 4; i++ {
+		stats := p.Ping(context.Background())
+		got = append(got, fmt.Sprintf("%s:%v", stats.Meta["family"], stats.Connected))
+	}
+	if strings.Join(got, " ") != "ipv4:true ipv6:false ipv4:true ipv6:false" {
+		t.Fatalf("unexpected probes %v", got)
+	}
+	if (&Option{Family: "ip6"}).Network("tcp") != "tcp6" || (*Option)(nil).Network("udp") != "udp" {
+		t.Fatal("unexpected networks")
+	}
+}
+
+func TestAllIPs(t *testing.T) {
+	p := AllIPs([]Ping{&sequencePing{results: []bool{true}}, &sequencePing{results: []bool{false}}, &sequencePing{results: []bool{true}}},
+		[]string{"192.0.2.1", "192.0.2.2", "2001:db8::1"})
+	var got []string
+	for i := 0; i < 4; i++ {
+		stats := p.Ping(context.Background())
+		got = append(got, fmt.Sprintf("%s:%v", stats.Meta["ip"], stats.Connected))
+	}
+	if strings.Join(got, " ") != "192.0.2.1:true 192.0.2.2:false 2001:db8::1:true 192.0.2.1:true" {
+		t.Fatalf("unexpected probes %v", got)
+	}
+	// A pinned address stands in for every lookup
+	ips, err := (&Option{Pin: NewPinTo(net.ParseIP("192.0.2.2"))}).LookupIP(context.Background(), nil, "example.invalid")
+	if err != nil || len(ips) != 1 || ips[0].String() != "192.0.2.2" {
+		t.Fatalf("got %v, %v", ips, err)
+	}
+}
+
+func TestOption_LookupIP(t *testing.T) {
+	// A resolver whose server never answers
+	hang := &net.Resolver{PreferGo: true, Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}}
+	op := &Option{DNSTimeout: 20 * time.Millisecond}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	_, err := op.LookupIP(ctx, hang, "example.invalid")
+	if !errors.Is(err, ErrDNSTimeout) {
+		t.Fatalf("expected a dns timeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("lookup took %s, beyond its DNS timeout", elapsed)
+	}
+	if got := formatError(err); got != "dns timeout" {
+		t.Fatalf("formatted as %q", got)
+	}
+
+	// The probe's own timeout running out first is not a DNS timeout
+	short, cancelShort := context.WithTimeout(context.Background(), 5*time.Millisecond)
+	defer cancelShort()
+	if _, err := op.LookupIP(short, hang, "example.invalid"); err == nil || errors.Is(err, ErrDNSTimeout) {
+		t.Fatalf("expected the probe's timeout, got %v", err)
+	}
+
+	// Addresses need no lookup
+	conn, err := op.Dial(ctx, &net.Dialer{Resolver: hang}, "udp", "127.0.0.1:9")
+	if err != nil {
+		t.Fatalf("dial an address: %v", err)
+	}
+	conn.Close()
+}
+
+func TestPin(t *testing.T) {
+	pin := NewPin()
+	answers := [][]net.IP{nil, {net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, {net.ParseIP("192.0.2.3")}}
+	lookups := 0
+	lookup := func() ([]net.IP, error) {
+		ips := answers[lookups]
+		lookups++
+		if ips == nil {
+			return nil, errors.New("no such host")
+		}
+		return ips, nil
+	}
+
+	// A failed lookup pins nothing
+	if _, err := pin.lookup("ip", "example.com", lookup); err == nil {
+		t.Fatal("expected the lookup error")
+	}
+	for range 2 {
+		ips, err := pin.lookup("ip", "example.com", lookup)
+		if err != nil || len(ips) != 1 || !ips[0].Equal(net.ParseIP("192.0.2.1")) {
+			t.Fatalf("got %v, %v, want the first address pinned", ips, err)
+		}
+	}
+	if lookups != 2 {
+		t.Fatalf("%d lookups, want none once pinned", lookups)
+	}
+	// Other families pin an address of their own
+	if ips, _ := pin.lookup("ip6", "example.com", lookup); !ips[0].Equal(net.ParseIP("192.0.2.3")) {
+		t.Fatalf("got %v for another family", ips)
+	}
+}
+
+func TestDialParallel(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	// A family that fails races the other one at once
+	dialer := &net.Dialer{FallbackDelay: time.Minute}
+	start := time.Now()
+	conn, err := dialParallel(ctx, dialer, "tcp", []net.IP{net.ParseIP("::1"), net.ParseIP("127.0.0.1")}, port)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if conn.RemoteAddr().String() != ln.Addr().String() || time.Since(start) > 10*time.Second {
+		t.Fatalf("expected the fallback to connect at once, got %s after %s", conn.RemoteAddr(), time.Since(start))
+	}
+
+	// Every address failing returns the error of the first family
+	ln.Close()
+	if _, err := dialParallel(ctx, dialer, "tcp", []net.IP{net.ParseIP("127.0.0.1"), net.ParseIP("::1")}, port); err == nil || !strings.Contains(err.Error(), "127.0.0.1") {
+		t.Fatalf("expected the error of the first family, got %v", err)
+	}
+}
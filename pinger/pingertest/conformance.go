@@ -0,0 +1,154 @@
+package pingertest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Promptly is how soon after its context is done a Ping must return.
+const Promptly = time.Second
+
+// Target creates the Pings a conformance run probes. Each function returns a
+// new Ping with the given per-probe timeout in its pinger.Option.
+type Target struct {
+	// Up returns a Ping of a target that answers, which must connect.
+	Up func(t *testing.T, timeout time.Duration) pinger.Ping
+	// Down returns a Ping of a target that refuses, which must fail fast.
+	Down func(t *testing.T, timeout time.Duration) pinger.Ping
+	// Hang returns a Ping of a target that never answers, which must time
+	// out and be cancellable. It may be nil for protocols where a target
+	// cannot hang, such as plain TCP connects.
+	Hang func(t *testing.T, timeout time.Duration) pinger.Ping
+}
+
+// Run runs the conformance suite against the Pings of target as subtests:
+//
+//   - a successful probe is connected, without error, and reports its
+//     duration and address;
+//   - a failed probe is not connected and reports an error;
+//   - a probe times out at its Option timeout, or earlier at the context's
+//     deadline, with an error classified as a timeout;
+//   - cancelling the context ends a probe promptly, even without a deadline;
+//   - a Ping is safe for concurrent use.
+func Run(t *testing.T, target Target) {
+	t.Run("Connected", func(t *testing.T) {
+		stats := target.Up(t, 5*time.Second).Ping(context.Background())
+		if !stats.Connected || stats.Error != nil {
+			t.Fatalf("probe of a live target failed: connected=%v error=%v", stats.Connected, stats.Error)
+		}
+		if stats.Duration <= 0 {
+			t.Errorf("Duration = %s, want > 0", stats.Duration)
+		}
+		if stats.Address == "" {
+			t.Error("Address is empty")
+		}
+	})
+
+	t.Run("Failed", func(t *testing.T) {
+		start := time.Now()
+		stats := target.Down(t, 5*time.Second).Ping(context.Background())
+		if stats.Connected {
+			t.Fatal("probe of a refusing target connected")
+		}
+		if stats.Error == nil {
+			t.Error("failed probe reports no error")
+		}
+		if elapsed := time.Since(start); elapsed > Promptly {
+			t.Errorf("refused probe took %s", elapsed)
+		}
+	})
+
+	t.Run("ConcurrentUse", func(t *testing.T) {
+		ping := target.Up(t, 5*time.Second)
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if stats := ping.Ping(context.Background()); !stats.Connected {
+					t.Errorf("concurrent probe failed: %v", stats.Error)
+				}
+			}()
+		}
+		wg.Wait()
+	})
+
+	if target.Hang == nil {
+		return
+	}
+
+	t.Run("OptionTimeout", func(t *testing.T) {
+		expectTimeout(t, target.Hang(t, 100*time.Millisecond), context.Background())
+	})
+
+	t.Run("ContextDeadline", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		expectTimeout(t, target.Hang(t, time.Minute), ctx)
+	})
+
+	t.Run("Cancel", func(t *testing.T) {
+		// No deadline, as when a Pinger is stopped, so only cancellation
+		// can end the probe
+		ctx, cancel := context.WithCancel(context.Background())
+		time.AfterFunc(100*time.Millisecond, cancel)
+		expectPrompt(t, target.Hang(t, time.Minute), ctx)
+	})
+
+	t.Run("Cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		expectPrompt(t, target.Up(t, time.Minute), ctx)
+	})
+}
+
+// expectTimeout checks that a probe ends with a timeout error within
+// Promptly of 100ms.
+func expectTimeout(t *testing.T, ping pinger.Ping, ctx context.Context) {
+	t.Helper()
+	start := time.Now()
+	stats := ping.Ping(ctx)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond+Promptly {
+		t.Fatalf("probe timed out after %s", elapsed)
+	}
+	if stats.Connected {
+		t.Fatal("probe of a hanging target connected")
+	}
+	if !IsTimeout(stats.Error) {
+		t.Errorf("error %v is not classified as a timeout", stats.Error)
+	}
+}
+
+// expectPrompt checks that a probe whose context is or will soon be
+// cancelled fails within Promptly.
+func expectPrompt(t *testing.T, ping pinger.Ping, ctx context.Context) {
+	t.Helper()
+	start := time.Now()
+	stats := ping.Ping(ctx)
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond+Promptly {
+		t.Fatalf("probe returned %s after it was cancelled", elapsed)
+	}
+	if stats.Connected {
+		t.Error("cancelled probe reports connected")
+	}
+	if stats.Error == nil {
+		t.Error("cancelled probe reports no error")
+	}
+}
+
+// IsTimeout reports whether err is a timeout as the pinger reports it: a
+// context or I/O deadline, or a net.Error timing out.
+func IsTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, os.ErrDeadlineExceeded) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
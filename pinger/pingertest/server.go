@@ -0,0 +1,203 @@
+// Package pingertest provides local test servers and a conformance suite for
+// pinger.Ping implementations.
+package pingertest
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+// Server is a local test server listening on the loopback interface.
+type Server struct {
+	Host string // always 127.0.0.1
+	Port int
+	URL  string // the base URL of HTTP and TLS servers
+
+	// TLSConfig trusts the certificate of a TLS server
+	TLSConfig *tls.Config
+
+	close func()
+}
+
+// Addr returns the host:port of the server.
+func (s *Server) Addr() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+// Close stops the server. Servers are also closed when the test ends.
+func (s *Server) Close() {
+	s.close()
+}
+
+// NewTCPEchoServer starts a TCP server echoing what it receives.
+func NewTCPEchoServer(t testing.TB) *Server {
+	return newTCPServer(t, func(conn net.Conn) {
+		io.Copy(conn, conn)
+	})
+}
+
+// NewTCPBlackhole starts a TCP server that accepts connections and never
+// answers, for probes that must time out or be cancelled.
+func NewTCPBlackhole(t testing.TB) *Server {
+	return newTCPServer(t, func(conn net.Conn) {
+		io.Copy(io.Discard, conn)
+	})
+}
+
+// NewUDPEchoServer starts a UDP server echoing every datagram.
+func NewUDPEchoServer(t testing.TB) *Server {
+	return newUDPServer(t, true)
+}
+
+// NewUDPBlackhole starts a UDP server that never answers.
+func NewUDPBlackhole(t testing.TB) *Server {
+	return newUDPServer(t, false)
+}
+
+// NewHTTPServer starts an HTTP server answering every request with handler,
+// or with an empty 200 response when handler is nil.
+func NewHTTPServer(t testing.TB, handler http.Handler) *Server {
+	return newHTTPServer(t, httptest.NewServer(orOK(handler)))
+}
+
+// NewTLSServer starts an HTTPS server with a self-signed certificate, which
+// also serves for raw TLS handshakes. Its TLSConfig trusts the certificate.
+func NewTLSServer(t testing.TB, handler http.Handler) *Server {
+	srv := httptest.NewTLSServer(orOK(handler))
+	s := newHTTPServer(t, srv)
+	s.TLSConfig = srv.Client().Transport.(*http.Transport).TLSClientConfig.Clone()
+	s.TLSConfig.ServerName = "example.com"
+	return s
+}
+
+// ClosedPort returns a loopback port nothing listens on, so that TCP
+// connections are refused and UDP datagrams draw a port unreachable.
+func ClosedPort(t testing.TB) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pingertest: listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	return port
+}
+
+// newTCPServer starts a TCP server handling every connection with handle.
+func newTCPServer(t testing.TB, handle func(net.Conn)) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pingertest: listen: %v", err)
+	}
+
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		conns = map[net.Conn]struct{}{}
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns[conn] = struct{}{}
+			mu.Unlock()
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+
+	var once sync.Once
+	s := &Server{
+		Host: "127.0.0.1",
+		Port: ln.Addr().(*net.TCPAddr).Port,
+		close: func() {
+			once.Do(func() {
+				ln.Close()
+				mu.Lock()
+				for conn := range conns {
+					conn.Close()
+				}
+				mu.Unlock()
+				wg.Wait()
+			})
+		},
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newUDPServer starts a UDP server, echoing datagrams if echo is set.
+func newUDPServer(t testing.TB, echo bool) *Server {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("pingertest: listen: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buf := make([]byte, 64<<10)
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			if err == nil && echo {
+				conn.WriteTo(buf[:n], addr)
+			}
+		}
+	}()
+
+	var once sync.Once
+	s := &Server{
+		Host: "127.0.0.1",
+		Port: conn.LocalAddr().(*net.UDPAddr).Port,
+		close: func() {
+			once.Do(func() {
+				conn.Close()
+				<-done
+			})
+		},
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// newHTTPServer wraps a started httptest server.
+func newHTTPServer(t testing.TB, srv *httptest.Server) *Server {
+	addr := srv.Listener.Addr().(*net.TCPAddr)
+	s := &Server{
+		Host:  "127.0.0.1",
+		Port:  addr.Port,
+		URL:   srv.URL,
+		close: srv.Close,
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+// orOK returns handler, or one answering 200 when it is nil.
+func orOK(handler http.Handler) http.Handler {
+	if handler != nil {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+}
@@ -0,0 +1,67 @@
+package pinger
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// SetQuarantine makes the Pinger probe only every interval once it has
+// failed continuously for after, so that a dead target neither uses up
+// resources nor floods sinks while its recovery is still noticed. Records of
+// a quarantined target carry the meta quarantined=true, and entering and
+// leaving quarantine is noted on the output. It must be called before Ping
+// or Probes.
+func (p *Pinger) SetQuarantine(after, interval time.Duration) {
+	p.quarantineAfter = after
+	p.quarantineInterval = interval
+}
+
+// inQuarantine reports whether the Pinger has failed for longer than its
+// quarantine allows at now. The caller must hold statsMu.
+func (p *Pinger) inQuarantine(now time.Time) bool {
+	return p.quarantineAfter > 0 && p.total > 0 && !p.up && now.Sub(p.since) >= p.quarantineAfter
+}
+
+// quarantineWait returns the quarantine interval while the Pinger is
+// quarantined, and false otherwise.
+func (p *Pinger) quarantineWait() (time.Duration, bool) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+	if !p.quarantined {
+		return 0, false
+	}
+	return p.quarantineInterval, true
+}
+
+// updateQuarantine enters or leaves quarantine after a probe, flagging its
+// stats, and returns the note of a change or "". The caller must hold
+// statsMu and has already recorded the probe's state.
+func (p *Pinger) updateQuarantine(stats *Stats) string {
+	if p.quarantineAfter <= 0 {
+		return ""
+	}
+	was := p.quarantined
+	p.quarantined = p.inQuarantine(time.Now())
+	if p.quarantined {
+		if stats.Meta == nil {
+			stats.Meta = make(map[string]fmt.Stringer)
+		}
+		stats.Meta["quarantined"] = StringerFunc(func() string { return "true" })
+	}
+	switch {
+	case p.quarantined && !was:
+		return fmt.Sprintf("%s: quarantined after failing for %s, probing every %s until it recovers\n",
+			p.url, p.quarantineAfter, p.quarantineInterval)
+	case was && !p.quarantined:
+		return fmt.Sprintf("%s: recovered, leaving quarantine\n", p.url)
+	}
+	return ""
+}
+
+// note writes a note about the Pinger to its output.
+func (p *Pinger) note(text string) {
+	if text != "" && p.out != nil {
+		_, _ = io.WriteString(p.out, text)
+	}
+}
@@ -0,0 +1,173 @@
+package pinger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Sink receives the record of every probe. Implementations must be safe for
+// concurrent use when shared between Pingers, and must not modify records.
+type Sink interface {
+	// Write delivers a single probe record.
+	Write(record *Record) error
+	// Close flushes buffered records and releases resources.
+	Close() error
+}
+
+// Record is a single probe result as delivered to sinks.
+type Record struct {
+	Target    string            // The target URL
+	Seq       int               // Sequence number of the probe, starting at 1
+	Timestamp time.Time         // When the probe started
+	Labels    map[string]string // Labels of the target, if any
+	Stats     *Stats            // The probe result
+	RawError  bool              // Show the error as returned, not simplified
+}
+
+// errorText returns the error of the probe as it is shown, or "".
+func (r *Record) errorText() string {
+	switch {
+	case r.Stats.Error == nil:
+		return ""
+	case r.RawError:
+		return r.Stats.Error.Error()
+	default:
+		return formatError(r.Stats.Error)
+	}
+}
+
+// String formats the record as the human-readable output line, followed by the
+// extra output of the probe on its own line when present.
+func (r *Record) String() string {
+	stats := r.Stats
+
+	status := "Failed"
+	errorDetail := ""
+	if stats.Connected {
+		status = "connected"
+	}
+	if stats.Error != nil {
+		errorDetail = fmt.Sprintf("(%s)", r.errorText())
+	}
+
+	// Example: "Ping %s(%s) %s%s - time=%s dns=%s"
+	// URL, Address, Status, ErrorDetail, Duration, DNSDuration
+	var line bytes.Buffer
+	fmt.Fprintf(&line, "Ping %s(%s) %s%s - time=%s dns=%s",
+		r.Target,
+		stats.Address,
+		status,
+		errorDetail,
+		stats.Duration,
+		stats.DNSDuration,
+	)
+
+	// Append metadata if present
+	if len(stats.Meta) > 0 {
+		fmt.Fprintf(&line, " %s", stats.FormatMeta())
+	}
+	line.WriteByte('\n')
+
+	// Append extra info if present
+	if stats.Extra != nil {
+		if extra := strings.TrimSpace(stats.Extra.String()); extra != "" {
+			fmt.Fprintf(&line, " %s\n", extra)
+		}
+	}
+	return line.String()
+}
+
+// recordJSON is the stable JSON representation of a Record. Durations are
+// expressed in milliseconds.
+type recordJSON struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	Target     string            `json:"target"`
+	Seq        int               `json:"seq"`
+	Connected  bool              `json:"connected"`
+	Address    string            `json:"address,omitempty"`
+	DurationMS float64           `json:"duration_ms"`
+	DNSMS      float64           `json:"dns_ms"`
+	Error      string            `json:"error,omitempty"`
+	Meta       map[string]string `json:"meta,omitempty"`
+	Extra      string            `json:"extra,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (r *Record) MarshalJSON() ([]byte, error) {
+	stats := r.Stats
+	v := recordJSON{
+		Timestamp:  r.Timestamp,
+		Target:     r.Target,
+		Seq:        r.Seq,
+		Connected:  stats.Connected,
+		Address:    stats.Address,
+		DurationMS: milliseconds(stats.Duration),
+		DNSMS:      milliseconds(stats.DNSDuration),
+		Labels:     r.Labels,
+	}
+	v.Error = r.errorText()
+	if len(stats.Meta) > 0 {
+		v.Meta = make(map[string]string, len(stats.Meta))
+		for key, value := range stats.Meta {
+			if value != nil {
+				v.Meta[key] = value.String()
+			}
+		}
+	}
+	if stats.Extra != nil {
+		v.Extra = strings.TrimSpace(stats.Extra.String())
+	}
+	return json.Marshal(v)
+}
+
+// UnmarshalJSON implements json.Unmarshaler. Errors and metadata are restored
+// as their string forms.
+func (r *Record) UnmarshalJSON(data []byte) error {
+	var v recordJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	stats := &Stats{
+		Connected:   v.Connected,
+		Address:     v.Address,
+		Duration:    fromMilliseconds(v.DurationMS),
+		DNSDuration: fromMilliseconds(v.DNSMS),
+	}
+	if v.Error != "" {
+		stats.Error = errors.New(v.Error)
+	}
+	if len(v.Meta) > 0 {
+		stats.Meta = make(map[string]fmt.Stringer, len(v.Meta))
+		for key, value := range v.Meta {
+			value := value
+			stats.Meta[key] = StringerFunc(func() string { return value })
+		}
+	}
+	if v.Extra != "" {
+		extra := v.Extra
+		stats.Extra = StringerFunc(func() string { return extra })
+	}
+	*r = Record{
+		Target:    v.Target,
+		Seq:       v.Seq,
+		Timestamp: v.Timestamp,
+		Labels:    v.Labels,
+		Stats:     stats,
+	}
+	return nil
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// fromMilliseconds converts fractional milliseconds to a duration.
+func fromMilliseconds(ms float64) time.Duration {
+	return time.Duration(ms * float64(time.Millisecond))
+}
@@ -0,0 +1,191 @@
+package pinger
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds the Spec of each registered Protocol. Applications create
+// their own to keep sets of differently configured protocols apart, such as
+// two libraries embedding the package; the package-level Register,
+// RegisterSpec, Load, LoadSpec and Protocols use DefaultRegistry, which the
+// command line fills. A Registry is safe for concurrent use.
+type Registry struct {
+	mu    sync.RWMutex
+	specs map[Protocol]Spec
+}
+
+// DefaultRegistry is the Registry of the package-level functions.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry creates an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{specs: make(map[Protocol]Spec)}
+}
+
+// Register registers a Factory function for a given Protocol, keeping the
+// rest of its Spec if it has one.
+func (r *Registry) Register(protocol Protocol, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	spec := r.specs[protocol]
+	spec.Factory = factory
+	r.specs[protocol] = spec
+}
+
+// RegisterSpec registers the Spec of a Protocol, replacing any previous
+// registration.
+func (r *Registry) RegisterSpec(protocol Protocol, spec Spec) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[protocol] = spec
+}
+
+// RegisterNamed registers the Spec of the protocol with the URL scheme
+// name, such as "redis", as RegisterSpec does, and returns the Protocol.
+// The first registration of a name in any Registry allocates it a Protocol
+// after the built-in ones, which NewProtocol and String then know, so that
+// targets with the scheme are parsed like those of the built-in protocols;
+// a built-in name returns the built-in Protocol.
+func (r *Registry) RegisterNamed(name string, spec Spec) (Protocol, error) {
+	protocol, err := nameProtocol(name)
+	if err != nil {
+		return 0, err
+	}
+	r.RegisterSpec(protocol, spec)
+	return protocol, nil
+}
+
+// Load retrieves the Factory function for a given Protocol.
+// Returns the Factory and a boolean indicating if it was found.
+func (r *Registry) Load(protocol Protocol) (Factory, bool) {
+	spec, ok := r.LoadSpec(protocol)
+	return spec.Factory, ok && spec.Factory != nil
+}
+
+// LoadSpec retrieves the Spec of a given Protocol.
+func (r *Registry) LoadSpec(protocol Protocol) (Spec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	spec, ok := r.specs[protocol]
+	return spec, ok
+}
+
+// Protocols returns the registered protocols in ascending order.
+func (r *Registry) Protocols() []Protocol {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	protocols := make([]Protocol, 0, len(r.specs))
+	for protocol := range r.specs {
+		protocols = append(protocols, protocol)
+	}
+	sort.Slice(protocols, func(i, j int) bool { return protocols[i] < protocols[j] })
+	return protocols
+}
+
+// Register registers a Factory function for a given Protocol in
+// DefaultRegistry, keeping the rest of its Spec if it has one.
+// It's typically called during package initialization (init functions).
+func Register(protocol Protocol, factory Factory) {
+	DefaultRegistry.Register(protocol, factory)
+}
+
+// RegisterSpec registers the Spec of a Protocol in DefaultRegistry,
+// replacing any previous registration.
+func RegisterSpec(protocol Protocol, spec Spec) {
+	DefaultRegistry.RegisterSpec(protocol, spec)
+}
+
+// RegisterNamed registers the Spec of the protocol with the URL scheme name
+// in DefaultRegistry, allocating a Protocol to a new name. Packages outside
+// this module add protocols this way, from an init function so that the
+// command line picks them up: their targets, help and subcommand.
+func RegisterNamed(name string, spec Spec) (Protocol, error) {
+	return DefaultRegistry.RegisterNamed(name, spec)
+}
+
+// Load retrieves the Factory function for a given Protocol from
+// DefaultRegistry.
+func Load(protocol Protocol) (Factory, bool) {
+	return DefaultRegistry.Load(protocol)
+}
+
+// LoadSpec retrieves the Spec of a given Protocol from DefaultRegistry.
+func LoadSpec(protocol Protocol) (Spec, bool) {
+	return DefaultRegistry.LoadSpec(protocol)
+}
+
+// Protocols returns the protocols registered in DefaultRegistry in
+// ascending order.
+func Protocols() []Protocol {
+	return DefaultRegistry.Protocols()
+}
+
+// names holds the names of the protocols RegisterNamed allocated, the one of
+// firstNamed+i at index i. Protocols are shared by all registries, so the
+// names are too.
+var names struct {
+	sync.RWMutex
+	list []string
+}
+
+// nameProtocol returns the Protocol named name, allocating one to a new
+// name.
+func nameProtocol(name string) (Protocol, error) {
+	if !validScheme(name) || strings.EqualFold(name, "unknown") {
+		return 0, fmt.Errorf("invalid protocol name %q, want a URL scheme such as \"redis\"", name)
+	}
+	name = strings.ToLower(name)
+	if protocol, err := NewProtocol(name); err == nil {
+		return protocol, nil
+	}
+	names.Lock()
+	defer names.Unlock()
+	// Allocated by a concurrent registration in the meantime
+	for i, n := range names.list {
+		if n == name {
+			return firstNamed + Protocol(i), nil
+		}
+	}
+	names.list = append(names.list, name)
+	return firstNamed + Protocol(len(names.list)-1), nil
+}
+
+// namedProtocol returns the name RegisterNamed allocated protocol to.
+func namedProtocol(protocol Protocol) (string, bool) {
+	names.RLock()
+	defer names.RUnlock()
+	i := int(protocol - firstNamed)
+	if i < 0 || i >= len(names.list) {
+		return "", false
+	}
+	return names.list[i], true
+}
+
+// protocolNamed returns the Protocol RegisterNamed allocated to name.
+func protocolNamed(name string) (Protocol, bool) {
+	names.RLock()
+	defer names.RUnlock()
+	for i, n := range names.list {
+		if n == name {
+			return firstNamed + Protocol(i), true
+		}
+	}
+	return 0, false
+}
+
+// validScheme reports whether name is a URL scheme: a letter followed by
+// letters, digits, "+", "-" and ".".
+func validScheme(name string) bool {
+	for i, c := range name {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z':
+		case i > 0 && ('0' <= c && c <= '9' || c == '+' || c == '-' || c == '.'):
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
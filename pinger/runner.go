@@ -0,0 +1,60 @@
+package pinger
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// ErrStarted is returned by Runner.Run when the Runner already ran.
+var ErrStarted = errors.New("runner already started")
+
+// ErrStopped is returned by Runner.Run when its Pinger was stopped before.
+var ErrStopped = errors.New("pinger stopped")
+
+// Runner runs a Pinger in the background and delivers the results of its
+// probes on a channel, for Go programs embedding circle-pinger that consume
+// them from another goroutine or select on them along with other events.
+type Runner struct {
+	pinger  *Pinger
+	started atomic.Bool
+}
+
+// NewRunner creates a Runner for p, which must not be run otherwise.
+func NewRunner(p *Pinger) *Runner {
+	return &Runner{pinger: p}
+}
+
+// Pinger returns the Pinger of the Runner, for its Summary or State.
+func (r *Runner) Pinger() *Pinger {
+	return r.pinger
+}
+
+// Run starts probing as Pinger.Probes does and returns the channel the
+// results are sent on, unbuffered, in the order of the probes. The channel
+// is closed once the counter is reached, ctx is done or the Pinger is
+// stopped; the caller must receive until then or cancel ctx. A Runner runs
+// only once.
+func (r *Runner) Run(ctx context.Context) (<-chan *Stats, error) {
+	if !r.started.CompareAndSwap(false, true) {
+		return nil, ErrStarted
+	}
+	select {
+	case <-r.pinger.Done():
+		return nil, ErrStopped
+	default:
+	}
+
+	results := make(chan *Stats)
+	go func() {
+		defer close(results)
+		for stats := range r.pinger.Probes(ctx) {
+			select {
+			case results <- stats:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return results, nil
+}
@@ -0,0 +1,118 @@
+package pinger
+
+import "time"
+
+// DefaultStateHistory is the number of recent results kept in a Pinger's State.
+const DefaultStateHistory = 20
+
+// ProbeResult is the outcome of a single probe as kept in State.
+type ProbeResult struct {
+	Time      time.Time     `json:"time"`
+	Connected bool          `json:"connected"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
+}
+
+// State is the persistable state of a Pinger: whether the target is up, since
+// when, the accumulated counters and the most recent results. Restoring it
+// after a restart lets statistics and up/down tracking continue instead of
+// starting over.
+type State struct {
+	Up            bool          `json:"up"`
+	Since         time.Time     `json:"since"`
+	Total         int           `json:"total"`
+	Failed        int           `json:"failed"`
+	MinDuration   time.Duration `json:"min_duration"`
+	MaxDuration   time.Duration `json:"max_duration"`
+	TotalDuration time.Duration `json:"total_duration"`
+	SumSquares    float64       `json:"sum_squares,omitempty"`
+	Streak        int           `json:"streak,omitempty"`
+	FailStreak    int           `json:"fail_streak,omitempty"`
+	Recent        []ProbeResult `json:"recent"`
+	Traffic       Traffic       `json:"traffic"`
+	Quarantined   bool          `json:"quarantined,omitempty"`
+}
+
+// State returns a copy of the Pinger's current state. It is safe to call
+// while the Pinger is running.
+func (p *Pinger) State() State {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+
+	state := State{
+		Up:            p.up,
+		Since:         p.since,
+		Total:         p.total,
+		Failed:        p.failedTotal,
+		MaxDuration:   p.maxDuration,
+		TotalDuration: p.totalDuration,
+		SumSquares:    p.sumSquares,
+		Streak:        p.streak,
+		FailStreak:    p.failStreak,
+		Recent:        append([]ProbeResult(nil), p.recent...),
+		Traffic:       p.traffic,
+		Quarantined:   p.quarantined,
+	}
+	if p.total > p.failedTotal {
+		state.MinDuration = p.minDuration
+	}
+	return state
+}
+
+// Restore replaces the Pinger's state with a previously saved one. It should
+// be called before Ping; restored probes count towards the counter.
+func (p *Pinger) Restore(state State) {
+	p.statsMu.Lock()
+	defer p.statsMu.Unlock()
+
+	p.up = state.Up
+	p.since = state.Since
+	p.total = state.Total
+	p.failedTotal = state.Failed
+	p.maxDuration = state.MaxDuration
+	p.totalDuration = state.TotalDuration
+	p.sumSquares = state.SumSquares
+	p.streak = state.Streak
+	p.failStreak = state.FailStreak
+	p.traffic = state.Traffic
+	p.quarantined = p.inQuarantine(time.Now())
+	if state.Total > state.Failed {
+		p.minDuration = state.MinDuration
+	}
+	p.recent = append([]ProbeResult(nil), state.Recent...)
+	if len(p.recent) > DefaultStateHistory {
+		p.recent = p.recent[len(p.recent)-DefaultStateHistory:]
+	}
+}
+
+// recordState updates the up/down state, the streaks and the recent results with the
+// outcome of a probe, and reports whether the state changed. The caller must
+// hold statsMu and has already counted the probe in total.
+func (p *Pinger) recordState(stats *Stats) (changed bool) {
+	now := time.Now()
+	if p.total == 1 && len(p.recent) == 0 || p.up != stats.Connected {
+		p.since = now
+		p.streak = 0
+		changed = true
+	}
+	p.up = stats.Connected
+	p.streak++
+	if !p.up {
+		p.failStreak = max(p.failStreak, p.streak)
+	}
+
+	result := ProbeResult{
+		Time:      now,
+		Connected: stats.Connected,
+		Duration:  stats.Duration,
+	}
+	if stats.Error != nil {
+		result.Error = formatError(stats.Error)
+	}
+	if len(p.recent) >= DefaultStateHistory {
+		copy(p.recent, p.recent[1:])
+		p.recent = p.recent[:len(p.recent)-1]
+	}
+	p.recent = append(p.recent, result)
+	return changed
+}
@@ -0,0 +1,213 @@
+package pinger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// TemplateFuncs are the functions available to output templates besides the
+// text/template builtins:
+//
+//	percent    formats a fraction as a percentage, e.g. 0.25 as "25.0%"
+//	ms         converts a time.Duration to float milliseconds
+//	join       joins a list of strings with a separator
+//	histogram  renders durations as an ASCII histogram, one indented line per bucket
+//	sparkline  renders the last durations as a line of unicode block elements
+func TemplateFuncs() template.FuncMap {
+	return template.FuncMap{
+		"percent":   func(f float64) string { return fmt.Sprintf("%.1f%%", f*100) },
+		"ms":        func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) },
+		"join":      strings.Join,
+		"histogram": renderHistogram,
+		"sparkline": func(durations []time.Duration) string {
+			return stats.Sparkline(durations[max(len(durations)-SparklineLength, 0):])
+		},
+	}
+}
+
+const (
+	// HistogramBuckets is the number of buckets of the summary histogram.
+	HistogramBuckets = 8
+	// SparklineLength is the number of recent probes in the summary sparkline.
+	SparklineLength = 60
+	// histogramResolution is the precision bucket bounds are shown with.
+	histogramResolution = time.Microsecond
+)
+
+// renderHistogram renders durations as HistogramBuckets lines such as
+// "    1.2ms - 1.5ms | ########             12", the bars scaled to the
+// fullest bucket. Durations too close together for their buckets to show
+// different bounds are rendered as a single bucket.
+func renderHistogram(durations []time.Duration) string {
+	const width = 30
+	n := HistogramBuckets
+	if len(durations) > 0 && slices.Max(durations)-slices.Min(durations) < time.Duration(n)*histogramResolution {
+		n = 1
+	}
+	buckets := stats.Histogram(durations, n)
+	fullest := 0
+	for _, b := range buckets {
+		fullest = max(fullest, b.Count)
+	}
+	var lines []string
+	for _, b := range buckets {
+		bar := strings.Repeat("#", (b.Count*width+fullest-1)/fullest)
+		lines = append(lines, fmt.Sprintf("    %10s - %-10s | %-*s %d",
+			b.Low.Round(histogramResolution), b.High.Round(histogramResolution), width, bar, b.Count))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseTemplate parses a user-supplied output template with TemplateFuncs.
+// A trailing newline is added when the text has none, so that every probe
+// or summary ends its line.
+func ParseTemplate(name, text string) (*template.Template, error) {
+	if !strings.HasSuffix(text, "\n") {
+		text += "\n"
+	}
+	return template.New(name).Funcs(TemplateFuncs()).Parse(text)
+}
+
+// Probe is the data a probe output template is executed with. Its fields are
+// part of the stable output interface and are only ever added to.
+type Probe struct {
+	Target      string            // The target URL
+	Seq         int               // Sequence number of the probe, starting at 1
+	Timestamp   time.Time         // When the probe started
+	Connected   bool              // Whether the probe succeeded
+	Address     string            // The address probed, if known
+	Duration    time.Duration     // Round-trip time
+	DNSDuration time.Duration     // DNS lookup time, if any
+	Error       string            // The error in the form of the text output, or ""
+	Meta        map[string]string // Protocol metadata, e.g. {{.Meta.status}}
+	Extra       string            // Additional protocol output, such as an HTTP trace
+	Labels      map[string]string // Labels of the target
+}
+
+// Probe returns the template data of the record.
+func (r *Record) Probe() *Probe {
+	stats := r.Stats
+	p := &Probe{
+		Target:      r.Target,
+		Seq:         r.Seq,
+		Timestamp:   r.Timestamp,
+		Connected:   stats.Connected,
+		Address:     stats.Address,
+		Duration:    stats.Duration,
+		DNSDuration: stats.DNSDuration,
+		Error:       r.errorText(),
+		Meta:        make(map[string]string, len(stats.Meta)),
+		Labels:      r.Labels,
+	}
+	for k, v := range stats.Meta {
+		if v != nil {
+			p.Meta[k] = v.String()
+		}
+	}
+	if stats.Extra != nil {
+		p.Extra = strings.TrimSpace(stats.Extra.String())
+	}
+	return p
+}
+
+// Summary is the data a summary template is executed with. Its fields are
+// part of the stable output interface and are only ever added to.
+type Summary struct {
+	URL          *url.URL      // The target
+	Total        int           // Probes sent
+	SuccessTotal int           // Successful probes
+	FailedTotal  int           // Failed probes
+	MinDuration  time.Duration // Fastest successful probe
+	MaxDuration  time.Duration // Slowest successful probe
+	AvgDuration  time.Duration // Mean of the successful probes
+	Loss         float64       // Fraction of failed probes
+
+	// LossLow and LossHigh bound the 95% confidence interval of Loss
+	LossLow, LossHigh float64
+
+	// Up is whether the last probe succeeded, Streak how many probes in a
+	// row ended the same way, and FailStreak the longest run of failures
+	Up         bool
+	Streak     int
+	FailStreak int
+
+	// AvgLow and AvgHigh bound the 95% confidence interval of AvgDuration,
+	// which needs at least two successful probes as HasAvgCI reports
+	HasAvgCI        bool
+	AvgLow, AvgHigh time.Duration
+
+	// Durations are those of the last successful probes, oldest first, at
+	// most DurationHistory of them
+	Durations []time.Duration
+
+	// Labels of the target
+	Labels map[string]string
+
+	// Traffic of the probes, estimated unless their protocol counts it
+	Traffic Traffic
+}
+
+// SummaryPercentiles are the percentiles of the JSON form of a Summary.
+var SummaryPercentiles = []float64{50, 90, 95, 99}
+
+// summaryJSON is the JSON form of a Summary, with durations in fractional
+// milliseconds. The latency fields are left out without a successful probe.
+type summaryJSON struct {
+	Target        string             `json:"target"`
+	Labels        map[string]string  `json:"labels,omitempty"`
+	Total         int                `json:"total"`
+	Successful    int                `json:"successful"`
+	Failed        int                `json:"failed"`
+	Loss          float64            `json:"loss"`
+	LossCI        [2]float64         `json:"loss_ci"`
+	MinMS         float64            `json:"min_ms,omitempty"`
+	MaxMS         float64            `json:"max_ms,omitempty"`
+	AvgMS         float64            `json:"avg_ms,omitempty"`
+	AvgCIMS       *[2]float64        `json:"avg_ci_ms,omitempty"`
+	PercentilesMS map[string]float64 `json:"percentiles_ms,omitempty"`
+	Up            bool               `json:"up"`
+	Streak        int                `json:"streak"`
+	FailStreak    int                `json:"fail_streak"`
+}
+
+// MarshalJSON implements json.Marshaler. Percentiles are those of
+// SummaryPercentiles over Durations, keyed as "p50" and so on.
+func (s Summary) MarshalJSON() ([]byte, error) {
+	v := summaryJSON{
+		Labels:     s.Labels,
+		Total:      s.Total,
+		Successful: s.SuccessTotal,
+		Failed:     s.FailedTotal,
+		Loss:       s.Loss,
+		LossCI:     [2]float64{s.LossLow, s.LossHigh},
+		Up:         s.Up,
+		Streak:     s.Streak,
+		FailStreak: s.FailStreak,
+	}
+	if s.URL != nil {
+		v.Target = s.URL.String()
+	}
+	if s.SuccessTotal > 0 {
+		v.MinMS = milliseconds(s.MinDuration)
+		v.MaxMS = milliseconds(s.MaxDuration)
+		v.AvgMS = milliseconds(s.AvgDuration)
+	}
+	if s.HasAvgCI {
+		v.AvgCIMS = &[2]float64{milliseconds(s.AvgLow), milliseconds(s.AvgHigh)}
+	}
+	if len(s.Durations) > 0 {
+		sample := stats.Sample{Durations: slices.Clone(s.Durations)}
+		v.PercentilesMS = make(map[string]float64, len(SummaryPercentiles))
+		for _, p := range SummaryPercentiles {
+			v.PercentilesMS[fmt.Sprintf("p%g", p)] = milliseconds(sample.Percentile(p))
+		}
+	}
+	return json.Marshal(v)
+}
@@ -0,0 +1,179 @@
+package pinger
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"syscall"
+)
+
+// Traffic counts the packets and bytes a probe sent and received, IP headers
+// included.
+type Traffic struct {
+	PacketsSent     int64 `json:"packets_sent"`
+	PacketsReceived int64 `json:"packets_received"`
+	BytesSent       int64 `json:"bytes_sent"`
+	BytesReceived   int64 `json:"bytes_received"`
+}
+
+// Add adds the counts of o to t.
+func (t *Traffic) Add(o Traffic) {
+	t.PacketsSent += o.PacketsSent
+	t.PacketsReceived += o.PacketsReceived
+	t.BytesSent += o.BytesSent
+	t.BytesReceived += o.BytesReceived
+}
+
+// Bytes returns the bytes sent and received.
+func (t Traffic) Bytes() int64 {
+	return t.BytesSent + t.BytesReceived
+}
+
+// String formats the traffic such as "12 packets sent (1.2 KiB), 10 received (4.8 KiB)".
+func (t Traffic) String() string {
+	return fmt.Sprintf("%d packets sent (%s), %d received (%s)",
+		t.PacketsSent, FormatBytes(t.BytesSent), t.PacketsReceived, FormatBytes(t.BytesReceived))
+}
+
+// FormatBytes formats n bytes with a binary unit, such as "1.2 KiB".
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return strconv.FormatInt(n, 10) + " B"
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 4; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTP"[exp])
+}
+
+// Estimated sizes of the parts of a probe, IPv4 headers included.
+const (
+	tcpHeader  = 52   // IPv4 and TCP headers with timestamps
+	tcpMSS     = 1448 // payload of a full segment
+	udpHeader  = 28   // IPv4 and UDP headers
+	icmpHeader = 28   // IPv4 and ICMP headers
+	ipv6Header = 40   // fixed IPv6 header
+	ethMinimum = 64   // minimum Ethernet frame, as ARP uses
+	syn        = 60   // a SYN or SYN-ACK with options
+	rst        = 40   // a RST, IPv4 and TCP headers without options
+	unreach    = 56   // an ICMP port unreachable quoting the IPv4 and UDP headers
+	httpHeads  = 400  // the headers of a response
+	httpAsk    = 200  // a request line and headers
+	tlsClient  = 600  // ClientHello, key exchange and Finished
+	tlsServer  = 4400 // ServerHello and certificate chain
+	udpPayload = 64   // the payload when a probe does not report it
+	echoData   = 8 + 13
+)
+
+// segments returns the traffic of n payload bytes carried in TCP segments.
+func segments(n int64) Traffic {
+	packets := max((n+tcpMSS-1)/tcpMSS, 1)
+	return Traffic{PacketsSent: packets, BytesSent: packets*tcpHeader + n}
+}
+
+// reversed swaps the directions of t.
+func (t Traffic) reversed() Traffic {
+	return Traffic{
+		PacketsSent:     t.PacketsReceived,
+		PacketsReceived: t.PacketsSent,
+		BytesSent:       t.BytesReceived,
+		BytesReceived:   t.BytesSent,
+	}
+}
+
+// EstimateTraffic estimates the traffic of a probe from its protocol and what
+// it reported, such as the body size of HTTP responses and the payload of UDP
+// datagrams. Retransmissions and link-layer framing are left out, and failed
+// probes are counted as far as they usually get, with the RST or port
+// unreachable answering a refused one.
+func EstimateTraffic(protocol Protocol, stats *Stats) Traffic {
+	var t Traffic
+	connected := stats != nil && stats.Connected
+	refused := stats != nil && !connected && errors.Is(stats.Error, syscall.ECONNREFUSED)
+	handshake := func() {
+		if !connected {
+			t.Add(Traffic{PacketsSent: 1, BytesSent: syn})
+			if refused {
+				t.Add(Traffic{PacketsReceived: 1, BytesReceived: rst})
+			}
+			return
+		}
+		// SYN, ACK, FIN and ACK out; SYN-ACK and FIN in
+		t.Add(Traffic{PacketsSent: 4, BytesSent: syn + 3*tcpHeader, PacketsReceived: 2, BytesReceived: syn + tcpHeader})
+	}
+	exchange := func(sent, received int64) {
+		if connected {
+			t.Add(segments(sent))
+			t.Add(segments(received).reversed())
+		}
+	}
+	switch protocol {
+	case TCP:
+		handshake()
+	case TLS:
+		handshake()
+		exchange(tlsClient, tlsServer)
+	case HTTP, HTTPS:
+		handshake()
+		if protocol == HTTPS {
+			exchange(tlsClient, tlsServer)
+		}
+		exchange(httpAsk, httpHeads+metaInt(stats, "bytes"))
+	case H2C, SOCKS5, RPC, SMB:
+		handshake()
+		exchange(httpAsk, httpAsk)
+	case UDP, GAMESERVER:
+		datagram := int64(udpHeader + udpPayload)
+		if n := metaInt(stats, "sent"); n > 0 {
+			datagram = udpHeader + n
+		}
+		t.Add(Traffic{PacketsSent: 1, BytesSent: datagram})
+		switch {
+		case connected:
+			t.Add(Traffic{PacketsReceived: 1, BytesReceived: datagram})
+		case refused:
+			t.Add(Traffic{PacketsReceived: 1, BytesReceived: unreach})
+		}
+	case ICMP:
+		t.Add(Traffic{PacketsSent: 1, BytesSent: icmpHeader + echoData})
+		if connected {
+			t.Add(Traffic{PacketsReceived: 1, BytesReceived: icmpHeader + echoData})
+		}
+	case IPV6EH:
+		// The baseline and one echo per extension header of 8 bytes
+		echo := int64(ipv6Header + echoData)
+		t.Add(Traffic{PacketsSent: 4, BytesSent: 4*echo + 3*8})
+		if connected {
+			t.Add(Traffic{PacketsReceived: 1, BytesReceived: echo})
+		}
+	case ARP:
+		t.Add(Traffic{PacketsSent: 1, BytesSent: ethMinimum})
+		if connected {
+			t.Add(Traffic{PacketsReceived: 1, BytesReceived: ethMinimum})
+		}
+	default:
+		if !connected {
+			t.Add(Traffic{PacketsSent: 1, BytesSent: syn})
+			if refused {
+				t.Add(Traffic{PacketsReceived: 1, BytesReceived: rst})
+			}
+		}
+		exchange(httpAsk, httpAsk)
+	}
+	return t
+}
+
+// metaInt returns the integer metadata key of stats, or 0 when missing.
+func metaInt(stats *Stats, key string) int64 {
+	if stats == nil || stats.Meta[key] == nil {
+		return 0
+	}
+	n, err := strconv.ParseInt(stats.Meta[key].String(), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package pinger
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// trendBuckets is how many buckets of probe times a trend window is
+	// split into.
+	trendBuckets = 30
+	// TrendMinSamples is how many successful probes both the last window
+	// and the window before need for their trend to be judged.
+	TrendMinSamples = 10
+)
+
+// trendBucket sums the times of the successful probes started in one slice
+// of a trend window.
+type trendBucket struct {
+	start time.Time
+	sum   time.Duration
+	n     int
+}
+
+// SetTrend makes the Pinger alert on slow degradations that absolute
+// thresholds miss: once the average time of the probes of the last window is
+// more than rise (0.3 for 30%) above that of the window before, it is noted
+// on the output and records carry the meta latency_trend with the rise,
+// until the average falls back within rise of the baseline. It must be
+// called before Ping or Probes.
+func (p *Pinger) SetTrend(window time.Duration, rise float64) {
+	p.trendWindow = window
+	p.trendRise = rise
+}
+
+// updateTrend adds a probe started at start to the trend, flagging its stats
+// while the trend alerts, and returns the note of a change or "". The caller
+// must hold statsMu.
+func (p *Pinger) updateTrend(stats *Stats, start time.Time) string {
+	if p.trendWindow <= 0 {
+		return ""
+	}
+	if p.trendSince.IsZero() {
+		p.trendSince = start
+	}
+
+	// Add the probe to its bucket and forget the buckets of neither window
+	if stats.Connected {
+		width := p.trendWindow / trendBuckets
+		if n := len(p.trend); n == 0 || start.Sub(p.trend[n-1].start) >= width {
+			p.trend = append(p.trend, trendBucket{start: start.Truncate(width)})
+		}
+		b := &p.trend[len(p.trend)-1]
+		b.sum += stats.Duration
+		b.n++
+	}
+	oldest := start.Add(-2 * p.trendWindow)
+	for len(p.trend) > 0 && !p.trend[0].start.After(oldest) {
+		p.trend = p.trend[1:]
+	}
+
+	// Judge the trend once both windows have been probed throughout
+	was := p.trendAlert
+	recent, baseline, ok := p.trendAverages(start)
+	if ok {
+		p.trendChange = float64(recent)/float64(baseline) - 1
+		p.trendAlert = p.trendChange > p.trendRise
+	}
+	if !p.trendAlert {
+		if was {
+			return fmt.Sprintf("%s: latency trend back within %.0f%% of the baseline\n", p.url, 100*p.trendRise)
+		}
+		return ""
+	}
+	change := p.trendChange
+	if stats.Meta == nil {
+		stats.Meta = make(map[string]fmt.Stringer)
+	}
+	stats.Meta["latency_trend"] = StringerFunc(func() string { return fmt.Sprintf("+%.0f%%", 100*change) })
+	if was {
+		return ""
+	}
+	return fmt.Sprintf("%s: latency rising, average %s over the last %s is %.0f%% above %s the %s before\n",
+		p.url, recent.Round(time.Microsecond), p.trendWindow, 100*change, baseline.Round(time.Microsecond), p.trendWindow)
+}
+
+// trendAverages returns the average time of the probes of the window ending
+// at now and of the window before, and false while either lacks probes.
+// The caller must hold statsMu.
+func (p *Pinger) trendAverages(now time.Time) (recent, baseline time.Duration, ok bool) {
+	if now.Sub(p.trendSince) < 2*p.trendWindow {
+		return 0, 0, false
+	}
+	cut := now.Add(-p.trendWindow)
+	var (
+		recentSum, baselineSum time.Duration
+		recentN, baselineN     int
+	)
+	for _, b := range p.trend {
+		if b.start.After(cut) {
+			recentSum += b.sum
+			recentN += b.n
+		} else {
+			baselineSum += b.sum
+			baselineN += b.n
+		}
+	}
+	if recentN < TrendMinSamples || baselineN < TrendMinSamples {
+		return 0, 0, false
+	}
+	return recentSum / time.Duration(recentN), baselineSum / time.Duration(baselineN), true
+}
@@ -0,0 +1,14 @@
+// Package power tells whether the machine runs on battery, so that
+// background monitors can probe less often and save energy.
+package power
+
+import "errors"
+
+// ErrUnsupported is returned where the power source cannot be read.
+var ErrUnsupported = errors.New("reading the power source is not supported on this platform")
+
+// OnBattery reports whether the machine runs on battery. Machines without
+// a battery, such as servers, never do.
+func OnBattery() (bool, error) {
+	return onBattery()
+}
@@ -0,0 +1,19 @@
+//go:build darwin
+
+package power
+
+import (
+	"bytes"
+	"os/exec"
+)
+
+// onBattery asks pmset, whose first line names the power source, e.g.
+// "Now drawing from 'Battery Power'".
+func onBattery() (bool, error) {
+	out, err := exec.Command("pmset", "-g", "batt").Output()
+	if err != nil {
+		return false, err
+	}
+	first, _, _ := bytes.Cut(out, []byte("\n"))
+	return bytes.Contains(first, []byte("'Battery Power'")), nil
+}
@@ -0,0 +1,49 @@
+//go:build linux
+
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// supplies is where the kernel lists the power supplies.
+var supplies = "/sys/class/power_supply"
+
+// onBattery reads the power supplies from sysfs: the machine runs on
+// battery when no mains or USB supply is online and a battery discharges.
+func onBattery() (bool, error) {
+	entries, err := os.ReadDir(supplies)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	discharging := false
+	for _, e := range entries {
+		dir := filepath.Join(supplies, e.Name())
+		switch read(dir, "type") {
+		case "Mains", "USB":
+			if read(dir, "online") == "1" {
+				return false, nil
+			}
+		case "Battery":
+			if read(dir, "status") == "Discharging" {
+				discharging = true
+			}
+		}
+	}
+	return discharging, nil
+}
+
+// read returns the trimmed content of the attribute file name in dir, or ""
+// when it cannot be read.
+func read(dir, name string) string {
+	b, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,31 @@
+package power
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOnBattery(t *testing.T) {
+	defer func(dir string) { supplies = dir }(supplies)
+	supplies = t.TempDir()
+	write := func(supply string, attrs map[string]string) {
+		os.MkdirAll(filepath.Join(supplies, supply), 0o755)
+		for name, value := range attrs {
+			os.WriteFile(filepath.Join(supplies, supply, name), []byte(value+"\n"), 0o644)
+		}
+	}
+
+	if on, err := OnBattery(); err != nil || on {
+		t.Fatalf("a machine without supplies should not run on battery, got %v, %v", on, err)
+	}
+	write("BAT0", map[string]string{"type": "Battery", "status": "Discharging"})
+	write("AC", map[string]string{"type": "Mains", "online": "0"})
+	if on, err := OnBattery(); err != nil || !on {
+		t.Fatalf("expected to run on battery, got %v, %v", on, err)
+	}
+	write("AC", map[string]string{"online": "1"})
+	if on, err := OnBattery(); err != nil || on {
+		t.Fatalf("expected to run on mains, got %v, %v", on, err)
+	}
+}
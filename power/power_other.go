@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !windows
+
+package power
+
+// onBattery returns ErrUnsupported.
+func onBattery() (bool, error) {
+	return false, ErrUnsupported
+}
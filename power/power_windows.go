@@ -0,0 +1,30 @@
+//go:build windows
+
+package power
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var getSystemPowerStatus = syscall.NewLazyDLL("kernel32.dll").NewProc("GetSystemPowerStatus")
+
+// systemPowerStatus is the SYSTEM_POWER_STATUS structure.
+type systemPowerStatus struct {
+	ACLineStatus        byte
+	BatteryFlag         byte
+	BatteryLifePercent  byte
+	SystemStatusFlag    byte
+	BatteryLifeTime     uint32
+	BatteryFullLifeTime uint32
+}
+
+// onBattery calls GetSystemPowerStatus, whose ACLineStatus is 0 when
+// offline, 1 when online and 255 when unknown.
+func onBattery() (bool, error) {
+	var status systemPowerStatus
+	if r, _, err := getSystemPowerStatus.Call(uintptr(unsafe.Pointer(&status))); r == 0 {
+		return false, err
+	}
+	return status.ACLineStatus == 0, nil
+}
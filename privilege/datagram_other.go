@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package privilege
+
+// canICMPDatagram always reports false where ICMP datagram sockets are unsupported.
+func canICMPDatagram() bool {
+	return false
+}
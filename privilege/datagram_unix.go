@@ -0,0 +1,16 @@
+//go:build linux || darwin
+
+package privilege
+
+import "syscall"
+
+// canICMPDatagram reports whether an unprivileged ICMP datagram socket can be
+// created (Linux net.ipv4.ping_group_range, always on macOS).
+func canICMPDatagram() bool {
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_DGRAM, syscall.IPPROTO_ICMP)
+	if err != nil {
+		return false
+	}
+	syscall.Close(fd)
+	return true
+}
@@ -0,0 +1,78 @@
+// Package privilege detects whether the current process may open the raw or
+// ICMP sockets required by the privileged probe modes.
+package privilege
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// Capabilities describes the socket privileges available to the process.
+type Capabilities struct {
+	Root         bool // effective uid 0 (or administrator on Windows)
+	Setuid       bool // effective uid differs from the real uid
+	NetRaw       bool // CAP_NET_RAW is in the effective set (Linux only)
+	RawSocket    bool // a raw ICMP socket could be opened
+	ICMPDatagram bool // an unprivileged ICMP datagram socket could be opened
+}
+
+// CanICMP reports whether ICMP echo probes can be sent by any means.
+func (c Capabilities) CanICMP() bool {
+	return c.RawSocket || c.ICMPDatagram
+}
+
+// String returns a short human-readable summary of the capabilities.
+func (c Capabilities) String() string {
+	var parts []string
+	if c.Root {
+		parts = append(parts, "root")
+	}
+	if c.Setuid {
+		parts = append(parts, "setuid")
+	}
+	if c.NetRaw {
+		parts = append(parts, "cap_net_raw")
+	}
+	if c.RawSocket {
+		parts = append(parts, "raw-socket")
+	}
+	if c.ICMPDatagram {
+		parts = append(parts, "icmp-datagram")
+	}
+	if len(parts) == 0 {
+		return "unprivileged"
+	}
+	return strings.Join(parts, ",")
+}
+
+// Detect probes the running process for raw-socket capabilities. It opens and
+// immediately closes the sockets it tests, so it is safe to call at startup.
+func Detect() Capabilities {
+	euid := os.Geteuid()
+	c := Capabilities{
+		Root:   euid == 0,
+		Setuid: euid >= 0 && euid != os.Getuid(),
+		NetRaw: hasNetRaw(),
+	}
+	if conn, err := net.ListenPacket("ip4:icmp", "0.0.0.0"); err == nil {
+		conn.Close()
+		c.RawSocket = true
+		// Opening a raw socket on Windows requires an elevated token.
+		if euid < 0 {
+			c.Root = true
+		}
+	}
+	c.ICMPDatagram = canICMPDatagram()
+	return c
+}
+
+// Require returns a descriptive error when ICMP probing is not possible, or
+// nil when it is.
+func (c Capabilities) Require(mode string) error {
+	if c.CanICMP() {
+		return nil
+	}
+	return fmt.Errorf("%s requires raw socket privileges (root, CAP_NET_RAW or administrator)", mode)
+}
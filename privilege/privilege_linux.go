@@ -0,0 +1,34 @@
+package privilege
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// capNetRaw is the bit index of CAP_NET_RAW in the capability sets.
+const capNetRaw = 13
+
+// hasNetRaw reports whether CAP_NET_RAW is present in the effective set.
+func hasNetRaw() bool {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "CapEff:") {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(strings.TrimPrefix(line, "CapEff:")), 16, 64)
+		if err != nil {
+			return false
+		}
+		return mask&(1<<capNetRaw) != 0
+	}
+	return false
+}
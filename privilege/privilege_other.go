@@ -0,0 +1,8 @@
+//go:build !linux
+
+package privilege
+
+// hasNetRaw always reports false on platforms without Linux capabilities.
+func hasNetRaw() bool {
+	return false
+}
@@ -0,0 +1,30 @@
+package proxyauth
+
+import "errors"
+
+// basic sends the user name and password of the proxy URL with the Basic
+// scheme, or no credentials at all without a user name.
+type basic struct {
+	user, password string
+}
+
+func newBasic(user, password string) *basic {
+	return &basic{user: user, password: password}
+}
+
+func (b *basic) Scheme() string { return "Basic" }
+
+// Next returns the credentials, nil without a user name, which sends the
+// CONNECT without a Proxy-Authorization header. Basic has no challenge to
+// answer: a proxy asking again refused the credentials.
+func (b *basic) Next(challenge []byte) ([]byte, error) {
+	if challenge != nil {
+		return nil, errors.New("the proxy refused the credentials")
+	}
+	if b.user == "" {
+		return nil, nil
+	}
+	return []byte(b.user + ":" + b.password), nil
+}
+
+func (b *basic) Close() {}
@@ -0,0 +1,55 @@
+package proxyauth
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// md4Rounds are the message word order, shifts and constant of the three
+// rounds of MD4.
+var md4Rounds = [3]struct {
+	order  [16]int
+	shifts [4]int
+	k      uint32
+	f      func(x, y, z uint32) uint32
+}{
+	{[16]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}, [4]int{3, 7, 11, 19}, 0,
+		func(x, y, z uint32) uint32 { return x&y | ^x&z }},
+	{[16]int{0, 4, 8, 12, 1, 5, 9, 13, 2, 6, 10, 14, 3, 7, 11, 15}, [4]int{3, 5, 9, 13}, 0x5a827999,
+		func(x, y, z uint32) uint32 { return x&y | x&z | y&z }},
+	{[16]int{0, 8, 4, 12, 2, 10, 6, 14, 1, 9, 5, 13, 3, 11, 7, 15}, [4]int{3, 9, 11, 15}, 0x6ed9eba1,
+		func(x, y, z uint32) uint32 { return x ^ y ^ z }},
+}
+
+// md4 returns the MD4 digest of data (RFC 1320), which NTLM hashes
+// passwords with. MD4 is broken and only implemented for that.
+func md4(data []byte) [16]byte {
+	// Pad to 56 bytes modulo 64, then append the length in bits
+	msg := append([]byte(nil), data...)
+	msg = append(msg, 0x80)
+	for len(msg)%64 != 56 {
+		msg = append(msg, 0)
+	}
+	msg = binary.LittleEndian.AppendUint64(msg, uint64(len(data))*8)
+
+	h := [4]uint32{0x67452301, 0xefcdab89, 0x98badcfe, 0x10325476}
+	var x [16]uint32
+	for ; len(msg) > 0; msg = msg[64:] {
+		for i := range x {
+			x[i] = binary.LittleEndian.Uint32(msg[4*i:])
+		}
+		a, b, c, d := h[0], h[1], h[2], h[3]
+		for _, round := range md4Rounds {
+			for n, i := range round.order {
+				a, b, c, d = d, bits.RotateLeft32(a+round.f(b, c, d)+x[i]+round.k, round.shifts[n%4]), b, c
+			}
+		}
+		h[0], h[1], h[2], h[3] = h[0]+a, h[1]+b, h[2]+c, h[3]+d
+	}
+
+	var sum [16]byte
+	for i, v := range h {
+		binary.LittleEndian.PutUint32(sum[4*i:], v)
+	}
+	return sum
+}
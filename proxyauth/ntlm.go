@@ -0,0 +1,213 @@
+package proxyauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// NTLM negotiate flags (MS-NLMP 2.2.2.5)
+const (
+	ntlmUnicode          = 0x00000001
+	ntlmRequestTarget    = 0x00000004
+	ntlmNTLM             = 0x00000200
+	ntlmAlwaysSign       = 0x00008000
+	ntlmExtendedSecurity = 0x00080000
+	ntlmTargetInfo       = 0x00800000
+	ntlm128              = 0x20000000
+	ntlm56               = 0x80000000
+
+	ntlmFlags = ntlmUnicode | ntlmRequestTarget | ntlmNTLM | ntlmAlwaysSign |
+		ntlmExtendedSecurity | ntlmTargetInfo | ntlm128 | ntlm56
+)
+
+// ntlmSignature starts every NTLM message.
+var ntlmSignature = []byte("NTLMSSP\x00")
+
+// avTimestamp is the AV pair of the server's time in the target info.
+const avTimestamp = 7
+
+// ntlm authenticates with NTLMv2 using a user name and password.
+type ntlm struct {
+	user, password, domain string
+
+	// now and random are replaced in tests
+	now    func() time.Time
+	random func([]byte) (int, error)
+}
+
+// newNTLM creates an NTLM authenticator. user may be given as
+// DOMAIN\user, otherwise domain is empty, which also suits user@domain.
+func newNTLM(user, password string) *ntlm {
+	domain := ""
+	if d, u, ok := strings.Cut(user, `\`); ok {
+		domain, user = d, u
+	}
+	return &ntlm{user: user, password: password, domain: domain, now: time.Now, random: rand.Read}
+}
+
+func (n *ntlm) Scheme() string { return "NTLM" }
+
+// Next returns the negotiate message for an empty challenge and the
+// authenticate message for the server's challenge message.
+func (n *ntlm) Next(challenge []byte) ([]byte, error) {
+	if challenge == nil {
+		return negotiateMessage(), nil
+	}
+	return n.authenticate(challenge)
+}
+
+func (n *ntlm) Close() {}
+
+// negotiateMessage returns the first message, offering ntlmFlags.
+func negotiateMessage() []byte {
+	msg := append([]byte(nil), ntlmSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, 1)
+	msg = binary.LittleEndian.AppendUint32(msg, ntlmFlags)
+	// Empty domain and workstation
+	return append(msg, make([]byte, 16)...)
+}
+
+// challengeMessage is what authenticate needs of the server's challenge.
+type challengeMessage struct {
+	flags      uint32
+	challenge  []byte
+	targetInfo []byte
+}
+
+// parseChallenge parses a challenge message (MS-NLMP 2.2.1.2).
+func parseChallenge(msg []byte) (*challengeMessage, error) {
+	if len(msg) < 48 || !bytes.Equal(msg[:8], ntlmSignature) || binary.LittleEndian.Uint32(msg[8:]) != 2 {
+		return nil, errors.New("ntlm: invalid challenge message")
+	}
+	info, err := field(msg, 40)
+	if err != nil {
+		return nil, err
+	}
+	return &challengeMessage{
+		flags:      binary.LittleEndian.Uint32(msg[20:]),
+		challenge:  msg[24:32],
+		targetInfo: info,
+	}, nil
+}
+
+// field returns the payload a security buffer at offset of msg points to.
+func field(msg []byte, offset int) ([]byte, error) {
+	length := int(binary.LittleEndian.Uint16(msg[offset:]))
+	start := int(binary.LittleEndian.Uint32(msg[offset+4:]))
+	if start+length > len(msg) {
+		return nil, errors.New("ntlm: field out of bounds")
+	}
+	return msg[start : start+length], nil
+}
+
+// authenticate returns the authenticate message answering challenge with
+// NTLMv2 responses (MS-NLMP 3.3.2).
+func (n *ntlm) authenticate(msg []byte) ([]byte, error) {
+	c, err := parseChallenge(msg)
+	if err != nil {
+		return nil, err
+	}
+	if c.flags&ntlmUnicode == 0 {
+		return nil, errors.New("ntlm: the proxy does not support unicode")
+	}
+
+	// The server's time when it sends one, so clocks need not agree
+	timestamp := filetime(n.now())
+	if t, ok := avPair(c.targetInfo, avTimestamp); ok && len(t) == 8 {
+		timestamp = binary.LittleEndian.Uint64(t)
+	}
+	clientChallenge := make([]byte, 8)
+	if _, err := n.random(clientChallenge); err != nil {
+		return nil, err
+	}
+	nt, lm := n.responses(c.challenge, clientChallenge, timestamp, c.targetInfo)
+
+	// Header of 64 bytes, then the payload the security buffers point to
+	payload := [][]byte{lm, nt, utf16le(n.domain), utf16le(n.user), nil, nil}
+	out := append([]byte(nil), ntlmSignature...)
+	out = binary.LittleEndian.AppendUint32(out, 3)
+	offset := 64
+	for i, p := range payload {
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(p)))
+		out = binary.LittleEndian.AppendUint16(out, uint16(len(p)))
+		out = binary.LittleEndian.AppendUint32(out, uint32(offset))
+		offset += len(p)
+		if i == len(payload)-1 {
+			out = binary.LittleEndian.AppendUint32(out, c.flags&ntlmFlags)
+		}
+	}
+	for _, p := range payload {
+		out = append(out, p...)
+	}
+	return out, nil
+}
+
+// responses returns the NTLMv2 and LMv2 responses to serverChallenge.
+func (n *ntlm) responses(serverChallenge, clientChallenge []byte, timestamp uint64, targetInfo []byte) (nt, lm []byte) {
+	key := ntowfv2(n.user, n.password, n.domain)
+
+	temp := []byte{1, 1, 0, 0, 0, 0, 0, 0}
+	temp = binary.LittleEndian.AppendUint64(temp, timestamp)
+	temp = append(temp, clientChallenge...)
+	temp = append(temp, 0, 0, 0, 0)
+	temp = append(temp, targetInfo...)
+	temp = append(temp, 0, 0, 0, 0)
+
+	proof := hmacMD5(key, serverChallenge, temp)
+	nt = append(proof, temp...)
+	lm = append(hmacMD5(key, serverChallenge, clientChallenge), clientChallenge...)
+	return nt, lm
+}
+
+// ntowfv2 returns the NTLMv2 response key of a user.
+func ntowfv2(user, password, domain string) []byte {
+	hash := md4(utf16le(password))
+	return hmacMD5(hash[:], utf16le(strings.ToUpper(user)+domain))
+}
+
+// hmacMD5 returns the HMAC-MD5 of the concatenated data under key.
+func hmacMD5(key []byte, data ...[]byte) []byte {
+	mac := hmac.New(md5.New, key)
+	for _, d := range data {
+		mac.Write(d)
+	}
+	return mac.Sum(nil)
+}
+
+// avPair returns the value of the AV pair id in target info.
+func avPair(info []byte, id uint16) ([]byte, bool) {
+	for len(info) >= 4 {
+		pairID := binary.LittleEndian.Uint16(info)
+		length := int(binary.LittleEndian.Uint16(info[2:]))
+		if pairID == 0 || len(info) < 4+length {
+			break
+		}
+		if pairID == id {
+			return info[4 : 4+length], true
+		}
+		info = info[4+length:]
+	}
+	return nil, false
+}
+
+// utf16le encodes s as UTF-16 little endian.
+func utf16le(s string) []byte {
+	var b []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, r)
+	}
+	return b
+}
+
+// filetime converts t to a Windows FILETIME, 100ns intervals since 1601.
+func filetime(t time.Time) uint64 {
+	const epochDelta = 116444736000000000 // from 1601 to 1970
+	return uint64(t.UnixNano()/100) + epochDelta
+}
@@ -0,0 +1,180 @@
+// Package proxyauth connects through HTTP proxies that require
+// connection-based authentication, such as NTLM or Negotiate (SPNEGO with
+// Kerberos), which Go's HTTP client cannot do: these schemes authenticate
+// a connection over several round trips rather than each request. It also
+// opens the CONNECT tunnels of probes other than HTTP requests, such as TCP
+// connects, through proxies with Basic authentication or none.
+package proxyauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Schemes are the authentication schemes New accepts.
+var Schemes = []string{"ntlm", "negotiate"}
+
+// Authenticator produces the tokens of a connection-based scheme.
+type Authenticator interface {
+	// Scheme is the name in the Proxy-Authorization header, e.g. "NTLM".
+	Scheme() string
+	// Next returns the token answering the proxy's challenge, nil for the
+	// first token.
+	Next(challenge []byte) ([]byte, error)
+	// Close releases the resources of the exchange.
+	Close()
+}
+
+// DialFunc connects to an address, such as net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer opens tunnels through an HTTP proxy with CONNECT, authenticating
+// every tunnel's connection.
+type Dialer struct {
+	proxy  *url.URL
+	scheme string
+	dial   DialFunc
+}
+
+// New creates a Dialer for proxy authenticating with scheme, "ntlm" or
+// "negotiate", or with an empty scheme with Basic, sending the user name and
+// password of the proxy URL when it has them. NTLM uses the user name and
+// password of the proxy URL, with the user given as DOMAIN\user or
+// user@domain; without them, and for Negotiate, the credentials of the
+// logged-on user are used, which is only supported on Windows.
+func New(proxy *url.URL, scheme string, dial DialFunc) (*Dialer, error) {
+	if proxy.Scheme != "http" {
+		if scheme == "" {
+			return nil, fmt.Errorf("CONNECT tunnels need an http:// proxy, not %s", proxy.Scheme)
+		}
+		return nil, fmt.Errorf("%s proxy authentication needs an http:// proxy, not %s", scheme, proxy.Scheme)
+	}
+	d := &Dialer{proxy: proxy, scheme: strings.ToLower(scheme), dial: dial}
+	// Fail early on a scheme this platform cannot do
+	auth, err := d.authenticator()
+	if err != nil {
+		return nil, err
+	}
+	auth.Close()
+	return d, nil
+}
+
+// authenticator returns a new Authenticator for one connection.
+func (d *Dialer) authenticator() (Authenticator, error) {
+	password, hasPassword := d.proxy.User.Password()
+	switch d.scheme {
+	case "":
+		return newBasic(d.proxy.User.Username(), password), nil
+	case "ntlm":
+		if hasPassword {
+			return newNTLM(d.proxy.User.Username(), password), nil
+		}
+		return newSSPI("NTLM", d.proxy.Hostname())
+	case "negotiate":
+		return newSSPI("Negotiate", d.proxy.Hostname())
+	}
+	return nil, fmt.Errorf("unknown proxy authentication %q, want one of %s", d.scheme, strings.Join(Schemes, ", "))
+}
+
+// DialContext connects to addr through a CONNECT tunnel of the proxy.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	proxyAddr := d.proxy.Host
+	if d.proxy.Port() == "" {
+		proxyAddr = net.JoinHostPort(d.proxy.Hostname(), "80")
+	}
+	conn, err := d.dial(ctx, network, proxyAddr)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+	tunnel, err := d.connect(conn, addr)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return tunnel, nil
+}
+
+// connect authenticates a CONNECT to addr on conn, answering the proxy's
+// challenges until it accepts or refuses.
+func (d *Dialer) connect(conn net.Conn, addr string) (net.Conn, error) {
+	auth, err := d.authenticator()
+	if err != nil {
+		return nil, err
+	}
+	defer auth.Close()
+
+	r := bufio.NewReader(conn)
+	var challenge []byte
+	for {
+		token, err := auth.Next(challenge)
+		if err != nil {
+			return nil, fmt.Errorf("proxy authentication: %w", err)
+		}
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Opaque: addr},
+			Host:   addr,
+			Header: http.Header{
+				"Proxy-Connection": {"Keep-Alive"},
+			},
+		}
+		if token != nil {
+			req.Header.Set("Proxy-Authorization", auth.Scheme()+" "+base64.StdEncoding.EncodeToString(token))
+		}
+		if err := req.Write(conn); err != nil {
+			return nil, err
+		}
+		resp, err := http.ReadResponse(r, req)
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode == http.StatusOK {
+			return &bufferedConn{Conn: conn, r: r}, nil
+		}
+		// Drain the body to read the next response on the connection
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		challenge = proxyChallenge(resp, auth.Scheme())
+		if resp.StatusCode != http.StatusProxyAuthRequired || challenge == nil {
+			return nil, fmt.Errorf("proxy refused CONNECT %s: %s", addr, resp.Status)
+		}
+	}
+}
+
+// proxyChallenge returns the challenge of scheme in the proxy's response,
+// or nil when it has none.
+func proxyChallenge(resp *http.Response, scheme string) []byte {
+	for _, header := range resp.Header.Values("Proxy-Authenticate") {
+		name, value, _ := strings.Cut(header, " ")
+		if !strings.EqualFold(name, scheme) || value == "" {
+			continue
+		}
+		if token, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value)); err == nil {
+			return token
+		}
+	}
+	return nil
+}
+
+// bufferedConn reads what the proxy sent after its response before the
+// rest of the connection.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
@@ -0,0 +1,236 @@
+package proxyauth
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMD4(t *testing.T) {
+	for in, want := range map[string]string{
+		"":                              "31d6cfe0d16ae931b73c59d7e0c089c0",
+		"abc":                           "a448017aaf21d8525fc10ae87aa6729d",
+		strings.Repeat("1234567890", 8): "e33b4ddc9c38f2199c3e7b164fcc0536",
+	} {
+		if sum := md4([]byte(in)); hex.EncodeToString(sum[:]) != want {
+			t.Errorf("md4(%q) = %x, want %s", in, sum, want)
+		}
+	}
+}
+
+// avPairs encodes the target info of the MS-NLMP 4.2.4 example.
+func avPairs() []byte {
+	var info []byte
+	for _, pair := range []struct {
+		id    uint16
+		value string
+	}{{2, "Domain"}, {1, "Server"}} {
+		value := utf16le(pair.value)
+		info = binary.LittleEndian.AppendUint16(info, pair.id)
+		info = binary.LittleEndian.AppendUint16(info, uint16(len(value)))
+		info = append(info, value...)
+	}
+	return append(info, 0, 0, 0, 0)
+}
+
+func TestNTLMv2(t *testing.T) {
+	// The NTLMv2 example of MS-NLMP 4.2.4
+	if key := ntowfv2("User", "Password", "Domain"); hex.EncodeToString(key) != "0c868a403bfd7a93a3001ef22ef02e3f" {
+		t.Fatalf("unexpected NTOWFv2 %x", key)
+	}
+	n := newNTLM(`Domain\User`, "Password")
+	serverChallenge, _ := hex.DecodeString("0123456789abcdef")
+	clientChallenge := bytes.Repeat([]byte{0xaa}, 8)
+	nt, lm := n.responses(serverChallenge, clientChallenge, 0, avPairs())
+	if got := hex.EncodeToString(nt[:16]); got != "68cd0ab851e51c96aabc927bebef6a1c" {
+		t.Errorf("unexpected NTProofStr %s", got)
+	}
+	if got := hex.EncodeToString(lm); got != "86c35097ac9cec102554764a57cccc19aaaaaaaaaaaaaaaa" {
+		t.Errorf("unexpected LMv2 response %s", got)
+	}
+}
+
+// challenge returns a challenge message with the server challenge and the
+// example target info.
+func challenge(serverChallenge []byte) []byte {
+	info := avPairs()
+	msg := append([]byte(nil), ntlmSignature...)
+	msg = binary.LittleEndian.AppendUint32(msg, 2)
+	msg = append(msg, make([]byte, 8)...) // empty target name
+	msg = binary.LittleEndian.AppendUint32(msg, ntlmFlags)
+	msg = append(msg, serverChallenge...)
+	msg = append(msg, make([]byte, 8)...)
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(info)))
+	msg = binary.LittleEndian.AppendUint16(msg, uint16(len(info)))
+	msg = binary.LittleEndian.AppendUint32(msg, 48)
+	return append(msg, info...)
+}
+
+// ntlmProxy accepts a CONNECT once the NTLM exchange verifies the password,
+// then echoes the tunnel.
+func ntlmProxy(t *testing.T, password string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		serverChallenge := []byte("12345678")
+		for _, want := range []uint32{1, 3} {
+			req, err := http.ReadRequest(r)
+			if err != nil || req.Method != http.MethodConnect {
+				return
+			}
+			scheme, token, _ := strings.Cut(req.Header.Get("Proxy-Authorization"), " ")
+			msg, _ := base64.StdEncoding.DecodeString(token)
+			if scheme != "NTLM" || len(msg) < 12 || binary.LittleEndian.Uint32(msg[8:]) != want {
+				io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+			if want == 1 {
+				io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: NTLM "+
+					base64.StdEncoding.EncodeToString(challenge(serverChallenge))+"\r\nContent-Length: 4\r\n\r\ndeny")
+				continue
+			}
+			// Check the NTProofStr against the known password
+			nt, _ := field(msg, 20)
+			domain, _ := field(msg, 28)
+			user, _ := field(msg, 36)
+			if !bytes.Equal(domain, utf16le("CORP")) || !bytes.Equal(user, utf16le("alice")) {
+				io.WriteString(conn, "HTTP/1.1 403 Forbidden\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+			key := ntowfv2("alice", password, "CORP")
+			if !bytes.Equal(hmacMD5(key, serverChallenge, nt[16:]), nt[:16]) {
+				io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nContent-Length: 0\r\n\r\n")
+				return
+			}
+			io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+			io.Copy(conn, r)
+		}
+	}()
+	return ln
+}
+
+func TestDialer(t *testing.T) {
+	for _, tc := range []struct {
+		password string
+		ok       bool
+	}{{"secret", true}, {"wrong", false}} {
+		ln := ntlmProxy(t, "secret")
+		defer ln.Close()
+		proxy := &url.URL{Scheme: "http", Host: ln.Addr().String(), User: url.UserPassword(`CORP\alice`, tc.password)}
+		d, err := New(proxy, "ntlm", (&net.Dialer{}).DialContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		conn, err := d.DialContext(ctx, "tcp", "example.com:443")
+		cancel()
+		if !tc.ok {
+			if err == nil {
+				t.Fatal("expected a wrong password to be refused")
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, "ping")
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+			t.Fatalf("tunnel echoed %q, %v", buf, err)
+		}
+		conn.Close()
+	}
+
+	if _, err := New(&url.URL{Scheme: "https", Host: "proxy:443"}, "ntlm", nil); err == nil {
+		t.Fatal("expected an error for an https proxy")
+	}
+	if _, err := New(&url.URL{Scheme: "http", Host: "proxy:8080"}, "digest", nil); err == nil {
+		t.Fatal("expected an error for an unknown scheme")
+	}
+}
+
+// basicProxy accepts a CONNECT carrying the Proxy-Authorization header want,
+// then echoes the tunnel.
+func basicProxy(t *testing.T, want string) net.Listener {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		req, err := http.ReadRequest(r)
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != want {
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\nContent-Length: 0\r\n\r\n")
+			return
+		}
+		io.WriteString(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		io.Copy(conn, r)
+	}()
+	return ln
+}
+
+func TestDialer_Basic(t *testing.T) {
+	want := "Basic " + base64.StdEncoding.EncodeToString([]byte("alice:secret"))
+	for _, tc := range []struct {
+		user *url.Userinfo
+		want string
+		ok   bool
+	}{
+		{url.UserPassword("alice", "secret"), want, true},
+		{url.UserPassword("alice", "wrong"), want, false},
+		{nil, "", true},
+		{nil, want, false},
+	} {
+		ln := basicProxy(t, tc.want)
+		defer ln.Close()
+		proxy := &url.URL{Scheme: "http", Host: ln.Addr().String(), User: tc.user}
+		d, err := New(proxy, "", (&net.Dialer{}).DialContext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		conn, err := d.DialContext(ctx, "tcp", "example.com:443")
+		cancel()
+		if !tc.ok {
+			if err == nil {
+				t.Fatalf("expected %v to be refused", tc.user)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, "ping")
+		buf := make([]byte, 4)
+		if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "ping" {
+			t.Fatalf("tunnel echoed %q, %v", buf, err)
+		}
+		conn.Close()
+	}
+}
@@ -0,0 +1,133 @@
+//go:build windows
+
+package proxyauth
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	secur32                        = syscall.NewLazyDLL("secur32.dll")
+	procAcquireCredentialsHandleW  = secur32.NewProc("AcquireCredentialsHandleW")
+	procInitializeSecurityContextW = secur32.NewProc("InitializeSecurityContextW")
+	procCompleteAuthToken          = secur32.NewProc("CompleteAuthToken")
+	procDeleteSecurityContext      = secur32.NewProc("DeleteSecurityContext")
+	procFreeCredentialsHandle      = secur32.NewProc("FreeCredentialsHandle")
+	procFreeContextBuffer          = secur32.NewProc("FreeContextBuffer")
+)
+
+// SSPI constants (sspi.h)
+const (
+	secpkgCredOutbound      = 2
+	securityNativeDrep      = 0x10
+	secbufferVersion        = 0
+	secbufferToken          = 2
+	iscReqAllocateMemory    = 0x100
+	iscReqConnection        = 0x800
+	secEOK                  = 0
+	secIContinueNeeded      = 0x00090312
+	secICompleteNeeded      = 0x00090313
+	secICompleteAndContinue = 0x00090314
+)
+
+type secHandle struct {
+	lower, upper uintptr
+}
+
+type secBuffer struct {
+	size   uint32
+	typ    uint32
+	buffer *byte
+}
+
+type secBufferDesc struct {
+	version uint32
+	count   uint32
+	buffers *secBuffer
+}
+
+// sspi authenticates with the credentials of the logged-on user through
+// the Windows security support provider of a package, "NTLM" or
+// "Negotiate".
+type sspi struct {
+	pkg     string
+	target  *uint16 // service principal name of the proxy
+	cred    secHandle
+	ctx     secHandle
+	started bool
+}
+
+// newSSPI acquires the logged-on user's credentials for pkg to
+// authenticate to the proxy at host.
+func newSSPI(pkg, host string) (Authenticator, error) {
+	name, err := syscall.UTF16PtrFromString(pkg)
+	if err != nil {
+		return nil, err
+	}
+	target, err := syscall.UTF16PtrFromString("HTTP/" + host)
+	if err != nil {
+		return nil, err
+	}
+	s := &sspi{pkg: pkg, target: target}
+	var expiry int64
+	ret, _, _ := procAcquireCredentialsHandleW.Call(
+		0, uintptr(unsafe.Pointer(name)), secpkgCredOutbound, 0, 0, 0, 0,
+		uintptr(unsafe.Pointer(&s.cred)), uintptr(unsafe.Pointer(&expiry)))
+	if ret != secEOK {
+		return nil, fmt.Errorf("%s: AcquireCredentialsHandle failed with 0x%x", pkg, ret)
+	}
+	return s, nil
+}
+
+func (s *sspi) Scheme() string { return s.pkg }
+
+// Next returns the next token of the security context.
+func (s *sspi) Next(challenge []byte) ([]byte, error) {
+	var in *secBufferDesc
+	var ctx *secHandle
+	if s.started {
+		if len(challenge) == 0 {
+			return nil, fmt.Errorf("%s: empty challenge", s.pkg)
+		}
+		ctx = &s.ctx
+		in = &secBufferDesc{
+			version: secbufferVersion,
+			count:   1,
+			buffers: &secBuffer{size: uint32(len(challenge)), typ: secbufferToken, buffer: &challenge[0]},
+		}
+	}
+	outBuf := secBuffer{typ: secbufferToken}
+	out := secBufferDesc{version: secbufferVersion, count: 1, buffers: &outBuf}
+	var attrs uint32
+	var expiry int64
+	ret, _, _ := procInitializeSecurityContextW.Call(
+		uintptr(unsafe.Pointer(&s.cred)), uintptr(unsafe.Pointer(ctx)), uintptr(unsafe.Pointer(s.target)),
+		iscReqAllocateMemory|iscReqConnection, 0, securityNativeDrep, uintptr(unsafe.Pointer(in)), 0,
+		uintptr(unsafe.Pointer(&s.ctx)), uintptr(unsafe.Pointer(&out)),
+		uintptr(unsafe.Pointer(&attrs)), uintptr(unsafe.Pointer(&expiry)))
+	s.started = true
+	switch ret {
+	case secICompleteNeeded, secICompleteAndContinue:
+		if r, _, _ := procCompleteAuthToken.Call(uintptr(unsafe.Pointer(&s.ctx)), uintptr(unsafe.Pointer(&out))); r != secEOK {
+			return nil, fmt.Errorf("%s: CompleteAuthToken failed with 0x%x", s.pkg, r)
+		}
+	case secEOK, secIContinueNeeded:
+	default:
+		return nil, fmt.Errorf("%s: InitializeSecurityContext failed with 0x%x", s.pkg, ret)
+	}
+	if outBuf.buffer == nil {
+		return nil, nil
+	}
+	defer procFreeContextBuffer.Call(uintptr(unsafe.Pointer(outBuf.buffer)))
+	return append([]byte(nil), unsafe.Slice(outBuf.buffer, outBuf.size)...), nil
+}
+
+// Close releases the security context and credentials.
+func (s *sspi) Close() {
+	if s.started {
+		procDeleteSecurityContext.Call(uintptr(unsafe.Pointer(&s.ctx)))
+	}
+	procFreeCredentialsHandle.Call(uintptr(unsafe.Pointer(&s.cred)))
+}
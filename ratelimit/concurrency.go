@@ -0,0 +1,43 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Concurrency implements the pinger.Budget interface
+var _ pinger.Budget = (*Concurrency)(nil)
+
+// Concurrency bounds how many probes of all targets are in flight at once,
+// the pinger.Budget shared by their Pingers. Probes beyond the bound wait
+// for one in flight to return, so a sweep of thousands of targets holds no
+// more sockets than the bound, however slow the targets are.
+type Concurrency struct {
+	slots chan struct{}
+}
+
+// NewConcurrency creates a Concurrency for n probes in flight.
+func NewConcurrency(n int) (*Concurrency, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("invalid concurrency %d, want at least 1 probe in flight", n)
+	}
+	return &Concurrency{slots: make(chan struct{}, n)}, nil
+}
+
+// Wait blocks until fewer probes than the bound are in flight or ctx is
+// done, and takes a slot for the probe.
+func (c *Concurrency) Wait(ctx context.Context) error {
+	select {
+	case c.slots <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Spend gives back the slot of a returned probe.
+func (c *Concurrency) Spend(stats *pinger.Stats) {
+	<-c.slots
+}
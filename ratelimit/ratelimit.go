@@ -0,0 +1,71 @@
+// Package ratelimit caps how many probes start per second, so that
+// back-to-back probing for load-style latency sampling stays at a set rate,
+// and how many are in flight at once, so that sweeps of many targets stay
+// within the file descriptors of the process.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Limiter implements the pinger.Budget interface
+var _ pinger.Budget = (*Limiter)(nil)
+
+// Limiter is a token bucket of probes holding a single token, the
+// pinger.Budget shared by the Pingers of all targets. Probe starts are
+// spaced evenly at the rate rather than sent in bursts.
+type Limiter struct {
+	period time.Duration // between two probe starts
+
+	mu   sync.Mutex
+	next time.Time // when the next probe may start
+	now  func() time.Time
+}
+
+// New creates a Limiter for perSecond probes per second.
+func New(perSecond float64) (*Limiter, error) {
+	if perSecond <= 0 || perSecond > 1e6 {
+		return nil, fmt.Errorf("invalid rate %v, want more than 0 and at most 1000000 probes per second", perSecond)
+	}
+	return &Limiter{
+		period: time.Duration(float64(time.Second) / perSecond),
+		now:    time.Now,
+	}, nil
+}
+
+// reserve takes the next slot and returns how long until it starts.
+func (l *Limiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	delay := l.next.Sub(now)
+	l.next = l.next.Add(l.period)
+	return delay
+}
+
+// Wait blocks until the next slot of the Limiter or ctx is done.
+func (l *Limiter) Wait(ctx context.Context) error {
+	d := l.reserve()
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Spend does nothing, as every probe costs one slot taken by Wait.
+func (l *Limiter) Spend(stats *pinger.Stats) {}
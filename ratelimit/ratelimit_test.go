@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Unix(0, 0)
+	l, err := New(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.now = func() time.Time { return now }
+
+	// Starts are spaced 10ms apart, and idle time saves up no burst
+	for i, want := range []time.Duration{0, 10 * time.Millisecond, 20 * time.Millisecond} {
+		if d := l.reserve(); d != want {
+			t.Fatalf("reservation %d: expected %s, got %s", i, want, d)
+		}
+	}
+	now = now.Add(time.Hour)
+	if d := l.reserve(); d != 0 {
+		t.Fatalf("expected no delay after idling, got %s", d)
+	}
+	if d := l.reserve(); d != 10*time.Millisecond {
+		t.Fatalf("expected a 10ms delay after idling, got %s", d)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.Wait(ctx); err == nil {
+		t.Fatal("expected Wait to stop with the context")
+	}
+
+	for _, rate := range []float64{0, -1, 2e6} {
+		if _, err := New(rate); err == nil {
+			t.Errorf("New(%v) succeeded", rate)
+		}
+	}
+}
+
+func TestConcurrency(t *testing.T) {
+	c, err := NewConcurrency(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if err := c.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Wait(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// A third probe waits for one of the two in flight
+	short, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	if err := c.Wait(short); err == nil {
+		t.Fatal("expected Wait to block beyond the bound")
+	}
+	c.Spend(nil)
+	if err := c.Wait(ctx); err != nil {
+		t.Fatalf("expected a slot once a probe returned, got %v", err)
+	}
+
+	if _, err := NewConcurrency(0); err == nil {
+		t.Error("NewConcurrency(0) succeeded")
+	}
+}
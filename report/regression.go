@@ -0,0 +1,127 @@
+package report
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/stats"
+	"github.com/circle-protocol/circle-pinger/utils"
+)
+
+// Metrics are the statistics compared by default.
+var Metrics = []string{"loss", "min", "avg", "p95", "p99"}
+
+// Value returns a metric of s: the loss as a fraction, and the others,
+// min, avg, max or a percentile such as p95, in nanoseconds. ok is false
+// for latency metrics without successful probes.
+func Value(s *stats.Sample, metric string) (v float64, ok bool) {
+	if metric == "loss" {
+		return s.Loss(), true
+	}
+	if len(s.Durations) == 0 {
+		return 0, false
+	}
+	var d time.Duration
+	switch metric {
+	case "min":
+		d = s.Percentile(0)
+	case "avg":
+		d = s.Mean()
+	case "max":
+		d = s.Percentile(100)
+	default:
+		p, _ := strconv.ParseFloat(strings.TrimPrefix(metric, "p"), 64)
+		d = s.Percentile(p)
+	}
+	return float64(d), true
+}
+
+// Limit bounds how much a metric may regress, that is grow, between two
+// sessions: by a share of its value before, or by an amount, percentage
+// points for the loss and a duration otherwise.
+type Limit struct {
+	Metric   string
+	Relative bool    // the limit is a share of the value before
+	Max      float64 // fraction, or nanoseconds for latency amounts
+	text     string
+}
+
+// String returns the limit as given, such as p95+20%.
+func (l Limit) String() string {
+	return l.text
+}
+
+// limitPattern matches a single limit such as p95+20% or avg+5ms.
+var limitPattern = regexp.MustCompile(`^(loss|avg|min|max|p[0-9]+(?:\.[0-9]+)?)\+(\S+)$`)
+
+// ParseLimits parses a comma-separated list of limits such as
+// "loss+1%,p95+20%,avg+5ms". The loss grows by percentage points, and
+// latency metrics by a percentage of their value before or a duration.
+func ParseLimits(spec string) ([]Limit, error) {
+	var limits []Limit
+	for _, s := range strings.Split(spec, ",") {
+		s = strings.TrimSpace(s)
+		m := limitPattern.FindStringSubmatch(s)
+		if m == nil {
+			return nil, fmt.Errorf(`invalid limit %q, want a metric (loss, min, avg, max, p95...), "+" and how much it may grow, such as "p95+20%%" or "avg+5ms"`, s)
+		}
+		l := Limit{Metric: m[1], text: s}
+		if p, err := strconv.ParseFloat(strings.TrimPrefix(l.Metric, "p"), 64); strings.HasPrefix(l.Metric, "p") && (err != nil || p <= 0 || p > 100) {
+			return nil, fmt.Errorf("invalid limit %q, want a percentile above p0 and at most p100", s)
+		}
+		if pct, ok := strings.CutSuffix(m[2], "%"); ok {
+			v, err := strconv.ParseFloat(pct, 64)
+			if err != nil || v < 0 {
+				return nil, fmt.Errorf("invalid limit %q, want a positive percentage", s)
+			}
+			l.Max, l.Relative = v/100, l.Metric != "loss"
+		} else {
+			if l.Metric == "loss" {
+				return nil, fmt.Errorf("invalid limit %q, want the loss growth in percentage points such as loss+1%%", s)
+			}
+			d, err := utils.ParseDuration(m[2])
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid limit %q, want a percentage or a duration", s)
+			}
+			l.Max = float64(d)
+		}
+		limits = append(limits, l)
+	}
+	return limits, nil
+}
+
+// Exceeded reports whether the metric grew from before to after by more
+// than the limit. A latency metric without successful probes in either
+// session cannot be compared and does not exceed it.
+func (l Limit) Exceeded(before, after *stats.Sample) bool {
+	b, okBefore := Value(before, l.Metric)
+	a, okAfter := Value(after, l.Metric)
+	if !okBefore || !okAfter {
+		return false
+	}
+	if l.Relative {
+		if b == 0 {
+			return a > 0
+		}
+		return a/b-1 > l.Max+1e-9
+	}
+	return a-b > l.Max+1e-9*math.Max(1, l.Max)
+}
+
+// Regressions returns the limits the change exceeds.
+func (c *Change) Regressions(limits []Limit) []Limit {
+	if c.Before == nil || c.After == nil {
+		return nil
+	}
+	var exceeded []Limit
+	for _, l := range limits {
+		if l.Exceeded(c.Before, c.After) {
+			exceeded = append(exceeded, l)
+		}
+	}
+	return exceeded
+}
@@ -0,0 +1,116 @@
+// Package report analyses sessions recorded with --record, the JSON lines
+// of probe records written by the record sink.
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// Session holds the results of a recorded session per target.
+type Session map[string]*stats.Sample
+
+// Load reads the session recorded in the file at path.
+func Load(path string) (Session, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	session, err := Read(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return session, nil
+}
+
+// Read reads a recorded session from r.
+func Read(r io.Reader) (Session, error) {
+	session := make(Session)
+	err := Records(r, func(record *pinger.Record) error {
+		s, ok := session[record.Target]
+		if !ok {
+			s = &stats.Sample{}
+			session[record.Target] = s
+		}
+		s.Add(record.Stats.Connected, record.Stats.Duration)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Records calls fn with every record of the session recorded in r, in the
+// order they were recorded, until fn returns an error.
+func Records(r io.Reader, fn func(record *pinger.Record) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record pinger.Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(&record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// Change is the comparison of one target between two sessions. Before or
+// After is nil when the target is missing from that session.
+type Change struct {
+	Target string
+	Before *stats.Sample
+	After  *stats.Sample
+
+	// LatencyP and LossP are the p-values of the latency and loss changes
+	LatencyP float64
+	LossP    float64
+}
+
+// Diff compares the targets of two sessions, sorted by target.
+func Diff(before, after Session) []*Change {
+	targets := make(map[string]bool)
+	for target := range before {
+		targets[target] = true
+	}
+	for target := range after {
+		targets[target] = true
+	}
+
+	changes := make([]*Change, 0, len(targets))
+	for target := range targets {
+		c := &Change{Target: target, Before: before[target], After: after[target], LatencyP: 1, LossP: 1}
+		if c.Before != nil && c.After != nil {
+			c.LatencyP = stats.MannWhitney(c.Before.Durations, c.After.Durations)
+			c.LossP = stats.TwoProportion(c.Before.Failed, c.Before.Total, c.After.Failed, c.After.Total)
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Target < changes[j].Target })
+	return changes
+}
+
+// LatencyShift returns the change of the median latency.
+func (c *Change) LatencyShift() time.Duration {
+	return c.After.Percentile(50) - c.Before.Percentile(50)
+}
+
+// LossShift returns the change of the loss rate, from -1 to 1.
+func (c *Change) LossShift() float64 {
+	return c.After.Loss() - c.Before.Loss()
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+func TestDiff(t *testing.T) {
+	var before, after strings.Builder
+	for i := 0; i < 30; i++ {
+		before.WriteString(`{"target":"tcp://a:80","seq":1,"connected":true,"duration_ms":10}` + "\n")
+		after.WriteString(`{"target":"tcp://a:80","seq":1,"connected":true,"duration_ms":30}` + "\n")
+		before.WriteString(`{"target":"tcp://b:80","seq":1,"connected":true,"duration_ms":10}` + "\n")
+		after.WriteString(`{"target":"tcp://b:80","seq":1,"connected":true,"duration_ms":10}` + "\n")
+	}
+	after.WriteString(`{"target":"tcp://c:80","seq":1,"connected":false,"duration_ms":1}` + "\n")
+
+	b, err := Read(strings.NewReader(before.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := Read(strings.NewReader(after.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := Diff(b, a)
+	if len(changes) != 3 {
+		t.Fatalf("expected 3 targets, got %d", len(changes))
+	}
+	if c := changes[0]; c.LatencyP > 0.001 || c.LatencyShift().Milliseconds() != 20 {
+		t.Fatalf("a should be significantly slower, p = %v shift = %s", c.LatencyP, c.LatencyShift())
+	}
+	if c := changes[1]; c.LatencyP < 0.05 {
+		t.Fatalf("b should be unchanged, p = %v", c.LatencyP)
+	}
+	if c := changes[2]; c.Before != nil || c.After == nil {
+		t.Fatal("c should only be in the after session")
+	}
+}
+
+func TestRegressions(t *testing.T) {
+	sample := func(failed int, ms ...int) *stats.Sample {
+		s := &stats.Sample{}
+		for i := 0; i < failed; i++ {
+			s.Add(false, 0)
+		}
+		for _, d := range ms {
+			s.Add(true, time.Duration(d)*time.Millisecond)
+		}
+		return s
+	}
+	limits, err := ParseLimits("loss+1%, p95+20%,avg+5ms")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		name          string
+		before, after *stats.Sample
+		want          string
+	}{
+		{"unchanged", sample(0, 10, 10, 10, 10), sample(0, 10, 10, 10, 10), ""},
+		{"within limits", sample(0, 10, 10, 10, 10), sample(0, 11, 11, 11, 12), ""},
+		{"more loss", sample(0, 10, 10, 10, 10), sample(1, 10, 10, 10), "loss+1%"},
+		{"slower tail", sample(0, 10, 10, 10, 10), sample(0, 10, 10, 10, 13), "p95+20%"},
+		{"slower", sample(0, 10, 10, 10, 10), sample(0, 16, 16, 16, 16), "p95+20%,avg+5ms"},
+		{"all lost", sample(0, 10), sample(4), "loss+1%"},
+	} {
+		var got []string
+		for _, l := range (&Change{Before: test.before, After: test.after}).Regressions(limits) {
+			got = append(got, l.String())
+		}
+		if strings.Join(got, ",") != test.want {
+			t.Errorf("%s: expected regressions %q, got %q", test.name, test.want, got)
+		}
+	}
+
+	for _, spec := range []string{"", "loss", "loss+5ms", "jitter+1ms", "p0+1ms", "p101+1ms", "avg+-1ms", "avg+x%"} {
+		if _, err := ParseLimits(spec); err == nil {
+			t.Errorf("expected an error for %q", spec)
+		}
+	}
+}
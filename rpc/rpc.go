@@ -0,0 +1,191 @@
+// Package rpc provides ONC RPC ping functionality for the circle-pinger tool.
+// Each probe asks the portmapper for the port of a program (NFS by default)
+// and then calls the program's NULL procedure on that port, which catches
+// services that accept TCP connections but no longer answer calls.
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+const (
+	// PortmapperPort is the well-known portmapper port.
+	PortmapperPort = 111
+
+	portmapperProgram = 100000
+	portmapperVersion = 2
+	procNull          = 0
+	procGetPort       = 3
+	protoTCP          = 6
+)
+
+// Program identifies an RPC program and version.
+type Program struct {
+	Number  uint32
+	Version uint32
+}
+
+// programs maps well-known program names to their number and usual version.
+var programs = map[string]Program{
+	"portmapper": {portmapperProgram, portmapperVersion},
+	"nfs":        {100003, 3},
+	"mountd":     {100005, 3},
+	"nlockmgr":   {100021, 4},
+	"status":     {100024, 1},
+}
+
+// DefaultProgram is the program probed when none is given: NFS version 3.
+var DefaultProgram = programs["nfs"]
+
+// ErrNotRegistered is returned when the portmapper has no port for the program.
+var ErrNotRegistered = errors.New("program not registered with the portmapper")
+
+// ParseProgram parses a program name such as "nfs" or a program number.
+// version overrides the usual version of the program when not zero.
+func ParseProgram(name string, version uint32) (Program, error) {
+	prog, ok := programs[strings.ToLower(name)]
+	if !ok {
+		n, err := strconv.ParseUint(name, 10, 32)
+		if err != nil {
+			return Program{}, fmt.Errorf("unknown RPC program %q", name)
+		}
+		prog = Program{Number: uint32(n), Version: 1}
+	}
+	if version != 0 {
+		prog.Version = version
+	}
+	return prog, nil
+}
+
+// New creates a new RPC Ping instance querying the portmapper at host:port.
+func New(host string, port int, op *pinger.Option, prog Program) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{
+		host:    host,
+		port:    port,
+		program: prog,
+		option:  op,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+}
+
+// Ping is the RPC ping implementation.
+type Ping struct {
+	option  *pinger.Option
+	host    string
+	port    int
+	program Program
+	dialer  *net.Dialer
+}
+
+// Ping looks up the program's port and calls its NULL procedure.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+	stats.Meta["program"] = pinger.StringerFunc(func() string {
+		return fmt.Sprintf("%d.v%d", p.program.Number, p.program.Version)
+	})
+
+	start := time.Now()
+	defer func() { stats.Duration = time.Since(start) }()
+
+	// Ask the portmapper where the program listens
+	var port uint32
+	addr, err := p.call(ctx, p.port, portmapperProgram, portmapperVersion, procGetPort, func(args []byte) []byte {
+		args = appendUint32(args, p.program.Number)
+		args = appendUint32(args, p.program.Version)
+		args = appendUint32(args, protoTCP)
+		return appendUint32(args, 0)
+	}, func(result []byte) error {
+		if len(result) < 4 {
+			return errors.New("short GETPORT result")
+		}
+		port = getUint32(result)
+		return nil
+	})
+	stats.Address = addr
+	if err != nil {
+		stats.Error = fmt.Errorf("portmapper: %w", err)
+		return stats
+	}
+	stats.Meta["getport"] = time.Since(start)
+	stats.Meta["registered"] = pinger.StringerFunc(func() string { return strconv.FormatBool(port != 0) })
+	if port == 0 {
+		stats.Error = ErrNotRegistered
+		return stats
+	}
+	stats.Meta["port"] = pinger.StringerFunc(func() string { return strconv.Itoa(int(port)) })
+
+	// Call the program itself
+	nullStart := time.Now()
+	_, err = p.call(ctx, int(port), p.program.Number, p.program.Version, procNull, nil, nil)
+	if err != nil {
+		stats.Error = fmt.Errorf("NULL call: %w", err)
+		return stats
+	}
+	stats.Meta["null"] = time.Since(nullStart)
+	stats.Connected = true
+	return stats
+}
+
+// call connects to port and makes one RPC call, returning the remote address.
+// args appends the call arguments, result parses the reply; either may be nil.
+func (p *Ping) call(ctx context.Context, port int, prog, vers, proc uint32, args func([]byte) []byte, result func([]byte) error) (string, error) {
+	conn, err := p.dialer.DialContext(ctx, "tcp", net.JoinHostPort(p.host, strconv.Itoa(port)))
+	if err != nil {
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			return oe.Addr.String(), err
+		}
+		return "", err
+	}
+	defer conn.Close()
+	addr := conn.RemoteAddr().String()
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	xid := uint32(time.Now().UnixNano())
+	msg := marshalCall(xid, prog, vers, proc)
+	if args != nil {
+		msg = args(msg)
+	}
+	if err := writeRecord(conn, msg); err != nil {
+		return addr, err
+	}
+	reply, err := readRecord(conn)
+	if err != nil {
+		return addr, err
+	}
+	body, err := parseReply(xid, reply)
+	if err != nil || result == nil {
+		return addr, err
+	}
+	return addr, result(body)
+}
@@ -0,0 +1,117 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+// serveRPC answers every call on a listener with results, or with an accept
+// status of stat when it is not zero.
+func serveRPC(t *testing.T, stat uint32, results func() []byte) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			call, err := readRecord(conn)
+			if err == nil {
+				reply := appendUint32(nil, getUint32(call))
+				for _, v := range []uint32{msgReply, replyAccepted, 0, 0, stat} {
+					reply = appendUint32(reply, v)
+				}
+				if stat == acceptSuccess && results != nil {
+					reply = append(reply, results()...)
+				}
+				writeRecord(conn, reply)
+			}
+			conn.Close()
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestPing(t *testing.T) {
+	nfs := serveRPC(t, acceptSuccess, nil)
+	portmapper := serveRPC(t, acceptSuccess, func() []byte { return appendUint32(nil, uint32(nfs)) })
+
+	stats := New("127.0.0.1", portmapper, &pinger.Option{Timeout: time.Second}, DefaultProgram).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["registered"].String(); got != "true" {
+		t.Fatalf("registered = %s", got)
+	}
+}
+
+func TestPing_NotRegistered(t *testing.T) {
+	portmapper := serveRPC(t, acceptSuccess, func() []byte { return appendUint32(nil, 0) })
+
+	stats := New("127.0.0.1", portmapper, &pinger.Option{Timeout: time.Second}, DefaultProgram).Ping(context.Background())
+	if stats.Connected || !errors.Is(stats.Error, ErrNotRegistered) {
+		t.Fatalf("expected ErrNotRegistered, got %v", stats.Error)
+	}
+}
+
+func TestPing_Unavailable(t *testing.T) {
+	nfs := serveRPC(t, 1, nil)
+	portmapper := serveRPC(t, acceptSuccess, func() []byte { return appendUint32(nil, uint32(nfs)) })
+
+	stats := New("127.0.0.1", portmapper, &pinger.Option{Timeout: time.Second}, DefaultProgram).Ping(context.Background())
+	if stats.Connected || stats.Error == nil {
+		t.Fatal("expected the NULL call to fail")
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			nfs := serveRPC(t, acceptSuccess, nil)
+			portmapper := serveRPC(t, acceptSuccess, func() []byte { return appendUint32(nil, uint32(nfs)) })
+			return New("127.0.0.1", portmapper, &pinger.Option{Timeout: timeout}, DefaultProgram)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout}, DefaultProgram)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.NewTCPBlackhole(t).Port, &pinger.Option{Timeout: timeout}, DefaultProgram)
+		},
+	})
+}
+
+func FuzzParseReply(f *testing.F) {
+	reply := appendUint32(nil, 7)
+	for _, v := range []uint32{msgReply, replyAccepted, 0, 0, acceptSuccess, 2049} {
+		reply = appendUint32(reply, v)
+	}
+	f.Add(uint32(7), reply)
+	f.Add(uint32(7), []byte{})
+	f.Fuzz(func(t *testing.T, xid uint32, b []byte) {
+		if results, err := parseReply(xid, b); err == nil && len(results) > len(b) {
+			t.Fatalf("results longer than the reply")
+		}
+	})
+}
+
+func FuzzReadRecord(f *testing.F) {
+	var buf bytes.Buffer
+	writeRecord(&buf, []byte("call"))
+	f.Add(buf.Bytes())
+	f.Add([]byte{0x80, 0, 0, 0})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		readRecord(bytes.NewReader(b))
+	})
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	rpcVersion = 2
+
+	msgCall  = 0
+	msgReply = 1
+
+	replyAccepted = 0
+	acceptSuccess = 0
+
+	lastFragment = 1 << 31
+	maxRecord    = 1 << 20
+)
+
+// acceptErrors describes the accept_stat values other than SUCCESS.
+var acceptErrors = map[uint32]string{
+	1: "program unavailable",
+	2: "program version mismatch",
+	3: "procedure unavailable",
+	4: "garbage arguments",
+	5: "system error",
+}
+
+// marshalCall builds a call message header with AUTH_NULL credentials.
+func marshalCall(xid, prog, vers, proc uint32) []byte {
+	b := make([]byte, 0, 64)
+	for _, v := range []uint32{xid, msgCall, rpcVersion, prog, vers, proc, 0, 0, 0, 0} {
+		b = appendUint32(b, v)
+	}
+	return b
+}
+
+// parseReply checks a reply message for xid and returns its results.
+func parseReply(xid uint32, b []byte) ([]byte, error) {
+	if len(b) < 12 {
+		return nil, errors.New("short RPC reply")
+	}
+	if getUint32(b) != xid || getUint32(b[4:]) != msgReply {
+		return nil, errors.New("unexpected RPC message")
+	}
+	if getUint32(b[8:]) != replyAccepted {
+		return nil, errors.New("RPC call denied")
+	}
+	b = b[12:]
+
+	// Skip the verifier, then check the accept status
+	if len(b) < 8 {
+		return nil, errors.New("short RPC reply")
+	}
+	verf := int(getUint32(b[4:])+3) &^ 3
+	if len(b) < 8+verf+4 {
+		return nil, errors.New("short RPC reply")
+	}
+	b = b[8+verf:]
+	if stat := getUint32(b); stat != acceptSuccess {
+		if msg, ok := acceptErrors[stat]; ok {
+			return nil, errors.New(msg)
+		}
+		return nil, fmt.Errorf("RPC call failed with status %d", stat)
+	}
+	return b[4:], nil
+}
+
+// writeRecord writes msg as a single record-marked fragment.
+func writeRecord(w io.Writer, msg []byte) error {
+	b := appendUint32(make([]byte, 0, 4+len(msg)), lastFragment|uint32(len(msg)))
+	_, err := w.Write(append(b, msg...))
+	return err
+}
+
+// readRecord reads a record made of one or more fragments.
+func readRecord(r io.Reader) ([]byte, error) {
+	var record []byte
+	for {
+		var mark [4]byte
+		if _, err := io.ReadFull(r, mark[:]); err != nil {
+			return nil, err
+		}
+		header := getUint32(mark[:])
+		size := int(header &^ lastFragment)
+		if len(record)+size > maxRecord {
+			return nil, fmt.Errorf("RPC record too large")
+		}
+		fragment := make([]byte, size)
+		if _, err := io.ReadFull(r, fragment); err != nil {
+			return nil, err
+		}
+		record = append(record, fragment...)
+		if header&lastFragment != 0 {
+			return record, nil
+		}
+	}
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func getUint32(b []byte) uint32 {
+	return binary.BigEndian.Uint32(b)
+}
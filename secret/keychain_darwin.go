@@ -0,0 +1,22 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychain reads the password of the generic item with the service
+// circle-pinger and the account item from the login keychain, as added with
+// security add-generic-password -s circle-pinger -a item -w.
+func keychain(item string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", Service, "-a", item, "-w")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("security find-generic-password: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
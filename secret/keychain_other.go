@@ -0,0 +1,27 @@
+//go:build !darwin && !windows
+
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// keychain reads the item with the attributes service circle-pinger and
+// account item from the Secret Service (GNOME Keyring, KWallet), as stored
+// with secret-tool store --label=item service circle-pinger account item.
+func keychain(item string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", Service, "account", item)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("secret-tool lookup: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("secret-tool lookup: %v", err)
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
@@ -0,0 +1,59 @@
+//go:build windows
+
+package secret
+
+import (
+	"fmt"
+	"syscall"
+	"unicode/utf16"
+	"unsafe"
+)
+
+var (
+	advapi32      = syscall.NewLazyDLL("advapi32.dll")
+	procCredReadW = advapi32.NewProc("CredReadW")
+	procCredFree  = advapi32.NewProc("CredFree")
+)
+
+// credTypeGeneric is CRED_TYPE_GENERIC (wincred.h).
+const credTypeGeneric = 1
+
+// credential is CREDENTIALW (wincred.h).
+type credential struct {
+	flags              uint32
+	typ                uint32
+	targetName         *uint16
+	comment            *uint16
+	lastWritten        [2]uint32
+	credentialBlobSize uint32
+	credentialBlob     *byte
+	persist            uint32
+	attributeCount     uint32
+	attributes         uintptr
+	targetAlias        *uint16
+	userName           *uint16
+}
+
+// keychain reads the password of the generic credential circle-pinger:item
+// from the Credential Manager, as added with
+// cmdkey /generic:circle-pinger:item /user:circle-pinger /pass.
+func keychain(item string) (string, error) {
+	target, err := syscall.UTF16PtrFromString(Service + ":" + item)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	ret, _, callErr := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred)))
+	if ret == 0 {
+		return "", fmt.Errorf("CredRead %s:%s: %v", Service, item, callErr)
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	// cmdkey stores passwords as UTF-16
+	blob := unsafe.Slice(cred.credentialBlob, cred.credentialBlobSize)
+	chars := make([]uint16, len(blob)/2)
+	for i := range chars {
+		chars[i] = uint16(blob[2*i]) | uint16(blob[2*i+1])<<8
+	}
+	return string(utf16.Decode(chars)), nil
+}
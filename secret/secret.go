@@ -0,0 +1,245 @@
+// Package secret resolves the credentials circle-pinger is given as
+// references rather than in the clear, and redacts them from its output.
+//
+// A reference is secret://name, anywhere in a proxy URL, a target URL, a
+// webhook URL or a discovery token, such as
+// http://user:secret://proxy-pass@proxy:8080. Each name is read from the
+// source it is defined with: an environment variable, a file or the keychain
+// of the operating system. A name defined nowhere is read from the
+// environment variable CIRCLE_PINGER_SECRET_<NAME>.
+//
+// Every value a Store resolves is replaced by its reference in the text
+// passed to Redact and written through Writer, so that secrets do not end up
+// in probe output, logs or dry-run plans.
+package secret
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Scheme prefixes secret references.
+const Scheme = "secret://"
+
+// Service is the service, or target name prefix on Windows, of the keychain
+// items secrets are read from.
+const Service = "circle-pinger"
+
+// EnvPrefix prefixes the environment variable a name defined nowhere is
+// read from.
+const EnvPrefix = "CIRCLE_PINGER_SECRET_"
+
+// minRedact is the shortest value that is redacted. Shorter values are
+// still used, but replacing them would mangle unrelated output.
+const minRedact = 4
+
+// refPattern matches the references in a text, and namePattern a name.
+var (
+	refPattern  = regexp.MustCompile(`secret://([A-Za-z0-9][A-Za-z0-9_.-]*)`)
+	namePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*$`)
+)
+
+// ValidName reports whether name can be referenced as secret://name.
+func ValidName(name string) bool {
+	return namePattern.MatchString(name)
+}
+
+// EnvName returns the environment variable a name defined nowhere is read
+// from, such as CIRCLE_PINGER_SECRET_PROXY_PASS for proxy-pass.
+func EnvName(name string) string {
+	return EnvPrefix + strings.NewReplacer("-", "_", ".", "_").Replace(strings.ToUpper(name))
+}
+
+// Source is where the value of a secret is read from. Exactly one field is
+// set.
+type Source struct {
+	Env      string // the name of an environment variable
+	File     string // the path of a file, without its trailing newline
+	Keychain string // the item of the keychain, under the service circle-pinger
+}
+
+// String describes the source for messages, without its value.
+func (s Source) String() string {
+	switch {
+	case s.Env != "":
+		return "environment variable " + s.Env
+	case s.File != "":
+		return "file " + s.File
+	case s.Keychain != "":
+		return "keychain item " + s.Keychain
+	default:
+		return "no source"
+	}
+}
+
+// read returns the value of the source.
+func (s Source) read() (string, error) {
+	switch {
+	case s.Env != "":
+		v, ok := os.LookupEnv(s.Env)
+		if !ok {
+			return "", fmt.Errorf("%s is not set", s.Env)
+		}
+		return v, nil
+	case s.File != "":
+		data, err := os.ReadFile(s.File)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case s.Keychain != "":
+		return keychain(s.Keychain)
+	default:
+		return "", fmt.Errorf("no source")
+	}
+}
+
+// Store resolves secret references and remembers the values it resolved
+// for redaction. It is safe for concurrent use.
+type Store struct {
+	mu       sync.RWMutex
+	sources  map[string]Source
+	names    map[string]string // of the values resolved, by value
+	replacer *strings.Replacer // redacting values, nil when there is none
+}
+
+// NewStore creates a Store with no names defined.
+func NewStore() *Store {
+	return &Store{sources: make(map[string]Source), names: make(map[string]string)}
+}
+
+// Define sets the source of name.
+func (s *Store) Define(name string, src Source) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sources[name] = src
+}
+
+// Lookup returns the value of name, read from its source on every call so
+// that rotated secrets are picked up. Values replaced by a rotation are still
+// redacted.
+func (s *Store) Lookup(name string) (string, error) {
+	s.mu.RLock()
+	src, ok := s.sources[name]
+	s.mu.RUnlock()
+	if !ok {
+		src = Source{Env: EnvName(name)}
+	}
+	v, err := src.read()
+	if err != nil {
+		if !ok {
+			return "", fmt.Errorf("secret %q is not defined in the configuration and %s is not set", name, src.Env)
+		}
+		return "", fmt.Errorf("secret %q from %s: %w", name, src, err)
+	}
+	if v == "" {
+		return "", fmt.Errorf("secret %q from %s is empty", name, src)
+	}
+	s.remember(name, v)
+	return v, nil
+}
+
+// remember records the value of name for redaction, along with the forms it
+// takes once escaped in a URL.
+func (s *Store) remember(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.names[value]; ok || len(value) < minRedact {
+		return
+	}
+	userinfo := strings.TrimPrefix(url.UserPassword("", value).String(), ":")
+	for _, form := range []string{value, url.PathEscape(value), url.QueryEscape(value), userinfo} {
+		s.names[form] = name
+	}
+
+	// Replace longer values first, so that a value containing another is
+	// replaced whole
+	values := make([]string, 0, len(s.names))
+	for v := range s.names {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool {
+		if len(values[i]) != len(values[j]) {
+			return len(values[i]) > len(values[j])
+		}
+		return values[i] < values[j]
+	})
+	pairs := make([]string, 0, 2*len(values))
+	for _, v := range values {
+		pairs = append(pairs, v, Scheme+s.names[v])
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+// Expand replaces every reference in text with the value of its secret.
+// Text without references is returned unchanged.
+func (s *Store) Expand(text string) (string, error) {
+	if !strings.Contains(text, Scheme) {
+		return text, nil
+	}
+	var err error
+	expanded := refPattern.ReplaceAllStringFunc(text, func(ref string) string {
+		v, lookupErr := s.Lookup(strings.TrimPrefix(ref, Scheme))
+		if lookupErr != nil && err == nil {
+			err = lookupErr
+		}
+		return v
+	})
+	if err != nil {
+		return "", err
+	}
+	return expanded, nil
+}
+
+// Mask replaces every reference in text with a placeholder, so that text
+// such as a URL can be validated without resolving its secrets.
+func Mask(text string) string {
+	return refPattern.ReplaceAllString(text, "secret")
+}
+
+// Redact replaces every value the Store resolved in text with its
+// reference.
+func (s *Store) Redact(text string) string {
+	s.mu.RLock()
+	r := s.replacer
+	s.mu.RUnlock()
+	if r == nil {
+		return text
+	}
+	return r.Replace(text)
+}
+
+// Writer returns a Writer redacting what is written to w.
+func (s *Store) Writer(w io.Writer) *Writer {
+	return &Writer{store: s, w: w}
+}
+
+// Writer redacts the secrets of its Store from every write. A secret split
+// across two writes is not redacted; the outputs of circle-pinger write
+// whole lines.
+type Writer struct {
+	store *Store
+	w     io.Writer
+}
+
+// Write implements io.Writer, reporting len(p) as written when the redacted
+// text is.
+func (w *Writer) Write(p []byte) (int, error) {
+	redacted := w.store.Redact(string(p))
+	if _, err := io.WriteString(w.w, redacted); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Unwrap returns the underlying writer, such as to tell whether it is a
+// terminal.
+func (w *Writer) Unwrap() io.Writer {
+	return w.w
+}
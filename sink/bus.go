@@ -0,0 +1,148 @@
+// Package sink provides the outputs probe records can be sent to, and a Bus
+// that fans records out to several of them at once.
+package sink
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Bus implements the pinger.Sink interface
+var _ pinger.Sink = (*Bus)(nil)
+
+// DefaultBuffer is the number of records buffered per sink.
+const DefaultBuffer = 256
+
+// Policy decides what Write does when the buffer of a sink is full.
+type Policy int
+
+const (
+	// Block waits for the sink to catch up, applying backpressure to the
+	// probes rather than losing records.
+	Block Policy = iota
+	// Drop discards the record for that sink and counts it, so that a slow
+	// sink never delays the next probe.
+	Drop
+)
+
+// Bus fans records out to several sinks. Every sink gets its own buffer and
+// goroutine, so a slow sink does not delay the others; what happens once its
+// buffer is full depends on the Policy.
+type Bus struct {
+	outputs []*output
+	policy  Policy
+	wg      sync.WaitGroup
+
+	mu     sync.RWMutex
+	closed bool
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// output is one sink of the bus with its buffer.
+type output struct {
+	sink    pinger.Sink
+	records chan *pinger.Record
+
+	dropped atomic.Uint64
+
+	mu       sync.Mutex
+	failures int
+	lastErr  error
+}
+
+// NewBus creates a Bus delivering to sinks with buffer records of buffering
+// per sink. A buffer of zero or less uses DefaultBuffer.
+func NewBus(buffer int, policy Policy, sinks ...pinger.Sink) *Bus {
+	if buffer <= 0 {
+		buffer = DefaultBuffer
+	}
+	b := &Bus{policy: policy}
+	for _, s := range sinks {
+		o := &output{sink: s, records: make(chan *pinger.Record, buffer)}
+		b.outputs = append(b.outputs, o)
+		b.wg.Add(1)
+		go func() {
+			defer b.wg.Done()
+			o.run()
+		}()
+	}
+	return b
+}
+
+// ErrClosed is returned when writing to a closed Bus.
+var ErrClosed = errors.New("sink: bus closed")
+
+// Write queues the record for every sink.
+func (b *Bus) Write(record *pinger.Record) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if b.closed {
+		return ErrClosed
+	}
+	for _, o := range b.outputs {
+		if b.policy == Block {
+			o.records <- record
+			continue
+		}
+		select {
+		case o.records <- record:
+		default:
+			o.dropped.Add(1)
+		}
+	}
+	return nil
+}
+
+// Dropped returns the number of records dropped for each sink, in the order
+// the sinks were given to NewBus.
+func (b *Bus) Dropped() []uint64 {
+	dropped := make([]uint64, len(b.outputs))
+	for i, o := range b.outputs {
+		dropped[i] = o.dropped.Load()
+	}
+	return dropped
+}
+
+// Close delivers the buffered records, closes every sink and reports the
+// errors the sinks returned along the way.
+func (b *Bus) Close() error {
+	b.closeOnce.Do(func() {
+		b.mu.Lock()
+		b.closed = true
+		b.mu.Unlock()
+		for _, o := range b.outputs {
+			close(o.records)
+		}
+		b.wg.Wait()
+
+		var errs []error
+		for _, o := range b.outputs {
+			if o.failures > 0 {
+				errs = append(errs, fmt.Errorf("%d records failed, last error: %w", o.failures, o.lastErr))
+			}
+			if err := o.sink.Close(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		b.closeErr = errors.Join(errs...)
+	})
+	return b.closeErr
+}
+
+// run delivers queued records to the sink until the buffer is closed.
+func (o *output) run() {
+	for record := range o.records {
+		if err := o.sink.Write(record); err != nil {
+			o.mu.Lock()
+			o.failures++
+			o.lastErr = err
+			o.mu.Unlock()
+		}
+	}
+}
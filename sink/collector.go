@@ -0,0 +1,102 @@
+package sink
+
+import (
+	"sync"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// Ensure Collector implements the pinger.Sink interface
+var _ pinger.Sink = (*Collector)(nil)
+
+// Collector keeps the results of every probe per target in memory, for
+// reports computed at the end of a run.
+type Collector struct {
+	mu      sync.Mutex
+	samples map[string]*stats.Sample
+}
+
+// NewCollector creates an empty Collector.
+func NewCollector() *Collector {
+	return &Collector{samples: make(map[string]*stats.Sample)}
+}
+
+// Write implements pinger.Sink.
+func (c *Collector) Write(record *pinger.Record) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.samples[record.Target]
+	if !ok {
+		s = &stats.Sample{}
+		c.samples[record.Target] = s
+	}
+	s.Add(record.Stats.Connected, record.Stats.Duration)
+	return nil
+}
+
+// Close implements pinger.Sink.
+func (c *Collector) Close() error {
+	return nil
+}
+
+// Samples returns the samples collected per target. It must only be called
+// once no more records are written.
+func (c *Collector) Samples() map[string]*stats.Sample {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.samples
+}
+
+// Ensure ByMeta implements the pinger.Sink interface
+var _ pinger.Sink = (*ByMeta)(nil)
+
+// ByMeta keeps the results of probes per target and value of a metadata
+// key, such as the IP family of pinger.DualStack probes or the address of
+// pinger.AllIPs ones, for the comparisons at the end of a run. Probes
+// without the key are ignored.
+type ByMeta struct {
+	key     string
+	mu      sync.Mutex
+	samples map[string]map[string]*stats.Sample
+}
+
+// NewByMeta creates an empty ByMeta splitting the results by key.
+func NewByMeta(key string) *ByMeta {
+	return &ByMeta{key: key, samples: make(map[string]map[string]*stats.Sample)}
+}
+
+// Write implements pinger.Sink.
+func (b *ByMeta) Write(record *pinger.Record) error {
+	value, ok := record.Stats.Meta[b.key]
+	if !ok || value == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	values, ok := b.samples[record.Target]
+	if !ok {
+		values = make(map[string]*stats.Sample)
+		b.samples[record.Target] = values
+	}
+	s, ok := values[value.String()]
+	if !ok {
+		s = &stats.Sample{}
+		values[value.String()] = s
+	}
+	s.Add(record.Stats.Connected, record.Stats.Duration)
+	return nil
+}
+
+// Close implements pinger.Sink.
+func (b *ByMeta) Close() error {
+	return nil
+}
+
+// Samples returns the samples per target and value of the key. It must only
+// be called once no more records are written.
+func (b *ByMeta) Samples() map[string]map[string]*stats.Sample {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.samples
+}
@@ -0,0 +1,99 @@
+package sink
+
+import (
+	"cmp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Explainer implements the pinger.Sink interface
+var _ pinger.Sink = (*Explainer)(nil)
+
+// Explainer averages the phases of the successful probes per target, to
+// show where their time goes at the end of a run.
+type Explainer struct {
+	mu      sync.Mutex
+	targets map[string]*phaseTotals
+}
+
+// phaseTotals sums the phases of a target's probes, in the order the
+// protocol reports them.
+type phaseTotals struct {
+	probes int
+	phases []pinger.Phase
+}
+
+// Breakdown is the average time the probes of a target spent per phase.
+type Breakdown struct {
+	Target string
+	Probes int            // Successful probes with phases
+	Phases []pinger.Phase // Average per phase, longest first
+	Total  time.Duration  // Sum of the averages
+}
+
+// Share returns the fraction of the total phase is.
+func (b Breakdown) Share(phase pinger.Phase) float64 {
+	if b.Total <= 0 {
+		return 0
+	}
+	return float64(phase.Duration) / float64(b.Total)
+}
+
+// NewExplainer creates an empty Explainer.
+func NewExplainer() *Explainer {
+	return &Explainer{targets: make(map[string]*phaseTotals)}
+}
+
+// Write implements pinger.Sink. Failed probes and probes of protocols
+// without phases are ignored.
+func (e *Explainer) Write(record *pinger.Record) error {
+	if !record.Stats.Connected || len(record.Stats.Phases) == 0 {
+		return nil
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	t, ok := e.targets[record.Target]
+	if !ok {
+		t = &phaseTotals{}
+		e.targets[record.Target] = t
+	}
+	t.probes++
+	for _, phase := range record.Stats.Phases {
+		i := slices.IndexFunc(t.phases, func(p pinger.Phase) bool { return p.Name == phase.Name })
+		if i < 0 {
+			t.phases = append(t.phases, pinger.Phase{Name: phase.Name})
+			i = len(t.phases) - 1
+		}
+		t.phases[i].Duration += phase.Duration
+	}
+	return nil
+}
+
+// Close implements pinger.Sink.
+func (e *Explainer) Close() error {
+	return nil
+}
+
+// Breakdowns returns the breakdown of every target with phases, sorted by
+// target.
+func (e *Explainer) Breakdowns() []Breakdown {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	breakdowns := make([]Breakdown, 0, len(e.targets))
+	for target, t := range e.targets {
+		b := Breakdown{Target: target, Probes: t.probes}
+		for _, phase := range t.phases {
+			avg := phase.Duration / time.Duration(t.probes)
+			b.Phases = append(b.Phases, pinger.Phase{Name: phase.Name, Duration: avg})
+			b.Total += avg
+		}
+		// The stable sort keeps the protocol's order among equal phases
+		slices.SortStableFunc(b.Phases, func(a, b pinger.Phase) int { return cmp.Compare(b.Duration, a.Duration) })
+		breakdowns = append(breakdowns, b)
+	}
+	slices.SortFunc(breakdowns, func(a, b Breakdown) int { return cmp.Compare(a.Target, b.Target) })
+	return breakdowns
+}
@@ -0,0 +1,75 @@
+package sink
+
+import (
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/hdr"
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure HDR implements the pinger.Sink interface
+var _ pinger.Sink = (*HDR)(nil)
+
+const (
+	// HDRHighest is the highest latency the HDR sink tracks; longer ones
+	// are counted as this.
+	HDRHighest = time.Hour
+	// HDRDigits is the precision of the HDR sink in significant digits.
+	HDRDigits = 3
+)
+
+// HDR records the latency of every successful probe in an HdrHistogram per
+// target, in nanoseconds, and writes them as an HdrHistogram log on Close.
+type HDR struct {
+	mu        sync.Mutex
+	file      *os.File
+	start     time.Time
+	intervals map[string]*hdr.Interval
+}
+
+// NewHDR creates the file at path, which is written when the sink is
+// closed.
+func NewHDR(path string) (*HDR, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &HDR{file: f, start: time.Now(), intervals: make(map[string]*hdr.Interval)}, nil
+}
+
+// Write implements pinger.Sink.
+func (h *HDR) Write(record *pinger.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	interval, ok := h.intervals[record.Target]
+	if !ok {
+		interval = &hdr.Interval{Tag: record.Target, Start: record.Timestamp, Histogram: hdr.New(int64(HDRHighest), HDRDigits)}
+		h.intervals[record.Target] = interval
+	}
+	interval.End = record.Timestamp.Add(record.Stats.Duration)
+	if record.Stats.Connected {
+		interval.Histogram.Record(int64(record.Stats.Duration))
+	}
+	return nil
+}
+
+// Close implements pinger.Sink, writing the log with a histogram per
+// target, sorted by target, and maximums in milliseconds.
+func (h *HDR) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	intervals := make([]hdr.Interval, 0, len(h.intervals))
+	for _, interval := range h.intervals {
+		intervals = append(intervals, *interval)
+	}
+	slices.SortFunc(intervals, func(a, b hdr.Interval) int { return strings.Compare(a.Tag, b.Tag) })
+	err := hdr.WriteLog(h.file, h.start, float64(time.Millisecond), intervals)
+	if closeErr := h.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,138 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Notifier implements the pinger.Sink interface
+var _ pinger.Sink = (*Notifier)(nil)
+
+// Notifier alerts when a target goes up or down: it rings the terminal bell
+// on w and optionally shows a desktop notification. The first result of a
+// target only sets its state.
+type Notifier struct {
+	w       io.Writer
+	desktop func(title, body string) error // nil without desktop notifications
+
+	mu sync.Mutex
+	up map[string]bool
+}
+
+// NewNotifier creates a Notifier ringing the bell on w, and with desktop set
+// also showing desktop notifications.
+func NewNotifier(w io.Writer, desktop bool) *Notifier {
+	n := &Notifier{w: w, up: make(map[string]bool)}
+	if desktop {
+		n.desktop = Desktop
+	}
+	return n
+}
+
+// Write implements pinger.Sink.
+func (n *Notifier) Write(record *pinger.Record) error {
+	up := record.Stats.Connected
+	n.mu.Lock()
+	was, seen := n.up[record.Target]
+	n.up[record.Target] = up
+	n.mu.Unlock()
+	if !seen || was == up {
+		return nil
+	}
+
+	if _, err := io.WriteString(n.w, "\a"); err != nil {
+		return err
+	}
+	if n.desktop == nil {
+		return nil
+	}
+	body := record.Target + " is up"
+	if !up {
+		body = record.Target + " is down"
+		if record.Stats.Error != nil {
+			body += ": " + record.Stats.Error.Error()
+		}
+	}
+	return n.desktop("circle-pinger", body)
+}
+
+// Close implements pinger.Sink.
+func (n *Notifier) Close() error {
+	return nil
+}
+
+// Desktop shows a desktop notification with notify-send, osascript on macOS,
+// or a PowerShell balloon tip on Windows, without waiting for it to be
+// dismissed.
+func Desktop(title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		quote := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf(`display notification "%s" with title "%s"`, quote.Replace(body), quote.Replace(title)))
+	case "windows":
+		quote := strings.NewReplacer(`'`, `''`)
+		cmd = exec.Command("powershell", "-NoProfile", "-NonInteractive", "-Command", fmt.Sprintf(windowsBalloon, quote.Replace(title), quote.Replace(body)))
+	default:
+		cmd = exec.Command("notify-send", title, body)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("desktop notification: %w", err)
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// windowsBalloon shows a balloon tip from the notification area, formatted
+// with the title and body quoted for single-quoted PowerShell strings. The
+// icon has to outlive the balloon, so the script waits before removing it.
+const windowsBalloon = `Add-Type -AssemblyName System.Windows.Forms, System.Drawing
+$icon = New-Object System.Windows.Forms.NotifyIcon
+$icon.Icon = [System.Drawing.SystemIcons]::Information
+$icon.Visible = $true
+$icon.ShowBalloonTip(10000, '%s', '%s', [System.Windows.Forms.ToolTipIcon]::Info)
+Start-Sleep -Seconds 10
+$icon.Dispose()`
+
+// HeadlineTargets is the number of targets Headline lists one by one; more
+// are condensed.
+const HeadlineTargets = 3
+
+// Headline condenses the summaries of a run into the body of a
+// notification: loss and average time per target, or for many targets how
+// many saw loss and the worst of them.
+func Headline(summaries []pinger.Summary) string {
+	if len(summaries) <= HeadlineTargets {
+		lines := make([]string, 0, len(summaries))
+		for _, s := range summaries {
+			line := fmt.Sprintf("%s: %d/%d ok, %.1f%% loss", s.URL, s.SuccessTotal, s.Total, s.Loss*100)
+			if s.SuccessTotal > 0 {
+				line += ", avg " + s.AvgDuration.Round(10*time.Microsecond).String()
+			}
+			lines = append(lines, line)
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	lossy := 0
+	var worst *pinger.Summary
+	for i, s := range summaries {
+		if s.FailedTotal > 0 {
+			lossy++
+		}
+		if worst == nil || s.Loss > worst.Loss {
+			worst = &summaries[i]
+		}
+	}
+	if lossy == 0 {
+		return fmt.Sprintf("%d targets, none with loss", len(summaries))
+	}
+	return fmt.Sprintf("%d targets, %d with loss, worst %s with %.1f%% loss", len(summaries), lossy, worst.URL, worst.Loss*100)
+}
@@ -0,0 +1,134 @@
+package sink
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Progress implements the pinger.Sink interface
+var _ pinger.Sink = (*Progress)(nil)
+
+// Progress periodically writes a progress line for a run with a fixed
+// counter: the probes done out of the total and when the run is expected to
+// complete, forecast from the interval and the probe durations observed so
+// far.
+type Progress struct {
+	w         io.Writer
+	every     time.Duration
+	counter   int
+	intervals map[string]time.Duration // interval of every target
+	start     time.Time
+
+	mu      sync.Mutex
+	targets map[string]*progressRow
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// progressRow holds what a forecast needs of one target.
+type progressRow struct {
+	done int
+	sum  time.Duration // of the probe durations
+	last time.Time     // when the last probe ended
+}
+
+// NewProgress creates a Progress writing to w every every, for targets,
+// keyed by URL, each probed counter times at its interval.
+func NewProgress(w io.Writer, every time.Duration, counter int, intervals map[string]time.Duration) *Progress {
+	p := &Progress{
+		w:         w,
+		every:     every,
+		counter:   counter,
+		intervals: intervals,
+		start:     time.Now(),
+		targets:   make(map[string]*progressRow),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+// Write implements pinger.Sink.
+func (p *Progress) Write(record *pinger.Record) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	row, ok := p.targets[record.Target]
+	if !ok {
+		row = &progressRow{}
+		p.targets[record.Target] = row
+	}
+	row.done++
+	row.sum += record.Stats.Duration
+	row.last = record.Timestamp.Add(record.Stats.Duration)
+	return nil
+}
+
+// Close implements pinger.Sink. The summary follows, so no final line is
+// written.
+func (p *Progress) Close() error {
+	close(p.stop)
+	<-p.done
+	return nil
+}
+
+// run writes the progress line every p.every.
+func (p *Progress) run() {
+	defer close(p.done)
+	ticker := time.NewTicker(p.every)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			fmt.Fprintln(p.w, p.Line(now))
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Line returns the progress line at now, such as
+// "progress: 120/1000 probes (12.0%), 2m0s elapsed, about 14m40s left, done around 15:04:05".
+func (p *Progress) Line(now time.Time) string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Targets without a result yet are assumed to be as fast as the others
+	var done int
+	var sum time.Duration
+	for _, row := range p.targets {
+		done += row.done
+		sum += row.sum
+	}
+	var avg time.Duration
+	if done > 0 {
+		avg = sum / time.Duration(done)
+	}
+
+	// The run completes with its slowest target
+	var left time.Duration
+	for target, interval := range p.intervals {
+		row, ok := p.targets[target]
+		if !ok {
+			left = max(left, pinger.Forecast(p.counter, interval, avg)-now.Sub(p.start))
+			continue
+		}
+		if remaining := p.counter - row.done; remaining > 0 {
+			rowAvg := row.sum / time.Duration(row.done)
+			// Every remaining probe follows a wait of interval, part of
+			// which has passed since the last one
+			left = max(left, time.Duration(remaining)*(interval+rowAvg)-now.Sub(row.last))
+		}
+	}
+	left = max(left, 0)
+
+	total := p.counter * len(p.intervals)
+	return fmt.Sprintf("progress: %d/%d probes (%.1f%%), %s elapsed, about %s left, done around %s",
+		done, total, float64(done)/float64(max(total, 1))*100,
+		now.Sub(p.start).Round(time.Second), left.Round(time.Second), now.Add(left).Format(time.TimeOnly))
+}
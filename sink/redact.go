@@ -0,0 +1,99 @@
+package sink
+
+import (
+	"maps"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Redact implements the pinger.Sink interface
+var _ pinger.Sink = (*Redact)(nil)
+
+// Redact passes records to a sink with the secrets in their text replaced,
+// so that credentials resolved into a target URL, or quoted by an error,
+// reach no output. Records without secrets are passed as they are.
+type Redact struct {
+	sink   pinger.Sink
+	redact func(string) string
+}
+
+// NewRedact creates a Redact passing records to sink with every text
+// replaced by redact, such as secret.Store.Redact.
+func NewRedact(sink pinger.Sink, redact func(string) string) *Redact {
+	return &Redact{sink: sink, redact: redact}
+}
+
+// Write implements pinger.Sink.
+func (r *Redact) Write(record *pinger.Record) error {
+	return r.sink.Write(r.record(record))
+}
+
+// record returns record with its text redacted, copying it only when a
+// secret is replaced.
+func (r *Redact) record(record *pinger.Record) *pinger.Record {
+	out := *record
+	stats := *record.Stats
+	dirty := false
+	redact := func(s string) (string, bool) {
+		redacted := r.redact(s)
+		if redacted == s {
+			return s, false
+		}
+		dirty = true
+		return redacted, true
+	}
+
+	out.Target, _ = redact(record.Target)
+	copied := false
+	for k, v := range record.Labels {
+		if redacted, ok := redact(v); ok {
+			if !copied {
+				out.Labels, copied = maps.Clone(record.Labels), true
+			}
+			out.Labels[k] = redacted
+		}
+	}
+	if stats.Error != nil {
+		if msg, ok := redact(stats.Error.Error()); ok {
+			stats.Error = &redactedError{msg: msg, err: stats.Error}
+		}
+	}
+	stats.Address, _ = redact(stats.Address)
+	copied = false
+	for k, v := range record.Stats.Meta {
+		if v == nil {
+			continue
+		}
+		if redacted, ok := redact(v.String()); ok {
+			if !copied {
+				stats.Meta, copied = maps.Clone(record.Stats.Meta), true
+			}
+			stats.Meta[k] = pinger.StringerFunc(func() string { return redacted })
+		}
+	}
+	if stats.Extra != nil {
+		if redacted, ok := redact(stats.Extra.String()); ok {
+			stats.Extra = pinger.StringerFunc(func() string { return redacted })
+		}
+	}
+	if !dirty {
+		return record
+	}
+	out.Stats = &stats
+	return &out
+}
+
+// redactedError is an error whose message had secrets replaced. It unwraps
+// to the original error, so that timeouts and the like are still told apart.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+func (e *redactedError) Unwrap() error { return e.err }
+
+// Close implements pinger.Sink, closing the redacted sink.
+func (r *Redact) Close() error {
+	return r.sink.Close()
+}
@@ -0,0 +1,49 @@
+package sink
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Sample implements the pinger.Sink interface
+var _ pinger.Sink = (*Sample)(nil)
+
+// ParseSample parses a sample rate such as "1/100" and returns its
+// denominator, the n of one record in n.
+func ParseSample(s string) (int, error) {
+	num, den, ok := strings.Cut(s, "/")
+	n, err := strconv.Atoi(den)
+	if !ok || num != "1" || err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid sample rate %q, want one in n such as 1/100", s)
+	}
+	return n, nil
+}
+
+// Sample passes one in n records of every target to a sink, the first and
+// then every nth probe, so that the sink sees a steady share of high-rate
+// probing. The statistics of the Pingers still count every probe.
+type Sample struct {
+	sink pinger.Sink
+	n    int
+}
+
+// NewSample creates a Sample passing one in n records to sink.
+func NewSample(sink pinger.Sink, n int) *Sample {
+	return &Sample{sink: sink, n: max(n, 1)}
+}
+
+// Write implements pinger.Sink.
+func (s *Sample) Write(record *pinger.Record) error {
+	if (record.Seq-1)%s.n != 0 {
+		return nil
+	}
+	return s.sink.Write(record)
+}
+
+// Close implements pinger.Sink, closing the sampled sink.
+func (s *Sample) Close() error {
+	return s.sink.Close()
+}
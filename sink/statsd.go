@@ -0,0 +1,93 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Statsd implements the pinger.Sink interface
+var _ pinger.Sink = (*Statsd)(nil)
+
+// DefaultStatsdPrefix prefixes every metric sent by a Statsd sink.
+const DefaultStatsdPrefix = "circle_pinger"
+
+// Statsd sends a timing and a success or failure counter per record to a
+// statsd server over UDP. Labels are sent as DogStatsD-style tags.
+type Statsd struct {
+	conn   net.Conn
+	prefix string
+	rate   string // the "|@rate" suffix of sampled metrics, or ""
+}
+
+// NewStatsd creates a Statsd sink sending to the host:port address.
+func NewStatsd(address string) (*Statsd, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	return &Statsd{conn: conn, prefix: DefaultStatsdPrefix}, nil
+}
+
+// SetSample marks the metrics as sampled at one record in n, so that the
+// statsd server scales the counters back up. It must be called before Write.
+func (s *Statsd) SetSample(n int) {
+	if n > 1 {
+		s.rate = "|@" + strconv.FormatFloat(1/float64(n), 'g', -1, 64)
+	}
+}
+
+// Write implements pinger.Sink. Every record is sent as one datagram.
+func (s *Statsd) Write(record *pinger.Record) error {
+	name := s.prefix + "." + metricName(record.Target)
+	tags := formatTags(record.Labels)
+
+	var b bytes.Buffer
+	if record.Stats.Connected {
+		fmt.Fprintf(&b, "%s.rtt:%.3f|ms%s%s\n", name, record.Stats.Duration.Seconds()*1000, s.rate, tags)
+		fmt.Fprintf(&b, "%s.success:1|c%s%s", name, s.rate, tags)
+	} else {
+		fmt.Fprintf(&b, "%s.failure:1|c%s%s", name, s.rate, tags)
+	}
+	_, err := s.conn.Write(b.Bytes())
+	return err
+}
+
+// Close implements pinger.Sink.
+func (s *Statsd) Close() error {
+	return s.conn.Close()
+}
+
+// metricName turns a target URL into a statsd-safe metric path component.
+func metricName(target string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			return r
+		default:
+			return '_'
+		}
+	}, target)
+}
+
+// formatTags formats labels as a "|#k:v,..." suffix, or "" without labels.
+func formatTags(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	tags := make([]string, len(keys))
+	for i, key := range keys {
+		tags[i] = key + ":" + labels[key]
+	}
+	return "|#" + strings.Join(tags, ",")
+}
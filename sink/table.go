@@ -0,0 +1,199 @@
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/stats"
+)
+
+// Ensure Table implements the pinger.Sink interface
+var _ pinger.Sink = (*Table)(nil)
+
+// tableWindow is the number of recent successful probes a row keeps to
+// compute its p95.
+const tableWindow = 100
+
+// Table renders one aligned row per target (target | last | avg | p95 |
+// loss | state), refreshed periodically, which is easier to scan than
+// interleaved lines when many targets are probed at once.
+type Table struct {
+	w       io.Writer
+	redraw  bool
+	refresh time.Duration
+
+	mu    sync.Mutex
+	rows  map[string]*tableRow
+	order []string // targets, sorted
+	dirty bool
+	lines int // lines drawn last time, erased on redraw
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// tableRow holds the statistics shown for one target.
+type tableRow struct {
+	last    *pinger.Stats
+	total   int
+	failed  int
+	sum     time.Duration
+	success int
+	recent  []time.Duration
+}
+
+// NewTable creates a Table writing to w every refresh. With redraw the
+// previous table is erased using ANSI escapes, which suits terminals;
+// otherwise each refresh appends a new table.
+func NewTable(w io.Writer, refresh time.Duration, redraw bool) *Table {
+	if refresh <= 0 {
+		refresh = pinger.DefaultInterval
+	}
+	t := &Table{
+		w:       w,
+		redraw:  redraw,
+		refresh: refresh,
+		rows:    make(map[string]*tableRow),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Write implements pinger.Sink.
+func (t *Table) Write(record *pinger.Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	row, ok := t.rows[record.Target]
+	if !ok {
+		row = &tableRow{}
+		t.rows[record.Target] = row
+		// Keep rows sorted so they do not move between refreshes
+		i, _ := slices.BinarySearch(t.order, record.Target)
+		t.order = slices.Insert(t.order, i, record.Target)
+	}
+	row.add(record.Stats)
+	t.dirty = true
+	return nil
+}
+
+// Close implements pinger.Sink, drawing the final table.
+func (t *Table) Close() error {
+	close(t.stop)
+	<-t.done
+	return t.draw()
+}
+
+// run draws the table every refresh when it changed.
+func (t *Table) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.refresh)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			t.draw()
+		case <-t.stop:
+			return
+		}
+	}
+}
+
+// draw writes the table if it changed since it was last drawn.
+func (t *Table) draw() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.dirty {
+		return nil
+	}
+	t.dirty = false
+
+	var b bytes.Buffer
+	if t.redraw && t.lines > 0 {
+		// Move up to the first line of the previous table and clear below
+		fmt.Fprintf(&b, "\x1b[%dA\x1b[J", t.lines)
+	} else if t.lines > 0 {
+		b.WriteByte('\n')
+	}
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TARGET\tLAST\tAVG\tP95\tLOSS\tSTATE")
+	for _, target := range t.order {
+		row := t.rows[target]
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%.1f%%\t%s\n", target, row.lastString(), row.avg(), row.p95(), row.loss(), row.state())
+	}
+	tw.Flush()
+	t.lines = len(t.order) + 1
+
+	_, err := t.w.Write(b.Bytes())
+	return err
+}
+
+// add accounts for the result of one probe.
+func (r *tableRow) add(stats *pinger.Stats) {
+	r.last = stats
+	r.total++
+	if !stats.Connected {
+		r.failed++
+		return
+	}
+	r.success++
+	r.sum += stats.Duration
+	r.recent = append(r.recent, stats.Duration)
+	if len(r.recent) > tableWindow {
+		r.recent = r.recent[1:]
+	}
+}
+
+func (r *tableRow) lastString() string {
+	if !r.last.Connected {
+		return "failed"
+	}
+	return formatDuration(r.last.Duration)
+}
+
+func (r *tableRow) avg() string {
+	if r.success == 0 {
+		return "-"
+	}
+	return formatDuration(r.sum / time.Duration(r.success))
+}
+
+// p95 returns the 95th percentile of the recent successful probes.
+func (r *tableRow) p95() string {
+	if len(r.recent) == 0 {
+		return "-"
+	}
+	sorted := slices.Clone(r.recent)
+	slices.Sort(sorted)
+	return formatDuration(stats.Percentile(sorted, 95))
+}
+
+func (r *tableRow) loss() float64 {
+	return float64(r.failed) / float64(r.total) * 100
+}
+
+func (r *tableRow) state() string {
+	if r.last.Connected {
+		return "up"
+	}
+	return "down"
+}
+
+// formatDuration rounds d for display in a table cell.
+func formatDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(10 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
@@ -0,0 +1,112 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Webhook implements the pinger.Sink interface
+var _ pinger.Sink = (*Webhook)(nil)
+
+// DefaultWebhookTimeout bounds every POST of a Webhook sink.
+const DefaultWebhookTimeout = 5 * time.Second
+
+// WebhookMode selects the records a Webhook posts.
+type WebhookMode string
+
+const (
+	// WebhookFailure posts every failed probe.
+	WebhookFailure WebhookMode = "failure"
+	// WebhookChange posts every probe changing the state of its target
+	// between up and down. The first probe of a target only sets its state.
+	WebhookChange WebhookMode = "change"
+	// WebhookAll posts every probe.
+	WebhookAll WebhookMode = "all"
+)
+
+// ParseWebhookMode parses "failure", "change" or "all".
+func ParseWebhookMode(s string) (WebhookMode, error) {
+	switch mode := WebhookMode(s); mode {
+	case WebhookFailure, WebhookChange, WebhookAll:
+		return mode, nil
+	}
+	return "", fmt.Errorf(`unknown webhook mode %q, want "failure", "change" or "all"`, s)
+}
+
+// WebhookEvent is the JSON payload posted by a Webhook.
+type WebhookEvent struct {
+	Event   WebhookMode    `json:"event"`   // The mode the probe was posted for
+	Target  string         `json:"target"`  // The target
+	Up      bool           `json:"up"`      // Whether the probe succeeded
+	Changed bool           `json:"changed"` // Whether the probe changed the state of the target
+	Record  *pinger.Record `json:"record"`  // The probe
+}
+
+// Webhook POSTs a JSON WebhookEvent to an HTTP endpoint for the records
+// selected by its mode, the lowest common denominator of alerting pipelines.
+type Webhook struct {
+	url    string
+	mode   WebhookMode
+	client *http.Client
+
+	mu sync.Mutex
+	up map[string]bool
+}
+
+// NewWebhook creates a Webhook posting to url the records selected by mode.
+func NewWebhook(url string, mode WebhookMode) *Webhook {
+	return &Webhook{
+		url:    url,
+		mode:   mode,
+		client: &http.Client{Timeout: DefaultWebhookTimeout},
+		up:     make(map[string]bool),
+	}
+}
+
+// Write implements pinger.Sink. A response other than 2xx is an error.
+func (w *Webhook) Write(record *pinger.Record) error {
+	up := record.Stats.Connected
+	w.mu.Lock()
+	was, seen := w.up[record.Target]
+	w.up[record.Target] = up
+	w.mu.Unlock()
+	changed := seen && was != up
+
+	switch {
+	case w.mode == WebhookFailure && up, w.mode == WebhookChange && !changed:
+		return nil
+	}
+	body, err := json.Marshal(WebhookEvent{Event: w.mode, Target: record.Target, Up: up, Changed: changed, Record: record})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "circle-pinger")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// Close implements pinger.Sink.
+func (w *Webhook) Close() error {
+	w.client.CloseIdleConnections()
+	return nil
+}
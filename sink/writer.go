@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"text/template"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure the writer sinks implement the pinger.Sink interface
+var (
+	_ pinger.Sink = (*Text)(nil)
+	_ pinger.Sink = (*JSON)(nil)
+	_ pinger.Sink = (*Template)(nil)
+)
+
+// Text writes records as the human-readable probe lines.
+type Text struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewText creates a Text sink writing to w.
+func NewText(w io.Writer) *Text {
+	return &Text{w: w}
+}
+
+// Write implements pinger.Sink.
+func (t *Text) Write(record *pinger.Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	_, err := io.WriteString(t.w, record.String())
+	return err
+}
+
+// Close implements pinger.Sink. The underlying writer is not closed.
+func (t *Text) Close() error {
+	return nil
+}
+
+// Template writes records rendered from a user-supplied template, executed
+// with the record's pinger.Probe.
+type Template struct {
+	mu  sync.Mutex
+	w   io.Writer
+	tpl *template.Template
+	buf bytes.Buffer
+}
+
+// NewTemplate creates a Template sink writing to w.
+func NewTemplate(w io.Writer, tpl *template.Template) *Template {
+	return &Template{w: w, tpl: tpl}
+}
+
+// Write implements pinger.Sink.
+func (t *Template) Write(record *pinger.Record) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Render into a buffer so a failing template never leaves half a line
+	t.buf.Reset()
+	if err := t.tpl.Execute(&t.buf, record.Probe()); err != nil {
+		return err
+	}
+	_, err := t.w.Write(t.buf.Bytes())
+	return err
+}
+
+// Close implements pinger.Sink. The underlying writer is not closed.
+func (t *Template) Close() error {
+	return nil
+}
+
+// JSON writes records as JSON lines.
+type JSON struct {
+	mu     sync.Mutex
+	enc    *json.Encoder
+	closer io.Closer
+}
+
+// NewJSON creates a JSON sink writing to w.
+func NewJSON(w io.Writer) *JSON {
+	return &JSON{enc: json.NewEncoder(w)}
+}
+
+// NewRecorder creates a JSON sink appending to the file at path, so that a
+// session can be recorded while results are shown elsewhere.
+func NewRecorder(path string) (*JSON, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	return &JSON{enc: json.NewEncoder(f), closer: f}, nil
+}
+
+// NewLog creates a JSON sink appending to the file at path, rotated once it
+// would grow past maxSize as RotatingFile describes, for long-running
+// sessions whose records must survive without filling the disk.
+func NewLog(path string, maxSize int64, maxFiles int) (*JSON, error) {
+	f, err := OpenRotatingFile(path, maxSize, maxFiles)
+	if err != nil {
+		return nil, err
+	}
+	return &JSON{enc: json.NewEncoder(f), closer: f}, nil
+}
+
+// Write implements pinger.Sink.
+func (j *JSON) Write(record *pinger.Record) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.enc.Encode(record)
+}
+
+// Close implements pinger.Sink, closing the file opened by NewRecorder or
+// NewLog.
+func (j *JSON) Close() error {
+	if j.closer == nil {
+		return nil
+	}
+	return j.closer.Close()
+}
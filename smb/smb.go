@@ -0,0 +1,219 @@
+// Package smb provides SMB2 ping functionality for the circle-pinger tool.
+// Each probe sends an SMB2 NEGOTIATE request and reports the dialect and
+// server GUID from the response, which proves the file service itself is
+// answering rather than just accepting TCP connections.
+package smb
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+const (
+	headerSize       = 64
+	negotiateCommand = 0x0000
+
+	securitySigningEnabled  = 0x01
+	securitySigningRequired = 0x02
+)
+
+// dialects are the SMB2/3 dialects offered, oldest first. SMB 3.1.1 is not
+// offered because it requires negotiate contexts.
+var dialects = []uint16{0x0202, 0x0210, 0x0300, 0x0302}
+
+// smbMagic is the SMB2 protocol identifier.
+var smbMagic = []byte{0xfe, 'S', 'M', 'B'}
+
+// New creates a new SMB Ping instance.
+func New(host string, port int, op *pinger.Option) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	return &Ping{
+		host:   host,
+		port:   port,
+		option: op,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+}
+
+// Ping is the SMB2 ping implementation.
+type Ping struct {
+	option *pinger.Option
+	host   string
+	port   int
+	dialer *net.Dialer
+}
+
+// Ping connects to the server and performs an SMB2 NEGOTIATE exchange.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+
+	start := time.Now()
+	conn, err := p.dialer.DialContext(ctx, "tcp", net.JoinHostPort(p.host, strconv.Itoa(p.port)))
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			stats.Address = oe.Addr.String()
+		}
+		return stats
+	}
+	defer conn.Close()
+	stats.Address = conn.RemoteAddr().String()
+	stats.Meta["connect"] = time.Since(start)
+
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	negotiateStart := time.Now()
+	resp, err := negotiate(conn)
+	stats.Duration = time.Since(start)
+	if err != nil {
+		stats.Error = err
+		return stats
+	}
+	stats.Connected = true
+	stats.Meta["negotiate"] = time.Since(negotiateStart)
+	stats.Meta["dialect"] = pinger.StringerFunc(func() string { return Dialect(resp.Dialect) })
+	stats.Meta["guid"] = pinger.StringerFunc(func() string { return FormatGUID(resp.ServerGUID) })
+	stats.Meta["signing"] = pinger.StringerFunc(func() string {
+		if resp.SecurityMode&securitySigningRequired != 0 {
+			return "required"
+		}
+		return "enabled"
+	})
+	return stats
+}
+
+// NegotiateResponse holds the fields of interest of a NEGOTIATE response.
+type NegotiateResponse struct {
+	SecurityMode uint16
+	Dialect      uint16
+	ServerGUID   [16]byte
+}
+
+// negotiate sends a NEGOTIATE request over rw and parses the response.
+func negotiate(rw io.ReadWriter) (*NegotiateResponse, error) {
+	if _, err := rw.Write(marshalNegotiate()); err != nil {
+		return nil, fmt.Errorf("write negotiate: %w", err)
+	}
+
+	var nb [4]byte
+	if _, err := io.ReadFull(rw, nb[:]); err != nil {
+		return nil, fmt.Errorf("read negotiate: %w", err)
+	}
+	size := int(binary.BigEndian.Uint32(nb[:]) & 0x00ffffff)
+	if size < headerSize+8 || size > 1<<20 {
+		return nil, fmt.Errorf("invalid response length %d", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(rw, msg); err != nil {
+		return nil, fmt.Errorf("read negotiate: %w", err)
+	}
+	return parseNegotiate(msg)
+}
+
+// marshalNegotiate builds a NEGOTIATE request framed for direct TCP transport.
+func marshalNegotiate() []byte {
+	body := 36 + 2*len(dialects)
+	b := make([]byte, 4+headerSize+body)
+	binary.BigEndian.PutUint32(b[0:], uint32(headerSize+body))
+
+	h := b[4:]
+	copy(h[0:4], smbMagic)
+	binary.LittleEndian.PutUint16(h[4:], headerSize)
+	binary.LittleEndian.PutUint16(h[12:], negotiateCommand)
+	binary.LittleEndian.PutUint16(h[14:], 1) // credits requested
+
+	n := h[headerSize:]
+	binary.LittleEndian.PutUint16(n[0:], 36)
+	binary.LittleEndian.PutUint16(n[2:], uint16(len(dialects)))
+	binary.LittleEndian.PutUint16(n[4:], securitySigningEnabled)
+	copy(n[12:28], "circle-pinger\x00\x00\x00") // client GUID
+	for i, d := range dialects {
+		binary.LittleEndian.PutUint16(n[36+2*i:], d)
+	}
+	return b
+}
+
+// parseNegotiate parses an SMB2 message expected to be a NEGOTIATE response.
+func parseNegotiate(msg []byte) (*NegotiateResponse, error) {
+	if len(msg) < headerSize+24 {
+		return nil, errors.New("short negotiate response")
+	}
+	if string(msg[0:4]) != string(smbMagic) {
+		if msg[0] == 0xff && string(msg[1:4]) == "SMB" {
+			return nil, errors.New("server only speaks SMB1")
+		}
+		return nil, errors.New("not an SMB2 response")
+	}
+	if cmd := binary.LittleEndian.Uint16(msg[12:]); cmd != negotiateCommand {
+		return nil, fmt.Errorf("unexpected command 0x%04x", cmd)
+	}
+	if status := binary.LittleEndian.Uint32(msg[8:]); status != 0 {
+		return nil, fmt.Errorf("negotiate failed with status 0x%08x", status)
+	}
+	body := msg[headerSize:]
+	resp := &NegotiateResponse{
+		SecurityMode: binary.LittleEndian.Uint16(body[2:]),
+		Dialect:      binary.LittleEndian.Uint16(body[4:]),
+	}
+	copy(resp.ServerGUID[:], body[8:24])
+	return resp, nil
+}
+
+// Dialect returns the conventional name of an SMB dialect revision.
+func Dialect(d uint16) string {
+	switch d {
+	case 0x0202:
+		return "2.0.2"
+	case 0x0210:
+		return "2.1"
+	case 0x0300:
+		return "3.0"
+	case 0x0302:
+		return "3.0.2"
+	case 0x0311:
+		return "3.1.1"
+	case 0x02ff:
+		return "2.???"
+	default:
+		return fmt.Sprintf("0x%04x", d)
+	}
+}
+
+// FormatGUID formats a GUID stored in its little-endian wire representation.
+func FormatGUID(g [16]byte) string {
+	return fmt.Sprintf("%08x-%04x-%04x-%x-%x",
+		binary.LittleEndian.Uint32(g[0:]),
+		binary.LittleEndian.Uint16(g[4:]),
+		binary.LittleEndian.Uint16(g[6:]),
+		g[8:10], g[10:16])
+}
@@ -0,0 +1,91 @@
+package smb
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+// serveNegotiate answers NEGOTIATE requests with dialect 3.0.2.
+func serveNegotiate(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go answerNegotiate(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+// answerNegotiate answers the NEGOTIATE request on conn.
+func answerNegotiate(conn net.Conn) {
+	defer conn.Close()
+	var nb [4]byte
+	io.ReadFull(conn, nb[:])
+	io.ReadFull(conn, make([]byte, binary.BigEndian.Uint32(nb[:])))
+
+	resp := make([]byte, 4+headerSize+65)
+	binary.BigEndian.PutUint32(resp, headerSize+65)
+	copy(resp[4:], smbMagic)
+	body := resp[4+headerSize:]
+	binary.LittleEndian.PutUint16(body[0:], 65)
+	binary.LittleEndian.PutUint16(body[2:], securitySigningRequired)
+	binary.LittleEndian.PutUint16(body[4:], 0x0302)
+	copy(body[8:24], []byte{0x33, 0x22, 0x11, 0x00, 0x55, 0x44, 0x77, 0x66, 0x88, 0x99, 0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff})
+	conn.Write(resp)
+}
+
+func TestPing(t *testing.T) {
+	port := serveNegotiate(t)
+	stats := New("127.0.0.1", port, &pinger.Option{Timeout: time.Second}).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["dialect"].String(); got != "3.0.2" {
+		t.Fatalf("dialect = %s", got)
+	}
+	if got := stats.Meta["guid"].String(); got != "00112233-4455-6677-8899-aabbccddeeff" {
+		t.Fatalf("guid = %s", got)
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", serveNegotiate(t), &pinger.Option{Timeout: timeout})
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout})
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.NewTCPBlackhole(t).Port, &pinger.Option{Timeout: timeout})
+		},
+	})
+}
+
+func FuzzParseNegotiate(f *testing.F) {
+	msg := make([]byte, headerSize+65)
+	copy(msg, smbMagic)
+	binary.LittleEndian.PutUint16(msg[headerSize:], 65)
+	binary.LittleEndian.PutUint16(msg[headerSize+4:], 0x0302)
+	f.Add(msg)
+	f.Add([]byte(smbMagic))
+	f.Fuzz(func(t *testing.T, msg []byte) {
+		parseNegotiate(msg)
+	})
+}
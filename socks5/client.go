@@ -0,0 +1,227 @@
+package socks5
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// Protocol constants from RFC 1928 and RFC 1929.
+const (
+	version5       = 0x05
+	authVersion    = 0x01
+	methodNone     = 0x00
+	methodUserPass = 0x02
+	methodNoAccept = 0xff
+
+	cmdConnect      = 0x01
+	cmdUDPAssociate = 0x03
+
+	atypIPv4   = 0x01
+	atypDomain = 0x03
+	atypIPv6   = 0x04
+)
+
+// ErrNoAcceptableMethod is returned when the proxy rejects every offered
+// authentication method.
+var ErrNoAcceptableMethod = errors.New("socks5: no acceptable authentication method")
+
+// replyMessages maps CONNECT reply codes to their RFC 1928 descriptions.
+var replyMessages = map[byte]string{
+	0x01: "general SOCKS server failure",
+	0x02: "connection not allowed by ruleset",
+	0x03: "network unreachable",
+	0x04: "host unreachable",
+	0x05: "connection refused",
+	0x06: "TTL expired",
+	0x07: "command not supported",
+	0x08: "address type not supported",
+}
+
+// Client performs the client side of the SOCKS5 protocol over an existing
+// connection to the proxy.
+type Client struct {
+	Username string
+	Password string
+}
+
+// Method returns a readable name for a negotiated authentication method.
+func Method(method byte) string {
+	switch method {
+	case methodNone:
+		return "none"
+	case methodUserPass:
+		return "userpass"
+	default:
+		return fmt.Sprintf("0x%02x", method)
+	}
+}
+
+// Handshake runs the greeting and, when required, the username/password
+// sub-negotiation. It returns the method selected by the proxy.
+func (c *Client) Handshake(conn io.ReadWriter) (byte, error) {
+	methods := []byte{methodNone}
+	if c.Username != "" {
+		methods = append(methods, methodUserPass)
+	}
+	greeting := append([]byte{version5, byte(len(methods))}, methods...)
+	if _, err := conn.Write(greeting); err != nil {
+		return 0, fmt.Errorf("socks5: write greeting: %w", err)
+	}
+
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return 0, fmt.Errorf("socks5: read greeting reply: %w", err)
+	}
+	if resp[0] != version5 {
+		return 0, fmt.Errorf("socks5: unexpected version %d", resp[0])
+	}
+
+	switch resp[1] {
+	case methodNone:
+		return resp[1], nil
+	case methodUserPass:
+		if c.Username == "" {
+			return resp[1], errors.New("socks5: proxy requires credentials")
+		}
+		return resp[1], c.authenticate(conn)
+	case methodNoAccept:
+		return resp[1], ErrNoAcceptableMethod
+	default:
+		return resp[1], fmt.Errorf("socks5: unsupported method 0x%02x", resp[1])
+	}
+}
+
+// authenticate performs the RFC 1929 username/password sub-negotiation.
+func (c *Client) authenticate(conn io.ReadWriter) error {
+	if len(c.Username) > 255 || len(c.Password) > 255 {
+		return errors.New("socks5: username or password too long")
+	}
+	req := []byte{authVersion, byte(len(c.Username))}
+	req = append(req, c.Username...)
+	req = append(req, byte(len(c.Password)))
+	req = append(req, c.Password...)
+	if _, err := conn.Write(req); err != nil {
+		return fmt.Errorf("socks5: write auth: %w", err)
+	}
+	var resp [2]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return fmt.Errorf("socks5: read auth reply: %w", err)
+	}
+	if resp[1] != 0x00 {
+		return errors.New("socks5: authentication failed")
+	}
+	return nil
+}
+
+// Connect asks the proxy to open a TCP connection to address and returns the
+// address the proxy bound for it.
+func (c *Client) Connect(conn io.ReadWriter, address string) (string, error) {
+	return c.request(conn, cmdConnect, address)
+}
+
+// UDPAssociate asks the proxy to relay UDP datagrams sent from address and
+// returns the relay address datagrams must be sent to.
+func (c *Client) UDPAssociate(conn io.ReadWriter, address string) (string, error) {
+	return c.request(conn, cmdUDPAssociate, address)
+}
+
+// request sends a SOCKS5 command and parses the reply.
+func (c *Client) request(conn io.ReadWriter, cmd byte, address string) (string, error) {
+	addr, err := AppendAddress([]byte{version5, cmd, 0x00}, address)
+	if err != nil {
+		return "", err
+	}
+	if _, err := conn.Write(addr); err != nil {
+		return "", fmt.Errorf("socks5: write request: %w", err)
+	}
+
+	var head [3]byte
+	if _, err := io.ReadFull(conn, head[:]); err != nil {
+		return "", fmt.Errorf("socks5: read reply: %w", err)
+	}
+	if head[0] != version5 {
+		return "", fmt.Errorf("socks5: unexpected version %d", head[0])
+	}
+	bound, err := ReadAddress(conn)
+	if err != nil {
+		return "", err
+	}
+	if head[1] != 0x00 {
+		msg, ok := replyMessages[head[1]]
+		if !ok {
+			msg = fmt.Sprintf("reply code 0x%02x", head[1])
+		}
+		return bound, fmt.Errorf("socks5: %s", msg)
+	}
+	return bound, nil
+}
+
+// AppendAddress appends the SOCKS5 encoding of a host:port address to b.
+func AppendAddress(b []byte, address string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("socks5: %w", err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port < 0 || port > 0xffff {
+		return nil, fmt.Errorf("socks5: invalid port %q", portStr)
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, atypIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, atypIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, fmt.Errorf("socks5: host name %q too long", host)
+		}
+		b = append(b, atypDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+	return binary.BigEndian.AppendUint16(b, uint16(port)), nil
+}
+
+// ReadAddress reads a SOCKS5 encoded address (type, address, port).
+func ReadAddress(r io.Reader) (string, error) {
+	var atyp [1]byte
+	if _, err := io.ReadFull(r, atyp[:]); err != nil {
+		return "", fmt.Errorf("socks5: read address type: %w", err)
+	}
+	var host string
+	switch atyp[0] {
+	case atypIPv4, atypIPv6:
+		size := net.IPv4len
+		if atyp[0] == atypIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(r, ip); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		host = ip.String()
+	case atypDomain:
+		var size [1]byte
+		if _, err := io.ReadFull(r, size[:]); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		name := make([]byte, size[0])
+		if _, err := io.ReadFull(r, name); err != nil {
+			return "", fmt.Errorf("socks5: read address: %w", err)
+		}
+		host = string(name)
+	default:
+		return "", fmt.Errorf("socks5: unknown address type 0x%02x", atyp[0])
+	}
+	var port [2]byte
+	if _, err := io.ReadFull(r, port[:]); err != nil {
+		return "", fmt.Errorf("socks5: read port: %w", err)
+	}
+	return net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port[:])))), nil
+}
@@ -0,0 +1,210 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DialFunc connects to an address, such as net.Dialer.DialContext.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Dialer connects to targets through a SOCKS5 proxy: TCP with CONNECT, and
+// UDP with UDP ASSOCIATE, relaying datagrams through the proxy. Host names
+// are sent as they are, for the proxy to resolve.
+type Dialer struct {
+	proxy  string // host:port of the proxy
+	client *Client
+	dial   DialFunc
+}
+
+// NewDialer creates a Dialer for a socks5://[user:password@]host[:port]
+// proxy URL, authenticating with its credentials, which connects to the
+// proxy with dial.
+func NewDialer(proxy *url.URL, dial DialFunc) (*Dialer, error) {
+	if proxy.Scheme != "socks5" {
+		return nil, fmt.Errorf("socks5: unsupported proxy scheme %q", proxy.Scheme)
+	}
+	port := proxy.Port()
+	if port == "" {
+		port = "1080"
+	}
+	client := &Client{}
+	if proxy.User != nil {
+		client.Username = proxy.User.Username()
+		client.Password, _ = proxy.User.Password()
+	}
+	return &Dialer{proxy: net.JoinHostPort(proxy.Hostname(), port), client: client, dial: dial}, nil
+}
+
+// IsProxy reports whether proxy is a SOCKS5 proxy URL, which Dialer takes.
+func IsProxy(proxy *url.URL) bool {
+	return proxy != nil && proxy.Scheme == "socks5"
+}
+
+// DialContext connects to addr through the proxy. network is "tcp" or
+// "udp", possibly restricted to a family, which only applies to the
+// connection to the proxy. The returned connection reports addr as its
+// remote address.
+func (d *Dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	family := strings.TrimLeft(network, "tcpud")
+	switch strings.TrimRight(network, "46") {
+	case "tcp":
+		return d.connect(ctx, "tcp"+family, addr)
+	case "udp":
+		return d.associate(ctx, "tcp"+family, "udp"+family, addr)
+	}
+	return nil, fmt.Errorf("socks5: unsupported network %q", network)
+}
+
+// handshake connects to the proxy and negotiates a session, sending cmd for
+// address, within ctx. It returns the control connection and the address
+// the proxy bound.
+func (d *Dialer) handshake(ctx context.Context, network string, cmd byte, address string) (net.Conn, string, error) {
+	conn, err := d.dial(ctx, network, d.proxy)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// Interrupt blocked reads and writes when the context ends
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	bound := ""
+	if _, err = d.client.Handshake(conn); err == nil {
+		bound, err = d.client.request(conn, cmd, address)
+	}
+	if !stop() || err != nil {
+		conn.Close()
+		if err == nil {
+			err = ctx.Err()
+		}
+		return nil, "", err
+	}
+	conn.SetDeadline(time.Time{})
+	return conn, bound, nil
+}
+
+// connect opens a TCP connection to addr with CONNECT.
+func (d *Dialer) connect(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, _, err := d.handshake(ctx, network, cmdConnect, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &tcpConn{Conn: conn, remote: proxiedAddr{"tcp", addr}}, nil
+}
+
+// associate asks the proxy to relay UDP datagrams to addr. The association
+// lasts as long as the control connection, which the returned connection
+// closes along with its own.
+func (d *Dialer) associate(ctx context.Context, network, udpNetwork, addr string) (net.Conn, error) {
+	// The client address is not known before the relay answers, so the
+	// proxy is asked to accept datagrams from any
+	control, relay, err := d.handshake(ctx, network, cmdUDPAssociate, "0.0.0.0:0")
+	if err != nil {
+		return nil, err
+	}
+
+	// Proxies answering with an unspecified address relay on their own
+	host, port, err := net.SplitHostPort(relay)
+	if err != nil {
+		control.Close()
+		return nil, fmt.Errorf("socks5: invalid relay address %q", relay)
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		proxyHost, _, _ := net.SplitHostPort(d.proxy)
+		relay = net.JoinHostPort(proxyHost, port)
+	}
+	conn, err := d.dial(ctx, udpNetwork, relay)
+	if err != nil {
+		control.Close()
+		return nil, err
+	}
+	header, err := AppendAddress([]byte{0x00, 0x00, 0x00}, addr)
+	if err != nil {
+		control.Close()
+		conn.Close()
+		return nil, err
+	}
+	return &udpConn{Conn: conn, control: control, header: header, remote: proxiedAddr{"udp", addr}}, nil
+}
+
+// proxiedAddr is the address of a target reached through the proxy, which
+// may be a host name.
+type proxiedAddr struct {
+	network, addr string
+}
+
+func (a proxiedAddr) Network() string { return a.network }
+func (a proxiedAddr) String() string  { return a.addr }
+
+// tcpConn is a connection tunnelled with CONNECT.
+type tcpConn struct {
+	net.Conn
+	remote net.Addr
+}
+
+// RemoteAddr returns the address of the target, rather than of the proxy.
+func (c *tcpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// udpConn relays datagrams through the proxy, adding the SOCKS5 UDP
+// request header to those it writes and removing it from those it reads.
+type udpConn struct {
+	net.Conn
+	control net.Conn
+	header  []byte
+	remote  net.Addr
+}
+
+// Read reads the payload of the next datagram relayed from the target.
+func (c *udpConn) Read(b []byte) (int, error) {
+	buf := make([]byte, len(b)+len(c.header)+255)
+	n, err := c.Conn.Read(buf)
+	if err != nil {
+		return 0, err
+	}
+	r := bytes.NewReader(buf[:n])
+	var head [3]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return 0, errors.New("socks5: short relayed datagram")
+	}
+	if head[2] != 0x00 {
+		return 0, errors.New("socks5: fragmented relayed datagram")
+	}
+	if _, err := ReadAddress(r); err != nil {
+		return 0, err
+	}
+	n, _ = r.Read(b)
+	return n, nil
+}
+
+// Write sends b to the target through the relay.
+func (c *udpConn) Write(b []byte) (int, error) {
+	if _, err := c.Conn.Write(append(c.header[:len(c.header):len(c.header)], b...)); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// Close closes the relay connection and ends the association.
+func (c *udpConn) Close() error {
+	err := c.Conn.Close()
+	if cerr := c.control.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// RemoteAddr returns the address of the target, rather than of the relay.
+func (c *udpConn) RemoteAddr() net.Addr {
+	return c.remote
+}
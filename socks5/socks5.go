@@ -0,0 +1,110 @@
+// Package socks5 provides SOCKS5 proxy ping functionality for the circle-pinger tool.
+package socks5
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// New creates a new SOCKS5 Ping instance. When connect is not empty, every
+// probe also asks the proxy to CONNECT to that host:port.
+func New(host string, port int, op *pinger.Option, client *Client, connect string) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	if client == nil {
+		client = &Client{}
+	}
+	return &Ping{
+		host:    host,
+		port:    port,
+		option:  op,
+		client:  client,
+		connect: connect,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+}
+
+// Ping is the SOCKS5 ping implementation. It measures how long the proxy
+// takes to negotiate a session and, optionally, to broker a connection.
+type Ping struct {
+	option  *pinger.Option
+	host    string
+	port    int
+	client  *Client
+	connect string
+	dialer  *net.Dialer
+}
+
+// Ping performs the SOCKS5 negotiation against the proxy.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+
+	start := time.Now()
+	conn, err := p.dialer.DialContext(ctx, "tcp", net.JoinHostPort(p.host, strconv.Itoa(p.port)))
+	connectDuration := time.Since(start)
+	if err != nil {
+		stats.Error = err
+		stats.Duration = connectDuration
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			stats.Address = oe.Addr.String()
+		}
+		return stats
+	}
+	defer conn.Close()
+	stats.Address = conn.RemoteAddr().String()
+	stats.Meta["dial"] = connectDuration
+
+	// Interrupt blocked reads and writes when the context ends
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	negotiateStart := time.Now()
+	method, err := p.client.Handshake(conn)
+	stats.Meta["negotiate"] = time.Since(negotiateStart)
+	stats.Meta["method"] = pinger.StringerFunc(func() string { return Method(method) })
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		return stats
+	}
+
+	if p.connect != "" {
+		brokerStart := time.Now()
+		bound, err := p.client.Connect(conn, p.connect)
+		stats.Meta["connect"] = time.Since(brokerStart)
+		if err != nil {
+			stats.Error = err
+			stats.Duration = time.Since(start)
+			return stats
+		}
+		stats.Meta["bound"] = pinger.StringerFunc(func() string { return bound })
+	}
+
+	stats.Connected = true
+	stats.Duration = time.Since(start)
+	return stats
+}
@@ -0,0 +1,257 @@
+package socks5
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+// serveSOCKS5 runs a minimal SOCKS5 server that requires user/pass and
+// accepts every CONNECT, replying with the requested address.
+func serveSOCKS5(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				head := make([]byte, 2)
+				io.ReadFull(conn, head)
+				io.ReadFull(conn, make([]byte, head[1]))
+				conn.Write([]byte{version5, methodUserPass})
+
+				io.ReadFull(conn, head)
+				user := make([]byte, head[1])
+				io.ReadFull(conn, user)
+				io.ReadFull(conn, head[:1])
+				pass := make([]byte, head[0])
+				io.ReadFull(conn, pass)
+				if string(user) != "user" || string(pass) != "pass" {
+					conn.Write([]byte{authVersion, 0x01})
+					return
+				}
+				conn.Write([]byte{authVersion, 0x00})
+
+				req := make([]byte, 3)
+				if _, err := io.ReadFull(conn, req); err != nil {
+					return
+				}
+				addr, err := ReadAddress(conn)
+				if err != nil {
+					return
+				}
+				reply, _ := AppendAddress([]byte{version5, 0x00, 0x00}, addr)
+				conn.Write(reply)
+			}(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+func TestPing(t *testing.T) {
+	addr := serveSOCKS5(t)
+	client := &Client{Username: "user", Password: "pass"}
+	ping := New("127.0.0.1", addr.Port, &pinger.Option{Timeout: time.Second}, client, "example.com:443")
+	stats := ping.Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["bound"].String(); got != "example.com:443" {
+		t.Fatalf("bound address = %s", got)
+	}
+}
+
+func TestPing_BadCredentials(t *testing.T) {
+	addr := serveSOCKS5(t)
+	client := &Client{Username: "user", Password: "wrong"}
+	ping := New("127.0.0.1", addr.Port, &pinger.Option{Timeout: time.Second}, client, "")
+	stats := ping.Ping(context.Background())
+	if stats.Connected {
+		t.Fatalf("it should fail authentication")
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	newPing := func(port int, timeout time.Duration) pinger.Ping {
+		client := &Client{Username: "user", Password: "pass"}
+		return New("127.0.0.1", port, &pinger.Option{Timeout: timeout}, client, "example.com:443")
+	}
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(serveSOCKS5(t).Port, timeout)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(pingertest.ClosedPort(t), timeout)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return newPing(pingertest.NewTCPBlackhole(t).Port, timeout)
+		},
+	})
+}
+
+func FuzzReadAddress(f *testing.F) {
+	for _, addr := range []string{"example.com:443", "192.0.2.1:80", "[2001:db8::1]:8080"} {
+		b, _ := AppendAddress(nil, addr)
+		f.Add(b)
+	}
+	f.Add([]byte{atypDomain, 0xff})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		addr, err := ReadAddress(bytes.NewReader(b))
+		if err != nil {
+			return
+		}
+		// An address read once survives encoding and decoding unchanged
+		encoded, err := AppendAddress(nil, addr)
+		if err != nil {
+			return
+		}
+		again, err := ReadAddress(bytes.NewReader(encoded))
+		if err != nil || again != addr {
+			t.Fatalf("address %q read back as %q, %v", addr, again, err)
+		}
+	})
+}
+
+// serveSOCKS5Relay runs a SOCKS5 server without authentication that relays
+// CONNECT tunnels and UDP ASSOCIATE datagrams to the requested addresses.
+func serveSOCKS5Relay(t *testing.T) *net.TCPAddr {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				head := make([]byte, 3)
+				io.ReadFull(conn, head[:2])
+				io.ReadFull(conn, make([]byte, head[1]))
+				conn.Write([]byte{version5, methodNone})
+
+				if _, err := io.ReadFull(conn, head); err != nil {
+					return
+				}
+				addr, err := ReadAddress(conn)
+				if err != nil {
+					return
+				}
+				switch head[1] {
+				case cmdConnect:
+					target, err := net.Dial("tcp", addr)
+					if err != nil {
+						conn.Write([]byte{version5, 0x05, 0x00, atypIPv4, 0, 0, 0, 0, 0, 0})
+						return
+					}
+					defer target.Close()
+					reply, _ := AppendAddress([]byte{version5, 0x00, 0x00}, target.LocalAddr().String())
+					conn.Write(reply)
+					go io.Copy(target, conn)
+					io.Copy(conn, target)
+				case cmdUDPAssociate:
+					relay, err := net.ListenPacket("udp", "127.0.0.1:0")
+					if err != nil {
+						return
+					}
+					defer relay.Close()
+					// Relay on the unspecified address, as many proxies answer
+					port := relay.LocalAddr().(*net.UDPAddr).Port
+					reply, _ := AppendAddress([]byte{version5, 0x00, 0x00}, net.JoinHostPort("0.0.0.0", strconv.Itoa(port)))
+					conn.Write(reply)
+					go relayDatagrams(relay)
+					io.Copy(io.Discard, conn)
+				}
+			}(conn)
+		}
+	}()
+	return ln.Addr().(*net.TCPAddr)
+}
+
+// relayDatagrams sends every datagram of a client to the target in its
+// header and relays a single reply back.
+func relayDatagrams(relay net.PacketConn) {
+	buf := make([]byte, 2048)
+	for {
+		n, client, err := relay.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		r := bytes.NewReader(buf[3:n])
+		addr, err := ReadAddress(r)
+		if err != nil {
+			continue
+		}
+		payload, _ := io.ReadAll(r)
+		target, err := net.Dial("udp", addr)
+		if err != nil {
+			continue
+		}
+		target.Write(payload)
+		target.SetReadDeadline(time.Now().Add(time.Second))
+		m, err := target.Read(buf)
+		target.Close()
+		if err != nil {
+			continue
+		}
+		reply, _ := AppendAddress([]byte{0x00, 0x00, 0x00}, addr)
+		relay.WriteTo(append(reply, buf[:m]...), client)
+	}
+}
+
+func TestDialer(t *testing.T) {
+	proxy := serveSOCKS5Relay(t)
+	u, _ := url.Parse("socks5://" + proxy.String())
+	d, err := NewDialer(u, (&net.Dialer{}).DialContext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	for _, c := range []struct {
+		network string
+		server  *pingertest.Server
+	}{
+		{"tcp", pingertest.NewTCPEchoServer(t)},
+		{"udp", pingertest.NewUDPEchoServer(t)},
+	} {
+		conn, err := d.DialContext(ctx, c.network, c.server.Addr())
+		if err != nil {
+			t.Fatalf("%s: dial through the proxy: %v", c.network, err)
+		}
+		if got := conn.RemoteAddr().String(); got != c.server.Addr() {
+			t.Errorf("%s: remote address %s, want the target", c.network, got)
+		}
+		conn.SetDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Write([]byte("ping")); err != nil {
+			t.Fatalf("%s: write: %v", c.network, err)
+		}
+		buf := make([]byte, 16)
+		n, err := conn.Read(buf)
+		if err != nil || string(buf[:n]) != "ping" {
+			t.Fatalf("%s: read %q, %v", c.network, buf[:n], err)
+		}
+		conn.Close()
+	}
+}
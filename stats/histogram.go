@@ -0,0 +1,59 @@
+package stats
+
+import (
+	"slices"
+	"time"
+)
+
+// Bucket is a range of durations [Low, High) and the number of durations in
+// it. The last bucket of a histogram includes its High.
+type Bucket struct {
+	Low, High time.Duration
+	Count     int
+}
+
+// Histogram counts durations in n buckets of equal width spanning their
+// minimum to maximum. It returns a single bucket when all durations are
+// equal, and none without durations.
+func Histogram(durations []time.Duration, n int) []Bucket {
+	if len(durations) == 0 || n <= 0 {
+		return nil
+	}
+	lo, hi := slices.Min(durations), slices.Max(durations)
+	if lo == hi {
+		return []Bucket{{Low: lo, High: hi, Count: len(durations)}}
+	}
+	width := (hi - lo + time.Duration(n) - 1) / time.Duration(n)
+	buckets := make([]Bucket, n)
+	for i := range buckets {
+		buckets[i].Low = lo + time.Duration(i)*width
+		buckets[i].High = buckets[i].Low + width
+	}
+	buckets[n-1].High = hi
+	for _, d := range durations {
+		i := min(int((d-lo)/width), n-1)
+		buckets[i].Count++
+	}
+	return buckets
+}
+
+// sparks are the block elements of a sparkline, from lowest to highest.
+var sparks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders durations as a line of block elements scaled between
+// their minimum and maximum, one per duration.
+func Sparkline(durations []time.Duration) string {
+	if len(durations) == 0 {
+		return ""
+	}
+	lo, hi := slices.Min(durations), slices.Max(durations)
+	line := make([]rune, len(durations))
+	for i, d := range durations {
+		level := 0
+		if hi > lo {
+			level = int(float64(d-lo) / float64(hi-lo) * float64(len(sparks)-1))
+		}
+		line[i] = sparks[level]
+	}
+	return string(line)
+}
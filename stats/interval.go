@@ -0,0 +1,81 @@
+package stats
+
+import (
+	"math"
+	"time"
+)
+
+// z95 is the standard normal quantile of a two-sided 95% interval.
+const z95 = 1.959963984540054
+
+// t95 holds the Student's t quantiles of a two-sided 95% interval for 1 to
+// 30 degrees of freedom.
+var t95 = [...]float64{
+	12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+	2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+	2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042,
+}
+
+// tQuantile95 returns the Student's t quantile of a two-sided 95% interval
+// for df degrees of freedom. Beyond the table it uses the Cornish-Fisher
+// expansion, which is accurate to three decimals there.
+func tQuantile95(df int) float64 {
+	if df <= len(t95) {
+		return t95[df-1]
+	}
+	n := float64(df)
+	return z95 + (z95*z95*z95+z95)/(4*n) + (5*math.Pow(z95, 5)+16*z95*z95*z95+3*z95)/(96*n*n)
+}
+
+// MeanCI returns the 95% confidence interval of the mean of n values given
+// their sum and sum of squares, using the t distribution so that small
+// samples get appropriately wide intervals. ok is false for fewer than two
+// values.
+func MeanCI(n int, sum, sumSquares float64) (lo, hi float64, ok bool) {
+	if n < 2 {
+		return 0, 0, false
+	}
+	fn := float64(n)
+	mean := sum / fn
+	variance := max((sumSquares-fn*mean*mean)/(fn-1), 0)
+	margin := tQuantile95(n-1) * math.Sqrt(variance/fn)
+	return mean - margin, mean + margin, true
+}
+
+// WilsonCI returns the 95% Wilson score interval of the proportion
+// failed/total. Unlike the normal approximation it stays within [0, 1] and
+// behaves well for small totals and proportions near 0 or 1.
+func WilsonCI(failed, total int) (lo, hi float64) {
+	if total == 0 {
+		return 0, 1
+	}
+	n := float64(total)
+	p := float64(failed) / n
+	z2 := z95 * z95
+	center := (p + z2/(2*n)) / (1 + z2/n)
+	margin := z95 / (1 + z2/n) * math.Sqrt(p*(1-p)/n+z2/(4*n*n))
+	return max(center-margin, 0), min(center+margin, 1)
+}
+
+// MeanCI returns the 95% confidence interval of the mean duration of the
+// successful probes; ok is false with fewer than two of them.
+func (s *Sample) MeanCI() (lo, hi time.Duration, ok bool) {
+	var sum, sumSquares float64
+	for _, d := range s.Durations {
+		sec := d.Seconds()
+		sum += sec
+		sumSquares += sec * sec
+	}
+	l, h, ok := MeanCI(len(s.Durations), sum, sumSquares)
+	return Seconds(max(l, 0)), Seconds(h), ok
+}
+
+// LossCI returns the 95% confidence interval of the loss rate.
+func (s *Sample) LossCI() (lo, hi float64) {
+	return WilsonCI(s.Failed, s.Total)
+}
+
+// Seconds converts seconds to a Duration.
+func Seconds(sec float64) time.Duration {
+	return time.Duration(sec * float64(time.Second))
+}
@@ -0,0 +1,69 @@
+// Package stats provides the statistics computed over probe results, such
+// as percentiles and loss rates, shared by the summaries and reports.
+package stats
+
+import (
+	"math"
+	"slices"
+	"time"
+)
+
+// Sample accumulates the results of the probes of one target.
+type Sample struct {
+	Total     int
+	Failed    int
+	Durations []time.Duration // durations of the successful probes
+	sorted    bool
+}
+
+// Add accounts for one probe result.
+func (s *Sample) Add(connected bool, d time.Duration) {
+	s.Total++
+	if !connected {
+		s.Failed++
+		return
+	}
+	s.Durations = append(s.Durations, d)
+	s.sorted = false
+}
+
+// Loss returns the fraction of failed probes, from 0 to 1.
+func (s *Sample) Loss() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.Failed) / float64(s.Total)
+}
+
+// Mean returns the mean duration of the successful probes.
+func (s *Sample) Mean() time.Duration {
+	if len(s.Durations) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range s.Durations {
+		sum += d
+	}
+	return sum / time.Duration(len(s.Durations))
+}
+
+// Percentile returns the p-th percentile (0 to 100) of the successful probe
+// durations, or 0 without any.
+func (s *Sample) Percentile(p float64) time.Duration {
+	if !s.sorted {
+		slices.Sort(s.Durations)
+		s.sorted = true
+	}
+	return Percentile(s.Durations, p)
+}
+
+// Percentile returns the p-th percentile (0 to 100) of sorted using the
+// nearest-rank method, or 0 when sorted is empty.
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	rank = min(max(rank, 1), len(sorted))
+	return sorted[rank-1]
+}
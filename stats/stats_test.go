@@ -0,0 +1,104 @@
+package stats
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSample(t *testing.T) {
+	var s Sample
+	for i := 20; i >= 1; i-- {
+		s.Add(i != 20, time.Duration(i)*time.Millisecond)
+	}
+	if s.Loss() != 0.05 {
+		t.Fatalf("loss = %v", s.Loss())
+	}
+	if got := s.Mean(); got != 10*time.Millisecond {
+		t.Fatalf("mean = %s", got)
+	}
+	for p, want := range map[float64]time.Duration{0: 1, 50: 10, 95: 19, 100: 19} {
+		if got := s.Percentile(p); got != want*time.Millisecond {
+			t.Fatalf("p%v = %s, want %s", p, got, want*time.Millisecond)
+		}
+	}
+}
+
+func durations(ms ...int) []time.Duration {
+	d := make([]time.Duration, len(ms))
+	for i, v := range ms {
+		d[i] = time.Duration(v) * time.Millisecond
+	}
+	return d
+}
+
+func TestMannWhitney(t *testing.T) {
+	same := durations(10, 11, 12, 13, 14, 15, 16, 17, 18, 19)
+	if p := MannWhitney(same, same); p < 0.9 {
+		t.Fatalf("identical samples should not differ, p = %v", p)
+	}
+	slower := durations(20, 21, 22, 23, 24, 25, 26, 27, 28, 29)
+	if p := MannWhitney(same, slower); p > 0.001 {
+		t.Fatalf("disjoint samples should differ, p = %v", p)
+	}
+	if p := MannWhitney(nil, slower); p != 1 {
+		t.Fatalf("empty sample should give p = 1, got %v", p)
+	}
+}
+
+func TestTwoProportion(t *testing.T) {
+	if p := TwoProportion(1, 100, 2, 100); p < 0.5 {
+		t.Fatalf("1%% and 2%% loss over 100 probes should not differ, p = %v", p)
+	}
+	if p := TwoProportion(0, 100, 20, 100); p > 0.001 {
+		t.Fatalf("0%% and 20%% loss over 100 probes should differ, p = %v", p)
+	}
+}
+
+func TestMeanCI(t *testing.T) {
+	// Four samples of mean 10 and standard deviation 1: 10 ± 3.182/2
+	lo, hi, ok := MeanCI(4, 40, 4*100+3)
+	if !ok || math.Abs(lo-8.409) > 0.001 || math.Abs(hi-11.591) > 0.001 {
+		t.Fatalf("CI = [%v, %v]", lo, hi)
+	}
+	if _, _, ok := MeanCI(1, 10, 100); ok {
+		t.Fatal("a single value has no interval")
+	}
+	if q := tQuantile95(100); math.Abs(q-1.984) > 0.001 {
+		t.Fatalf("t(100) = %v", q)
+	}
+}
+
+func TestWilsonCI(t *testing.T) {
+	// No loss in 4 probes is still compatible with about half of them failing
+	lo, hi := WilsonCI(0, 4)
+	if lo != 0 || math.Abs(hi-0.490) > 0.001 {
+		t.Fatalf("CI = [%v, %v]", lo, hi)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	// A bimodal distribution, as behind a load balancer with a slow backend
+	var durations []time.Duration
+	for range 6 {
+		durations = append(durations, 10*time.Millisecond, 50*time.Millisecond)
+	}
+	durations = append(durations, 11*time.Millisecond)
+	buckets := Histogram(durations, 4)
+	counts := []int{7, 0, 0, 6}
+	for i, b := range buckets {
+		if b.Count != counts[i] {
+			t.Fatalf("bucket %s-%s has %d durations, want %d", b.Low, b.High, b.Count, counts[i])
+		}
+	}
+	if buckets[0].Low != 10*time.Millisecond || buckets[3].High != 50*time.Millisecond {
+		t.Fatalf("buckets span %s-%s", buckets[0].Low, buckets[3].High)
+	}
+	if got := Histogram([]time.Duration{time.Second, time.Second}, 4); len(got) != 1 || got[0].Count != 2 {
+		t.Fatalf("equal durations: %v", got)
+	}
+
+	if got := Sparkline([]time.Duration{time.Millisecond, 5 * time.Millisecond, 8 * time.Millisecond}); got != "▁▅█" {
+		t.Fatalf("Sparkline = %q", got)
+	}
+}
@@ -0,0 +1,84 @@
+package stats
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"time"
+)
+
+// MannWhitney returns the two-sided p-value of the Mann-Whitney U test of
+// whether durations in a and b come from the same distribution. It uses the
+// normal approximation with tie correction and returns 1 when either
+// sample is empty. Unlike a t-test it is not thrown off by the long tail
+// latency distributions usually have.
+func MannWhitney(a, b []time.Duration) float64 {
+	n1, n2 := float64(len(a)), float64(len(b))
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+
+	type value struct {
+		d     time.Duration
+		fromA bool
+	}
+	values := make([]value, 0, len(a)+len(b))
+	for _, d := range a {
+		values = append(values, value{d, true})
+	}
+	for _, d := range b {
+		values = append(values, value{d, false})
+	}
+	slices.SortFunc(values, func(x, y value) int { return cmp.Compare(x.d, y.d) })
+
+	// Sum the ranks of a, averaging the ranks of ties
+	var rankSumA, ties float64
+	for i := 0; i < len(values); {
+		j := i
+		for j < len(values) && values[j].d == values[i].d {
+			j++
+		}
+		rank := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			if values[k].fromA {
+				rankSumA += rank
+			}
+		}
+		t := float64(j - i)
+		ties += t*t*t - t
+		i = j
+	}
+
+	u := rankSumA - n1*(n1+1)/2
+	n := n1 + n2
+	mean := n1 * n2 / 2
+	variance := n1 * n2 / 12 * ((n + 1) - ties/(n*(n-1)))
+	if variance <= 0 {
+		return 1
+	}
+	// Continuity correction
+	z := (math.Abs(u-mean) - 0.5) / math.Sqrt(variance)
+	return twoSided(max(z, 0))
+}
+
+// TwoProportion returns the two-sided p-value of the z-test of whether the
+// failure rates failed1/total1 and failed2/total2 differ. It returns 1 when
+// either total is zero or the pooled rate is 0 or 1.
+func TwoProportion(failed1, total1, failed2, total2 int) float64 {
+	if total1 == 0 || total2 == 0 {
+		return 1
+	}
+	n1, n2 := float64(total1), float64(total2)
+	p1, p2 := float64(failed1)/n1, float64(failed2)/n2
+	pooled := float64(failed1+failed2) / (n1 + n2)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/n1 + 1/n2))
+	if se == 0 {
+		return 1
+	}
+	return twoSided(math.Abs(p1-p2) / se)
+}
+
+// twoSided returns the two-sided p-value of a standard normal statistic z.
+func twoSided(z float64) float64 {
+	return math.Erfc(z / math.Sqrt2)
+}
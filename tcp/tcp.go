@@ -7,16 +7,20 @@ import (
 	"fmt"
 	"net"
 	"net/http/httptrace"
+	"strconv"
 	"time"
 
 	"github.com/circle-protocol/circle-pinger/meta"
 	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/proxyauth"
+	"github.com/circle-protocol/circle-pinger/socks5"
+	"github.com/circle-protocol/circle-pinger/tcpinfo"
 )
 
 var _ pinger.Ping = (*Ping)(nil)
 
 func New(host string, port int, op *pinger.Option, tls bool) *Ping {
-	return &Ping{
+	p := &Ping{
 		tls:    tls,
 		host:   host,
 		port:   port,
@@ -25,14 +29,34 @@ func New(host string, port int, op *pinger.Option, tls bool) *Ping {
 			Resolver: op.Resolver,
 		},
 	}
+	// A proxy connects to the target on behalf of the probes, a SOCKS5 one
+	// with CONNECT and an http:// one with a CONNECT tunnel
+	switch {
+	case socks5.IsProxy(op.Proxy):
+		p.proxy, _ = socks5.NewDialer(op.Proxy, p.dialer.DialContext)
+	case op.Proxy != nil && op.Proxy.Scheme == "http":
+		if d, err := proxyauth.New(op.Proxy, op.ProxyAuth, p.dialer.DialContext); err != nil {
+			p.proxyErr = err
+		} else {
+			p.proxy = d
+		}
+	}
+	return p
 }
 
 type Ping struct {
-	option *pinger.Option
-	host   string
-	port   int
-	dialer *net.Dialer
-	tls    bool
+	option   *pinger.Option
+	host     string
+	port     int
+	dialer   *net.Dialer
+	proxy    proxyDialer
+	proxyErr error // why the proxy cannot be used, failing every probe
+	tls      bool
+}
+
+// proxyDialer connects to a target through a proxy.
+type proxyDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
 }
 
 func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
@@ -45,6 +69,7 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 
 	var stats pinger.Stats
 	var dnsStart time.Time
+	var resolved []net.IP
 	// trace dns query
 	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
 		DNSStart: func(info httptrace.DNSStartInfo) {
@@ -52,6 +77,9 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 		},
 		DNSDone: func(info httptrace.DNSDoneInfo) {
 			stats.DNSDuration = time.Since(dnsStart)
+			for _, addr := range info.Addrs {
+				resolved = append(resolved, addr.IP)
+			}
 		},
 	})
 
@@ -62,18 +90,29 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 		tlsConn *tls.Conn
 		tlsErr  error
 	)
-	if p.tls {
-		tlsConn, err = tls.DialWithDialer(p.dialer, "tcp", fmt.Sprintf("%s:%d", p.host, p.port), &tls.Config{
-			InsecureSkipVerify: true,
-		})
-		if err == nil {
-			conn = tlsConn.NetConn()
-		} else {
-			tlsErr = err
-			conn, err = p.dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", p.host, p.port))
+	addr := net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	if p.proxyErr != nil {
+		err = p.proxyErr
+	} else if p.option.FailoverIPs && p.proxy == nil && net.ParseIP(p.host) == nil {
+		// Resolve up front to try every address in turn, instead of leaving
+		// it to the dialer, so that the one which answered can be reported
+		var addrs []string
+		var ips []net.IP
+		if ips, err = p.option.LookupIP(ctx, p.dialer.Resolver, p.host); err == nil {
+			for _, ip := range ips {
+				addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(p.port)))
+			}
+			var i int
+			i, err = pinger.Failover(ctx, addrs, func(ctx context.Context, addr string) error {
+				conn, tlsConn, tlsErr, err = p.dial(ctx, addr)
+				return err
+			})
+			if err == nil && len(addrs) > 1 && p.option.FailoverIPs {
+				stats.Meta = map[string]fmt.Stringer{"failover": pinger.FailoverMeta(i, len(addrs))}
+			}
 		}
 	} else {
-		conn, err = p.dialer.DialContext(ctx, "tcp", fmt.Sprintf("%s:%d", p.host, p.port))
+		conn, tlsConn, tlsErr, err = p.dial(ctx, addr)
 	}
 	stats.Duration = time.Since(start)
 	if err != nil {
@@ -84,6 +123,23 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	} else {
 		stats.Connected = true
 		stats.Address = conn.RemoteAddr().String()
+		if p.proxy != nil {
+			// The tunnel of an HTTP proxy reports the proxy as its remote end
+			stats.Address = addr
+			if stats.Meta == nil {
+				stats.Meta = make(map[string]fmt.Stringer)
+			}
+			stats.Meta["proxy"] = pinger.StringerFunc(p.option.Proxy.Redacted)
+		}
+		if p.option.Verbose >= pinger.VerboseAddresses {
+			if stats.Meta == nil {
+				stats.Meta = make(map[string]fmt.Stringer)
+			}
+			stats.Meta["local"] = conn.LocalAddr()
+		}
+		if p.tls {
+			p.addOptions(&stats, conn)
+		}
 		if tlsConn != nil && len(tlsConn.ConnectionState().PeerCertificates) > 0 {
 			state := tlsConn.ConnectionState()
 			stats.Extra = meta.Meta{
@@ -97,5 +153,50 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 			stats.Extra = bytes.NewBufferString(fmt.Sprintf("TLS handshake failed, %s", tlsErr))
 		}
 	}
+	if p.option.Verbose >= pinger.VerboseAddresses && len(resolved) > 0 {
+		if stats.Meta == nil {
+			stats.Meta = make(map[string]fmt.Stringer)
+		}
+		stats.Meta["resolved"] = pinger.JoinAddrs(resolved)
+	}
 	return &stats
 }
+
+// dial connects to addr, directly or through the proxy, attempting a TLS
+// handshake first in TLS mode and falling back to a plain connection if it
+// fails.
+func (p *Ping) dial(ctx context.Context, addr string) (conn net.Conn, tlsConn *tls.Conn, tlsErr, err error) {
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return p.option.Dial(ctx, p.dialer, network, addr)
+	}
+	if p.proxy != nil {
+		dial = p.proxy.DialContext
+	}
+	conn, err = dial(ctx, p.option.Network("tcp"), addr)
+	if err != nil || !p.tls {
+		return conn, nil, nil, err
+	}
+	tlsConn = tls.Client(conn, &tls.Config{InsecureSkipVerify: true, ServerName: p.host})
+	if tlsErr = tlsConn.HandshakeContext(ctx); tlsErr == nil {
+		return conn, tlsConn, nil, nil
+	}
+	conn.Close()
+	conn, err = dial(ctx, p.option.Network("tcp"), addr)
+	return conn, nil, tlsErr, err
+}
+
+// addOptions adds what the connection negotiated in its handshake to the
+// metadata, where the platform exposes it.
+func (p *Ping) addOptions(stats *pinger.Stats, conn net.Conn) {
+	info, err := tcpinfo.Read(conn)
+	if err != nil {
+		return
+	}
+	if stats.Meta == nil {
+		stats.Meta = make(map[string]fmt.Stringer)
+	}
+	stats.Meta["mss"] = pinger.StringerFunc(func() string { return strconv.Itoa(info.MSS) })
+	stats.Meta["tcp_options"] = pinger.StringerFunc(info.Options)
+	stats.Meta["wscale"] = pinger.StringerFunc(info.WindowScale)
+	stats.Meta["ecn"] = pinger.StringerFunc(info.ECNState)
+}
@@ -2,9 +2,16 @@ package tcp
 
 import (
 	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
 )
 
 func TestPing(t *testing.T) {
@@ -22,3 +29,91 @@ func TestPing_Failed(t *testing.T) {
 		t.Fatalf("it should be connected refused error")
 	}
 }
+
+func TestPing_CancelHandshake(t *testing.T) {
+	// A server that accepts but never answers the TLS handshake
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	ping := New("127.0.0.1", port, &pinger.Option{Timeout: 5 * time.Second}, true)
+	// Cancel without a deadline, as Stop does, so only cancellation can end the probe
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	ping.Ping(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("cancellation did not interrupt the handshake, took %s", elapsed)
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			srv := pingertest.NewTCPEchoServer(t)
+			return New(srv.Host, srv.Port, &pinger.Option{Timeout: timeout}, false)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout}, false)
+		},
+	})
+}
+
+func TestPing_HTTPProxy(t *testing.T) {
+	// A proxy tunnelling CONNECT requests with the right Basic credentials
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := parseProxyAuth(r); r.Method != http.MethodConnect || !ok || user != "alice" || pass != "secret" {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		target, err := net.Dial("tcp", r.Host)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer target.Close()
+		w.WriteHeader(http.StatusOK)
+		conn, buf, _ := w.(http.Hijacker).Hijack()
+		defer conn.Close()
+		go io.Copy(target, buf)
+		io.Copy(conn, target)
+	}))
+	defer proxy.Close()
+	srv := pingertest.NewTCPEchoServer(t)
+
+	for _, tc := range []struct {
+		user *url.Userinfo
+		ok   bool
+	}{{url.UserPassword("alice", "secret"), true}, {url.UserPassword("alice", "wrong"), false}} {
+		u, _ := url.Parse(proxy.URL)
+		u.User = tc.user
+		ping := New(srv.Host, srv.Port, &pinger.Option{Timeout: 2 * time.Second, Proxy: u}, false)
+		stats := ping.Ping(context.Background())
+		if stats.Connected != tc.ok {
+			t.Fatalf("%v: connected %v, %v", tc.user, stats.Connected, stats.Error)
+		}
+		if tc.ok && stats.Address != srv.Addr() {
+			t.Errorf("address %s, want the target %s", stats.Address, srv.Addr())
+		}
+	}
+}
+
+// parseProxyAuth returns the Basic credentials of the Proxy-Authorization
+// header of r.
+func parseProxyAuth(r *http.Request) (user, pass string, ok bool) {
+	req := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+	return req.BasicAuth()
+}
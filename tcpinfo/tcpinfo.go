@@ -0,0 +1,69 @@
+// Package tcpinfo reads what a TCP connection negotiated in its handshake:
+// ECN, the maximum segment size, window scaling, SACK and timestamps.
+// Middleboxes that strip or rewrite TCP options show up as options missing
+// or differing from what the endpoints support.
+package tcpinfo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUnsupported is returned where the platform does not expose the
+// options of a connection.
+var ErrUnsupported = errors.New("tcp options are not available on this platform")
+
+// Info is what a connection negotiated.
+type Info struct {
+	MSS        int  // Maximum segment size of the sending side
+	WScale     bool // Whether window scaling was negotiated
+	SndWScale  int  // Window scale shift of the peer, if WScale
+	RcvWScale  int  // Window scale shift of the local end, if WScale
+	SACK       bool // Whether selective acknowledgments were permitted
+	Timestamps bool // Whether timestamps were negotiated
+	ECN        bool // Whether ECN was negotiated
+	ECNSeen    bool // Whether a packet with ECN bits was received
+}
+
+// Options lists the negotiated options, such as "sack,ts,wscale,ecn", or
+// "none".
+func (i *Info) Options() string {
+	var opts []string
+	if i.SACK {
+		opts = append(opts, "sack")
+	}
+	if i.Timestamps {
+		opts = append(opts, "ts")
+	}
+	if i.WScale {
+		opts = append(opts, "wscale")
+	}
+	if i.ECN {
+		opts = append(opts, "ecn")
+	}
+	if len(opts) == 0 {
+		return "none"
+	}
+	return strings.Join(opts, ",")
+}
+
+// WindowScale formats the window scale shifts as "<peer>/<local>", or "off".
+func (i *Info) WindowScale() string {
+	if !i.WScale {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%d", i.SndWScale, i.RcvWScale)
+}
+
+// ECNState is "negotiated", "seen" when ECN marks arrived without being
+// negotiated, or "off".
+func (i *Info) ECNState() string {
+	switch {
+	case i.ECN:
+		return "negotiated"
+	case i.ECNSeen:
+		return "seen"
+	}
+	return "off"
+}
@@ -0,0 +1,80 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// Bits of tcpi_options in struct tcp_info.
+const (
+	optTimestamps = 1 << 0
+	optSACK       = 1 << 1
+	optWScale     = 1 << 2
+	optECN        = 1 << 3
+	optECNSeen    = 1 << 4
+)
+
+// Read returns the options conn negotiated, from its TCP_INFO.
+func Read(conn net.Conn) (*Info, error) {
+	sc, ok := conn.(syscall.Conn)
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	raw, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	// The syscall package has no getsockopt into a plain buffer. The kernel
+	// copies as much of tcp_info as fits, so the 32 bytes of an ICMPv6
+	// filter hold the fields read here.
+	var filter *syscall.ICMPv6Filter
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		filter, sockErr = syscall.GetsockoptICMPv6Filter(int(fd), syscall.IPPROTO_TCP, syscall.TCP_INFO)
+	}); err != nil {
+		return nil, err
+	}
+	if sockErr != nil {
+		return nil, sockErr
+	}
+	b := make([]byte, 0, 4*len(filter.Data))
+	for _, word := range filter.Data {
+		b = binary.NativeEndian.AppendUint32(b, word)
+	}
+	return parse(b)
+}
+
+// parse decodes the start of a struct tcp_info:
+//
+//	u8 state, ca_state, retransmits, probes, backoff, options
+//	u8 snd_wscale:4, rcv_wscale:4
+//	u8 delivery_rate_app_limited:1, fastopen_client_fail:2
+//	u32 rto, ato, snd_mss, rcv_mss
+func parse(b []byte) (*Info, error) {
+	if len(b) < 20 {
+		return nil, fmt.Errorf("short tcp_info of %d bytes", len(b))
+	}
+	options := b[5]
+	// Bitfields are allocated from the least significant bit on little
+	// endian platforms and from the most significant on big endian ones
+	snd, rcv := int(b[6]&0x0f), int(b[6]>>4)
+	if binary.NativeEndian.Uint16([]byte{0, 1}) == 1 {
+		snd, rcv = rcv, snd
+	}
+	info := &Info{
+		MSS:        int(binary.NativeEndian.Uint32(b[16:20])),
+		WScale:     options&optWScale != 0,
+		SACK:       options&optSACK != 0,
+		Timestamps: options&optTimestamps != 0,
+		ECN:        options&optECN != 0,
+		ECNSeen:    options&optECNSeen != 0,
+	}
+	if info.WScale {
+		info.SndWScale, info.RcvWScale = snd, rcv
+	}
+	return info, nil
+}
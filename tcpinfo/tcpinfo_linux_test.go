@@ -0,0 +1,53 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"net"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		if conn, err := ln.Accept(); err == nil {
+			defer conn.Close()
+			conn.Read(make([]byte, 1))
+		}
+	}()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	info, err := Read(conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.MSS <= 0 || info.Options() == "" {
+		t.Fatalf("unexpected info %+v", info)
+	}
+}
+
+func TestParse(t *testing.T) {
+	b := make([]byte, 24)
+	b[5] = optSACK | optWScale | optECN
+	b[6] = 7<<4 | 9 // rcv_wscale 7, snd_wscale 9 on little endian
+	b[16] = 0xb4
+	b[17] = 0x05 // 1460 on little endian
+	info, err := parse(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Options() != "sack,wscale,ecn" || info.ECNState() != "negotiated" {
+		t.Fatalf("unexpected options %+v", info)
+	}
+	if info.MSS == 1460 && info.WindowScale() != "9/7" {
+		t.Fatalf("unexpected window scale %s", info.WindowScale())
+	}
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package tcpinfo
+
+import "net"
+
+// Read returns ErrUnsupported: only Linux exposes the options of a
+// connection.
+func Read(conn net.Conn) (*Info, error) {
+	return nil, ErrUnsupported
+}
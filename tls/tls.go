@@ -0,0 +1,167 @@
+// Package tls provides TLS handshake ping functionality for the
+// circle-pinger tool. Each probe connects and performs only the TLS
+// handshake, timing the connect and the handshake separately and reporting
+// the negotiated parameters and the server certificate.
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/proxyauth"
+	"github.com/circle-protocol/circle-pinger/socks5"
+)
+
+// Ensure Ping implements the pinger.Ping interface
+var _ pinger.Ping = (*Ping)(nil)
+
+// New creates a new TLS Ping instance. config may be nil; the server name
+// defaults to host and certificates are verified unless config disables it.
+func New(host string, port int, op *pinger.Option, config *tls.Config) *Ping {
+	// Handle nil option gracefully
+	if op == nil {
+		op = &pinger.Option{}
+	}
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		config.ServerName = host
+	}
+	p := &Ping{
+		host:   host,
+		port:   port,
+		option: op,
+		config: config,
+		dialer: &net.Dialer{
+			Resolver: op.Resolver,
+		},
+	}
+	// The handshake goes through a tunnel of a SOCKS5 or http:// proxy
+	switch {
+	case socks5.IsProxy(op.Proxy):
+		p.proxy, _ = socks5.NewDialer(op.Proxy, p.dialer.DialContext)
+	case op.Proxy != nil && op.Proxy.Scheme == "http":
+		if d, err := proxyauth.New(op.Proxy, op.ProxyAuth, p.dialer.DialContext); err != nil {
+			p.proxyErr = err
+		} else {
+			p.proxy = d
+		}
+	}
+	return p
+}
+
+// Ping is the TLS ping implementation.
+type Ping struct {
+	option   *pinger.Option
+	host     string
+	port     int
+	config   *tls.Config
+	dialer   *net.Dialer
+	proxy    proxyDialer
+	proxyErr error // why the proxy cannot be used, failing every probe
+}
+
+// proxyDialer connects to a target through a proxy.
+type proxyDialer interface {
+	DialContext(ctx context.Context, network, addr string) (net.Conn, error)
+}
+
+// Ping connects and performs a TLS handshake.
+func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
+	timeout := pinger.DefaultTimeout
+	if p.option.Timeout > 0 {
+		timeout = p.option.Timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	stats := &pinger.Stats{
+		Meta: make(map[string]fmt.Stringer),
+	}
+	var dnsStart time.Time
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSStart: func(info httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			stats.DNSDuration = time.Since(dnsStart)
+		},
+	})
+
+	start := time.Now()
+	addr := net.JoinHostPort(p.host, strconv.Itoa(p.port))
+	var conn net.Conn
+	var err error
+	switch {
+	case p.proxyErr != nil:
+		err = p.proxyErr
+	case p.proxy != nil:
+		conn, err = p.proxy.DialContext(ctx, p.option.Network("tcp"), addr)
+	default:
+		conn, err = p.option.Dial(ctx, p.dialer, p.option.Network("tcp"), addr)
+	}
+	if err != nil {
+		stats.Error = err
+		stats.Duration = time.Since(start)
+		if oe, ok := err.(*net.OpError); ok && oe.Addr != nil {
+			stats.Address = oe.Addr.String()
+		}
+		return stats
+	}
+	defer conn.Close()
+	stats.Address = conn.RemoteAddr().String()
+	stats.Meta["connect"] = time.Since(start)
+	if p.proxy != nil {
+		// The tunnel of an HTTP proxy reports the proxy as its remote end
+		stats.Address = addr
+		stats.Meta["proxy"] = pinger.StringerFunc(p.option.Proxy.Redacted)
+	}
+
+	handshakeStart := time.Now()
+	tlsConn := tls.Client(conn, p.config)
+	err = tlsConn.HandshakeContext(ctx)
+	stats.Duration = time.Since(start)
+	stats.Meta["handshake"] = time.Since(handshakeStart)
+	if err != nil {
+		stats.Error = fmt.Errorf("handshake: %w", err)
+		return stats
+	}
+	stats.Connected = true
+
+	state := tlsConn.ConnectionState()
+	stats.Meta["version"] = pinger.StringerFunc(func() string {
+		return strings.ReplaceAll(tls.VersionName(state.Version), " ", "")
+	})
+	stats.Meta["cipher"] = pinger.StringerFunc(func() string { return tls.CipherSuiteName(state.CipherSuite) })
+	if state.NegotiatedProtocol != "" {
+		stats.Meta["alpn"] = pinger.StringerFunc(func() string { return state.NegotiatedProtocol })
+	}
+	if len(state.PeerCertificates) > 0 {
+		addCertificate(stats.Meta, state.PeerCertificates[0])
+	}
+	return stats
+}
+
+// addCertificate adds the metadata of the leaf certificate to meta.
+func addCertificate(meta map[string]fmt.Stringer, cert *x509.Certificate) {
+	meta["subject"] = pinger.StringerFunc(func() string { return strconv.Quote(cert.Subject.CommonName) })
+	meta["issuer"] = pinger.StringerFunc(func() string { return strconv.Quote(cert.Issuer.CommonName) })
+	meta["expires"] = pinger.StringerFunc(func() string { return cert.NotAfter.UTC().Format(time.RFC3339) })
+	meta["days_left"] = pinger.StringerFunc(func() string {
+		return strconv.Itoa(int(time.Until(cert.NotAfter).Hours() / 24))
+	})
+	if len(cert.DNSNames) > 0 {
+		meta["dns_names"] = pinger.StringerFunc(func() string { return strings.Join(cert.DNSNames, ",") })
+	}
+}
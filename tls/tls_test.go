@@ -0,0 +1,55 @@
+package tls
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
+)
+
+func TestPing(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	host, port, _ := net.SplitHostPort(srv.Listener.Addr().String())
+	portNum, _ := strconv.Atoi(port)
+	op := &pinger.Option{Timeout: time.Second}
+
+	// The test certificate is self-signed, so verification must fail
+	stats := New(host, portNum, op, nil).Ping(context.Background())
+	if stats.Connected {
+		t.Fatal("handshake with an untrusted certificate should fail")
+	}
+
+	stats = New(host, portNum, op, &tls.Config{InsecureSkipVerify: true}).Ping(context.Background())
+	if !stats.Connected {
+		t.Fatalf("ping failed, %s", stats.Error)
+	}
+	if got := stats.Meta["version"].String(); got != "TLS1.3" {
+		t.Fatalf("version = %s", got)
+	}
+	if _, ok := stats.Meta["handshake"]; !ok {
+		t.Fatal("handshake duration missing")
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			srv := pingertest.NewTLSServer(t, nil)
+			return New(srv.Host, srv.Port, &pinger.Option{Timeout: timeout}, srv.TLSConfig)
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout}, nil)
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			srv := pingertest.NewTCPBlackhole(t)
+			return New(srv.Host, srv.Port, &pinger.Option{Timeout: timeout}, nil)
+		},
+	})
+}
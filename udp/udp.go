@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/socks5"
 )
 
 // Ensure that our Ping struct implements the pinger.Ping interface
@@ -21,7 +22,7 @@ func New(host string, port int, op *pinger.Option) *Ping {
 		op = &pinger.Option{}
 	}
 
-	return &Ping{
+	p := &Ping{
 		host:   host,
 		port:   port,
 		option: op,
@@ -29,6 +30,11 @@ func New(host string, port int, op *pinger.Option) *Ping {
 			Resolver: op.Resolver, // Use resolver from option
 		},
 	}
+	// A SOCKS5 proxy relays the datagrams with UDP ASSOCIATE
+	if socks5.IsProxy(op.Proxy) {
+		p.proxy, _ = socks5.NewDialer(op.Proxy, p.dialer.DialContext)
+	}
+	return p
 }
 
 // Ping is the UDP ping implementation.
@@ -56,6 +62,7 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 
 	// --- Address Resolution (Manual for separate DNS timing) ---
 	var resolvedIP string
+	var others []net.IP // further addresses to fail over to
 	var dnsErr error
 	startDNS := time.Now()
 
@@ -64,6 +71,9 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 		// It's already an IP address, no DNS lookup needed
 		resolvedIP = ip.String()
 		stats.DNSDuration = 0 // No DNS time
+	} else if p.proxy != nil {
+		// The proxy resolves the host it relays the datagrams to
+		resolvedIP = p.host
 	} else {
 		// It's a hostname, perform DNS lookup using the dialer's resolver or default
 		// Use LookupIPContext for context-aware DNS resolution
@@ -72,8 +82,8 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 			resolver = p.dialer.Resolver
 		}
 
-		ips, lookupErr := resolver.LookupIP(pingCtx, "ip", p.host) // "ip" network type for both IPv4 and IPv6
-		stats.DNSDuration = time.Since(startDNS)                   // Record DNS duration
+		ips, lookupErr := p.option.LookupIP(pingCtx, resolver, p.host) // Both IPv4 and IPv6 unless restricted, within the DNS timeout
+		stats.DNSDuration = time.Since(startDNS)                       // Record DNS duration
 
 		if lookupErr != nil {
 			dnsErr = fmt.Errorf("dns lookup failed: %w", lookupErr)
@@ -90,44 +100,83 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 		}
 		// Use the first resolved IP address (usually sufficient for ping)
 		resolvedIP = ips[0].String()
+		if p.option != nil && p.option.FailoverIPs {
+			others = ips[1:]
+		}
+		if p.verbose() {
+			stats.Meta["resolved"] = pinger.JoinAddrs(ips)
+		}
+	}
+
+	// Probe the resolved address, or with failover every address in turn
+	// until one answers
+	addrs := []string{net.JoinHostPort(resolvedIP, strconv.Itoa(p.port))}
+	for _, ip := range others {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), strconv.Itoa(p.port)))
+	}
+
+	// Send a small UDP packet. The content isn't critical for basic reachability.
+	// A small payload like a single byte or a timestamp is common.
+	sendData := []byte("ping") // Simple payload
+	i, err := pinger.Failover(pingCtx, addrs, func(ctx context.Context, addr string) error {
+		stats.Address = addr // Record the address used
+		return p.exchange(ctx, addr, sendData, stats)
+	})
+
+	// Stop the total timer right after the last attempt finishes
+	stats.Duration = time.Since(startTotal)
+
+	if err == nil {
+		// Success! Received a UDP response packet.
+		stats.Connected = true
+		if len(addrs) > 1 {
+			stats.Meta["failover"] = pinger.FailoverMeta(i, len(addrs))
+		}
+	} else {
+		// Dial, write or read failed (timeout, ICMP error surfaced as socket error, etc.)
+		// The pinger's logStats function will use formatError to make this user-friendly.
+		stats.Error = err
 	}
 
-	// Construct the target address using the resolved IP and port
-	targetAddr := net.JoinHostPort(resolvedIP, strconv.Itoa(p.port))
-	stats.Address = targetAddr // Record the address used
+	if p.proxy != nil {
+		stats.Meta["proxy"] = pinger.StringerFunc(p.option.Proxy.Redacted)
+	}
 
-	// --- UDP Connection and Ping Attempt ---
+	// Add sent byte count to meta
+	stats.Meta["sent"] = pinger.StringerFunc(func() string { return strconv.Itoa(len(sendData)) })
+	return stats
+}
 
+// exchange sends data to addr and waits for a reply until ctx is done.
+func (p *Ping) exchange(ctx context.Context, addr string, data []byte, stats *pinger.Stats) error {
 	// Use the dialer with DialContext for timeout-aware dialing.
 	// For UDP, DialContext doesn't truly establish a connection,
 	// but it binds the local socket and associates it with the remote address.
-	// The Dialer timeout applies to the Dial call itself (e.g., initial setup, immediate errors).
-	conn, dialErr := p.dialer.DialContext(pingCtx, "udp", targetAddr)
-	if dialErr != nil {
-		stats.Error = fmt.Errorf("dial failed: %w", dialErr)
-		stats.Duration = time.Since(startTotal) // Total time includes failed dial
-		// If there was a DNS error before, dialErr will overwrite it. This seems acceptable.
-		return stats
+	dial := p.dialer.DialContext
+	if p.proxy != nil {
+		dial = p.proxy.DialContext
+	}
+	conn, err := dial(ctx, p.option.Network("udp"), addr)
+	if err != nil {
+		return fmt.Errorf("dial failed: %w", err)
 	}
 	defer conn.Close() // Ensure the UDP connection is closed
+	if p.verbose() {
+		stats.Meta["local"] = conn.LocalAddr()
+	}
 
 	// Set a read deadline on the connection using the remaining time from the context.
 	// This is crucial for the Read() call to time out if no response is received.
-	if deadline, ok := pingCtx.Deadline(); ok {
+	if deadline, ok := ctx.Deadline(); ok {
 		conn.SetReadDeadline(deadline)
-	} else {
-		// Fallback, should not be hit with context.WithTimeout above
-		conn.SetReadDeadline(time.Now().Add(timeout))
 	}
 
-	// Send a small UDP packet. The content isn't critical for basic reachability.
-	// A small payload like a single byte or a timestamp is common.
-	sendData := []byte("ping") // Simple payload
-	_, writeErr := conn.Write(sendData)
-	if writeErr != nil {
-		stats.Error = fmt.Errorf("write failed: %w", writeErr)
-		stats.Duration = time.Since(startTotal) // Total time includes write failure
-		return stats
+	// Interrupt the read as soon as the caller cancels, not only at the deadline
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	if _, err := conn.Write(data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
 	}
 
 	// Attempt to read a response from the connection.
@@ -136,35 +185,16 @@ func (p *Ping) Ping(ctx context.Context) *pinger.Stats {
 	// 2. The read deadline is reached (timeout).
 	// 3. An ICMP error (like Port Unreachable) is received by the OS
 	//    and potentially surfaced by the Read call as a socket error.
-	readBuf := make([]byte, 1024)    // Buffer to read into
-	_, readErr := conn.Read(readBuf) // Read from the connection
-
-	// Stop the total timer right after the read attempt finishes
-	stats.Duration = time.Since(startTotal)
-
-	// Check the result of the read operation
-	if readErr == nil {
-		// Success! Received a UDP response packet.
-		stats.Connected = true
-		stats.Error = nil // Clear any prior DNS error if successful response indicates host is fine
-		// stats.Duration already contains the Round Trip Time (send + wait + receive)
-	} else {
-		// Read failed (timeout, ICMP error surfaced as socket error, etc.)
-		stats.Connected = false
-		// Read errors might include context.DeadlineExceeded or network errors
-		stats.Error = fmt.Errorf("read failed: %w", readErr)
-		// The pinger's logStats function will use formatError to make this user-friendly.
+	readBuf := make([]byte, 1024)
+	if _, err := conn.Read(readBuf); err != nil {
+		return fmt.Errorf("read failed: %w", err)
 	}
+	return nil
+}
 
-	// Add sent/received byte count to meta if desired
-	stats.Meta["sent"] = pinger.StringerFunc(func() string { return strconv.Itoa(len(sendData)) })
-	// Note: Received byte count is tricky if readBuf wasn't fully filled or if errors occurred.
-	// For simplicity, we can omit the received count or only include on success.
-	// if readErr == nil {
-	//    stats.Meta["recv"] = pinger.StringerFunc(func() string { return strconv.Itoa(n) }) // 'n' from conn.Read(readBuf[:n])
-	// }
-
-	return stats
+// verbose reports whether the resolved and source addresses are requested.
+func (p *Ping) verbose() bool {
+	return p.option != nil && p.option.Verbose >= pinger.VerboseAddresses
 }
 
 // Ping struct definition
@@ -172,5 +202,6 @@ type Ping struct {
 	option *pinger.Option
 	host   string
 	port   int
-	dialer *net.Dialer // Dialer to potentially use custom resolver
+	dialer *net.Dialer    // Dialer to potentially use custom resolver
+	proxy  *socks5.Dialer // Relays the datagrams when a SOCKS5 proxy is set
 }
@@ -2,10 +2,12 @@ package udp
 
 import (
 	"context"
+	"net"
 	"testing"
 	"time"
 
 	"github.com/circle-protocol/circle-pinger/pinger"
+	"github.com/circle-protocol/circle-pinger/pinger/pingertest"
 )
 
 func TestPing(t *testing.T) {
@@ -53,3 +55,40 @@ func TestPing_Failed(t *testing.T) {
 
 	t.Logf("UDP ping correctly failed with error: %v", stats.Error)
 }
+
+func TestPing_Cancel(t *testing.T) {
+	// A server that never answers keeps the read waiting
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	port := conn.LocalAddr().(*net.UDPAddr).Port
+	ping := New("127.0.0.1", port, &pinger.Option{Timeout: 5 * time.Second})
+	// Cancel without a deadline, as Stop does, so only cancellation can end the probe
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	stats := ping.Ping(ctx)
+	if stats.Connected || time.Since(start) > time.Second {
+		t.Fatalf("cancellation did not interrupt the read: %v after %s", stats.Error, time.Since(start))
+	}
+}
+
+func TestPing_Conformance(t *testing.T) {
+	pingertest.Run(t, pingertest.Target{
+		Up: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			srv := pingertest.NewUDPEchoServer(t)
+			return New(srv.Host, srv.Port, &pinger.Option{Timeout: timeout})
+		},
+		Down: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			return New("127.0.0.1", pingertest.ClosedPort(t), &pinger.Option{Timeout: timeout})
+		},
+		Hang: func(t *testing.T, timeout time.Duration) pinger.Ping {
+			srv := pingertest.NewUDPBlackhole(t)
+			return New(srv.Host, srv.Port, &pinger.Option{Timeout: timeout})
+		},
+	})
+}
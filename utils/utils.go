@@ -2,6 +2,7 @@ package utils
 
 import (
 	"fmt"
+	"math"
 	"net"
 	"net/url"
 	"strconv"
@@ -33,6 +34,9 @@ func FormatIP(IP string) (string, error) {
 // ParseDuration parse the t as time.Duration, it will parse t as mills when missing unit.
 func ParseDuration(t string) (time.Duration, error) {
 	if timeout, err := strconv.ParseInt(t, 10, 64); err == nil {
+		if timeout > math.MaxInt64/int64(time.Millisecond) || timeout < math.MinInt64/int64(time.Millisecond) {
+			return 0, fmt.Errorf("time: invalid duration %q, out of range", t)
+		}
 		return time.Duration(timeout) * time.Millisecond, nil
 	}
 	return time.ParseDuration(t)
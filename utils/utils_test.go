@@ -1,6 +1,10 @@
 package utils
+
 import (
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -38,4 +42,71 @@ func TestFormatIP(t *testing.T) {
 			So(rc, ShouldEqual, "[2002:ac1f:91c5:1::bd59]")
 		})
 	})
-}
\ No newline at end of file
+}
+func FuzzParseAddress(f *testing.F) {
+	for _, s := range []string{"google.com", "google.com:443", "https://google.com/path?q=1", "[::1]:80", "udp://8.8.8.8:53", "", "://", "%zz"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, addr string) {
+		u, err := ParseAddress(addr)
+		if err != nil {
+			return
+		}
+		// Addresses without a scheme are tcp targets
+		if !strings.Contains(addr, "://") && u.Scheme != "tcp" {
+			t.Fatalf("ParseAddress(%q) scheme = %q, want tcp", addr, u.Scheme)
+		}
+	})
+}
+
+func FuzzParseDuration(f *testing.F) {
+	for _, s := range []string{"1s", "500", "1.5m", "-3", "9223372036854775807", "1h2m3s4ms", ""} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		d, err := ParseDuration(s)
+		if err != nil {
+			return
+		}
+		// A bare number is milliseconds, and never silently overflows
+		if n, err := strconv.ParseInt(s, 10, 64); err == nil && d/time.Millisecond != time.Duration(n) {
+			t.Fatalf("ParseDuration(%q) = %s, want %d milliseconds", s, d, n)
+		}
+	})
+}
+
+func FuzzFormatIP(f *testing.F) {
+	for _, s := range []string{"192.168.0.1", "[2002:ac1f:91c5:1::bd59]", " 10.0.0.1 ", "::ffff:1.2.3.4", "fe80::1%eth0", "host"} {
+		f.Add(s)
+	}
+	f.Fuzz(func(t *testing.T, s string) {
+		ip, err := FormatIP(s)
+		if err != nil {
+			return
+		}
+		// The result is a stable, valid IP literal
+		if again, err := FormatIP(ip); err != nil || again != ip {
+			t.Fatalf("FormatIP(%q) = %q is not stable: %q, %v", s, ip, again, err)
+		}
+	})
+}
+
+func TestParseSize(t *testing.T) {
+
+	Convey("Size", t, func() {
+		Convey("with units", func() {
+			for text, want := range map[string]int64{"512": 512, "64K": 64 << 10, "10MB": 10 << 20, "1g": 1 << 30, "7 B": 7} {
+				n, err := ParseSize(text)
+				So(err, ShouldBeNil)
+				So(n, ShouldEqual, want)
+			}
+		})
+
+		Convey("invalid", func() {
+			_, err := ParseSize("10XB")
+			So(err, ShouldNotBeNil)
+			_, err = ParseSize("-1")
+			So(err, ShouldNotBeNil)
+		})
+	})
+}